@@ -24,6 +24,7 @@ var (
 	Hostname        string
 	Network         string
 	NetworkArgs     []string
+	PublishPorts    []string
 	DNS             string
 	Security        []string
 	CgroupsPath     string
@@ -191,7 +192,7 @@ var actionNetworkFlag = cmdline.Flag{
 	Value:        &Network,
 	DefaultValue: "bridge",
 	Name:         "network",
-	Usage:        "specify desired network type separated by commas, each network will bring up a dedicated interface inside container",
+	Usage:        "specify desired network type separated by commas, each network will bring up a dedicated interface inside container (use 'slirp' for an unprivileged user-mode network)",
 	EnvKeys:      []string{"NETWORK"},
 	Tag:          "<name>",
 	ExcludedOS:   []string{cmdline.Darwin},
@@ -203,12 +204,24 @@ var actionNetworkArgsFlag = cmdline.Flag{
 	Value:        &NetworkArgs,
 	DefaultValue: []string{},
 	Name:         "network-args",
-	Usage:        "specify network arguments to pass to CNI plugins",
+	Usage:        "specify network arguments to pass to CNI plugins (only portmap is supported by the slirp network)",
 	EnvKeys:      []string{"NETWORK_ARGS"},
 	Tag:          "<args>",
 	ExcludedOS:   []string{cmdline.Darwin},
 }
 
+// --publish
+var actionPublishFlag = cmdline.Flag{
+	ID:           "actionPublishFlag",
+	Value:        &PublishPorts,
+	DefaultValue: []string{},
+	Name:         "publish",
+	Usage:        "publish a container port on the host, e.g. --publish 8080:80/tcp (implies --net, unprivileged users get the slirp network)",
+	EnvKeys:      []string{"PUBLISH"},
+	Tag:          "<hostPort:containerPort[/protocol]>",
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
 // --dns
 var actionDNSFlag = cmdline.Flag{
 	ID:           "actionDnsFlag",
@@ -638,8 +651,10 @@ func init() {
 	cmdManager.RegisterFlagForCmd(&actionNoPrivsFlag, actionsInstanceCmd...)
 	cmdManager.RegisterFlagForCmd(&actionNvidiaFlag, actionsInstanceCmd...)
 	cmdManager.RegisterFlagForCmd(&actionOverlayFlag, actionsInstanceCmd...)
+	cmdManager.RegisterFlagForCmd(&actionPublishFlag, actionsInstanceCmd...)
 	cmdManager.RegisterFlagForCmd(&commonPromptForPassphraseFlag, actionsInstanceCmd...)
 	cmdManager.RegisterFlagForCmd(&commonPEMFlag, actionsInstanceCmd...)
+	cmdManager.RegisterFlagForCmd(&commonPGPFlag, actionsInstanceCmd...)
 	cmdManager.RegisterFlagForCmd(&actionPidNamespaceFlag, actionsInstanceCmd...)
 	cmdManager.RegisterFlagForCmd(&actionPwdFlag, actionsCmd...)
 	cmdManager.RegisterFlagForCmd(&actionScratchFlag, actionsInstanceCmd...)
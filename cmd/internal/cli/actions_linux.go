@@ -18,6 +18,7 @@ import (
 
 	"github.com/opencontainers/runtime-tools/generate"
 	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/app/singularity"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/instance"
 	"github.com/sylabs/singularity/internal/pkg/plugin"
@@ -30,13 +31,32 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/util/user"
 	imgutil "github.com/sylabs/singularity/pkg/image"
 	"github.com/sylabs/singularity/pkg/image/unpacker"
+	"github.com/sylabs/singularity/pkg/network"
 	"github.com/sylabs/singularity/pkg/runtime/engine/config"
 	singularityConfig "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
 	"github.com/sylabs/singularity/pkg/util/crypt"
 	"github.com/sylabs/singularity/pkg/util/namespaces"
 	"github.com/sylabs/singularity/pkg/util/nvidia"
+	"golang.org/x/sys/unix"
 )
 
+// setOnCommandLine returns true if the flag name was set on the command
+// line, a flag set by its environment variable is marked as changed too.
+func setOnCommandLine(cmd *cobra.Command, name string) bool {
+	f := cmd.Flag(name)
+	if f == nil || !f.Changed {
+		return false
+	}
+	// the environment variable is ignored when the flag is set on
+	// the command line, so the flag value differs unless both match
+	for _, key := range f.Annotations["envkey"] {
+		if v, ok := os.LookupEnv(envPrefix + key); ok && strings.TrimSpace(v) == f.Value.String() {
+			return false
+		}
+	}
+	return true
+}
+
 // EnsureRootPriv ensures that a command is executed with root privileges.
 func EnsureRootPriv(cmd *cobra.Command, args []string) {
 	if os.Geteuid() != 0 {
@@ -44,7 +64,43 @@ func EnsureRootPriv(cmd *cobra.Command, args []string) {
 	}
 }
 
-func convertImage(filename string, unsquashfsPath string) (string, error) {
+// statfs is the function pointing to unix.Statfs and
+// is replaced by tests.
+var statfs = unix.Statfs
+
+// isMemoryFs returns whether the directory dir is on a memory
+// backed file system.
+func isMemoryFs(dir string) bool {
+	st := &unix.Statfs_t{}
+	if err := statfs(dir, st); err != nil {
+		return false
+	}
+	// the type is a signed integer on some architectures
+	fsType := uint32(st.Type)
+	return fsType == unix.TMPFS_MAGIC || fsType == unix.RAMFS_MAGIC
+}
+
+// memoryTmpDir returns the first directory of dirs on a memory
+// backed file system, so decrypted data is never written to disk.
+func memoryTmpDir(dirs ...string) (string, error) {
+	var tried []string
+	for _, d := range dirs {
+		if d == "" {
+			continue
+		}
+		if isMemoryFs(d) {
+			return d, nil
+		}
+		tried = append(tried, d)
+	}
+	return "", fmt.Errorf("none of %s is a tmpfs directory to decrypt the root filesystem in memory, set SINGULARITY_TMPDIR to a tmpfs directory", strings.Join(tried, ", "))
+}
+
+// convertImage extracts the root filesystem of the image filename into
+// a temporary sandbox, key is used to decrypt a root filesystem encrypted
+// in user space and is ignored for a squashfs root filesystem. Encrypted
+// root filesystems are only extracted to a memory backed file system.
+func convertImage(filename string, unsquashfsPath string, key []byte) (string, error) {
 	img, err := imgutil.Init(filename, false)
 	if err != nil {
 		return "", fmt.Errorf("could not open image %s: %s", filename, err)
@@ -56,8 +112,11 @@ func convertImage(filename string, unsquashfsPath string) (string, error) {
 	}
 
 	// squashfs only
-	if img.Partitions[0].Type != imgutil.SQUASHFS {
+	partType := img.Partitions[0].Type
+	if partType != imgutil.SQUASHFS && partType != imgutil.ENCRYPTSQUASHFS {
 		return "", fmt.Errorf("not a squashfs root filesystem")
+	} else if partType == imgutil.ENCRYPTSQUASHFS && key == nil {
+		return "", fmt.Errorf("encrypted root filesystem could only be extracted with a PGP key (--pgp-path)")
 	}
 
 	// create a reader for rootfs partition
@@ -65,6 +124,13 @@ func convertImage(filename string, unsquashfsPath string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("could not extract root filesystem: %s", err)
 	}
+
+	if partType == imgutil.ENCRYPTSQUASHFS {
+		reader, err = crypt.NewReader(reader, key)
+		if err != nil {
+			return "", fmt.Errorf("could not decrypt root filesystem: %s", err)
+		}
+	}
 	s := unpacker.NewSquashfs()
 	if !s.HasUnsquashfs() && unsquashfsPath != "" {
 		s.UnsquashfsPath = unsquashfsPath
@@ -79,6 +145,13 @@ func convertImage(filename string, unsquashfsPath string) (string, error) {
 		}
 	}
 
+	if partType == imgutil.ENCRYPTSQUASHFS {
+		tmpdir, err = memoryTmpDir(tmpdir, os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm")
+		if err != nil {
+			return "", err
+		}
+	}
+
 	// create temporary sandbox
 	dir, err := ioutil.TempDir(tmpdir, "rootfs-")
 	if err != nil {
@@ -192,6 +265,46 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 		engineConfig.SetImage(abspath)
 	}
 
+	// requesting a network created with 'network create' on the
+	// command line implies a network namespace
+	networkChanged := cobraCmd.Flag("network").Changed
+	createdNetwork := false
+	if setOnCommandLine(cobraCmd, "network") {
+		for _, n := range strings.Split(Network, ",") {
+			if singularity.IsCreatedNetwork(strings.TrimSpace(n)) {
+				createdNetwork = true
+				NetNamespace = true
+				break
+			}
+		}
+	}
+
+	// published ports are forwarded with the portmap argument of the
+	// first network, unprivileged users can only publish ports with
+	// the slirp network
+	if len(PublishPorts) > 0 {
+		if Network == "none" {
+			sylog.Fatalf("Ports can't be published with the 'none' network")
+		}
+		NetNamespace = true
+		if !networkChanged && uid != 0 && !IsFakeroot {
+			Network = network.SlirpNetwork
+		}
+		for _, p := range PublishPorts {
+			pm, err := network.ParsePublish(p)
+			if err != nil {
+				sylog.Fatalf("Invalid published port: %s", err)
+			}
+			NetworkArgs = append(NetworkArgs, fmt.Sprintf("portmap=%d:%d/%s", pm.HostPort, pm.ContainerPort, pm.Protocol))
+		}
+	}
+
+	// the slirp network is configured by slirp4netns from the
+	// container user namespace for unprivileged users
+	if NetNamespace && Network == network.SlirpNetwork && uid != 0 && !IsFakeroot {
+		UserNamespace = true
+	}
+
 	useSuid := true
 
 	// singularity was compiled with '--without-suid' option
@@ -248,6 +361,9 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 		engineConfig.AppendFilesPath(nvidia.IpcsPath(userPath)...)
 	}
 
+	// key used to decrypt a root filesystem encrypted in user space
+	var userspaceKey []byte
+
 	// early check for key material before we start engine so we can fail fast if missing
 	// we do not need this check when joining a running instance, just for starting a container
 	if !engineConfig.GetInstanceJoin() {
@@ -270,12 +386,24 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 				sylog.Fatalf("While handling encryption material: %v", err)
 			}
 
+			if keyInfo.Format == crypt.PGP {
+				if err := askPGPKeyPassphrase(&keyInfo); err != nil {
+					sylog.Fatalf("While reading key passphrase: %s", err)
+				}
+			}
+
 			plaintextKey, err := crypt.PlaintextKey(keyInfo, engineConfig.GetImage())
 			if err != nil {
 				sylog.Fatalf("Cannot retrieve key from image %s: %+v", engineConfig.GetImage(), err)
 			}
 
-			engineConfig.SetEncryptionKey(plaintextKey)
+			if keyInfo.Format == crypt.PGP {
+				// root filesystem is decrypted in user space
+				// while being converted to a sandbox below
+				userspaceKey = plaintextKey
+			} else {
+				engineConfig.SetEncryptionKey(plaintextKey)
+			}
 		}
 
 		// don't defer this call as in all cases it won't be
@@ -430,8 +558,12 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 	}
 
 	if NetNamespace {
-		if IsFakeroot && Network != "none" {
-			engineConfig.SetNetwork("fakeroot")
+		if IsFakeroot && Network != "none" && Network != network.SlirpNetwork {
+			// a network created with 'network create' is used
+			// as is, the fakeroot network is used otherwise
+			if !createdNetwork {
+				engineConfig.SetNetwork("fakeroot")
+			}
 
 			// unprivileged installation could not use fakeroot
 			// network because it requires a setuid installation
@@ -501,17 +633,30 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 	generator.AddProcessEnv("SINGULARITY_APPNAME", AppName)
 
 	// convert image file to sandbox if we are using user
-	// namespace or if we are currently running inside a
-	// user namespace
-	if (UserNamespace || insideUserNs) && fs.IsFile(image) {
+	// namespace, if we are currently running inside a user
+	// namespace or if the root filesystem is encrypted in
+	// user space
+	if ((UserNamespace || insideUserNs) && fs.IsFile(image)) || userspaceKey != nil {
+		// changes would be lost with the temporary sandbox
+		// instead of being written to the SIF overlay partition
+		if IsWritable && userspaceKey != nil {
+			sylog.Fatalf("--writable is not supported with encrypted image %s, its root filesystem is decrypted to a temporary sandbox", image)
+		} else if IsWritable {
+			sylog.Fatalf("--writable with image file %s is not supported with user namespace", image)
+		}
 		unsquashfsPath := ""
 		if engineConfig.File.MksquashfsPath != "" {
 			d := filepath.Dir(engineConfig.File.MksquashfsPath)
 			unsquashfsPath = filepath.Join(d, "unsquashfs")
 		}
-		sylog.Verbosef("User namespace requested, convert image %s to sandbox", image)
-		sylog.Infof("Convert SIF file to sandbox...")
-		dir, err := convertImage(image, unsquashfsPath)
+		if userspaceKey != nil {
+			sylog.Verbosef("Encrypted root filesystem, decrypt image %s to sandbox", image)
+			sylog.Infof("Decrypt SIF file to sandbox...")
+		} else {
+			sylog.Verbosef("User namespace requested, convert image %s to sandbox", image)
+			sylog.Infof("Convert SIF file to sandbox...")
+		}
+		dir, err := convertImage(image, unsquashfsPath, userspaceKey)
 		if err != nil {
 			sylog.Fatalf("while extracting %s: %s", image, err)
 		}
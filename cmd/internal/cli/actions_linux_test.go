@@ -0,0 +1,77 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// setFsType sets the file system type of st, the type of the
+// field depends on the architecture.
+func setFsType(st *unix.Statfs_t, fsType uint32) {
+	v := reflect.ValueOf(&st.Type).Elem()
+	switch v.Kind() {
+	case reflect.Int32:
+		v.SetInt(int64(int32(fsType)))
+	case reflect.Int64:
+		v.SetInt(int64(fsType))
+	default:
+		v.SetUint(uint64(fsType))
+	}
+}
+
+func TestMemoryTmpDir(t *testing.T) {
+	defer func() {
+		statfs = unix.Statfs
+	}()
+
+	fsTypes := map[string]uint32{
+		"/tmp":        0xef53,
+		"/run/user/1": unix.TMPFS_MAGIC,
+		"/dev/shm":    unix.TMPFS_MAGIC,
+		"/ramfs":      unix.RAMFS_MAGIC,
+	}
+	statfs = func(path string, st *unix.Statfs_t) error {
+		fsType, ok := fsTypes[path]
+		if !ok {
+			return unix.ENOENT
+		}
+		setFsType(st, fsType)
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		dirs    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "TmpDirOnDisk", dirs: []string{"/tmp", "/run/user/1", "/dev/shm"}, want: "/run/user/1"},
+		{name: "NoRuntimeDir", dirs: []string{"/tmp", "", "/dev/shm"}, want: "/dev/shm"},
+		{name: "TmpDirOnRamfs", dirs: []string{"/ramfs", "/dev/shm"}, want: "/ramfs"},
+		{name: "NoMemoryFs", dirs: []string{"/tmp", "/missing"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := memoryTmpDir(tt.dirs...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if dir != tt.want {
+				t.Errorf("got %s, want %s", dir, tt.want)
+			}
+		})
+	}
+}
@@ -22,19 +22,27 @@ import (
 )
 
 var buildArgs struct {
-	sections   []string
-	arch       string
-	builderURL string
-	libraryURL string
-	detached   bool
-	encrypt    bool
-	fakeroot   bool
-	isJSON     bool
-	noCleanUp  bool
-	noTest     bool
-	remote     bool
-	sandbox    bool
-	update     bool
+	sections     []string
+	cacheMounts  []string
+	recipients   []string
+	secrets      []string
+	arch         string
+	builderURL   string
+	libraryURL   string
+	stage        string
+	keepStages   string
+	jobs         int
+	debugShell   bool
+	detached     bool
+	encrypt      bool
+	fakeroot     bool
+	isJSON       bool
+	noCleanUp    bool
+	noTest       bool
+	remote       bool
+	reproducible bool
+	sandbox      bool
+	update       bool
 }
 
 // -s|--sandbox
@@ -54,7 +62,7 @@ var buildSectionFlag = cmdline.Flag{
 	Value:        &buildArgs.sections,
 	DefaultValue: []string{"all"},
 	Name:         "section",
-	Usage:        "only run specific section(s) of deffile (setup, post, files, environment, test, labels, none)",
+	Usage:        "only run specific section(s) of deffile (setup, condaenv, post, files, environment, test, labels, none)",
 	EnvKeys:      []string{"SECTION"},
 }
 
@@ -107,7 +115,7 @@ var buildArchFlag = cmdline.Flag{
 	Value:        &buildArgs.arch,
 	DefaultValue: runtime.GOARCH,
 	Name:         "arch",
-	Usage:        "architecture for remote build",
+	Usage:        "architecture of the image to build, bootstrap images are pulled for it and scripts of foreign images run with QEMU user emulation, a comma separated list builds a multi-architecture SIF image",
 	EnvKeys:      []string{"BUILD_ARCH"},
 }
 
@@ -183,22 +191,107 @@ var buildEncryptFlag = cmdline.Flag{
 	Usage:        "build an image with an encrypted file system",
 }
 
+// --jobs
+var buildJobsFlag = cmdline.Flag{
+	ID:           "buildJobsFlag",
+	Value:        &buildArgs.jobs,
+	DefaultValue: runtime.NumCPU(),
+	Name:         "jobs",
+	Usage:        "maximum number of independent stages of a multi-stage definition built concurrently",
+	EnvKeys:      []string{"BUILD_JOBS"},
+}
+
+// --stage
+var buildStageFlag = cmdline.Flag{
+	ID:           "buildStageFlag",
+	Value:        &buildArgs.stage,
+	DefaultValue: "",
+	Name:         "stage",
+	Usage:        "build a multi-stage definition up to the named stage and use it as the output image",
+	EnvKeys:      []string{"BUILD_STAGE"},
+}
+
+// --keep-stages
+var buildKeepStagesFlag = cmdline.Flag{
+	ID:           "buildKeepStagesFlag",
+	Value:        &buildArgs.keepStages,
+	DefaultValue: "",
+	Name:         "keep-stages",
+	Usage:        "keep the stages built as sandboxes in this directory, even if the build fails",
+	EnvKeys:      []string{"KEEP_STAGES"},
+}
+
+// --debug-shell
+var buildDebugShellFlag = cmdline.Flag{
+	ID:           "buildDebugShellFlag",
+	Value:        &buildArgs.debugShell,
+	DefaultValue: false,
+	Name:         "debug-shell",
+	Usage:        "start an interactive shell in the container when %post or %test fails, the build resumes if the shell exits with status 0",
+}
+
+// --secret
+var buildSecretFlag = cmdline.Flag{
+	ID:           "buildSecretFlag",
+	Value:        &buildArgs.secrets,
+	DefaultValue: []string{},
+	Name:         "secret",
+	Usage:        "expose a secret file to %setup and %post at /run/secrets/<id> without storing it in the image, as id=<id>,src=<path> (can be specified multiple times)",
+}
+
+// --cache-mount
+var buildCacheMountFlag = cmdline.Flag{
+	ID:           "buildCacheMountFlag",
+	Value:        &buildArgs.cacheMounts,
+	DefaultValue: []string{},
+	Name:         "cache-mount",
+	Usage:        "persist the content of a container directory like /var/cache/yum across builds in the build cache, it is only mounted during %post and never stored in the image (can be specified multiple times)",
+	EnvKeys:      []string{"BUILD_CACHE_MOUNT"},
+}
+
+// --reproducible
+var buildReproducibleFlag = cmdline.Flag{
+	ID:           "buildReproducibleFlag",
+	Value:        &buildArgs.reproducible,
+	DefaultValue: false,
+	Name:         "reproducible",
+	Usage:        "build a reproducible image, all times are set to SOURCE_DATE_EPOCH (or 0 if unset)",
+	EnvKeys:      []string{"REPRODUCIBLE"},
+}
+
+// --recipient
+var buildRecipientFlag = cmdline.Flag{
+	ID:           "buildRecipientFlag",
+	Value:        &buildArgs.recipients,
+	DefaultValue: []string{},
+	Name:         "recipient",
+	Usage:        "path to an additional public key, in the same format as --pem-path or --pgp-path, the image is encrypted for (can be specified multiple times)",
+}
+
 func init() {
 	cmdManager.RegisterCmd(buildCmd)
 
 	cmdManager.RegisterFlagForCmd(&buildArchFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildBuilderFlag, buildCmd)
+	cmdManager.RegisterFlagForCmd(&buildCacheMountFlag, buildCmd)
+	cmdManager.RegisterFlagForCmd(&buildDebugShellFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildDetachedFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildDisableCacheFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildEncryptFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildFakerootFlag, buildCmd)
+	cmdManager.RegisterFlagForCmd(&buildJobsFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildJSONFlag, buildCmd)
+	cmdManager.RegisterFlagForCmd(&buildKeepStagesFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildLibraryFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildNoCleanupFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildNoTestFlag, buildCmd)
+	cmdManager.RegisterFlagForCmd(&buildRecipientFlag, buildCmd)
+	cmdManager.RegisterFlagForCmd(&buildSecretFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildRemoteFlag, buildCmd)
+	cmdManager.RegisterFlagForCmd(&buildReproducibleFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildSandboxFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildSectionFlag, buildCmd)
+	cmdManager.RegisterFlagForCmd(&buildStageFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&buildUpdateFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&commonForceFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&commonNoHTTPSFlag, buildCmd)
@@ -210,6 +303,7 @@ func init() {
 
 	cmdManager.RegisterFlagForCmd(&commonPromptForPassphraseFlag, buildCmd)
 	cmdManager.RegisterFlagForCmd(&commonPEMFlag, buildCmd)
+	cmdManager.RegisterFlagForCmd(&commonPGPFlag, buildCmd)
 }
 
 // buildCmd represents the build command.
@@ -275,6 +369,22 @@ func definitionFromSpec(spec string) (types.Definition, error) {
 		return def, nil
 	}
 
+	// Try spec as definition in JSON or YAML form, the raw
+	// definition of the last stage holds every stage
+	if parser.IsStructuredDefinition(spec) {
+		f, err := os.Open(spec)
+		if err != nil {
+			return types.Definition{}, err
+		}
+		defer f.Close()
+
+		defs, err := parser.ParseStructured(f)
+		if err != nil {
+			return types.Definition{}, err
+		}
+		return defs[len(defs)-1], nil
+	}
+
 	// Try spec as local file
 	var isValid bool
 	isValid, err = parser.IsValidDefinition(spec)
@@ -11,10 +11,15 @@ import (
 	"io/ioutil"
 	"os"
 	osExec "os/exec"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/app/singularity"
 	"github.com/sylabs/singularity/internal/pkg/build"
 	"github.com/sylabs/singularity/internal/pkg/build/remotebuilder"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
@@ -30,6 +35,7 @@ import (
 	"github.com/sylabs/singularity/pkg/image"
 	"github.com/sylabs/singularity/pkg/runtime/engine/config"
 	"github.com/sylabs/singularity/pkg/util/crypt"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 func fakerootExec(cmdArgs []string) {
@@ -88,10 +94,6 @@ func fakerootExec(cmdArgs []string) {
 func runBuild(cmd *cobra.Command, args []string) {
 	ctx := context.TODO()
 
-	if buildArgs.arch != runtime.GOARCH && !buildArgs.remote {
-		sylog.Fatalf("Requested architecture (%s) does not match host (%s). Cannot build locally.", buildArgs.arch, runtime.GOARCH)
-	}
-
 	dest := args[0]
 	spec := args[1]
 
@@ -113,6 +115,24 @@ func runBuildRemote(ctx context.Context, cmd *cobra.Command, dst, spec string) {
 	if buildArgs.encrypt {
 		sylog.Fatalf("Building encrypted container with the remote builder is not currently supported.")
 	}
+	if buildArgs.reproducible {
+		sylog.Fatalf("Building reproducible container with the remote builder is not currently supported.")
+	}
+	if buildArgs.stage != "" || buildArgs.keepStages != "" {
+		sylog.Fatalf("Building stages with the remote builder is not currently supported.")
+	}
+	if buildArgs.debugShell {
+		sylog.Fatalf("Debug shell with the remote builder is not currently supported.")
+	}
+	if len(buildArgs.secrets) > 0 {
+		sylog.Fatalf("Build secrets with the remote builder are not currently supported.")
+	}
+	if len(buildArgs.cacheMounts) > 0 {
+		sylog.Fatalf("Cache mounts with the remote builder are not currently supported.")
+	}
+	if strings.Contains(buildArgs.arch, ",") {
+		sylog.Fatalf("Building multi-architecture container with the remote builder is not currently supported.")
+	}
 
 	handleRemoteBuildFlags(cmd)
 
@@ -187,34 +207,94 @@ func runBuildRemote(ctx context.Context, cmd *cobra.Command, dst, spec string) {
 
 func runBuildLocal(ctx context.Context, cmd *cobra.Command, dst, spec string) {
 	var keyInfo *crypt.KeyInfo
-	if buildArgs.encrypt || promptForPassphrase || cmd.Flags().Lookup("pem-path").Changed {
-		if os.Getuid() != 0 {
-			sylog.Fatalf("You must be root to build an encrypted container")
-		}
-
+	if buildArgs.encrypt || promptForPassphrase || cmd.Flags().Lookup("pem-path").Changed || cmd.Flags().Lookup("pgp-path").Changed {
 		k, err := getEncryptionMaterial(cmd)
 		if err != nil {
 			sylog.Fatalf("While handling encryption material: %v", err)
 		}
+
+		// PGP encrypted containers are encrypted in user space
+		// and don't require cryptsetup
+		if k.Format != crypt.PGP && os.Getuid() != 0 {
+			sylog.Fatalf("You must be root to build an encrypted container, use --pgp-path to build an encrypted container without privileges")
+		}
+
+		if len(buildArgs.recipients) > 0 {
+			if k.Format != crypt.PEM && k.Format != crypt.PGP {
+				sylog.Fatalf("--recipient requires --pem-path or --pgp-path")
+			}
+			k.Recipients = buildArgs.recipients
+		}
+
 		keyInfo = &k
 	} else {
+		if len(buildArgs.recipients) > 0 {
+			sylog.Fatalf("--recipient requires --pem-path or --pgp-path")
+		}
+
 		_, passphraseEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PASSPHRASE")
 		_, pemPathEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PEM_PATH")
-		if passphraseEnvOK || pemPathEnvOK {
+		_, pgpPathEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PGP_PATH")
+		if passphraseEnvOK || pemPathEnvOK || pgpPathEnvOK {
 			sylog.Warningf("Encryption related env vars found, but --encrypt was not specified. NOT encrypting container.")
 		}
 	}
 
+	var epoch *time.Time
+	if buildArgs.reproducible {
+		// encryption keys are random
+		if keyInfo != nil {
+			sylog.Fatalf("Building reproducible encrypted container is not supported")
+		}
+		t, err := sourceDateEpoch()
+		if err != nil {
+			sylog.Fatalf("While building reproducible container: %v", err)
+		}
+		epoch = &t
+	}
+
+	jobs := buildArgs.jobs
+	if buildArgs.debugShell {
+		if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+			sylog.Fatalf("--debug-shell requires an interactive terminal")
+		}
+		// the shell needs the terminal, stages are built one after the other
+		jobs = 1
+	}
+
+	secrets, err := buildSecrets()
+	if err != nil {
+		sylog.Fatalf("While preparing build secrets: %v", err)
+	}
+
 	imgCache := getCacheHandle(cache.Config{})
 	if imgCache == nil {
 		sylog.Fatalf("Failed to create an image cache handle")
 	}
 
+	archs, err := buildArchs()
+	if err != nil {
+		sylog.Fatalf("%s", err)
+	}
+	if len(archs) > 1 {
+		if buildArgs.sandbox || buildArgs.update {
+			sylog.Fatalf("Multi-architecture builds require the SIF format")
+		}
+		if keyInfo != nil {
+			sylog.Fatalf("Building multi-architecture encrypted container is not supported")
+		}
+	}
+
+	cacheMounts, err := buildCacheMounts(imgCache)
+	if err != nil {
+		sylog.Fatalf("While preparing cache mounts: %v", err)
+	}
+
 	if syscall.Getuid() != 0 && !buildArgs.fakeroot && fs.IsFile(spec) && !isImage(spec) {
 		sylog.Fatalf("You must be the root user, however you can use --remote or --fakeroot to build from a Singularity recipe file")
 	}
 
-	err := checkSections()
+	err = checkSections()
 	if err != nil {
 		sylog.Fatalf("Could not check build sections: %v", err)
 	}
@@ -243,34 +323,175 @@ func runBuildLocal(ctx context.Context, cmd *cobra.Command, dst, spec string) {
 		buildFormat = "sandbox"
 	}
 
-	b, err := build.New(
-		defs,
-		build.Config{
-			Dest:      dst,
-			Format:    buildFormat,
-			NoCleanUp: buildArgs.noCleanUp,
-			Opts: types.Options{
-				ImgCache:          imgCache,
-				TmpDir:            tmpDir,
-				NoCache:           disableCache,
-				Update:            buildArgs.update,
-				Force:             forceOverwrite,
-				Sections:          buildArgs.sections,
-				NoTest:            buildArgs.noTest,
-				NoHTTPS:           noHTTPS,
-				LibraryURL:        buildArgs.libraryURL,
-				LibraryAuthToken:  authToken,
-				DockerAuthConfig:  &authConf,
-				EncryptionKeyInfo: keyInfo,
-			},
-		})
+	conf := build.Config{
+		Dest:       dst,
+		Format:     buildFormat,
+		NoCleanUp:  buildArgs.noCleanUp,
+		Jobs:       jobs,
+		Stage:      buildArgs.stage,
+		KeepStages: buildArgs.keepStages,
+		Opts: types.Options{
+			ImgCache:          imgCache,
+			TmpDir:            tmpDir,
+			NoCache:           disableCache,
+			Update:            buildArgs.update,
+			Force:             forceOverwrite,
+			Sections:          buildArgs.sections,
+			NoTest:            buildArgs.noTest,
+			DebugShell:        buildArgs.debugShell,
+			NoHTTPS:           noHTTPS,
+			LibraryURL:        buildArgs.libraryURL,
+			LibraryAuthToken:  authToken,
+			DockerAuthConfig:  &authConf,
+			EncryptionKeyInfo: keyInfo,
+			SourceDateEpoch:   epoch,
+			Secrets:           secrets,
+			CacheMounts:       cacheMounts,
+		},
+	}
+
+	if len(archs) > 1 {
+		err = buildMultiArch(ctx, spec, conf, archs)
+	} else {
+		err = buildArch(ctx, defs, conf, archs[0])
+	}
 	if err != nil {
-		sylog.Fatalf("Unable to create build: %v", err)
+		sylog.Fatalf("While performing build: %v", err)
 	}
+}
 
-	if err = b.Full(ctx); err != nil {
-		sylog.Fatalf("While performing build: %v", err)
+// buildArchs returns the architectures specified with --arch, a
+// comma separated list builds a multi-architecture image.
+func buildArchs() ([]string, error) {
+	archs := strings.Split(buildArgs.arch, ",")
+	seen := make(map[string]bool, len(archs))
+	for _, arch := range archs {
+		if arch == "" {
+			return nil, fmt.Errorf("invalid architecture list %q", buildArgs.arch)
+		}
+		if seen[arch] {
+			return nil, fmt.Errorf("architecture %s specified more than once", arch)
+		}
+		seen[arch] = true
+	}
+	return archs, nil
+}
+
+// buildArch builds the image described by conf from defs for the
+// architecture arch, bootstrap images are pulled for the host
+// architecture by default.
+func buildArch(ctx context.Context, defs []types.Definition, conf build.Config, arch string) error {
+	if arch != runtime.GOARCH {
+		conf.Opts.Arch = arch
 	}
+
+	b, err := build.New(defs, conf)
+	if err != nil {
+		return fmt.Errorf("unable to create build: %v", err)
+	}
+	return b.Full(ctx)
+}
+
+// buildMultiArch builds the SIF image described by conf from spec for
+// each architecture of archs, and merges them into a multi-architecture
+// image whose primary system partition is the one of the first
+// architecture.
+func buildMultiArch(ctx context.Context, spec string, conf build.Config, archs []string) error {
+	dir, err := ioutil.TempDir(tmpDir, "build-arch-")
+	if err != nil {
+		return fmt.Errorf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	dst := conf.Dest
+	srcs := make([]string, 0, len(archs))
+
+	for _, arch := range archs {
+		sylog.Infof("Building image for %s", arch)
+
+		// definitions are parsed again as builds may alter them
+		defs, err := build.MakeAllDefs(spec)
+		if err != nil {
+			return fmt.Errorf("unable to build from %s: %v", spec, err)
+		}
+
+		conf.Dest = filepath.Join(dir, arch+".sif")
+		if err := buildArch(ctx, defs, conf, arch); err != nil {
+			return fmt.Errorf("while building image for %s: %v", arch, err)
+		}
+		srcs = append(srcs, conf.Dest)
+	}
+
+	return singularity.SIFMerge(dst, srcs, conf.Opts.SourceDateEpoch)
+}
+
+// buildSecrets returns the secrets specified with --secret, mapping
+// their ID to the absolute path of their source file.
+func buildSecrets() (map[string]string, error) {
+	if len(buildArgs.secrets) == 0 {
+		return nil, nil
+	}
+
+	secrets := make(map[string]string, len(buildArgs.secrets))
+	for _, spec := range buildArgs.secrets {
+		id, src, err := build.ParseSecret(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := secrets[id]; ok {
+			return nil, fmt.Errorf("secret %s specified more than once", id)
+		}
+		src, err = filepath.Abs(src)
+		if err != nil {
+			return nil, fmt.Errorf("while resolving secret %s path: %v", id, err)
+		}
+		if !fs.IsFile(src) {
+			return nil, fmt.Errorf("secret %s: %s is not a file", id, src)
+		}
+		secrets[id] = src
+	}
+	return secrets, nil
+}
+
+// buildCacheMounts returns the cache mounts specified with --cache-mount,
+// mapping the container directories to their build cache directory.
+func buildCacheMounts(imgCache *cache.Handle) (map[string]string, error) {
+	if len(buildArgs.cacheMounts) == 0 {
+		return nil, nil
+	}
+	if disableCache || imgCache.IsDisabled() {
+		return nil, fmt.Errorf("cache mounts require the cache, which is disabled")
+	}
+
+	mounts := make(map[string]string, len(buildArgs.cacheMounts))
+	for _, dir := range buildArgs.cacheMounts {
+		dir = filepath.Clean(dir)
+		if !filepath.IsAbs(dir) || dir == "/" {
+			return nil, fmt.Errorf("cache mount %s must be an absolute path to a container directory", dir)
+		}
+		src := imgCache.BuildCache(dir)
+		if src == "" {
+			return nil, fmt.Errorf("unable to create the build cache of %s", dir)
+		}
+		mounts[dir] = src
+	}
+	return mounts, nil
+}
+
+// sourceDateEpoch returns the time set by the SOURCE_DATE_EPOCH
+// environment variable, or the Unix epoch if not set.
+func sourceDateEpoch() (time.Time, error) {
+	value, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok {
+		sylog.Verbosef("SOURCE_DATE_EPOCH not set, using 0")
+		return time.Unix(0, 0), nil
+	}
+
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || sec < 0 {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH value %q", value)
+	}
+	return time.Unix(sec, 0), nil
 }
 
 func checkSections() error {
@@ -327,24 +548,28 @@ func handleBuildFlags(cmd *cobra.Command) {
 
 // getEncryptionMaterial handles the setting of encryption environment and flag parameters to eventually be
 // passed to the crypt package for handling.
-// This handles the SINGULARITY_ENCRYPTION_PASSPHRASE/PEM_PATH envvars outside of cobra in order to
+// This handles the SINGULARITY_ENCRYPTION_PASSPHRASE/PEM_PATH/PGP_PATH envvars outside of cobra in order to
 // enforce the unique flag/env precidence for the encryption flow
 func getEncryptionMaterial(cmd *cobra.Command) (crypt.KeyInfo, error) {
 	passphraseFlag := cmd.Flags().Lookup("passphrase")
 	PEMFlag := cmd.Flags().Lookup("pem-path")
+	PGPFlag := cmd.Flags().Lookup("pgp-path")
 	passphraseEnv, passphraseEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PASSPHRASE")
 	pemPathEnv, pemPathEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PEM_PATH")
+	pgpPathEnv, pgpPathEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PGP_PATH")
 
 	// checks for no flags/envvars being set
-	if !(PEMFlag.Changed || pemPathEnvOK || passphraseFlag.Changed || passphraseEnvOK) {
+	if !(PEMFlag.Changed || pemPathEnvOK || PGPFlag.Changed || pgpPathEnvOK || passphraseFlag.Changed || passphraseEnvOK) {
 		sylog.Fatalf("Unable to use container encryption. Must supply encryption material through enironment variables or flags.")
 	}
 
 	// order of precidence:
 	// 1. PEM flag
-	// 2. Passphrase flag
-	// 3. PEM envvar
-	// 4. Passphrase envvar
+	// 2. PGP flag
+	// 3. Passphrase flag
+	// 4. PEM envvar
+	// 5. PGP envvar
+	// 6. Passphrase envvar
 
 	if PEMFlag.Changed {
 		exists, err := fs.PathExists(encryptionPEMPath)
@@ -360,6 +585,20 @@ func getEncryptionMaterial(cmd *cobra.Command) (crypt.KeyInfo, error) {
 		return crypt.KeyInfo{Format: crypt.PEM, Path: encryptionPEMPath}, nil
 	}
 
+	if PGPFlag.Changed {
+		exists, err := fs.PathExists(encryptionPGPPath)
+		if err != nil {
+			sylog.Fatalf("Unable to verify existence of %s: %v", encryptionPGPPath, err)
+		}
+
+		if !exists {
+			sylog.Fatalf("Specified PGP key file %s: does not exist.", encryptionPGPPath)
+		}
+
+		sylog.Verbosef("Using pgp path flag for encrypted container")
+		return crypt.KeyInfo{Format: crypt.PGP, Path: encryptionPGPPath}, nil
+	}
+
 	if passphraseFlag.Changed {
 		sylog.Verbosef("Using interactive passphrase entry for encrypted container")
 		passphrase, err := interactive.AskQuestionNoEcho("Enter encryption passphrase: ")
@@ -386,6 +625,20 @@ func getEncryptionMaterial(cmd *cobra.Command) (crypt.KeyInfo, error) {
 		return crypt.KeyInfo{Format: crypt.PEM, Path: pemPathEnv}, nil
 	}
 
+	if pgpPathEnvOK {
+		exists, err := fs.PathExists(pgpPathEnv)
+		if err != nil {
+			sylog.Fatalf("Unable to verify existence of %s: %v", pgpPathEnv, err)
+		}
+
+		if !exists {
+			sylog.Fatalf("Specified PGP key file %s: does not exist.", pgpPathEnv)
+		}
+
+		sylog.Verbosef("Using pgp path environment variable for encrypted container")
+		return crypt.KeyInfo{Format: crypt.PGP, Path: pgpPathEnv}, nil
+	}
+
 	if passphraseEnvOK {
 		sylog.Verbosef("Using passphrase environment variable for encrypted container")
 		return crypt.KeyInfo{Format: crypt.Passphrase, Material: passphraseEnv}, nil
@@ -393,3 +646,18 @@ func getEncryptionMaterial(cmd *cobra.Command) (crypt.KeyInfo, error) {
 
 	return crypt.KeyInfo{}, nil
 }
+
+// askPGPKeyPassphrase prompts for the passphrase of the PGP private key
+// k.Path when it's protected and stores it in k.Material.
+func askPGPKeyPassphrase(k *crypt.KeyInfo) error {
+	encrypted, err := crypt.PGPKeyEncrypted(k.Path)
+	if err != nil {
+		return fmt.Errorf("while reading PGP key %s: %s", k.Path, err)
+	}
+	if !encrypted {
+		return nil
+	}
+
+	k.Material, err = interactive.AskQuestionNoEcho("Enter key passphrase : ")
+	return err
+}
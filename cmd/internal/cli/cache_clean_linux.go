@@ -39,7 +39,7 @@ var (
 		DefaultValue: []string{"all"},
 		Name:         "type",
 		ShortHand:    "T",
-		Usage:        "a list of cache types to clean (possible values: library, oci, shub, blob, net, oras, all)",
+		Usage:        "a list of cache types to clean (possible values: library, oci, shub, blob, net, oras, build, all)",
 	}
 
 	// -N|--name
@@ -59,12 +59,42 @@ var fakerootConfigDisableFlag = cmdline.Flag{
 	Usage:        "disable a user fakeroot mapping entry preventing him to use the fakeroot feature (the user mapping must be present)",
 }
 
+// --audit
+var fakerootConfigAudit bool
+var fakerootConfigAuditFlag = cmdline.Flag{
+	ID:           "fakerootConfigAuditFlag",
+	Value:        &fakerootConfigAudit,
+	DefaultValue: false,
+	Name:         "audit",
+	Usage:        "check fakeroot mapping entries for overlapping or invalid ranges",
+}
+
 // configFakerootCmd singularity config fakeroot
 var configFakerootCmd = &cobra.Command{
-	Args:                  cobra.ExactArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if fakerootConfigAudit {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	DisableFlagsInUseLine: true,
 	PreRun:                EnsureRootPriv,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if fakerootConfigAudit {
+			issues, err := singularity.FakerootAudit()
+			if err != nil {
+				sylog.Fatalf("%s", err)
+			}
+			for _, issue := range issues {
+				fmt.Println(issue)
+			}
+			if len(issues) > 0 {
+				sylog.Fatalf("Found %d issue(s) in fakeroot mapping entries", len(issues))
+			}
+			fmt.Println("No issue found in fakeroot mapping entries")
+			return nil
+		}
+
 		username := args[0]
 		var op singularity.FakerootConfigOp
 
@@ -98,4 +128,5 @@ func init() {
 	cmdManager.RegisterFlagForCmd(&fakerootConfigRemoveFlag, configFakerootCmd)
 	cmdManager.RegisterFlagForCmd(&fakerootConfigEnableFlag, configFakerootCmd)
 	cmdManager.RegisterFlagForCmd(&fakerootConfigDisableFlag, configFakerootCmd)
+	cmdManager.RegisterFlagForCmd(&fakerootConfigAuditFlag, configFakerootCmd)
 }
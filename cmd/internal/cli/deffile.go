@@ -0,0 +1,143 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/build/types/parser"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+func init() {
+	cmdManager.RegisterCmd(DeffileCmd)
+	cmdManager.RegisterSubCmd(DeffileCmd, DeffileLintCmd)
+	cmdManager.RegisterSubCmd(DeffileCmd, DeffileFmtCmd)
+	cmdManager.RegisterSubCmd(DeffileCmd, DeffileFromDockerfileCmd)
+	cmdManager.RegisterSubCmd(DeffileCmd, DeffileConvertCmd)
+	cmdManager.RegisterSubCmd(DeffileCmd, DeffileSchemaCmd)
+
+	cmdManager.RegisterFlagForCmd(&deffileConvertToFlag, DeffileConvertCmd)
+}
+
+// --to
+var deffileConvertTo string
+var deffileConvertToFlag = cmdline.Flag{
+	ID:           "deffileConvertToFlag",
+	Value:        &deffileConvertTo,
+	DefaultValue: "",
+	Name:         "to",
+	Required:     true,
+	Usage:        "format of the converted definition: json, yaml or def",
+}
+
+// DeffileCmd is the 'deffile' command that allows to check, format
+// and convert definition files.
+var DeffileCmd = &cobra.Command{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:           docs.DeffileUse,
+	Short:         docs.DeffileShort,
+	Long:          docs.DeffileLong,
+	Example:       docs.DeffileExample,
+	SilenceErrors: true,
+}
+
+// DeffileLintCmd is 'singularity deffile lint' and reports the
+// problems found in a definition file.
+var DeffileLintCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		n, err := singularity.DeffileLint(args[0], os.Stdout)
+		if err != nil {
+			sylog.Fatalf("Failed to check definition file: %s", err)
+		}
+		if n > 0 {
+			sylog.Fatalf("%d error(s) found in %s", n, args[0])
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.DeffileLintUse,
+	Short:   docs.DeffileLintShort,
+	Long:    docs.DeffileLintLong,
+	Example: docs.DeffileLintExample,
+}
+
+// DeffileFmtCmd is 'singularity deffile fmt' and rewrites a
+// definition file in canonical form.
+var DeffileFmtCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.DeffileFormat(args[0]); err != nil {
+			sylog.Fatalf("Failed to format definition file: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.DeffileFmtUse,
+	Short:   docs.DeffileFmtShort,
+	Long:    docs.DeffileFmtLong,
+	Example: docs.DeffileFmtExample,
+}
+
+// DeffileFromDockerfileCmd is 'singularity deffile from-dockerfile' and
+// converts a Dockerfile to a definition file.
+var DeffileFromDockerfileCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.DeffileFromDockerfile(args[0], os.Stdout); err != nil {
+			sylog.Fatalf("Failed to convert Dockerfile: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.DeffileFromDockerfileUse,
+	Short:   docs.DeffileFromDockerfileShort,
+	Long:    docs.DeffileFromDockerfileLong,
+	Example: docs.DeffileFromDockerfileExample,
+}
+
+// DeffileConvertCmd is 'singularity deffile convert' and converts a
+// definition between the definition file, JSON and YAML forms.
+var DeffileConvertCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.DeffileConvert(args[0], deffileConvertTo, os.Stdout); err != nil {
+			sylog.Fatalf("Failed to convert definition: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.DeffileConvertUse,
+	Short:   docs.DeffileConvertShort,
+	Long:    docs.DeffileConvertLong,
+	Example: docs.DeffileConvertExample,
+}
+
+// DeffileSchemaCmd is 'singularity deffile schema' and displays the
+// JSON schema of definitions in JSON or YAML form.
+var DeffileSchemaCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Print(parser.Schema)
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(0),
+
+	Use:     docs.DeffileSchemaUse,
+	Short:   docs.DeffileSchemaShort,
+	Long:    docs.DeffileSchemaLong,
+	Example: docs.DeffileSchemaExample,
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/crypt"
+)
+
+func init() {
+	cmdManager.RegisterFlagForCmd(&commonPEMFlag, EncryptAddRecipientCmd)
+	cmdManager.RegisterFlagForCmd(&commonPGPFlag, EncryptAddRecipientCmd)
+}
+
+// EncryptAddRecipientCmd is 'singularity encrypt add-recipient' and wraps
+// the encryption key of an image for additional public keys.
+var EncryptAddRecipientCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		var keyInfo crypt.KeyInfo
+
+		switch {
+		case encryptionPEMPath != "" && encryptionPGPPath != "":
+			sylog.Fatalf("--pem-path and --pgp-path are mutually exclusive")
+		case encryptionPEMPath != "":
+			keyInfo = crypt.KeyInfo{Format: crypt.PEM, Path: encryptionPEMPath}
+		case encryptionPGPPath != "":
+			keyInfo = crypt.KeyInfo{Format: crypt.PGP, Path: encryptionPGPPath}
+			if err := askPGPKeyPassphrase(&keyInfo); err != nil {
+				sylog.Fatalf("While reading key passphrase: %s", err)
+			}
+		default:
+			sylog.Fatalf("The private key of a current recipient must be provided with --pem-path or --pgp-path")
+		}
+
+		image := args[len(args)-1]
+
+		if err := singularity.EncryptAddRecipients(image, keyInfo, args[:len(args)-1]); err != nil {
+			sylog.Fatalf("Failed to add recipient(s) to %s: %s", image, err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(2),
+
+	Use:     docs.EncryptAddRecipientUse,
+	Short:   docs.EncryptAddRecipientShort,
+	Long:    docs.EncryptAddRecipientLong,
+	Example: docs.EncryptAddRecipientExample,
+}
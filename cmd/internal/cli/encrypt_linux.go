@@ -0,0 +1,35 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+)
+
+func init() {
+	cmdManager.RegisterCmd(EncryptCmd)
+	cmdManager.RegisterSubCmd(EncryptCmd, EncryptAddRecipientCmd)
+	cmdManager.RegisterSubCmd(EncryptCmd, EncryptRemoveRecipientCmd)
+	cmdManager.RegisterSubCmd(EncryptCmd, EncryptListRecipientsCmd)
+}
+
+// EncryptCmd is the 'encrypt' command that allows the management of
+// the recipients of an encrypted image.
+var EncryptCmd = &cobra.Command{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:           docs.EncryptUse,
+	Short:         docs.EncryptShort,
+	Long:          docs.EncryptLong,
+	Example:       docs.EncryptExample,
+	SilenceErrors: true,
+}
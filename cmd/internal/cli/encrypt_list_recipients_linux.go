@@ -0,0 +1,30 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// EncryptListRecipientsCmd is 'singularity encrypt list-recipients' and
+// lists the recipients of an encrypted image.
+var EncryptListRecipientsCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.EncryptListRecipients(args[0]); err != nil {
+			sylog.Fatalf("Failed to list recipients of %s: %s", args[0], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.EncryptListRecipientsUse,
+	Short:   docs.EncryptListRecipientsShort,
+	Long:    docs.EncryptListRecipientsLong,
+	Example: docs.EncryptListRecipientsExample,
+}
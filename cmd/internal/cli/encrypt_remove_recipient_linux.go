@@ -0,0 +1,30 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// EncryptRemoveRecipientCmd is 'singularity encrypt remove-recipient' and
+// removes the encryption key wrapped for a recipient from an image.
+var EncryptRemoveRecipientCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.EncryptRemoveRecipient(args[1], args[0]); err != nil {
+			sylog.Fatalf("Failed to remove recipient %s from %s: %s", args[0], args[1], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+
+	Use:     docs.EncryptRemoveRecipientUse,
+	Short:   docs.EncryptRemoveRecipientShort,
+	Long:    docs.EncryptRemoveRecipientLong,
+	Example: docs.EncryptRemoveRecipientExample,
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+var networkSubnet string
+
+// --subnet
+var networkSubnetFlag = cmdline.Flag{
+	ID:           "networkSubnetFlag",
+	Value:        &networkSubnet,
+	DefaultValue: "",
+	Name:         "subnet",
+	Usage:        "private IPv4 subnet of the network (e.g. 10.90.0.0/24)",
+}
+
+// NetworkCreateCmd is 'singularity network create' and creates a
+// bridge network.
+var NetworkCreateCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.NetworkCreate(args[0], networkSubnet); err != nil {
+			sylog.Fatalf("Failed to create network: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.NetworkCreateUse,
+	Short:   docs.NetworkCreateShort,
+	Long:    docs.NetworkCreateLong,
+	Example: docs.NetworkCreateExample,
+}
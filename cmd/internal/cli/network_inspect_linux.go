@@ -0,0 +1,32 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// NetworkInspectCmd is 'singularity network inspect' and displays the
+// details of a network.
+var NetworkInspectCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.NetworkInspect(args[0], os.Stdout); err != nil {
+			sylog.Fatalf("Failed to inspect network: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.NetworkInspectUse,
+	Short:   docs.NetworkInspectShort,
+	Long:    docs.NetworkInspectLong,
+	Example: docs.NetworkInspectExample,
+}
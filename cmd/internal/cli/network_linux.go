@@ -0,0 +1,38 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+)
+
+func init() {
+	cmdManager.RegisterCmd(NetworkCmd)
+	cmdManager.RegisterSubCmd(NetworkCmd, NetworkCreateCmd)
+	cmdManager.RegisterSubCmd(NetworkCmd, NetworkListCmd)
+	cmdManager.RegisterSubCmd(NetworkCmd, NetworkRemoveCmd)
+	cmdManager.RegisterSubCmd(NetworkCmd, NetworkInspectCmd)
+
+	cmdManager.RegisterFlagForCmd(&networkSubnetFlag, NetworkCreateCmd)
+}
+
+// NetworkCmd is the 'network' command that allows the management of
+// container networks.
+var NetworkCmd = &cobra.Command{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:           docs.NetworkUse,
+	Short:         docs.NetworkShort,
+	Long:          docs.NetworkLong,
+	Example:       docs.NetworkExample,
+	SilenceErrors: true,
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// NetworkListCmd is 'singularity network ls' and lists the available
+// networks.
+var NetworkListCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.NetworkList(os.Stdout); err != nil {
+			sylog.Fatalf("Failed to list networks: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(0),
+	Aliases:               []string{"list"},
+
+	Use:     docs.NetworkListUse,
+	Short:   docs.NetworkListShort,
+	Long:    docs.NetworkListLong,
+	Example: docs.NetworkListExample,
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// NetworkRemoveCmd is 'singularity network rm' and removes a network.
+var NetworkRemoveCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.NetworkRemove(args[0]); err != nil {
+			sylog.Fatalf("Failed to remove network: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Aliases:               []string{"remove"},
+
+	Use:     docs.NetworkRemoveUse,
+	Short:   docs.NetworkRemoveShort,
+	Long:    docs.NetworkRemoveLong,
+	Example: docs.NetworkRemoveExample,
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+var (
+	overlaySize   string
+	overlaySparse bool
+)
+
+// -s|--size
+var overlaySizeFlag = cmdline.Flag{
+	ID:           "overlaySizeFlag",
+	Value:        &overlaySize,
+	DefaultValue: "64M",
+	Name:         "size",
+	ShortHand:    "s",
+	Usage:        "size of the overlay (e.g. 512M, 1G)",
+}
+
+// --sparse
+var overlaySparseFlag = cmdline.Flag{
+	ID:           "overlaySparseFlag",
+	Value:        &overlaySparse,
+	DefaultValue: false,
+	Name:         "sparse",
+	Usage:        "create a sparse overlay, disk space is allocated as data is written",
+}
+
+// OverlayCreateCmd is 'singularity overlay create' and creates a
+// writable overlay.
+var OverlayCreateCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		size := overlaySizeBytes(overlaySize)
+
+		if err := singularity.OverlayCreate(args[0], size, overlaySparse); err != nil {
+			sylog.Fatalf("Failed to create overlay: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.OverlayCreateUse,
+	Short:   docs.OverlayCreateShort,
+	Long:    docs.OverlayCreateLong,
+	Example: docs.OverlayCreateExample,
+}
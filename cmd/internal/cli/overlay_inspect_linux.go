@@ -0,0 +1,32 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// OverlayInspectCmd is 'singularity overlay inspect' and displays the
+// size and usage of an overlay.
+var OverlayInspectCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.OverlayInspect(args[0], os.Stdout); err != nil {
+			sylog.Fatalf("Failed to inspect overlay: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.OverlayInspectUse,
+	Short:   docs.OverlayInspectShort,
+	Long:    docs.OverlayInspectLong,
+	Example: docs.OverlayInspectExample,
+}
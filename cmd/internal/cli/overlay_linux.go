@@ -0,0 +1,50 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+
+	units "github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+func init() {
+	cmdManager.RegisterCmd(OverlayCmd)
+	cmdManager.RegisterSubCmd(OverlayCmd, OverlayCreateCmd)
+	cmdManager.RegisterSubCmd(OverlayCmd, OverlayResizeCmd)
+	cmdManager.RegisterSubCmd(OverlayCmd, OverlayInspectCmd)
+
+	cmdManager.RegisterFlagForCmd(&overlaySizeFlag, OverlayCreateCmd)
+	cmdManager.RegisterFlagForCmd(&overlaySparseFlag, OverlayCreateCmd)
+	cmdManager.RegisterFlagForCmd(&overlayResizeSizeFlag, OverlayResizeCmd)
+}
+
+// OverlayCmd is the 'overlay' command that allows the management of
+// writable overlays.
+var OverlayCmd = &cobra.Command{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:           docs.OverlayUse,
+	Short:         docs.OverlayShort,
+	Long:          docs.OverlayLong,
+	Example:       docs.OverlayExample,
+	SilenceErrors: true,
+}
+
+// overlaySizeBytes parses a human readable overlay size.
+func overlaySizeBytes(size string) int64 {
+	n, err := units.RAMInBytes(size)
+	if err != nil {
+		sylog.Fatalf("Invalid overlay size %s: %s", size, err)
+	}
+	return n
+}
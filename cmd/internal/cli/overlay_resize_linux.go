@@ -0,0 +1,46 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+var overlayResizeSize string
+
+// -s|--size
+var overlayResizeSizeFlag = cmdline.Flag{
+	ID:           "overlayResizeSizeFlag",
+	Value:        &overlayResizeSize,
+	DefaultValue: "",
+	Name:         "size",
+	ShortHand:    "s",
+	Usage:        "new size of the overlay (e.g. 512M, 1G)",
+	Required:     true,
+}
+
+// OverlayResizeCmd is 'singularity overlay resize' and resizes an
+// ext3 overlay image.
+var OverlayResizeCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		size := overlaySizeBytes(overlayResizeSize)
+
+		if err := singularity.OverlayResize(args[0], size); err != nil {
+			sylog.Fatalf("Failed to resize overlay: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.OverlayResizeUse,
+	Short:   docs.OverlayResizeShort,
+	Long:    docs.OverlayResizeLong,
+	Example: docs.OverlayResizeExample,
+}
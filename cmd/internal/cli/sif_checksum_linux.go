@@ -0,0 +1,62 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+var sifChecksumFile string
+
+// -c|--checksums
+var sifChecksumFileFlag = cmdline.Flag{
+	ID:           "sifChecksumFileFlag",
+	Value:        &sifChecksumFile,
+	DefaultValue: "",
+	Name:         "checksums",
+	ShortHand:    "c",
+	Usage:        "file containing the expected checksums, as printed by 'singularity sif checksum'",
+}
+
+func init() {
+	cmdManager.RegisterFlagForCmd(&sifChecksumFileFlag, SifVerifyCmd)
+}
+
+// SifChecksumCmd prints the checksums of the data objects of a SIF image.
+var SifChecksumCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFChecksum(args[0]); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.SifChecksumUse,
+	Short:   docs.SifChecksumShort,
+	Long:    docs.SifChecksumLong,
+	Example: docs.SifChecksumExample,
+}
+
+// SifVerifyCmd verifies the data objects of a SIF image.
+var SifVerifyCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFVerifyChecksums(args[0], sifChecksumFile); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.SifVerifyUse,
+	Short:   docs.SifVerifyShort,
+	Long:    docs.SifVerifyLong,
+	Example: docs.SifVerifyExample,
+}
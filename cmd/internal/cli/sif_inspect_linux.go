@@ -0,0 +1,96 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+var sifJSON bool
+
+// --json
+var sifJSONFlag = cmdline.Flag{
+	ID:           "sifJSONFlag",
+	Value:        &sifJSON,
+	DefaultValue: false,
+	Name:         "json",
+	ShortHand:    "j",
+	Usage:        "print descriptors as JSON",
+}
+
+func init() {
+	cmdManager.RegisterFlagForCmd(&sifJSONFlag, SifListCmd, SifInfoCmd)
+}
+
+// SifHeaderCmd displays the global header of a SIF image.
+var SifHeaderCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFHeader(args[0]); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.SifHeaderUse,
+	Short:   docs.SifHeaderShort,
+	Long:    docs.SifHeaderLong,
+	Example: docs.SifHeaderExample,
+}
+
+// SifListCmd lists the descriptors of a SIF image.
+var SifListCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFList(args[0], sifJSON); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.SifListUse,
+	Short:   docs.SifListShort,
+	Long:    docs.SifListLong,
+	Example: docs.SifListExample,
+}
+
+// SifInfoCmd displays a descriptor of a SIF image.
+var SifInfoCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFInfo(sifDescriptorID(args[0]), args[1], sifJSON); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+
+	Use:     docs.SifInfoUse,
+	Short:   docs.SifInfoShort,
+	Long:    docs.SifInfoLong,
+	Example: docs.SifInfoExample,
+}
+
+// SifDumpCmd writes a data object of a SIF image to the standard output.
+var SifDumpCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFDump(sifDescriptorID(args[0]), args[1], os.Stdout); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+
+	Use:     docs.SifDumpUse,
+	Short:   docs.SifDumpShort,
+	Long:    docs.SifDumpLong,
+	Example: docs.SifDumpExample,
+}
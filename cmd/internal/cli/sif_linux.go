@@ -0,0 +1,57 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+func init() {
+	cmdManager.RegisterCmd(SifCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifHeaderCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifListCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifInfoCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifDumpCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifNewCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifAddCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifDelCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifReplaceCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifSetPrimCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifMergeCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifChecksumCmd)
+	cmdManager.RegisterSubCmd(SifCmd, SifVerifyCmd)
+}
+
+// SifCmd is the root command for the SIF image manipulation commands.
+//
+// singularity sif [...]
+var SifCmd = &cobra.Command{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:           docs.SifUse,
+	Short:         docs.SifShort,
+	Long:          docs.SifLong,
+	Example:       docs.SifExample,
+	Aliases:       []string{"siftool"},
+	SilenceErrors: true,
+}
+
+// sifDescriptorID parses a descriptor ID from the command line.
+func sifDescriptorID(arg string) uint32 {
+	id, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil || id == 0 {
+		sylog.Fatalf("Invalid descriptor ID %q", arg)
+	}
+	return uint32(id)
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// SifMergeCmd merges SIF images of several architectures.
+var SifMergeCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := os.Stat(args[0]); err == nil {
+			sylog.Fatalf("Image file %s already exists", args[0])
+		}
+		if err := singularity.SIFMerge(args[0], args[1:], nil); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(3),
+
+	Use:     docs.SifMergeUse,
+	Short:   docs.SifMergeShort,
+	Long:    docs.SifMergeLong,
+	Example: docs.SifMergeExample,
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+var (
+	sifObjectOpts singularity.SIFObjectOptions
+	sifZero       bool
+)
+
+// --datatype
+var sifDatatypeFlag = cmdline.Flag{
+	ID:           "sifDatatypeFlag",
+	Value:        &sifObjectOpts.Datatype,
+	DefaultValue: "",
+	Name:         "datatype",
+	Usage:        "type of the data object (deffile, envvar, labels, partition, signature, json, generic, cryptomessage)",
+	Required:     true,
+}
+
+// --parttype
+var sifParttypeFlag = cmdline.Flag{
+	ID:           "sifParttypeFlag",
+	Value:        &sifObjectOpts.Parttype,
+	DefaultValue: "",
+	Name:         "parttype",
+	Usage:        "type of the partition with --datatype partition (system, primsys, data, overlay)",
+}
+
+// --partfs
+var sifPartfsFlag = cmdline.Flag{
+	ID:           "sifPartfsFlag",
+	Value:        &sifObjectOpts.Fstype,
+	DefaultValue: "",
+	Name:         "partfs",
+	Usage:        "file system of the partition with --datatype partition (squashfs, ext3, immuobj, raw, encryptedsquashfs), detected by default",
+}
+
+// --partarch
+var sifPartarchFlag = cmdline.Flag{
+	ID:           "sifPartarchFlag",
+	Value:        &sifObjectOpts.Arch,
+	DefaultValue: "",
+	Name:         "partarch",
+	Usage:        "architecture of the partition with --datatype partition (amd64, arm64, ppc64le...), host architecture by default",
+}
+
+// --signhash
+var sifSignhashFlag = cmdline.Flag{
+	ID:           "sifSignhashFlag",
+	Value:        &sifObjectOpts.Hashtype,
+	DefaultValue: "",
+	Name:         "signhash",
+	Usage:        "hash type of the signature with --datatype signature (sha256, sha384, sha512, blake2s, blake2b)",
+}
+
+// --signentity
+var sifSignentityFlag = cmdline.Flag{
+	ID:           "sifSignentityFlag",
+	Value:        &sifObjectOpts.Entity,
+	DefaultValue: "",
+	Name:         "signentity",
+	Usage:        "fingerprint of the signing entity with --datatype signature",
+}
+
+// --groupid
+var sifGroupidFlag = cmdline.Flag{
+	ID:           "sifGroupidFlag",
+	Value:        &sifObjectOpts.Groupid,
+	DefaultValue: uint32(0),
+	Name:         "groupid",
+	Usage:        "group of the data object (default: none)",
+}
+
+// --link
+var sifLinkFlag = cmdline.Flag{
+	ID:           "sifLinkFlag",
+	Value:        &sifObjectOpts.Link,
+	DefaultValue: uint32(0),
+	Name:         "link",
+	Usage:        "ID of the descriptor the data object is linked to (default: none)",
+}
+
+// --link-group
+var sifLinkGroupFlag = cmdline.Flag{
+	ID:           "sifLinkGroupFlag",
+	Value:        &sifObjectOpts.LinkGroup,
+	DefaultValue: uint32(0),
+	Name:         "link-group",
+	Usage:        "ID of the group the data object is linked to (default: none)",
+}
+
+// --alignment
+var sifAlignmentFlag = cmdline.Flag{
+	ID:           "sifAlignmentFlag",
+	Value:        &sifObjectOpts.Alignment,
+	DefaultValue: 0,
+	Name:         "alignment",
+	Usage:        "alignment of the data object in the image (default: page size)",
+}
+
+// --name
+var sifNameFlag = cmdline.Flag{
+	ID:           "sifNameFlag",
+	Value:        &sifObjectOpts.Name,
+	DefaultValue: "",
+	Name:         "name",
+	Usage:        "name of the data object (default: data file name)",
+}
+
+// --zero
+var sifZeroFlag = cmdline.Flag{
+	ID:           "sifZeroFlag",
+	Value:        &sifZero,
+	DefaultValue: false,
+	Name:         "zero",
+	Usage:        "overwrite the data object content with zeros",
+}
+
+func init() {
+	cmdManager.RegisterFlagForCmd(&sifDatatypeFlag, SifAddCmd)
+	cmdManager.RegisterFlagForCmd(&sifParttypeFlag, SifAddCmd)
+	cmdManager.RegisterFlagForCmd(&sifPartfsFlag, SifAddCmd)
+	cmdManager.RegisterFlagForCmd(&sifPartarchFlag, SifAddCmd)
+	cmdManager.RegisterFlagForCmd(&sifSignhashFlag, SifAddCmd)
+	cmdManager.RegisterFlagForCmd(&sifSignentityFlag, SifAddCmd)
+	cmdManager.RegisterFlagForCmd(&sifGroupidFlag, SifAddCmd)
+	cmdManager.RegisterFlagForCmd(&sifLinkFlag, SifAddCmd)
+	cmdManager.RegisterFlagForCmd(&sifLinkGroupFlag, SifAddCmd)
+	cmdManager.RegisterFlagForCmd(&sifAlignmentFlag, SifAddCmd)
+	cmdManager.RegisterFlagForCmd(&sifNameFlag, SifAddCmd, SifReplaceCmd)
+	cmdManager.RegisterFlagForCmd(&sifZeroFlag, SifDelCmd)
+}
+
+// SifNewCmd creates an empty SIF image.
+var SifNewCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFNew(args[0]); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.SifNewUse,
+	Short:   docs.SifNewShort,
+	Long:    docs.SifNewLong,
+	Example: docs.SifNewExample,
+}
+
+// SifAddCmd adds a data object to a SIF image.
+var SifAddCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFAdd(args[0], args[1], sifObjectOpts); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+
+	Use:     docs.SifAddUse,
+	Short:   docs.SifAddShort,
+	Long:    docs.SifAddLong,
+	Example: docs.SifAddExample,
+}
+
+// SifDelCmd deletes a data object from a SIF image.
+var SifDelCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFDelete(sifDescriptorID(args[0]), args[1], sifZero); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+
+	Use:     docs.SifDelUse,
+	Short:   docs.SifDelShort,
+	Long:    docs.SifDelLong,
+	Example: docs.SifDelExample,
+}
+
+// SifReplaceCmd replaces the content of a data object of a SIF image.
+var SifReplaceCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFReplace(sifDescriptorID(args[0]), args[1], args[2], sifObjectOpts.Name); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(3),
+
+	Use:     docs.SifReplaceUse,
+	Short:   docs.SifReplaceShort,
+	Long:    docs.SifReplaceLong,
+	Example: docs.SifReplaceExample,
+}
+
+// SifSetPrimCmd sets the primary system partition of a SIF image.
+var SifSetPrimCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.SIFSetPrimary(sifDescriptorID(args[0]), args[1]); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+
+	Use:     docs.SifSetPrimUse,
+	Short:   docs.SifSetPrimShort,
+	Long:    docs.SifSetPrimLong,
+	Example: docs.SifSetPrimExample,
+}
@@ -49,6 +49,7 @@ var (
 	dockerLogin      bool
 
 	encryptionPEMPath   string
+	encryptionPGPPath   string
 	promptForPassphrase bool
 	forceOverwrite      bool
 	noHTTPS             bool
@@ -177,6 +178,15 @@ var commonPEMFlag = cmdline.Flag{
 	Usage:        "enter an path to a PEM formated RSA key for an encrypted container",
 }
 
+// --pgp-path
+var commonPGPFlag = cmdline.Flag{
+	ID:           "actionEncryptionPGPPath",
+	Value:        &encryptionPGPPath,
+	DefaultValue: "",
+	Name:         "pgp-path",
+	Usage:        "enter a path to a PGP key for a container encrypted in user space (public key to build, private key to run)",
+}
+
 // -F|--force
 var commonForceFlag = cmdline.Flag{
 	ID:           "commonForceFlag",
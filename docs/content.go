@@ -155,7 +155,140 @@ Enterprise Performance Computing (EPC)`
       Build a base sandbox from DockerHub, make changes to it, then build sif
           $ singularity build --sandbox /tmp/debian docker://debian:latest
           $ singularity exec --writable /tmp/debian apt-get install python
-          $ singularity build /tmp/debian2.sif /tmp/debian`
+          $ singularity build /tmp/debian2.sif /tmp/debian
+
+      Build the stage named "devel" of a multi-stage definition and keep all stages
+      as sandboxes in /tmp/stages:
+          $ singularity build --stage devel --keep-stages /tmp/stages /tmp/devel.sif /path/to/multistage.def
+
+      Start a shell in the container when %post fails, to fix the build and resume it:
+          $ sudo singularity build --debug-shell /tmp/debian4.sif /path/to/debian.def
+
+      Use a pip configuration holding a private index token in %post, available at
+      $SINGULARITY_SECRETS/pip without being stored in the image:
+          $ sudo singularity build --secret id=pip,src=$HOME/.pip/pip.conf /tmp/debian5.sif /path/to/debian.def
+
+      Keep the packages downloaded by apt in %post across builds:
+          $ sudo singularity build --cache-mount /var/cache/apt /tmp/debian6.sif /path/to/debian.def
+
+      Build an arm64 image on an amd64 host, %post runs with the static QEMU user
+      emulator qemu-aarch64-static registered in binfmt_misc if needed:
+          $ sudo singularity build --arch arm64 /tmp/debian7.sif docker://debian:latest
+
+      Build a multi-architecture image running on both amd64 and arm64 hosts:
+          $ sudo singularity build --arch amd64,arm64 /tmp/debian8.sif docker://debian:latest
+
+      Build a reproducible sif file, rebuilding it gives the same file:
+          $ SOURCE_DATE_EPOCH=$(git log -1 --format=%ct) singularity build --reproducible /tmp/debian3.sif /path/to/debian.def`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// deffile
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	DeffileUse   string = `deffile`
+	DeffileShort string = `Check, format and convert definition files`
+	DeffileLong  string = `
+  Check definition files for problems before building them, rewrite them in
+  a canonical form, create them from a Dockerfile or convert them to and from
+  JSON and YAML.`
+	DeffileExample string = `
+  All group commands have their own help output:
+
+  $ singularity help deffile lint
+  $ singularity deffile lint --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// deffile lint
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	DeffileLintUse   string = `lint <definition file>`
+	DeffileLintShort string = `Report problems found in a definition file`
+	DeffileLintLong  string = `
+  The deffile lint command checks a definition file and reports each problem
+  found with its line number:
+
+    - missing, unknown or duplicate headers, and headers not used by the
+      bootstrap agent of the stage
+    - unknown or duplicate sections
+    - %files and %appfiles sources missing on the host, relative paths are
+      resolved from the current directory like the build does
+    - %app* sections of an app without %appinstall or %apprun section
+    - shell syntax errors in scripts run by a POSIX shell, bash or mksh
+
+  Problems are either errors, which make the build fail, or warnings. The
+  command exits with a non zero status if any error is found.`
+	DeffileLintExample string = `
+  $ singularity deffile lint container.def`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// deffile fmt
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	DeffileFmtUse   string = `fmt <definition file>`
+	DeffileFmtShort string = `Rewrite a definition file in canonical form`
+	DeffileFmtLong  string = `
+  The deffile fmt command rewrites a definition file in canonical form: header
+  keywords are spelled the same way, stages and sections are separated by a
+  single blank line and section bodies are indented with four spaces. Sections
+  containing here-documents are kept as is.`
+	DeffileFmtExample string = `
+  $ singularity deffile fmt container.def`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// deffile from-dockerfile
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	DeffileFromDockerfileUse   string = `from-dockerfile <Dockerfile>`
+	DeffileFromDockerfileShort string = `Convert a Dockerfile to a definition file`
+	DeffileFromDockerfileLong  string = `
+  The deffile from-dockerfile command converts a Dockerfile to a definition
+  file written to the standard output:
+
+    - FROM becomes a docker (or scratch) bootstrap, each FROM ... AS <name>
+      of a multi-stage Dockerfile becomes a stage with the same name
+    - RUN, WORKDIR and ARG are added to %post
+    - ENV is added to %environment and to %post for the following RUN
+    - COPY and ADD are added to %files, COPY --from to %files from <stage>
+    - LABEL and MAINTAINER are added to %labels
+    - ENTRYPOINT and CMD become the %runscript, CMD being the default
+      arguments replaced by the container arguments
+
+  Other instructions are ignored with a warning. The sources of COPY and ADD
+  are relative to the Dockerfile directory, the container should be built
+  from there.`
+	DeffileFromDockerfileExample string = `
+  $ singularity deffile from-dockerfile Dockerfile > container.def
+  $ sudo singularity build container.sif container.def`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// deffile convert
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	DeffileConvertUse   string = `convert --to <format> <definition>`
+	DeffileConvertShort string = `Convert a definition to JSON, YAML or definition file form`
+	DeffileConvertLong  string = `
+  The deffile convert command converts a definition to the format given with
+  --to and writes it to the standard output:
+
+    json: JSON form described by the schema of 'singularity deffile schema'
+    yaml: YAML form of the JSON form
+    def:  definition file form
+
+  Definitions with a .json, .yaml or .yml extension are read in JSON or YAML
+  form, other files are read as definition files. Definitions in JSON or YAML
+  form can be built directly, their stages are converted to a definition file
+  saved in the container.`
+	DeffileConvertExample string = `
+  $ singularity deffile convert --to yaml container.def > container.yaml
+  $ singularity deffile convert --to def container.yaml
+  $ sudo singularity build container.sif container.yaml`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// deffile schema
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	DeffileSchemaUse   string = `schema`
+	DeffileSchemaShort string = `Display the JSON schema of definitions in JSON or YAML form`
+	DeffileSchemaLong  string = `
+  The deffile schema command displays the JSON schema of definitions in JSON
+  or YAML form. The schema is versioned with the "version" property of the
+  definition, the current version is "1".`
+	DeffileSchemaExample string = `
+  $ singularity deffile schema > definition.schema.json`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// Cache
@@ -181,12 +314,14 @@ Enterprise Performance Computing (EPC)`
   SINGULARITY_CACHEDIR is not set). By default the entire cache is cleaned, use
   --name or --type flags to override this behavior. Note: if you use Singularity
   as root, cache will be stored in '/root/.singularity/.cache', to clean that
-  cache, you will need to run 'cache clean --all' as root, or with 'sudo'.`
+  cache, you will need to run 'cache clean --all' as root, or with 'sudo'.
+  The build type holds the directories persisted by 'build --cache-mount'.`
 	CacheCleanExample string = `
   All group commands have their own help output:
 
   $ singularity help cache clean --name cache_name.sif
   $ singularity help cache clean --type=library,oci
+  $ singularity help cache clean --type=build
   $ singularity cache clean --help`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -204,6 +339,380 @@ Enterprise Performance Computing (EPC)`
   $ singularity help cache list --type=library,oci
   $ singularity cache list --help`
 
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// encrypt
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	EncryptUse   string = `encrypt`
+	EncryptShort string = `Manage the recipients of an encrypted container`
+	EncryptLong  string = `
+  Manage the recipients of a container encrypted with PEM or PGP keys. The
+  encryption key of the container is wrapped separately for each recipient,
+  recipients can be added or removed without re-encrypting the container
+  root filesystem.`
+	EncryptExample string = `
+  All group commands have their own help output:
+
+  $ singularity help encrypt add-recipient
+  $ singularity encrypt list-recipients --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// encrypt add-recipient
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	EncryptAddRecipientUse   string = `add-recipient [add-recipient options...] <public key path>... <image path>`
+	EncryptAddRecipientShort string = `Grant access to an encrypted container to new recipients`
+	EncryptAddRecipientLong  string = `
+  The encrypt add-recipient command wraps the encryption key of a container
+  for one or more additional public keys. The encryption key is first
+  recovered with the private key of a current recipient, given with --pem-path
+  or --pgp-path. Public keys must be in the same format as the private key.`
+	EncryptAddRecipientExample string = `
+  $ singularity encrypt add-recipient --pgp-path ~/my-private.asc alice.asc bob.asc container.sif
+  $ singularity encrypt add-recipient --pem-path rsa_pri.pem rsa_pub_new.pem container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// encrypt remove-recipient
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	EncryptRemoveRecipientUse   string = `remove-recipient <recipient ID|public key path> <image path>`
+	EncryptRemoveRecipientShort string = `Revoke access to an encrypted container from a recipient`
+	EncryptRemoveRecipientLong  string = `
+  The encrypt remove-recipient command removes the encryption key wrapped for
+  a recipient from a container. The recipient is designated either by the ID
+  shown by 'singularity encrypt list-recipients' or by the path to its public
+  key. The last recipient of a container cannot be removed.
+
+  NOTE: a removed recipient who kept a copy of the encryption key can still
+  decrypt the container, rebuild the container to rotate the encryption key.`
+	EncryptRemoveRecipientExample string = `
+  $ singularity encrypt remove-recipient bob.asc container.sif
+  $ singularity encrypt remove-recipient pgp:0123456789ABCDEF0123456789ABCDEF01234567 container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// encrypt list-recipients
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	EncryptListRecipientsUse   string = `list-recipients <image path>`
+	EncryptListRecipientsShort string = `List the recipients of an encrypted container`
+	EncryptListRecipientsLong  string = `
+  The encrypt list-recipients command lists the recipients the encryption key
+  of a container is wrapped for.`
+	EncryptListRecipientsExample string = `
+  $ singularity encrypt list-recipients container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifUse   string = `sif`
+	SifShort string = `Manipulate Singularity Image Format (SIF) images`
+	SifLong  string = `
+  A set of commands are provided to display elements such as the SIF global
+  header, the data object descriptors and to dump data objects. Data objects
+  like definition files, labels or data and overlay partitions can be added
+  to, removed from or replaced in an existing SIF image, SIF images of several
+  architectures can be merged, and the data objects can be checksummed and
+  verified.`
+	SifExample string = `
+  All group commands have their own help output:
+
+  $ singularity help sif add
+  $ singularity sif list --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif header
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifHeaderUse   string = `header <image path>`
+	SifHeaderShort string = `Display the global header of a SIF image`
+	SifHeaderLong  string = `
+  The sif header command displays the global header of a SIF image.`
+	SifHeaderExample string = `
+  $ singularity sif header container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif list
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifListUse   string = `list [list options...] <image path>`
+	SifListShort string = `List the data object descriptors of a SIF image`
+	SifListLong  string = `
+  The sif list command lists the data object descriptors of a SIF image, the
+  --json option prints the global header and the descriptors as a JSON
+  document.`
+	SifListExample string = `
+  $ singularity sif list container.sif
+  $ singularity sif list --json container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif info
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifInfoUse   string = `info [info options...] <descriptor ID> <image path>`
+	SifInfoShort string = `Display a data object descriptor of a SIF image`
+	SifInfoLong  string = `
+  The sif info command displays the detailed information of a data object
+  descriptor of a SIF image, as text or as JSON with the --json option.`
+	SifInfoExample string = `
+  $ singularity sif info 1 container.sif
+  $ singularity sif info --json 3 container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif dump
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifDumpUse   string = `dump <descriptor ID> <image path>`
+	SifDumpShort string = `Write a data object of a SIF image to the standard output`
+	SifDumpLong  string = `
+  The sif dump command writes the content of a data object of a SIF image to
+  the standard output.`
+	SifDumpExample string = `
+  $ singularity sif dump 1 container.sif > Singularity.def`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif new
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifNewUse   string = `new <image path>`
+	SifNewShort string = `Create an empty SIF image`
+	SifNewLong  string = `
+  The sif new command creates a SIF image without any data object.`
+	SifNewExample string = `
+  $ singularity sif new data.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif add
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifAddUse   string = `add [add options...] <image path> <data file>`
+	SifAddShort string = `Add a data object to a SIF image`
+	SifAddLong  string = `
+  The sif add command adds the content of a file as a new data object to a
+  SIF image. The type of the data object is given with --datatype, partitions
+  also require a partition type with --parttype, their file system type is
+  detected from the file content when --partfs is not given.`
+	SifAddExample string = `
+  Add labels to a container:
+  $ singularity sif add --datatype labels container.sif labels.json
+
+  Attach a squashfs data partition to a container:
+  $ singularity sif add --datatype partition --parttype data container.sif data.squashfs
+
+  Attach an ext3 overlay partition to a container:
+  $ singularity sif add --datatype partition --parttype overlay --partfs ext3 container.sif overlay.img`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif del
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifDelUse   string = `del [del options...] <descriptor ID> <image path>`
+	SifDelShort string = `Delete a data object from a SIF image`
+	SifDelLong  string = `
+  The sif del command deletes a data object from a SIF image. The image is
+  shrunk when the data object is the last one of the image, --zero overwrites
+  the data object content with zeros.`
+	SifDelExample string = `
+  $ singularity sif del 3 container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif replace
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifReplaceUse   string = `replace [replace options...] <descriptor ID> <image path> <data file>`
+	SifReplaceShort string = `Replace the content of a data object of a SIF image`
+	SifReplaceLong  string = `
+  The sif replace command replaces the content of a data object of a SIF image
+  with the content of a file. The data object keeps its ID, type, group and
+  links, and its name unless --name is given.
+
+  NOTE: replacing a signed data object invalidates its signature.`
+	SifReplaceExample string = `
+  $ singularity sif replace 3 container.sif labels.json`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif setprim
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifSetPrimUse   string = `setprim <descriptor ID> <image path>`
+	SifSetPrimShort string = `Set the primary system partition of a SIF image`
+	SifSetPrimLong  string = `
+  The sif setprim command sets a system partition as the primary system
+  partition of a SIF image, the previous primary partition becomes a regular
+  system partition.`
+	SifSetPrimExample string = `
+  $ singularity sif setprim 4 container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif merge
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifMergeUse   string = `merge <image path> <source image path>...`
+	SifMergeShort string = `Merge SIF images of several architectures into one`
+	SifMergeLong  string = `
+  The sif merge command creates a multi-architecture SIF image holding the
+  system partitions of the source images, one per architecture. The primary
+  system partition, definition file and labels come from the first source
+  image. At runtime, the system partition matching the host architecture is
+  used, so the same image can be run on hosts of all the merged architectures.
+
+  NOTE: the signatures of the source images are not copied, the merged image
+  has to be signed again.`
+	SifMergeExample string = `
+  $ singularity sif merge container.sif container-amd64.sif container-arm64.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif checksum
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifChecksumUse   string = `checksum <image path>`
+	SifChecksumShort string = `Print the checksums of the data objects of a SIF image`
+	SifChecksumLong  string = `
+  The sif checksum command prints the SHA256 checksum of each data object of a
+  SIF image, one line per data object with the checksum followed by the
+  descriptor ID. The output can be saved and verified later with
+  'singularity sif verify --checksums'.`
+	SifChecksumExample string = `
+  $ singularity sif checksum container.sif > container.sha256`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// sif verify
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	SifVerifyUse   string = `verify [verify options...] <image path>`
+	SifVerifyShort string = `Verify the data objects of a SIF image`
+	SifVerifyLong  string = `
+  The sif verify command verifies that all data objects of a SIF image lie
+  within the image file, and that their content matches the checksums found
+  in the file given with --checksums. Descriptors not listed in the checksum
+  file are not compared.`
+	SifVerifyExample string = `
+  $ singularity sif verify container.sif
+  $ singularity sif verify --checksums container.sha256 container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayUse   string = `overlay`
+	OverlayShort string = `Manage writable overlays`
+	OverlayLong  string = `
+  Manage the ext3 writable overlays used to persist changes made to a
+  container.`
+	OverlayExample string = `
+  All group commands have their own help output:
+
+  $ singularity help overlay create
+  $ singularity overlay create --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay create
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayCreateUse   string = `create [create options...] <path>`
+	OverlayCreateShort string = `Create a writable overlay image or SIF overlay partition`
+	OverlayCreateLong  string = `
+  The overlay create command creates an ext3 writable overlay of the given
+  size. The overlay contains the "upper" and "work" directories used by
+  overlayfs and is owned by the calling user.
+
+  If the path doesn't exist, a standalone overlay image is created which can
+  be used with the --overlay option of the action commands. The image is fully
+  allocated on disk unless --sparse is specified.
+
+  If the path is an existing SIF image, a sparse overlay partition is appended
+  to the image instead. The overlay partition is used automatically when the
+  container is run with --writable, changes are stored in the overlay partition
+  while the root filesystem stays immutable and its signatures remain valid.
+  Without --writable, the content of the overlay is visible read-only.`
+	OverlayCreateExample string = `
+  $ singularity overlay create --size 1G overlay.img
+  $ singularity shell --overlay overlay.img container.sif
+
+  $ singularity overlay create --sparse --size 10G overlay.img
+
+  $ singularity overlay create --size 1G container.sif
+  $ singularity shell --writable container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay resize
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayResizeUse   string = `resize --size <size> <overlay image>`
+	OverlayResizeShort string = `Resize an ext3 overlay image`
+	OverlayResizeLong  string = `
+  The overlay resize command checks the filesystem of an ext3 overlay image and
+  grows or shrinks it to the given size. The overlay must not be in use by a
+  running container. A grown image is extended sparse. Overlay partitions
+  embedded in SIF images can't be resized.`
+	OverlayResizeExample string = `
+  $ singularity overlay resize --size 2G overlay.img`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// overlay inspect
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	OverlayInspectUse   string = `inspect <path>`
+	OverlayInspectShort string = `Show the size and usage of an overlay`
+	OverlayInspectLong  string = `
+  The overlay inspect command shows the size, the disk space allocated, the
+  filesystem usage and whether a container is writing into an ext3 overlay
+  image, or into each overlay partition of a SIF image.`
+	OverlayInspectExample string = `
+  $ singularity overlay inspect overlay.img
+  $ singularity overlay inspect container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkUse   string = `network`
+	NetworkShort string = `Manage container networks`
+	NetworkLong  string = `
+  Manage the bridge networks containers and instances can be attached to with
+  the --network option. Instances on the same network can reach each other
+  using their instance name.
+
+  Networks created by root are system networks stored in the CNI configuration
+  directory. Networks created by other users are stored in
+  '~/.singularity/network' and are only available to them with --fakeroot.`
+	NetworkExample string = `
+  All group commands have their own help output:
+
+  $ singularity help network create
+  $ singularity network create --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network create
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkCreateUse   string = `create [create options...] <name>`
+	NetworkCreateShort string = `Create a bridge network`
+	NetworkCreateLong  string = `
+  The network create command generates the CNI configuration of a bridge
+  network using host-local IP address management. The subnet must be a private
+  IPv4 subnet not used by another network, a host interface or a host route.
+  Subnets of networks created by regular users must also be part of the
+  'bridge network subnets' set in singularity.conf (10.89.0.0/16 by default),
+  and are checked again each time a container joins the network. A free /24
+  subnet is chosen when --subnet is not specified.`
+	NetworkCreateExample string = `
+  $ singularity network create mynet
+  $ singularity network create --subnet 10.89.100.0/24 mynet
+
+  $ singularity instance start --fakeroot --network mynet db.sif db
+  $ singularity instance start --fakeroot --network mynet app.sif app`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network list
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkListUse   string = `ls`
+	NetworkListShort string = `List the available networks`
+	NetworkListLong  string = `
+  The network ls command lists the system networks and the networks created by
+  the current user, with the number of running instances attached to them.`
+	NetworkListExample string = `
+  $ singularity network ls`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network remove
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkRemoveUse   string = `rm <name>`
+	NetworkRemoveShort string = `Remove a network`
+	NetworkRemoveLong  string = `
+  The network rm command removes a network created with 'network create'. The
+  network must not be used by a running instance.`
+	NetworkRemoveExample string = `
+  $ singularity network rm mynet`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// network inspect
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	NetworkInspectUse   string = `inspect <name>`
+	NetworkInspectShort string = `Show the details of a network`
+	NetworkInspectLong  string = `
+  The network inspect command shows the type, subnet and bridge of a network
+  and the running instances attached to it as a JSON document.`
+	NetworkInspectExample string = `
+  $ singularity network inspect mynet`
+
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// key
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -496,20 +1005,33 @@ Enterprise Performance Computing (EPC)`
   instances that are currently running in the background.`
 	InstanceListExample string = `
   $ singularity instance list
-  INSTANCE NAME      PID       IMAGE
-  test               11963     /home/mibauer/singularity/sinstance/test.sif
-  test2              11964     /home/mibauer/singularity/sinstance/test.sif
-  lolcow             11965     /home/mibauer/singularity/sinstance/lolcow.sif
+  INSTANCE NAME    PID      IP              PORTS                IMAGE
+  test             11963                                         /home/mibauer/singularity/sinstance/test.sif
+  test2            11964                                         /home/mibauer/singularity/sinstance/test.sif
+  lolcow           11965    10.22.0.4       8080->80/tcp         /home/mibauer/singularity/sinstance/lolcow.sif
 
   $ singularity instance list 'test*'
-  INSTANCE NAME      PID       IMAGE
-  test               11963     /home/mibauer/singularity/sinstance/test.sif
-  test2              11964     /home/mibauer/singularity/sinstance/test.sif
+  INSTANCE NAME    PID      IP              PORTS                IMAGE
+  test             11963                                         /home/mibauer/singularity/sinstance/test.sif
+  test2            11964                                         /home/mibauer/singularity/sinstance/test.sif
 
   $ sudo singularity instance list -u mibauer
-  INSTANCE NAME      PID       IMAGE
-  test               11963     /home/mibauer/singularity/sinstance/test.sif
-  test2              16219     /home/mibauer/singularity/sinstance/test.sif`
+  INSTANCE NAME    PID      IP              PORTS                IMAGE
+  test             11963                                         /home/mibauer/singularity/sinstance/test.sif
+  test2            16219                                         /home/mibauer/singularity/sinstance/test.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance port
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstancePortUse   string = `port <instance name>`
+	InstancePortShort string = `List the ports published by a named instance`
+	InstancePortLong  string = `
+  The instance port command lists the container ports published on the host
+  by an instance with --publish or the portmap network argument.`
+	InstancePortExample string = `
+  $ singularity instance start --publish 8080:80 nginx.sif web
+  $ singularity instance port web
+  80/tcp -> 0.0.0.0:8080`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// instance start
@@ -989,11 +1511,16 @@ Enterprise Performance Computing (EPC)`
   $ singularity help config fakeroot
   $ singularity config fakeroot --help`
 
-	ConfigFakerootUse   string = `fakeroot <option> <user>`
+	ConfigFakerootUse   string = `fakeroot <option> [user]`
 	ConfigFakerootShort string = `Manage fakeroot user mappings entries (root user only)`
 	ConfigFakerootLong  string = `
   The config fakeroot command allow a root user to add/remove/enable/disable fakeroot
-  user mappings.`
+  user mappings.
+
+  With --audit, /etc/subuid and /etc/subgid are checked for malformed entries,
+  overlapping ranges and users unable to use fakeroot. When 'fakeroot allocation'
+  is set to 'uid' in singularity.conf, entries overlapping the automatic allocation
+  range are reported too.`
 	ConfigFakerootExample string = `
   To add a fakeroot user mapping for vagrant user:
   $ singularity config fakeroot --add vagrant
@@ -1005,5 +1532,8 @@ Enterprise Performance Computing (EPC)`
   $ singularity config fakeroot --disable vagrant
 
   To enable a fakeroot user mapping for vagrant user:
-  $ singularity config fakeroot --enable vagrant`
+  $ singularity config fakeroot --enable vagrant
+
+  To check fakeroot user mappings:
+  $ singularity config fakeroot --audit`
 )
@@ -61,6 +61,11 @@ func (c imgBuildTests) buildFrom(t *testing.T) {
 			name:      "BusyBox",
 			buildSpec: "../examples/busybox/Singularity",
 		},
+		{
+			name:       "Apk",
+			dependency: "apk.static",
+			buildSpec:  "../examples/alpine/Singularity",
+		},
 		{
 			name:       "Debootstrap",
 			dependency: "debootstrap",
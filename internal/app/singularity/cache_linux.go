@@ -18,7 +18,7 @@ func normalizeCacheList(cacheList []string) ([]string, error) {
 
 	for _, e := range cacheList {
 		switch e {
-		case "library", "oci", "shub", "blob", "net", "oras":
+		case "library", "oci", "shub", "blob", "net", "oras", "build":
 			list = append(list, e)
 
 		case "blobs":
@@ -37,7 +37,7 @@ func normalizeCacheList(cacheList []string) ([]string, error) {
 
 	if all {
 		// cleanAll overrides all the specified names
-		list = []string{"library", "oci", "shub", "blob", "net", "oras"}
+		list = []string{"library", "oci", "shub", "blob", "net", "oras", "build"}
 	}
 
 	return list, nil
@@ -57,6 +57,8 @@ func cacheTypeToDir(imgCache *cache.Handle, cacheType string) (string, error) {
 		return imgCache.Net, nil
 	case "oras":
 		return imgCache.Oras, nil
+	case "build":
+		return imgCache.Build, nil
 	}
 
 	return "", errInvalidCacheType
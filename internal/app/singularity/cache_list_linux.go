@@ -99,6 +99,61 @@ func listTypeCache(printList bool, name, cachePath string) (int, int64, error) {
 	return count, totalSize, nil
 }
 
+// listBuildCache will list the build cache mounts in cachePath, each
+// mount being a directory tree. Will return: the number of cache mounts,
+// the total space they are using and an error if one occurs.
+func listBuildCache(printList bool, cachePath string) (int, int64, error) {
+	_, err := os.Stat(cachePath)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, fmt.Errorf("unable to open cache build at directory %s: %v", cachePath, err)
+	}
+
+	cacheDirs, err := ioutil.ReadDir(cachePath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to open cache build at directory %s: %v", cachePath, err)
+	}
+
+	var (
+		totalSize int64
+		count     int
+	)
+
+	for _, dir := range cacheDirs {
+		if !dir.IsDir() {
+			sylog.Debugf("stray file in cache dir: %v", filepath.Join(cachePath, dir.Name()))
+			continue
+		}
+
+		var size int64
+		err := filepath.Walk(filepath.Join(cachePath, dir.Name()), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				size += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, 0, fmt.Errorf("unable to look in: %s: %v", cachePath, err)
+		}
+
+		if printList {
+			fmt.Printf("%-24.22s %-22s %-16s %s\n",
+				dir.Name(),
+				dir.ModTime().Format("2006-01-02 15:04:05"),
+				findSize(size),
+				"build")
+		}
+		totalSize += size
+		count++
+	}
+
+	return count, totalSize, nil
+}
+
 // ListSingularityCache will list the local singularity cache for the
 // types specified by cacheListTypes. If cacheListTypes contains the
 // value "all", all the cache entries are considered. If cacheListVerbose is
@@ -115,8 +170,8 @@ func ListSingularityCache(imgCache *cache.Handle, cacheListTypes []string, cache
 	}
 
 	var (
-		containerCount, blobCount             int
-		containerSpace, blobSpace, totalSpace int64
+		containerCount, blobCount, buildCount             int
+		containerSpace, blobSpace, buildSpace, totalSpace int64
 	)
 
 	if cacheListVerbose {
@@ -125,6 +180,7 @@ func ListSingularityCache(imgCache *cache.Handle, cacheListTypes []string, cache
 
 	containersShown := false
 	blobsShown := false
+	buildShown := false
 
 	for _, cacheType := range cacheTypes {
 		if cacheType == "blob" {
@@ -142,6 +198,19 @@ func ListSingularityCache(imgCache *cache.Handle, cacheListTypes []string, cache
 			blobSpace = blobsSize
 			totalSpace += blobsSize
 			blobsShown = true
+		} else if cacheType == "build" {
+			// build cache mounts are directory trees
+			// counted as a whole
+			cacheDir, _ := cacheTypeToDir(imgCache, cacheType)
+			count, size, err := listBuildCache(cacheListVerbose, cacheDir)
+			if err != nil {
+				fmt.Print(err)
+				return err
+			}
+			buildCount = count
+			buildSpace = size
+			totalSpace += size
+			buildShown = true
 		} else {
 			cacheDir, _ := cacheTypeToDir(imgCache, cacheType)
 			count, size, err := listTypeCache(cacheListVerbose, cacheType, cacheDir)
@@ -160,20 +229,18 @@ func ListSingularityCache(imgCache *cache.Handle, cacheListTypes []string, cache
 		fmt.Print("\n")
 	}
 
-	out := new(strings.Builder)
-	out.WriteString("There are")
+	var shown []string
 	if containersShown {
-		fmt.Fprintf(out, " %d container file(s) using %s", containerCount, findSize(containerSpace))
-	}
-	if containersShown && blobsShown {
-		fmt.Fprintf(out, " and")
+		shown = append(shown, fmt.Sprintf("%d container file(s) using %s", containerCount, findSize(containerSpace)))
 	}
 	if blobsShown {
-		fmt.Fprintf(out, " %d oci blob file(s) using %s", blobCount, findSize(blobSpace))
+		shown = append(shown, fmt.Sprintf("%d oci blob file(s) using %s", blobCount, findSize(blobSpace)))
+	}
+	if buildShown {
+		shown = append(shown, fmt.Sprintf("%d build cache mount(s) using %s", buildCount, findSize(buildSpace)))
 	}
-	out.WriteString(" of space\n")
 
-	fmt.Print(out.String())
+	fmt.Printf("There are %s of space\n", strings.Join(shown, " and "))
 	fmt.Printf("Total space used: %s\n", findSize(totalSpace))
 
 	return nil
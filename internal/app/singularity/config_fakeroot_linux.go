@@ -8,7 +8,9 @@ package singularity
 import (
 	"fmt"
 
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/fakeroot"
+	"github.com/sylabs/singularity/pkg/runtime/engine/config"
 )
 
 // FakerootConfigOp defines a type for a fakeroot
@@ -80,3 +82,29 @@ func FakerootConfig(username string, op FakerootConfigOp) error {
 
 	return nil
 }
+
+// FakerootAudit checks /etc/subuid and /etc/subgid files against the
+// fakeroot allocation policy set in singularity.conf and returns the
+// list of issues found.
+func FakerootAudit() ([]string, error) {
+	var issues []string
+
+	cfg, err := config.ParseFile(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse singularity.conf file: %s", err)
+	}
+	policy, err := fakeroot.GetAllocationPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range []string{fakeroot.SubUIDFile, fakeroot.SubGIDFile} {
+		fileIssues, err := fakeroot.Audit(path, policy)
+		if err != nil {
+			return nil, fmt.Errorf("while auditing %s: %s", path, err)
+		}
+		issues = append(issues, fileIssues...)
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/sylabs/singularity/internal/pkg/build/deffile"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/build/types"
+	"github.com/sylabs/singularity/pkg/build/types/parser"
+)
+
+// DeffileLint checks the definition file at path, writes the problems
+// found to w and returns the number of errors.
+func DeffileLint(path string, w io.Writer) (int, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("while reading definition file: %s", err)
+	}
+
+	errors := 0
+	for _, p := range deffile.Lint(raw) {
+		if p.Severity == deffile.Error {
+			errors++
+		}
+		fmt.Fprintf(w, "%s:%s\n", path, p)
+	}
+	return errors, nil
+}
+
+// DeffileFormat rewrites the definition file at path in canonical form.
+func DeffileFormat(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("while getting definition file information: %s", err)
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("while reading definition file: %s", err)
+	}
+
+	formatted := deffile.Format(raw)
+	if bytes.Equal(raw, formatted) {
+		return nil
+	}
+	if err := ioutil.WriteFile(path, formatted, fi.Mode()); err != nil {
+		return fmt.Errorf("while writing definition file: %s", err)
+	}
+	return nil
+}
+
+// DeffileFromDockerfile converts the Dockerfile at path to a definition
+// file written to w, instructions which can't be converted are reported
+// as warnings.
+func DeffileFromDockerfile(path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("while opening Dockerfile: %s", err)
+	}
+	defer f.Close()
+
+	defs, problems, err := deffile.FromDockerfile(f)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		sylog.Warningf("%s:%d: %s", path, p.Line, p.Message)
+	}
+
+	var b bytes.Buffer
+	for i := range defs {
+		types.WriteDefinitionFile(&defs[i], &b)
+	}
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("while writing definition file: %s", err)
+	}
+	return nil
+}
+
+// DeffileConvert converts the definition at path to the format "json",
+// "yaml" or "def" and writes it to w. The definition is read in JSON or
+// YAML form if the file has a .json, .yaml or .yml extension.
+func DeffileConvert(path, format string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("while opening definition: %s", err)
+	}
+	defer f.Close()
+
+	parse := parser.All
+	if parser.IsStructuredDefinition(path) {
+		parse = parser.ParseStructured
+	}
+	defs, err := parse(f)
+	if err != nil {
+		return fmt.Errorf("while parsing definition: %s", err)
+	}
+
+	switch format {
+	case "json":
+		return parser.WriteJSON(w, defs)
+	case "yaml":
+		return parser.WriteYAML(w, defs)
+	case "def":
+		var b bytes.Buffer
+		for i := range defs {
+			types.WriteDefinitionFile(&defs[i], &b)
+		}
+		_, err := w.Write(b.Bytes())
+		return err
+	}
+	return fmt.Errorf("unknown format %s, must be json, yaml or def", format)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/crypt"
+)
+
+// EncryptAddRecipients wraps the encryption key of the image for the
+// public keys found in the files recipients. The key is recovered with
+// the private key described by keyInfo.
+func EncryptAddRecipients(image string, keyInfo crypt.KeyInfo, recipients []string) error {
+	if err := crypt.AddRecipients(image, keyInfo, recipients); err != nil {
+		return err
+	}
+
+	sylog.Infof("Recipient(s) added to %s", image)
+	return nil
+}
+
+// EncryptRemoveRecipient removes a recipient, designated by its ID or
+// by the path to its public key, from the image.
+func EncryptRemoveRecipient(image string, recipient string) error {
+	if err := crypt.RemoveRecipient(image, recipient); err != nil {
+		return err
+	}
+
+	sylog.Infof("Recipient %s removed from %s", recipient, image)
+	return nil
+}
+
+// EncryptListRecipients lists the recipients the encryption key of
+// the image is wrapped for.
+func EncryptListRecipients(image string) error {
+	recipients, err := crypt.ImageRecipients(image)
+	if err != nil {
+		return err
+	}
+
+	if len(recipients) == 0 {
+		fmt.Printf("There are no recipients in %s.\n", image)
+		return nil
+	}
+
+	fmt.Printf("%-8s  %-6s  %s\n", "DESCR ID", "FORMAT", "RECIPIENT")
+
+	for _, r := range recipients {
+		format := "unknown"
+		switch r.Format {
+		case crypt.PEM:
+			format = "pem"
+		case crypt.PGP:
+			format = "pgp"
+		}
+
+		id := r.ID
+		if id == "" {
+			id = "-"
+		}
+
+		fmt.Printf("%-8d  %-6s  %s\n", r.DescrID, format, id)
+	}
+
+	return nil
+}
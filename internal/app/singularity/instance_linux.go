@@ -4,20 +4,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/sylabs/singularity/internal/pkg/instance"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/network"
 	"github.com/sylabs/singularity/pkg/util/fs/proc"
 )
 
 type instanceInfo struct {
-	Instance string `json:"instance"`
-	Pid      int    `json:"pid"`
-	Image    string `json:"img"`
-	IP       string `json:"ip"`
+	Instance string                 `json:"instance"`
+	Pid      int                    `json:"pid"`
+	Image    string                 `json:"img"`
+	IP       string                 `json:"ip"`
+	Ports    []network.PortMapEntry `json:"ports,omitempty"`
 }
 
 // PrintInstanceList fetches instance list, applying name and
@@ -30,12 +35,12 @@ func PrintInstanceList(w io.Writer, name, user string, formatJSON bool) error {
 	}
 
 	if !formatJSON {
-		_, err := fmt.Fprintf(w, "%-16s %-8s %-15s %s\n", "INSTANCE NAME", "PID", "IP", "IMAGE")
+		_, err := fmt.Fprintf(w, "%-16s %-8s %-15s %-20s %s\n", "INSTANCE NAME", "PID", "IP", "PORTS", "IMAGE")
 		if err != nil {
 			return fmt.Errorf("could not write list header: %v", err)
 		}
 		for _, i := range ii {
-			_, err := fmt.Fprintf(w, "%-16s %-8d %-15s %s\n", i.Name, i.Pid, i.IP, i.Image)
+			_, err := fmt.Fprintf(w, "%-16s %-8d %-15s %-20s %s\n", i.Name, i.Pid, i.IP, portsString(i.Ports), i.Image)
 			if err != nil {
 				return fmt.Errorf("could not write instance info: %v", err)
 			}
@@ -49,6 +54,7 @@ func PrintInstanceList(w io.Writer, name, user string, formatJSON bool) error {
 		instances[i].Pid = ii[i].Pid
 		instances[i].Instance = ii[i].Name
 		instances[i].IP = ii[i].IP
+		instances[i].Ports = ii[i].Ports
 	}
 
 	enc := json.NewEncoder(w)
@@ -63,6 +69,46 @@ func PrintInstanceList(w io.Writer, name, user string, formatJSON bool) error {
 	return nil
 }
 
+// portsString returns the comma separated list of port mappings
+func portsString(ports []network.PortMapEntry) string {
+	s := make([]string, len(ports))
+	for i, pm := range ports {
+		s[i] = pm.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// PrintInstancePorts prints the ports published by the instance name,
+// one mapping per line in the form containerPort/protocol -> hostIP:hostPort.
+func PrintInstancePorts(w io.Writer, name, user string) error {
+	ii, err := instance.List(user, name, instance.SingSubDir)
+	if err != nil {
+		return fmt.Errorf("could not retrieve instance list: %v", err)
+	}
+	if len(ii) == 0 {
+		return fmt.Errorf("no instance %s found", name)
+	} else if len(ii) > 1 {
+		return fmt.Errorf("multiple instances match %s", name)
+	}
+
+	return writeInstancePorts(w, ii[0].Ports)
+}
+
+// writeInstancePorts writes the published ports, one mapping per line.
+func writeInstancePorts(w io.Writer, ports []network.PortMapEntry) error {
+	for _, pm := range ports {
+		hostIP := pm.HostIP
+		if hostIP == "" {
+			hostIP = "0.0.0.0"
+		}
+		_, err := fmt.Fprintf(w, "%d/%s -> %s\n", pm.ContainerPort, pm.Protocol, net.JoinHostPort(hostIP, strconv.Itoa(pm.HostPort)))
+		if err != nil {
+			return fmt.Errorf("could not write instance port: %v", err)
+		}
+	}
+	return nil
+}
+
 // WriteInstancePidFile fetches instance's PID and writes it to the pidFile,
 // truncating it if it already exists. Note that the name should not be a glob,
 // i.e. name should identify a single instance only, otherwise an error is returned.
@@ -0,0 +1,55 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sylabs/singularity/pkg/network"
+)
+
+func TestWriteInstancePorts(t *testing.T) {
+	tests := []struct {
+		name   string
+		ports  []network.PortMapEntry
+		output string
+	}{
+		{
+			name:   "NoPorts",
+			output: "",
+		},
+		{
+			name: "AllAddresses",
+			ports: []network.PortMapEntry{
+				{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+				{HostPort: 5353, ContainerPort: 53, Protocol: "udp"},
+			},
+			output: "80/tcp -> 0.0.0.0:8080\n53/udp -> 0.0.0.0:5353\n",
+		},
+		{
+			name: "HostIP",
+			ports: []network.PortMapEntry{
+				{HostPort: 8443, ContainerPort: 443, Protocol: "tcp", HostIP: "127.0.0.1"},
+				{HostPort: 8080, ContainerPort: 80, Protocol: "tcp", HostIP: "::1"},
+			},
+			output: "443/tcp -> 127.0.0.1:8443\n80/tcp -> [::1]:8080\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			if err := writeInstancePorts(&b, tt.ports); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if b.String() != tt.output {
+				t.Errorf("unexpected output %q instead of %q", b.String(), tt.output)
+			}
+		})
+	}
+}
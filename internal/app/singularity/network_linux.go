@@ -0,0 +1,389 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/instance"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/network"
+	"github.com/sylabs/singularity/pkg/runtime/engine/config"
+	"github.com/sylabs/singularity/pkg/syfs"
+)
+
+const (
+	systemNetworkScope = "system"
+	userNetworkScope   = "user"
+)
+
+// NetworkInstance describes an instance attached to a network.
+type NetworkInstance struct {
+	Name string `json:"name"`
+	IP   string `json:"ip"`
+}
+
+// NetworkInfo describes a network available to the current user.
+type NetworkInfo struct {
+	Name      string            `json:"name"`
+	Scope     string            `json:"scope"`
+	Type      string            `json:"type"`
+	Subnet    string            `json:"subnet,omitempty"`
+	Bridge    string            `json:"bridge,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Instances []NetworkInstance `json:"instances"`
+}
+
+// systemNetworkDir returns the CNI configuration directory set in
+// singularity.conf.
+func systemNetworkDir() (string, error) {
+	cfg, err := config.ParseFile(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse singularity.conf file: %s", err)
+	}
+	if cfg.CniConfPath == "" {
+		return filepath.Join(buildcfg.SYSCONFDIR, "singularity", "network"), nil
+	}
+	return cfg.CniConfPath, nil
+}
+
+// networkDir returns the directory where the networks created by the
+// current user are stored: the CNI configuration directory for root,
+// the user configuration directory otherwise.
+func networkDir() (string, string, error) {
+	if os.Getuid() == 0 {
+		dir, err := systemNetworkDir()
+		return dir, systemNetworkScope, err
+	}
+	return syfs.NetworkDir(), userNetworkScope, nil
+}
+
+// listNetworks returns the system networks and the networks created by
+// the current user.
+func listNetworks() ([]NetworkInfo, error) {
+	var infos []NetworkInfo
+
+	systemDir, err := systemNetworkDir()
+	if err != nil {
+		return nil, err
+	}
+
+	confLists, err := network.GetAllNetworkConfigList(&network.CNIPath{Conf: systemDir})
+	if _, ok := err.(libcni.NoConfigsFoundError); err != nil && !ok && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("while reading system networks: %s", err)
+	}
+	for _, c := range confLists {
+		info := NetworkInfo{
+			Name:  c.Name,
+			Scope: systemNetworkScope,
+		}
+		if len(c.Plugins) > 0 {
+			var plugin struct {
+				Bridge string `json:"bridge"`
+				IPAM   struct {
+					Subnet string `json:"subnet"`
+				} `json:"ipam"`
+			}
+			json.Unmarshal(c.Plugins[0].Bytes, &plugin)
+
+			info.Type = c.Plugins[0].Network.Type
+			info.Bridge = plugin.Bridge
+			info.Subnet = plugin.IPAM.Subnet
+		}
+		// networks created with 'network create' can be removed
+		if _, err := network.LoadBridgeNetwork(systemDir, c.Name, 0); err == nil {
+			info.Path = network.BridgeNetworkPath(systemDir, c.Name)
+		}
+		infos = append(infos, info)
+	}
+
+	if os.Getuid() != 0 {
+		files, err := filepath.Glob(network.BridgeNetworkPath(syfs.NetworkDir(), "*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+			n, err := network.LoadBridgeNetwork(syfs.NetworkDir(), name, os.Getuid())
+			if err != nil {
+				sylog.Warningf("Ignoring network %s: %s", name, err)
+				continue
+			}
+			infos = append(infos, NetworkInfo{
+				Name:   n.Name,
+				Scope:  userNetworkScope,
+				Type:   "bridge",
+				Subnet: n.Subnet.String(),
+				Bridge: n.Bridge,
+				Path:   f,
+			})
+		}
+	}
+
+	instances, err := instance.List("", "*", instance.SingSubDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve instance list: %s", err)
+	}
+
+	for i := range infos {
+		infos[i].Instances = make([]NetworkInstance, 0)
+		for _, inst := range instances {
+			for _, n := range inst.Networks {
+				if n == infos[i].Name {
+					infos[i].Instances = append(infos[i].Instances, NetworkInstance{inst.Name, inst.IP})
+					break
+				}
+			}
+		}
+	}
+
+	return infos, nil
+}
+
+// getNetwork returns the network name available to the current user,
+// user networks take precedence over system networks.
+func getNetwork(name string) (*NetworkInfo, error) {
+	infos, err := listNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *NetworkInfo
+	for i := range infos {
+		if infos[i].Name == name && (found == nil || infos[i].Scope == userNetworkScope) {
+			found = &infos[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("network %s not found", name)
+	}
+	return found, nil
+}
+
+// IsCreatedNetwork returns true if the network name was created with
+// 'network create', by the current user or by root.
+func IsCreatedNetwork(name string) bool {
+	if os.Getuid() != 0 {
+		if _, err := network.LoadBridgeNetwork(syfs.NetworkDir(), name, os.Getuid()); err == nil {
+			return true
+		}
+	}
+	systemDir, err := systemNetworkDir()
+	if err != nil {
+		return false
+	}
+	_, err = network.LoadBridgeNetwork(systemDir, name, 0)
+	return err == nil
+}
+
+// usedSubnets returns the subnets of the existing networks and of the
+// host interfaces.
+func usedSubnets(infos []NetworkInfo) ([]*net.IPNet, error) {
+	var subnets []*net.IPNet
+
+	for _, info := range infos {
+		if _, subnet, err := net.ParseCIDR(info.Subnet); err == nil {
+			subnets = append(subnets, subnet)
+		}
+	}
+
+	host, err := network.HostSubnets()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(subnets, host...), nil
+}
+
+// subnetPool returns the subnets the bridge networks of the current
+// user must be part of, set in singularity.conf. Root networks can use
+// any private subnet, they are chosen from 10.89.0.0/16 by default.
+func subnetPool() ([]*net.IPNet, bool, error) {
+	if os.Getuid() == 0 {
+		pool, err := network.ParseSubnetPool([]string{"10.89.0.0/16"})
+		return pool, false, err
+	}
+
+	cfg, err := config.ParseFile(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to parse singularity.conf file: %s", err)
+	}
+	pool, err := network.ParseSubnetPool(cfg.BridgeNetworkSubnets)
+	return pool, len(pool) > 0, err
+}
+
+// maxFreeSubnets is the maximum number of /24 subnets of a pool subnet
+// tried by freeSubnet.
+const maxFreeSubnets = 4096
+
+// freeSubnet returns the first /24 subnet of the pool subnets, or the
+// pool subnet itself if it's smaller, which doesn't overlap the used
+// subnets.
+func freeSubnet(pool, used []*net.IPNet) (string, error) {
+	for _, p := range pool {
+		ones, bits := p.Mask.Size()
+		size := 24
+		if ones > size {
+			size = ones
+		}
+		count := 1 << uint(size-ones)
+		if count > maxFreeSubnets {
+			count = maxFreeSubnets
+		}
+
+		base := binary.BigEndian.Uint32(p.IP.To4())
+		for i := 0; i < count; i++ {
+			ip := make(net.IP, net.IPv4len)
+			binary.BigEndian.PutUint32(ip, base+uint32(i)<<uint(bits-size))
+			subnet := &net.IPNet{IP: ip, Mask: net.CIDRMask(size, bits)}
+
+			free := true
+			for _, u := range used {
+				if network.SubnetsOverlap(subnet, u) {
+					free = false
+					break
+				}
+			}
+			if free {
+				return subnet.String(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no free subnet found, use --subnet to specify one")
+}
+
+// NetworkCreate creates the bridge network name using the IPv4 subnet,
+// a free subnet is chosen when subnet is empty. Networks created by root
+// are system networks available to root, networks created by other users
+// are only available to them with --fakeroot.
+func NetworkCreate(name, subnet string) error {
+	dir, scope, err := networkDir()
+	if err != nil {
+		return err
+	}
+
+	infos, err := listNetworks()
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.Name == name {
+			return fmt.Errorf("%s network %s already exists", info.Scope, name)
+		}
+	}
+
+	used, err := usedSubnets(infos)
+	if err != nil {
+		return err
+	}
+	pool, restricted, err := subnetPool()
+	if err != nil {
+		return err
+	}
+	if subnet == "" {
+		subnet, err = freeSubnet(pool, used)
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := network.NewBridgeNetwork(name, subnet, os.Getuid())
+	if err != nil {
+		return err
+	}
+	if restricted && !network.InSubnetPool(n.Subnet, pool) {
+		return fmt.Errorf("subnet %s is not part of the allowed bridge network subnets %v", n.Subnet, pool)
+	}
+	for _, u := range used {
+		if network.SubnetsOverlap(n.Subnet, u) {
+			return fmt.Errorf("subnet %s overlaps with %s used by another network or a host interface", n.Subnet, u)
+		}
+	}
+
+	b, err := n.ConfList()
+	if err != nil {
+		return fmt.Errorf("while generating network configuration: %s", err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("while creating network directory: %s", err)
+	}
+
+	path := network.BridgeNetworkPath(dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("while creating network configuration: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(b); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("while writing network configuration: %s", err)
+	}
+
+	sylog.Infof("Created %s network %s with subnet %s", scope, name, n.Subnet)
+	return nil
+}
+
+// NetworkRemove removes the network name created by the current user,
+// the network must not be used by a running instance.
+func NetworkRemove(name string) error {
+	info, err := getNetwork(name)
+	if err != nil {
+		return err
+	}
+
+	if info.Path == "" || (info.Scope == systemNetworkScope && os.Getuid() != 0) {
+		return fmt.Errorf("network %s was not created by 'network create', it can't be removed", name)
+	}
+	if len(info.Instances) > 0 {
+		names := make([]string, len(info.Instances))
+		for i, inst := range info.Instances {
+			names[i] = inst.Name
+		}
+		return fmt.Errorf("network %s is used by instance(s) %s", name, strings.Join(names, ", "))
+	}
+
+	if err := os.Remove(info.Path); err != nil {
+		return fmt.Errorf("while removing network configuration: %s", err)
+	}
+
+	sylog.Infof("Removed %s network %s", info.Scope, name)
+	return nil
+}
+
+// NetworkList prints the networks available to the current user.
+func NetworkList(w io.Writer) error {
+	infos, err := listNetworks()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%-20s %-8s %-10s %-18s %s\n", "NAME", "SCOPE", "TYPE", "SUBNET", "INSTANCES")
+	for _, info := range infos {
+		fmt.Fprintf(w, "%-20s %-8s %-10s %-18s %d\n", info.Name, info.Scope, info.Type, info.Subnet, len(info.Instances))
+	}
+	return nil
+}
+
+// NetworkInspect prints the description of the network name and of the
+// instances attached to it as a JSON document.
+func NetworkInspect(name string, w io.Writer) error {
+	info, err := getNetwork(name)
+	if err != nil {
+		return err
+	}
+	return printJSON(w, info)
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"testing"
+
+	"github.com/sylabs/singularity/pkg/network"
+)
+
+func TestFreeSubnet(t *testing.T) {
+	tests := []struct {
+		name    string
+		pool    []string
+		used    []string
+		subnet  string
+		wantErr bool
+	}{
+		{name: "First", pool: []string{"10.89.0.0/16"}, subnet: "10.89.0.0/24"},
+		{name: "SkipUsed", pool: []string{"10.89.0.0/16"}, used: []string{"10.89.0.0/23", "10.89.2.1/32"}, subnet: "10.89.3.0/24"},
+		{name: "NextPoolSubnet", pool: []string{"10.89.0.0/24", "172.20.0.0/16"}, used: []string{"10.89.0.0/24"}, subnet: "172.20.0.0/24"},
+		{name: "SmallPoolSubnet", pool: []string{"192.168.7.0/28"}, subnet: "192.168.7.0/28"},
+		{name: "Exhausted", pool: []string{"10.89.0.0/24"}, used: []string{"10.0.0.0/8"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := network.ParseSubnetPool(tt.pool)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			used, err := network.ParseSubnetPool(tt.used)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			subnet, err := freeSubnet(pool, used)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if subnet != tt.subnet {
+				t.Errorf("got subnet %s, want %s", subnet, tt.subnet)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/image"
+	"github.com/sylabs/singularity/pkg/image/packer"
+	"golang.org/x/sys/unix"
+)
+
+// OverlayMinSize is the minimal size of an overlay image, ext3
+// requires some room for its journal.
+const OverlayMinSize = 64 << 20
+
+// sifOverlayName is the name of the overlay partition embedded in
+// a SIF image.
+const sifOverlayName = "overlay"
+
+// createOverlayImage creates at path an ext3 image of size bytes
+// containing the upper and work directories of a writable overlay,
+// owned by the calling user. The image is fully allocated unless
+// sparse is true.
+func createOverlayImage(path string, size int64, sparse bool) error {
+	if size < OverlayMinSize {
+		return fmt.Errorf("overlay size must be at least %d MiB", OverlayMinSize>>20)
+	}
+
+	ext3 := packer.NewExt3()
+	if !ext3.HasMkfs() {
+		return fmt.Errorf("mkfs.ext3 not found, e2fsprogs >= 1.43 is required to create overlay images")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "overlay-")
+	if err != nil {
+		return fmt.Errorf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return fmt.Errorf("while setting temporary directory permissions: %s", err)
+	}
+	for _, d := range []string{"upper", "work"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, d), 0755); err != nil {
+			return fmt.Errorf("while creating overlay %s directory: %s", d, err)
+		}
+	}
+
+	// mkfs.ext3 copies the ownership of the upper and work
+	// directories, the root directory is set explicitly
+	opts := []string{"-E", fmt.Sprintf("root_owner=%d:%d", os.Getuid(), os.Getgid())}
+
+	if err := ext3.Create(tmpDir, path, size, opts); err != nil {
+		return err
+	}
+
+	if !sparse {
+		if err := allocateFile(path, size); err != nil {
+			os.Remove(path)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allocateFile allocates the disk blocks of the first size bytes of
+// the file at path.
+func allocateFile(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %s", path, err)
+	}
+	defer f.Close()
+
+	if err := unix.Fallocate(int(f.Fd()), 0, 0, size); err != nil {
+		return fmt.Errorf("while allocating %d bytes for %s: %s", size, path, err)
+	}
+	return nil
+}
+
+// OverlayCreate creates a writable overlay of size bytes at path. If
+// path is an existing SIF image, the overlay is embedded as a partition
+// of the image, otherwise an ext3 overlay image file is created, fully
+// allocated unless sparse is true. Overlay partitions are always sparse.
+func OverlayCreate(path string, size int64, sparse bool) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := createOverlayImage(path, size, sparse); err != nil {
+			return err
+		}
+		sylog.Infof("Overlay image of %d MiB created at %s", size>>20, path)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("while calling stat on %s: %s", path, err)
+	}
+
+	img, err := image.Init(path, false)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %s", path, err)
+	}
+	img.File.Close()
+
+	if img.Type != image.SIF {
+		return fmt.Errorf("%s already exists and is not a SIF image", path)
+	}
+
+	return overlayCreateSIF(path, size)
+}
+
+// overlayCreateSIF creates a writable overlay partition of size bytes
+// and embeds it in the SIF image path. The overlay partition is added
+// to the group of the primary system partition, the primary partition
+// and its signatures are left untouched. The holes of the partition
+// are preserved in the SIF image.
+func overlayCreateSIF(path string, size int64) error {
+	fimg, err := loadSIF(path, false)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	primDescr, _, err := fimg.GetPartPrimSys()
+	if err != nil {
+		return fmt.Errorf("while looking for primary system partition in %s: %s", path, err)
+	}
+
+	if fstype, err := primDescr.GetFsType(); err == nil && fstype == sif.FsExt3 {
+		return fmt.Errorf("%s root filesystem is already writable", path)
+	}
+
+	for i := range fimg.DescrArr {
+		d := &fimg.DescrArr[i]
+		if !d.Used || d.Datatype != sif.DataPartition || d.Groupid != primDescr.Groupid {
+			continue
+		}
+		if ptype, err := d.GetPartType(); err == nil && ptype == sif.PartOverlay {
+			return fmt.Errorf("%s already contains an overlay partition (descriptor %d)", path, d.ID)
+		}
+	}
+
+	arch, err := primDescr.GetArch()
+	if err != nil {
+		return fmt.Errorf("while reading primary system partition architecture: %s", err)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "sif-overlay-")
+	if err != nil {
+		return fmt.Errorf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	overlayPath := filepath.Join(tmpDir, sifOverlayName)
+	if err := createOverlayImage(overlayPath, size, true); err != nil {
+		return err
+	}
+
+	f, err := os.Open(overlayPath)
+	if err != nil {
+		return fmt.Errorf("while opening overlay image: %s", err)
+	}
+	defer f.Close()
+
+	input := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  primDescr.Groupid,
+		Link:     sif.DescrUnusedLink,
+		Fname:    sifOverlayName,
+		Fp:       sparseReader{f},
+		Size:     size,
+	}
+	if err := input.SetPartExtra(sif.FsExt3, sif.PartOverlay, cString(arch[:])); err != nil {
+		return err
+	}
+
+	if err := fimg.AddObject(input); err != nil {
+		return fmt.Errorf("while adding overlay partition to %s: %s", path, err)
+	}
+
+	sylog.Infof("Writable overlay partition of %d MiB added to %s", size>>20, path)
+	return nil
+}
+
+// sparseReader wraps a reader and implements io.WriterTo to seek over
+// the blocks of zeros instead of writing them when the destination is
+// a file, preserving the holes of a sparse source file.
+type sparseReader struct {
+	r io.Reader
+}
+
+const sparseBlockSize = 4096
+
+func (s sparseReader) Read(p []byte) (int, error) {
+	return s.r.Read(p)
+}
+
+func (s sparseReader) WriteTo(w io.Writer) (int64, error) {
+	f, ok := w.(*os.File)
+	if !ok {
+		return io.Copy(w, s.r)
+	}
+
+	var (
+		n    int64
+		hole bool
+		zero [sparseBlockSize]byte
+	)
+
+	buf := make([]byte, 256*sparseBlockSize)
+
+	for {
+		nr, err := io.ReadFull(s.r, buf)
+
+		for off := 0; off < nr; off += sparseBlockSize {
+			end := off + sparseBlockSize
+			if end > nr {
+				end = nr
+			}
+			block := buf[off:end]
+
+			if bytes.Equal(block, zero[:len(block)]) {
+				if _, err := f.Seek(int64(len(block)), io.SeekCurrent); err != nil {
+					return n, err
+				}
+				hole = true
+			} else {
+				if _, err := f.Write(block); err != nil {
+					return n, err
+				}
+				hole = false
+			}
+			n += int64(len(block))
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return n, err
+		}
+	}
+
+	// a trailing hole doesn't extend the file, set its size
+	// up to the current offset
+	if hole {
+		off, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return n, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			return n, err
+		}
+		if fi.Size() < off {
+			if err := f.Truncate(off); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
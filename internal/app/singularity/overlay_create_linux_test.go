@@ -0,0 +1,113 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/test"
+	"github.com/sylabs/singularity/pkg/image/packer"
+)
+
+func TestOverlayCreateSIF(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	if !packer.NewExt3().HasMkfs() {
+		t.Skip("mkfs.ext3 not found")
+	}
+
+	dir, err := ioutil.TempDir("", "overlay-create-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "image.sif")
+
+	if err := SIFNew(image); err != nil {
+		t.Fatalf("failed to create SIF image: %s", err)
+	}
+
+	// a squashfs v4 super block with zlib compression is enough
+	// to pass the image format checks
+	superblock := make([]byte, 4096)
+	copy(superblock, "hsqs")
+	binary.LittleEndian.PutUint16(superblock[20:], 1)
+	binary.LittleEndian.PutUint16(superblock[28:], 4)
+	rootfs := writeDataFile(t, dir, "rootfs", string(superblock))
+	opts := SIFObjectOptions{
+		Datatype: "partition",
+		Parttype: "primsys",
+		Fstype:   "squashfs",
+		Groupid:  1,
+	}
+	if err := SIFAdd(image, rootfs, opts); err != nil {
+		t.Fatalf("failed to add root filesystem partition: %s", err)
+	}
+
+	const size = 256 << 20
+
+	if err := OverlayCreate(image, OverlayMinSize-1, true); err == nil {
+		t.Errorf("unexpected success with an overlay smaller than %d bytes", OverlayMinSize)
+	}
+	if err := OverlayCreate(image, size, true); err != nil {
+		t.Fatalf("failed to create overlay partition: %s", err)
+	}
+	if err := OverlayCreate(image, size, true); err == nil {
+		t.Errorf("unexpected success while adding a second overlay partition")
+	}
+
+	fimg, err := sif.LoadContainer(image, true)
+	if err != nil {
+		t.Fatalf("failed to load SIF image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	parts, _, err := fimg.GetPartFromGroup(sif.DescrDefaultGroup)
+	if err != nil {
+		t.Fatalf("failed to get partitions: %s", err)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("unexpected number of partitions in group: %d", len(parts))
+	}
+
+	overlay := parts[1]
+	if ptype, _ := overlay.GetPartType(); ptype != sif.PartOverlay {
+		t.Errorf("unexpected partition type %d", ptype)
+	}
+	if fstype, _ := overlay.GetFsType(); fstype != sif.FsExt3 {
+		t.Errorf("unexpected file system type %d", fstype)
+	}
+	if overlay.Filelen != size {
+		t.Errorf("unexpected overlay size %d instead of %d", overlay.Filelen, size)
+	}
+	if prim, _, err := fimg.GetPartPrimSys(); err != nil || prim.ID != parts[0].ID {
+		t.Errorf("primary partition changed")
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(image, &st); err != nil {
+		t.Fatalf("failed to stat %s: %s", image, err)
+	}
+	if st.Blocks*512 >= size {
+		t.Errorf("overlay partition is not sparse: %d bytes allocated", st.Blocks*512)
+	}
+
+	info := overlayInfo(t, image)
+	if info.Partition != sifOverlayName || info.Size != size {
+		t.Errorf("unexpected overlay partition %q of %d bytes", info.Partition, info.Size)
+	}
+	if info.Allocated >= size {
+		t.Errorf("overlay partition is fully allocated")
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/image"
+	"github.com/sylabs/singularity/pkg/image/packer"
+	"golang.org/x/sys/unix"
+)
+
+// ext3SuperBlockOffset is the offset of the ext3 super block from
+// the beginning of the filesystem.
+const ext3SuperBlockOffset = 1024
+
+// lseek whence values to find data and holes in sparse files, not
+// defined by the golang.org/x/sys/unix version in use
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+// ext3SuperBlock represents the beginning of an ext3 super block.
+type ext3SuperBlock struct {
+	InodesCount     uint32
+	BlocksCount     uint32
+	RBlocksCount    uint32
+	FreeBlocksCount uint32
+	FreeInodesCount uint32
+	FirstDataBlock  uint32
+	LogBlockSize    uint32
+	_               [7]uint32
+	Magic           [2]byte
+}
+
+// OverlayInfo describes an ext3 writable overlay.
+type OverlayInfo struct {
+	// Path of the overlay image file.
+	Path string
+	// Partition is the name of the overlay partition for an
+	// overlay embedded in a SIF image, empty otherwise.
+	Partition string
+	// Size of the overlay and space allocated on disk in bytes.
+	Size      int64
+	Allocated int64
+	// Filesystem capacity and usage.
+	BlockSize  uint64
+	Blocks     uint64
+	FreeBlocks uint64
+	Inodes     uint32
+	FreeInodes uint32
+	// InUse is true if a container is currently writing into the
+	// overlay.
+	InUse bool
+}
+
+// readExt3SuperBlock reads the super block of the ext3 filesystem
+// starting at offset in the image file.
+func readExt3SuperBlock(f *os.File, offset int64) (*ext3SuperBlock, error) {
+	sb := &ext3SuperBlock{}
+
+	r := io.NewSectionReader(f, offset+ext3SuperBlockOffset, int64(binary.Size(sb)))
+	if err := binary.Read(r, binary.LittleEndian, sb); err != nil {
+		return nil, fmt.Errorf("while reading ext3 super block: %s", err)
+	}
+	if !bytes.Equal(sb.Magic[:], []byte{0x53, 0xef}) {
+		return nil, fmt.Errorf("bad ext3 super block magic")
+	}
+
+	return sb, nil
+}
+
+// allocatedBytes returns the number of bytes allocated on disk for the
+// range of size bytes starting at offset in the file.
+func allocatedBytes(f *os.File, offset, size int64) (int64, error) {
+	var allocated int64
+
+	fd := int(f.Fd())
+	end := offset + size
+
+	for pos := offset; pos < end; {
+		data, err := unix.Seek(fd, pos, seekData)
+		if err == unix.ENXIO {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		if data >= end {
+			break
+		}
+		hole, err := unix.Seek(fd, data, seekHole)
+		if err != nil {
+			return 0, err
+		}
+		if hole > end {
+			hole = end
+		}
+		allocated += hole - data
+		pos = hole
+	}
+
+	return allocated, nil
+}
+
+// overlayAllocatedBytes returns the disk space allocated for the
+// overlay section of the image. Preallocated blocks are reported as
+// holes by lseek, the block count of the file is used for overlay
+// image files.
+func overlayAllocatedBytes(img *image.Image, s image.Section) (int64, error) {
+	if img.Type != image.EXT3 {
+		return allocatedBytes(img.File, int64(s.Offset), int64(s.Size))
+	}
+
+	var st unix.Stat_t
+	if err := unix.Fstat(int(img.File.Fd()), &st); err != nil {
+		return 0, err
+	}
+	return st.Blocks * 512, nil
+}
+
+// overlayInfos returns the description of the ext3 overlay image file,
+// or of the ext3 overlay partitions of the SIF image file.
+func overlayInfos(path string) ([]OverlayInfo, error) {
+	img, err := image.Init(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("while opening %s: %s", path, err)
+	}
+	defer img.File.Close()
+
+	var sections []image.Section
+
+	switch img.Type {
+	case image.EXT3:
+		sections = img.Partitions
+	case image.SIF:
+		for _, p := range img.Partitions[1:] {
+			if p.Type == image.EXT3 {
+				sections = append(sections, p)
+			}
+		}
+		if len(sections) == 0 {
+			return nil, fmt.Errorf("no overlay partition found in %s", path)
+		}
+	default:
+		return nil, fmt.Errorf("%s is not an ext3 overlay image nor a SIF image", path)
+	}
+
+	infos := make([]OverlayInfo, 0, len(sections))
+
+	for _, s := range sections {
+		sb, err := readExt3SuperBlock(img.File, int64(s.Offset))
+		if err != nil {
+			return nil, err
+		}
+
+		allocated, err := overlayAllocatedBytes(img, s)
+		if err != nil {
+			return nil, fmt.Errorf("while computing allocated space: %s", err)
+		}
+
+		info := OverlayInfo{
+			Path:       path,
+			Size:       int64(s.Size),
+			Allocated:  allocated,
+			BlockSize:  1024 << sb.LogBlockSize,
+			Blocks:     uint64(sb.BlocksCount),
+			FreeBlocks: uint64(sb.FreeBlocksCount),
+			Inodes:     sb.InodesCount,
+			FreeInodes: sb.FreeInodesCount,
+		}
+		if img.Type == image.SIF {
+			info.Partition = s.Name
+		}
+
+		// a read lock can't be acquired while a container holds
+		// the write lock of the overlay
+		if err := img.LockSection(s); err != nil {
+			info.InUse = true
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// OverlayInspect prints the size, allocation and usage of the ext3
+// overlay image file, or of the overlay partitions of the SIF image
+// file, to w.
+func OverlayInspect(path string, w io.Writer) error {
+	infos, err := overlayInfos(path)
+	if err != nil {
+		return err
+	}
+
+	for i, info := range infos {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		used := (info.Blocks - info.FreeBlocks) * info.BlockSize
+		total := info.Blocks * info.BlockSize
+		// a corrupted super block may report no block
+		percent := uint64(0)
+		if total > 0 {
+			percent = used * 100 / total
+		}
+
+		if info.Partition != "" {
+			fmt.Fprintf(w, "Overlay:    %s (SIF partition %s)\n", info.Path, info.Partition)
+		} else {
+			fmt.Fprintf(w, "Overlay:    %s\n", info.Path)
+		}
+		fmt.Fprintf(w, "Size:       %d MiB (%d MiB allocated)\n", info.Size>>20, info.Allocated>>20)
+		fmt.Fprintf(w, "Used:       %d MiB / %d MiB (%d%%)\n", used>>20, total>>20, percent)
+		fmt.Fprintf(w, "Inodes:     %d / %d\n", info.Inodes-info.FreeInodes, info.Inodes)
+		if info.InUse {
+			fmt.Fprintln(w, "In use:     yes")
+		} else {
+			fmt.Fprintln(w, "In use:     no")
+		}
+	}
+
+	return nil
+}
+
+// OverlayResize resizes the ext3 overlay image file to size bytes, the
+// overlay must not be in use by a container while resized.
+func OverlayResize(path string, size int64) error {
+	if size < OverlayMinSize {
+		return fmt.Errorf("overlay size must be at least %d MiB", OverlayMinSize>>20)
+	}
+
+	ext3 := packer.NewExt3()
+	if !ext3.HasResize() {
+		return fmt.Errorf("e2fsck or resize2fs not found, e2fsprogs is required to resize overlay images")
+	}
+
+	img, err := image.Init(path, true)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %s", path, err)
+	}
+	defer img.File.Close()
+
+	switch img.Type {
+	case image.EXT3:
+	case image.SIF:
+		return fmt.Errorf("resizing overlay partitions embedded in a SIF image is not supported")
+	default:
+		return fmt.Errorf("%s is not an ext3 overlay image", path)
+	}
+
+	if img.Partitions[0].Offset != 0 {
+		return fmt.Errorf("%s has a launch script header, resizing is not supported", path)
+	}
+
+	// hold the write lock until the image is resized
+	if err := img.LockSection(img.Partitions[0]); err != nil {
+		return err
+	}
+
+	if err := ext3.Resize(path, size); err != nil {
+		return fmt.Errorf("while resizing %s: %s", path, err)
+	}
+
+	sylog.Infof("Overlay image %s resized to %d MiB", path, size>>20)
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/test"
+	"github.com/sylabs/singularity/pkg/image/packer"
+)
+
+func overlayInfo(t *testing.T, path string) OverlayInfo {
+	infos, err := overlayInfos(path)
+	if err != nil {
+		t.Fatalf("failed to inspect overlay %s: %s", path, err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("unexpected number of overlays: %d", len(infos))
+	}
+	return infos[0]
+}
+
+func TestOverlayImage(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	ext3 := packer.NewExt3()
+	if !ext3.HasMkfs() || !ext3.HasResize() {
+		t.Skip("e2fsprogs not found")
+	}
+
+	dir, err := ioutil.TempDir("", "overlay-image-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name       string
+		size       int64
+		sparse     bool
+		resize     int64
+		fullyAlloc bool
+	}{
+		{"Sparse", 128 << 20, true, 256 << 20, false},
+		{"Allocated", 128 << 20, false, 96 << 20, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".img")
+
+			if err := OverlayCreate(path, tt.size, tt.sparse); err != nil {
+				t.Fatalf("failed to create overlay: %s", err)
+			}
+			if err := OverlayCreate(path, tt.size, tt.sparse); err == nil {
+				t.Fatalf("unexpected success while overwriting %s", path)
+			}
+
+			info := overlayInfo(t, path)
+			if info.Size != tt.size {
+				t.Errorf("unexpected size %d instead of %d", info.Size, tt.size)
+			}
+			if (info.Allocated >= info.Size) != tt.fullyAlloc {
+				t.Errorf("unexpected allocated size %d for an overlay of %d bytes", info.Allocated, info.Size)
+			}
+			if info.InUse {
+				t.Errorf("overlay reported in use")
+			}
+
+			if err := OverlayResize(path, tt.resize); err != nil {
+				t.Fatalf("failed to resize overlay: %s", err)
+			}
+
+			info = overlayInfo(t, path)
+			if info.Size != tt.resize {
+				t.Errorf("unexpected size %d after resize instead of %d", info.Size, tt.resize)
+			}
+			if int64(info.Blocks*info.BlockSize) != tt.resize {
+				t.Errorf("filesystem not resized: %d blocks of %d bytes", info.Blocks, info.BlockSize)
+			}
+		})
+	}
+
+	if err := OverlayResize(filepath.Join(dir, "Sparse.img"), OverlayMinSize-1); err == nil {
+		t.Errorf("unexpected success while resizing below %d bytes", OverlayMinSize)
+	}
+}
@@ -61,7 +61,7 @@ func LibraryPush(ctx context.Context, file, dest, authToken, libraryURI, keyServ
 		return fmt.Errorf("unable to open: %v: %v", file, err)
 	}
 
-	arch, err := sifArch(file)
+	archs, err := sifArchs(file)
 	if err != nil {
 		return err
 	}
@@ -103,17 +103,60 @@ func LibraryPush(ctx context.Context, file, dest, authToken, libraryURI, keyServ
 	}
 	defer f.Close()
 
-	return libraryClient.UploadImage(ctx, f, r.Host+r.Path, arch, r.Tags, "No Description", &progressCallback{})
+	// multi-architecture images are uploaded for each of their
+	// architectures, the runtime selects the matching partition
+	for _, arch := range archs {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("error rewinding image %s: %v", file, err)
+		}
+		if len(archs) > 1 {
+			sylog.Infof("Uploading image for %s", arch)
+		}
+		if err := libraryClient.UploadImage(ctx, f, r.Host+r.Path, arch, r.Tags, "No Description", &progressCallback{}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func sifArch(filename string) (string, error) {
+// sifArchs returns the architectures of the SIF image, the one of its
+// primary system partition first followed by the architectures of the
+// other system partitions of multi-architecture images.
+func sifArchs(filename string) ([]string, error) {
 	fimg, err := sif.LoadContainer(filename, true)
 	if err != nil {
-		return "", fmt.Errorf("unable to open: %v: %v", filename, err)
+		return nil, fmt.Errorf("unable to open: %v: %v", filename, err)
 	}
+	defer fimg.UnloadContainer()
+
 	arch := sif.GetGoArch(string(fimg.Header.Arch[:sif.HdrArchLen-1]))
 	if arch == "unknown" {
-		return arch, fmt.Errorf("unknown architecture in SIF file")
+		return nil, fmt.Errorf("unknown architecture in SIF file")
+	}
+	archs := []string{arch}
+
+	for _, d := range fimg.DescrArr {
+		if !d.Used || d.Datatype != sif.DataPartition {
+			continue
+		}
+		if ptype, err := d.GetPartType(); err != nil || ptype != sif.PartSystem {
+			continue
+		}
+		b, err := d.GetArch()
+		if err != nil {
+			continue
+		}
+		arch := sif.GetGoArch(string(b[:sif.HdrArchLen-1]))
+		if arch == "unknown" {
+			continue
+		}
+		known := false
+		for _, a := range archs {
+			known = known || a == arch
+		}
+		if !known {
+			archs = append(archs, arch)
+		}
 	}
-	return arch, nil
+	return archs, nil
 }
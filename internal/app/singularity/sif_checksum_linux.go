@@ -0,0 +1,172 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+// SIFObjectChecksum holds the SHA256 checksum of a SIF data object.
+type SIFObjectChecksum struct {
+	ID       uint32
+	Checksum string
+}
+
+// checkObjectBounds returns an error if the data object described by
+// d doesn't fit within the data section of the SIF image.
+func checkObjectBounds(fimg *sif.FileImage, d *sif.Descriptor) error {
+	if d.Fileoff < fimg.Header.Dataoff || d.Filelen < 0 {
+		return fmt.Errorf("data object %d has an invalid offset or size", d.ID)
+	}
+	if d.Fileoff+d.Filelen > fimg.Filesize {
+		return fmt.Errorf("data object %d ends at %d beyond the end of the file (%d), image may be truncated", d.ID, d.Fileoff+d.Filelen, fimg.Filesize)
+	}
+	return nil
+}
+
+// SIFChecksums computes the SHA256 checksums of all the data objects
+// of the SIF image file, ordered by descriptor ID.
+func SIFChecksums(file string) ([]SIFObjectChecksum, error) {
+	fimg, err := loadSIF(file, true)
+	if err != nil {
+		return nil, err
+	}
+	defer fimg.UnloadContainer()
+
+	var checksums []SIFObjectChecksum
+
+	for i := range fimg.DescrArr {
+		d := &fimg.DescrArr[i]
+		if !d.Used {
+			continue
+		}
+
+		if err := checkObjectBounds(fimg, d); err != nil {
+			return nil, err
+		}
+
+		if _, err := fimg.Fp.Seek(d.Fileoff, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("while seeking to data object %d: %s", d.ID, err)
+		}
+
+		h := sha256.New()
+		if _, err := io.CopyN(h, fimg.Fp, d.Filelen); err != nil {
+			return nil, fmt.Errorf("while reading data object %d: %s", d.ID, err)
+		}
+
+		checksums = append(checksums, SIFObjectChecksum{
+			ID:       d.ID,
+			Checksum: hex.EncodeToString(h.Sum(nil)),
+		})
+	}
+
+	return checksums, nil
+}
+
+// SIFChecksum prints the SHA256 checksums of the data objects of the
+// SIF image file, one "<checksum>  <descriptor ID>" line per object.
+func SIFChecksum(file string) error {
+	checksums, err := SIFChecksums(file)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range checksums {
+		fmt.Printf("%s  %d\n", c.Checksum, c.ID)
+	}
+
+	return nil
+}
+
+// readChecksums parses a checksum file as written by SIFChecksum.
+func readChecksums(r io.Reader) (map[uint32]string, error) {
+	checksums := make(map[uint32]string)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<checksum> <descriptor ID>\"", line)
+		}
+
+		id, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid descriptor ID %s", line, fields[1])
+		}
+
+		checksums[uint32(id)] = strings.ToLower(fields[0])
+	}
+
+	return checksums, scanner.Err()
+}
+
+// SIFVerifyChecksums verifies that the data objects of the SIF image
+// file lie within the file and, when checksumFile is not empty, that
+// their content matches the checksums it contains.
+func SIFVerifyChecksums(file, checksumFile string) error {
+	checksums, err := SIFChecksums(file)
+	if err != nil {
+		return err
+	}
+
+	if checksumFile == "" {
+		fmt.Printf("%d data object(s) verified\n", len(checksums))
+		return nil
+	}
+
+	f, err := os.Open(checksumFile)
+	if err != nil {
+		return fmt.Errorf("while opening checksum file: %s", err)
+	}
+	defer f.Close()
+
+	expected, err := readChecksums(f)
+	if err != nil {
+		return fmt.Errorf("while reading checksum file %s: %s", checksumFile, err)
+	}
+
+	failed := 0
+
+	for _, c := range checksums {
+		e, ok := expected[c.ID]
+		if !ok {
+			continue
+		}
+		delete(expected, c.ID)
+
+		if e != c.Checksum {
+			fmt.Printf("%d: FAILED\n", c.ID)
+			failed++
+			continue
+		}
+		fmt.Printf("%d: OK\n", c.ID)
+	}
+
+	for id := range expected {
+		fmt.Printf("%d: MISSING\n", id)
+		failed++
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d data object(s) failed verification", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,351 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/pkg/util/crypt"
+)
+
+// names of the SIF types accepted on the command line and displayed
+// in JSON documents, all SIF types are int32 values
+var (
+	sifDatatypes = map[string]int32{
+		"deffile":       int32(sif.DataDeffile),
+		"envvar":        int32(sif.DataEnvVar),
+		"labels":        int32(sif.DataLabels),
+		"partition":     int32(sif.DataPartition),
+		"signature":     int32(sif.DataSignature),
+		"json":          int32(sif.DataGenericJSON),
+		"generic":       int32(sif.DataGeneric),
+		"cryptomessage": int32(sif.DataCryptoMessage),
+	}
+
+	sifFstypes = map[string]int32{
+		"squashfs":          int32(sif.FsSquash),
+		"ext3":              int32(sif.FsExt3),
+		"immuobj":           int32(sif.FsImmuObj),
+		"raw":               int32(sif.FsRaw),
+		"encryptedsquashfs": int32(sif.FsEncryptedSquashfs),
+	}
+
+	sifParttypes = map[string]int32{
+		"system":  int32(sif.PartSystem),
+		"primsys": int32(sif.PartPrimSys),
+		"data":    int32(sif.PartData),
+		"overlay": int32(sif.PartOverlay),
+	}
+
+	sifHashtypes = map[string]int32{
+		"sha256":  int32(sif.HashSHA256),
+		"sha384":  int32(sif.HashSHA384),
+		"sha512":  int32(sif.HashSHA512),
+		"blake2s": int32(sif.HashBLAKE2S),
+		"blake2b": int32(sif.HashBLAKE2B),
+	}
+
+	sifFormattypes = map[string]int32{
+		"openpgp": int32(sif.FormatOpenPGP),
+		"pem":     int32(sif.FormatPEM),
+	}
+
+	sifMessagetypes = map[string]int32{
+		"clearsignature":  int32(sif.MessageClearSignature),
+		"rsaoaep":         int32(sif.MessageRSAOAEP),
+		"pgpencryptedkey": int32(crypt.MessagePGPEncryptedKey),
+	}
+)
+
+// sifTypeName returns the name of value in the names map, or its
+// numerical representation if it's not a known value.
+func sifTypeName(names map[string]int32, value int32) string {
+	for k, v := range names {
+		if v == value {
+			return k
+		}
+	}
+	return fmt.Sprintf("%d", value)
+}
+
+// sifTypeValue returns the value of the type name in the names map.
+func sifTypeValue(names map[string]int32, kind string, name string) (int32, error) {
+	if v, ok := names[strings.ToLower(name)]; ok {
+		return v, nil
+	}
+
+	valid := make([]string, 0, len(names))
+	for k := range names {
+		valid = append(valid, k)
+	}
+	sort.Strings(valid)
+
+	return 0, fmt.Errorf("unknown %s %q, valid values are: %s", kind, name, strings.Join(valid, ", "))
+}
+
+// sifPartitionJSON describes the partition specific fields of a
+// SIF descriptor.
+type sifPartitionJSON struct {
+	Fstype   string `json:"fstype"`
+	Parttype string `json:"parttype"`
+	Arch     string `json:"arch"`
+}
+
+// sifSignatureJSON describes the signature specific fields of a
+// SIF descriptor.
+type sifSignatureJSON struct {
+	Hashtype string `json:"hashtype"`
+	Entity   string `json:"entity"`
+}
+
+// sifCryptoMessageJSON describes the cryptographic message specific
+// fields of a SIF descriptor.
+type sifCryptoMessageJSON struct {
+	Formattype  string `json:"formattype"`
+	Messagetype string `json:"messagetype"`
+}
+
+// sifDescriptorJSON is the JSON representation of a SIF descriptor.
+type sifDescriptorJSON struct {
+	ID            uint32                `json:"id"`
+	Datatype      string                `json:"datatype"`
+	Groupid       uint32                `json:"groupid,omitempty"`
+	Link          uint32                `json:"link,omitempty"`
+	LinkIsGroup   bool                  `json:"linkIsGroup,omitempty"`
+	Name          string                `json:"name"`
+	Offset        int64                 `json:"offset"`
+	Size          int64                 `json:"size"`
+	Ctime         time.Time             `json:"ctime"`
+	Mtime         time.Time             `json:"mtime"`
+	UID           int64                 `json:"uid"`
+	GID           int64                 `json:"gid"`
+	Partition     *sifPartitionJSON     `json:"partition,omitempty"`
+	Signature     *sifSignatureJSON     `json:"signature,omitempty"`
+	CryptoMessage *sifCryptoMessageJSON `json:"cryptoMessage,omitempty"`
+}
+
+// sifImageJSON is the JSON representation of a SIF global header and
+// of its descriptors.
+type sifImageJSON struct {
+	ID          string              `json:"id"`
+	Version     string              `json:"version"`
+	Arch        string              `json:"arch"`
+	Ctime       time.Time           `json:"ctime"`
+	Mtime       time.Time           `json:"mtime"`
+	Dfree       int64               `json:"descriptorsFree"`
+	Dtotal      int64               `json:"descriptorsTotal"`
+	Descriptors []sifDescriptorJSON `json:"descriptors"`
+}
+
+func cString(b []byte) string {
+	if n := strings.IndexByte(string(b), 0); n >= 0 {
+		return string(b[:n])
+	}
+	return string(b)
+}
+
+func sifDescriptorToJSON(d *sif.Descriptor) (sifDescriptorJSON, error) {
+	j := sifDescriptorJSON{
+		ID:       d.ID,
+		Datatype: sifTypeName(sifDatatypes, int32(d.Datatype)),
+		Name:     d.GetName(),
+		Offset:   d.Fileoff,
+		Size:     d.Filelen,
+		Ctime:    time.Unix(d.Ctime, 0).UTC(),
+		Mtime:    time.Unix(d.Mtime, 0).UTC(),
+		UID:      d.UID,
+		GID:      d.Gid,
+	}
+
+	if d.Groupid != sif.DescrUnusedGroup {
+		j.Groupid = d.Groupid &^ sif.DescrGroupMask
+	}
+	if d.Link != sif.DescrUnusedLink {
+		j.Link = d.Link &^ sif.DescrGroupMask
+		j.LinkIsGroup = d.Link&sif.DescrGroupMask == sif.DescrGroupMask
+	}
+
+	switch d.Datatype {
+	case sif.DataPartition:
+		fs, err := d.GetFsType()
+		if err != nil {
+			return j, err
+		}
+		part, err := d.GetPartType()
+		if err != nil {
+			return j, err
+		}
+		arch, err := d.GetArch()
+		if err != nil {
+			return j, err
+		}
+		j.Partition = &sifPartitionJSON{
+			Fstype:   sifTypeName(sifFstypes, int32(fs)),
+			Parttype: sifTypeName(sifParttypes, int32(part)),
+			Arch:     sif.GetGoArch(cString(arch[:])),
+		}
+	case sif.DataSignature:
+		hash, err := d.GetHashType()
+		if err != nil {
+			return j, err
+		}
+		entity, err := d.GetEntityString()
+		if err != nil {
+			return j, err
+		}
+		j.Signature = &sifSignatureJSON{
+			Hashtype: sifTypeName(sifHashtypes, int32(hash)),
+			Entity:   entity,
+		}
+	case sif.DataCryptoMessage:
+		format, err := d.GetFormatType()
+		if err != nil {
+			return j, err
+		}
+		message, err := d.GetMessageType()
+		if err != nil {
+			return j, err
+		}
+		j.CryptoMessage = &sifCryptoMessageJSON{
+			Formattype:  sifTypeName(sifFormattypes, int32(format)),
+			Messagetype: sifTypeName(sifMessagetypes, int32(message)),
+		}
+	}
+
+	return j, nil
+}
+
+// loadSIF loads the SIF image file, the returned image must be
+// unloaded by the caller.
+func loadSIF(file string, rdonly bool) (*sif.FileImage, error) {
+	fimg, err := sif.LoadContainer(file, rdonly)
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF image %s: %s", file, err)
+	}
+	return &fimg, nil
+}
+
+// SIFHeader displays the global header of the SIF image file.
+func SIFHeader(file string) error {
+	fimg, err := loadSIF(file, true)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	fmt.Print(fimg.FmtHeader())
+	return nil
+}
+
+// SIFList lists the descriptors of the SIF image file, as a table or
+// as a JSON document when asJSON is true.
+func SIFList(file string, asJSON bool) error {
+	fimg, err := loadSIF(file, true)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	if !asJSON {
+		fmt.Println("Container id:", fimg.Header.ID)
+		fmt.Println("Created on:  ", time.Unix(fimg.Header.Ctime, 0).UTC())
+		fmt.Println("Modified on: ", time.Unix(fimg.Header.Mtime, 0).UTC())
+		fmt.Println("----------------------------------------------------")
+		fmt.Println("Descriptor list:")
+		fmt.Print(fimg.FmtDescrList())
+		return nil
+	}
+
+	img := sifImageJSON{
+		ID:          fimg.Header.ID.String(),
+		Version:     cString(fimg.Header.Version[:]),
+		Arch:        sif.GetGoArch(cString(fimg.Header.Arch[:])),
+		Ctime:       time.Unix(fimg.Header.Ctime, 0).UTC(),
+		Mtime:       time.Unix(fimg.Header.Mtime, 0).UTC(),
+		Dfree:       fimg.Header.Dfree,
+		Dtotal:      fimg.Header.Dtotal,
+		Descriptors: make([]sifDescriptorJSON, 0),
+	}
+
+	for i := range fimg.DescrArr {
+		if !fimg.DescrArr[i].Used {
+			continue
+		}
+		d, err := sifDescriptorToJSON(&fimg.DescrArr[i])
+		if err != nil {
+			return fmt.Errorf("while reading descriptor %d: %s", fimg.DescrArr[i].ID, err)
+		}
+		img.Descriptors = append(img.Descriptors, d)
+	}
+
+	return printJSON(os.Stdout, img)
+}
+
+// SIFInfo displays the descriptor id of the SIF image file, as text
+// or as a JSON document when asJSON is true.
+func SIFInfo(id uint32, file string, asJSON bool) error {
+	fimg, err := loadSIF(file, true)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	descr, _, err := fimg.GetFromDescrID(id)
+	if err != nil {
+		return fmt.Errorf("while looking for descriptor %d: %s", id, err)
+	}
+
+	if !asJSON {
+		fmt.Print(fimg.FmtDescrInfo(id))
+		return nil
+	}
+
+	d, err := sifDescriptorToJSON(descr)
+	if err != nil {
+		return fmt.Errorf("while reading descriptor %d: %s", id, err)
+	}
+
+	return printJSON(os.Stdout, d)
+}
+
+// SIFDump writes the data object id of the SIF image file to w.
+func SIFDump(id uint32, file string, w io.Writer) error {
+	fimg, err := loadSIF(file, true)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	descr, _, err := fimg.GetFromDescrID(id)
+	if err != nil {
+		return fmt.Errorf("while looking for descriptor %d: %s", id, err)
+	}
+
+	if _, err := fimg.Fp.Seek(descr.Fileoff, io.SeekStart); err != nil {
+		return fmt.Errorf("while seeking to data object %d: %s", id, err)
+	}
+	if _, err := io.CopyN(w, fimg.Fp, descr.Filelen); err != nil {
+		return fmt.Errorf("while dumping data object %d: %s", id, err)
+	}
+
+	return nil
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return fmt.Errorf("while marshaling JSON: %s", err)
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
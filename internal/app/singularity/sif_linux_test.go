@@ -0,0 +1,144 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/test"
+)
+
+func writeDataFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+	return path
+}
+
+func dumpObject(t *testing.T, id uint32, image string) string {
+	var buf bytes.Buffer
+	if err := SIFDump(id, image, &buf); err != nil {
+		t.Fatalf("failed to dump data object %d: %s", id, err)
+	}
+	return buf.String()
+}
+
+func TestSIFObjects(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	dir, err := ioutil.TempDir("", "sif-objects-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "image.sif")
+
+	if err := SIFNew(image); err != nil {
+		t.Fatalf("failed to create SIF image: %s", err)
+	}
+
+	deffile := writeDataFile(t, dir, "Singularity", "Bootstrap: docker\nFrom: alpine\n")
+	labels := writeDataFile(t, dir, "labels.json", `{"version": "1"}`)
+	newLabels := writeDataFile(t, dir, "labels-new.json", `{"version": "2", "patched": "yes"}`)
+	generic := writeDataFile(t, dir, "data", "some data")
+
+	objects := []struct {
+		file string
+		opts SIFObjectOptions
+	}{
+		{deffile, SIFObjectOptions{Datatype: "deffile"}},
+		{labels, SIFObjectOptions{Datatype: "labels", Link: 1}},
+		{generic, SIFObjectOptions{Datatype: "generic", Name: "custom"}},
+	}
+
+	for _, o := range objects {
+		if err := SIFAdd(image, o.file, o.opts); err != nil {
+			t.Fatalf("failed to add %s: %s", o.file, err)
+		}
+	}
+
+	if err := SIFAdd(image, generic, SIFObjectOptions{Datatype: "unknown"}); err == nil {
+		t.Fatalf("unexpected success while adding data object with unknown type")
+	}
+	if err := SIFAdd(image, generic, SIFObjectOptions{Datatype: "partition", Parttype: "data"}); err == nil {
+		t.Fatalf("unexpected success while adding partition with undetectable file system")
+	}
+
+	// free the first descriptor slot, the replaced data object
+	// must keep its ID anyway
+	if err := SIFDelete(1, image, true); err != nil {
+		t.Fatalf("failed to delete data object: %s", err)
+	}
+	if err := SIFDelete(1, image, false); err == nil {
+		t.Fatalf("unexpected success while deleting a deleted data object")
+	}
+	if err := SIFReplace(2, image, newLabels, ""); err != nil {
+		t.Fatalf("failed to replace data object: %s", err)
+	}
+
+	fimg, err := sif.LoadContainer(image, true)
+	if err != nil {
+		t.Fatalf("failed to load SIF image: %s", err)
+	}
+	descr, _, err := fimg.GetFromDescrID(2)
+	if err != nil {
+		fimg.UnloadContainer()
+		t.Fatalf("replaced data object not found: %s", err)
+	}
+	if descr.Datatype != sif.DataLabels || descr.Link != 1 || descr.GetName() != "labels.json" {
+		fimg.UnloadContainer()
+		t.Fatalf("replaced data object attributes not preserved")
+	}
+	if _, _, err := fimg.GetFromDescrID(1); err == nil {
+		fimg.UnloadContainer()
+		t.Fatalf("replaced data object allocated a new descriptor")
+	}
+	d3, _, err := fimg.GetFromDescrID(3)
+	if err != nil || d3.GetName() != "custom" || d3.Datatype != sif.DataGeneric {
+		fimg.UnloadContainer()
+		t.Fatalf("unexpected generic data object")
+	}
+	fimg.UnloadContainer()
+
+	if s := dumpObject(t, 2, image); s != `{"version": "2", "patched": "yes"}` {
+		t.Fatalf("unexpected replaced data object content: %s", s)
+	}
+	if s := dumpObject(t, 3, image); s != "some data" {
+		t.Fatalf("unexpected data object content: %s", s)
+	}
+
+	checksums, err := SIFChecksums(image)
+	if err != nil {
+		t.Fatalf("failed to compute checksums: %s", err)
+	}
+	if len(checksums) != 2 {
+		t.Fatalf("unexpected number of checksums: %d instead of 2", len(checksums))
+	}
+
+	var good, bad bytes.Buffer
+	for _, c := range checksums {
+		fmt.Fprintf(&good, "%s  %d\n", c.Checksum, c.ID)
+		fmt.Fprintf(&bad, "%s  %d\n", checksums[0].Checksum, c.ID)
+	}
+	goodFile := writeDataFile(t, dir, "good.sha256", good.String())
+	badFile := writeDataFile(t, dir, "bad.sha256", bad.String())
+
+	if err := SIFVerifyChecksums(image, goodFile); err != nil {
+		t.Fatalf("unexpected verification failure: %s", err)
+	}
+	if err := SIFVerifyChecksums(image, badFile); err == nil {
+		t.Fatalf("unexpected verification success with wrong checksums")
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/build/assemblers"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// mergedDatatypes are the types of the data objects copied from the
+// first image by SIFMerge along with the system partitions.
+var mergedDatatypes = map[sif.Datatype]bool{
+	sif.DataDeffile:     true,
+	sif.DataEnvVar:      true,
+	sif.DataLabels:      true,
+	sif.DataGenericJSON: true,
+	sif.DataGeneric:     true,
+}
+
+// sifSystemPartitions returns the system partitions of the loaded SIF
+// image by architecture, the primary one first.
+func sifSystemPartitions(fimg *sif.FileImage) ([]*sif.Descriptor, []string, error) {
+	var descrs []*sif.Descriptor
+	var archs []string
+
+	for i := range fimg.DescrArr {
+		d := &fimg.DescrArr[i]
+		if !d.Used || d.Datatype != sif.DataPartition {
+			continue
+		}
+		ptype, err := d.GetPartType()
+		if err != nil {
+			return nil, nil, err
+		}
+		if ptype != sif.PartPrimSys && ptype != sif.PartSystem {
+			continue
+		}
+		fstype, err := d.GetFsType()
+		if err != nil {
+			return nil, nil, err
+		}
+		if fstype == sif.FsEncryptedSquashfs {
+			return nil, nil, fmt.Errorf("encrypted partitions can't be merged")
+		}
+		b, err := d.GetArch()
+		if err != nil {
+			return nil, nil, err
+		}
+		arch := sif.GetGoArch(cString(b[:]))
+		if arch == "unknown" {
+			return nil, nil, fmt.Errorf("system partition %d has no known architecture", d.ID)
+		}
+
+		if ptype == sif.PartPrimSys {
+			descrs = append([]*sif.Descriptor{d}, descrs...)
+			archs = append([]string{arch}, archs...)
+		} else {
+			descrs = append(descrs, d)
+			archs = append(archs, arch)
+		}
+	}
+
+	if len(descrs) == 0 {
+		return nil, nil, fmt.Errorf("no system partition found")
+	}
+	return descrs, archs, nil
+}
+
+// partitionName returns the name of the system partition of arch with
+// the file system type fstype in a merged image, the names of the source
+// images are temporary file names.
+func partitionName(arch string, fstype sif.Fstype) string {
+	switch fstype {
+	case sif.FsSquash:
+		return arch + ".squashfs"
+	case sif.FsExt3:
+		return arch + ".ext3"
+	}
+	return arch + ".img"
+}
+
+// mergeID returns the ID of a reproducible merged image, derived from
+// the descriptors and the content of its data objects.
+func mergeID(inputs []sif.DescriptorInput) (uuid.UUID, error) {
+	h := sha256.New()
+
+	for _, in := range inputs {
+		fmt.Fprintf(h, "%d:%d:%s:%d:", in.Datatype, in.Groupid, in.Fname, in.Size)
+		h.Write(in.Extra.Bytes())
+		// read a copy of the section, its offset is
+		// used to create the image
+		r := io.NewSectionReader(in.Fp.(io.ReaderAt), 0, in.Size)
+		if _, err := io.Copy(h, r); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	return uuid.NewV5(uuid.NamespaceOID, hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// SIFMerge creates the multi-architecture SIF image file holding the
+// system partitions of the SIF images srcs, one per architecture and
+// each in its own group. The primary system partition of the first
+// image stays the primary one, the definition file, labels and other
+// metadata are copied from the first image. Signatures are not copied
+// as they don't apply to the merged image, it has to be signed again.
+// If epoch is not nil, the merged image is reproducible: its ID is
+// derived from the merged data objects and epoch is recorded in place
+// of the current time.
+func SIFMerge(file string, srcs []string, epoch *time.Time) error {
+	var inputs []sif.DescriptorInput
+	seen := make(map[string]string)
+
+	for i, src := range srcs {
+		fimg, err := loadSIF(src, true)
+		if err != nil {
+			return err
+		}
+		defer fimg.UnloadContainer()
+
+		descrs, archs, err := sifSystemPartitions(fimg)
+		if err != nil {
+			return fmt.Errorf("while reading system partitions of %s: %s", src, err)
+		}
+
+		fp, err := os.Open(src)
+		if err != nil {
+			return fmt.Errorf("while opening %s: %s", src, err)
+		}
+		defer fp.Close()
+
+		if i == 0 {
+			for j := range fimg.DescrArr {
+				d := &fimg.DescrArr[j]
+				if !d.Used || !mergedDatatypes[d.Datatype] {
+					continue
+				}
+				if err := checkObjectBounds(fimg, d); err != nil {
+					return fmt.Errorf("while reading %s: %s", src, err)
+				}
+				inputs = append(inputs, sif.DescriptorInput{
+					Datatype: d.Datatype,
+					Groupid:  d.Groupid,
+					Link:     sif.DescrUnusedLink,
+					Size:     d.Filelen,
+					Fname:    d.GetName(),
+					Fp:       io.NewSectionReader(fp, d.Fileoff, d.Filelen),
+				})
+			}
+		}
+
+		for j, d := range descrs {
+			if prev, ok := seen[archs[j]]; ok {
+				return fmt.Errorf("both %s and %s hold a %s system partition", prev, src, archs[j])
+			}
+			seen[archs[j]] = src
+
+			if err := checkObjectBounds(fimg, d); err != nil {
+				return fmt.Errorf("while reading %s: %s", src, err)
+			}
+			fstype, err := d.GetFsType()
+			if err != nil {
+				return fmt.Errorf("while reading %s: %s", src, err)
+			}
+
+			ptype := sif.PartSystem
+			if len(seen) == 1 {
+				ptype = sif.PartPrimSys
+			}
+
+			// each architecture gets its own group so overlay
+			// partitions apply to a single root filesystem, the
+			// primary one keeps the default group
+			input := sif.DescriptorInput{
+				Datatype: sif.DataPartition,
+				Groupid:  sif.DescrGroupMask | uint32(len(seen)),
+				Link:     sif.DescrUnusedLink,
+				Size:     d.Filelen,
+				Fname:    partitionName(archs[j], fstype),
+				Fp:       io.NewSectionReader(fp, d.Fileoff, d.Filelen),
+			}
+			if err := input.SetPartExtra(fstype, ptype, sif.GetSIFArch(archs[j])); err != nil {
+				return fmt.Errorf("while setting %s partition information: %s", archs[j], err)
+			}
+			inputs = append(inputs, input)
+		}
+	}
+
+	cinfo := sif.CreateInfo{
+		Pathname:   file,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: inputs,
+	}
+	if epoch != nil {
+		id, err := mergeID(inputs)
+		if err != nil {
+			return fmt.Errorf("while computing image ID: %s", err)
+		}
+		cinfo.ID = id
+	}
+
+	if _, err := sif.CreateContainer(cinfo); err != nil {
+		os.Remove(file)
+		return fmt.Errorf("while creating SIF image %s: %s", file, err)
+	}
+
+	if epoch != nil {
+		if err := assemblers.SetSIFTimes(file, *epoch); err != nil {
+			os.Remove(file)
+			return fmt.Errorf("while setting image times: %s", err)
+		}
+	}
+
+	sylog.Infof("Created %s with %d system partitions", file, len(seen))
+	return nil
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/test"
+	"github.com/sylabs/singularity/pkg/image"
+)
+
+const testSquash = "../../../pkg/image/testdata/squashfs.v4"
+
+func createArchSIF(t *testing.T, dir, arch string, deffile bool) string {
+	path := filepath.Join(dir, arch+".sif")
+
+	if err := SIFNew(path); err != nil {
+		t.Fatalf("failed to create SIF image: %s", err)
+	}
+	if deffile {
+		def := writeDataFile(t, dir, "Singularity", "Bootstrap: docker\nFrom: alpine\n")
+		if err := SIFAdd(path, def, SIFObjectOptions{Datatype: "deffile"}); err != nil {
+			t.Fatalf("failed to add definition file: %s", err)
+		}
+	}
+	opts := SIFObjectOptions{
+		Datatype: "partition",
+		Parttype: "primsys",
+		Fstype:   "squashfs",
+		Arch:     arch,
+	}
+	// the build names the partition after its temporary file
+	squash, err := ioutil.TempFile(dir, "squashfs-")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %s", err)
+	}
+	defer os.Remove(squash.Name())
+	b, err := ioutil.ReadFile(testSquash)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", testSquash, err)
+	}
+	if _, err := squash.Write(b); err != nil {
+		t.Fatalf("failed to write %s: %s", squash.Name(), err)
+	}
+	squash.Close()
+
+	if err := SIFAdd(path, squash.Name(), opts); err != nil {
+		t.Fatalf("failed to add %s partition: %s", arch, err)
+	}
+	return path
+}
+
+func TestSIFMerge(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	dir, err := ioutil.TempDir("", "sif-merge-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	amd64 := createArchSIF(t, dir, "amd64", true)
+	arm64 := createArchSIF(t, dir, "arm64", false)
+	empty := filepath.Join(dir, "empty.sif")
+	if err := SIFNew(empty); err != nil {
+		t.Fatalf("failed to create SIF image: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		srcs    []string
+		wantErr bool
+	}{
+		{name: "TwoArchitectures", srcs: []string{amd64, arm64}},
+		{name: "DuplicateArchitecture", srcs: []string{amd64, amd64}, wantErr: true},
+		{name: "NoSystemPartition", srcs: []string{amd64, empty}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := filepath.Join(dir, tt.name+".sif")
+			err := SIFMerge(merged, tt.srcs, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			fimg, err := loadSIF(merged, true)
+			if err != nil {
+				t.Fatalf("failed to load merged image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			if _, _, err := fimg.GetFromDescr(sif.Descriptor{Datatype: sif.DataDeffile}); err != nil {
+				t.Errorf("definition file not found in merged image: %s", err)
+			}
+
+			descrs, archs, err := sifSystemPartitions(fimg)
+			if err != nil {
+				t.Fatalf("failed to get system partitions: %s", err)
+			}
+			if len(archs) != 2 || archs[0] != "amd64" || archs[1] != "arm64" {
+				t.Fatalf("unexpected system partitions %v", archs)
+			}
+
+			if descrs[0].Groupid != sif.DescrDefaultGroup || descrs[1].Groupid == descrs[0].Groupid {
+				t.Errorf("unexpected system partition groups %d and %d", descrs[0].Groupid, descrs[1].Groupid)
+			}
+
+			// an overlay of the primary system partition group
+			// must not apply to the other architectures
+			opts := SIFObjectOptions{
+				Datatype: "partition",
+				Parttype: "overlay",
+				Fstype:   "squashfs",
+				Arch:     archs[0],
+				Groupid:  descrs[0].Groupid &^ sif.DescrGroupMask,
+			}
+			if err := SIFAdd(merged, testSquash, opts); err != nil {
+				t.Fatalf("failed to add overlay partition: %s", err)
+			}
+
+			for i, arch := range archs {
+				img, err := image.InitArch(merged, false, arch)
+				if err != nil {
+					t.Fatalf("failed to open %s root filesystem: %s", arch, err)
+				}
+				img.File.Close()
+				if img.Partitions[0].Offset != uint64(descrs[i].Fileoff) {
+					t.Errorf("unexpected %s root filesystem at offset %d", arch, img.Partitions[0].Offset)
+				}
+				if n := len(img.Partitions); (i == 0 && n != 2) || (i > 0 && n != 1) {
+					t.Errorf("unexpected %d %s partitions", n, arch)
+				}
+			}
+
+			pushArchs, err := sifArchs(merged)
+			if err != nil {
+				t.Fatalf("failed to get image architectures: %s", err)
+			}
+			if len(pushArchs) != 2 || pushArchs[0] != "amd64" || pushArchs[1] != "arm64" {
+				t.Errorf("unexpected image architectures %v", pushArchs)
+			}
+
+			if _, err := image.InitArch(merged, false, "s390x"); err == nil {
+				t.Errorf("unexpected success with missing architecture")
+			}
+		})
+	}
+}
+
+func TestSIFMergeReproducible(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	dir, err := ioutil.TempDir("", "sif-merge-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	epoch := time.Unix(1500000000, 0)
+
+	// the source images of each merge are created separately,
+	// they only share their content
+	var images [][]byte
+	for _, name := range []string{"first", "second"} {
+		srcDir := filepath.Join(dir, name)
+		if err := os.Mkdir(srcDir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %s", srcDir, err)
+		}
+		srcs := []string{
+			createArchSIF(t, srcDir, "amd64", true),
+			createArchSIF(t, srcDir, "arm64", false),
+		}
+
+		merged := filepath.Join(dir, name+".sif")
+		if err := SIFMerge(merged, srcs, &epoch); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		b, err := ioutil.ReadFile(merged)
+		if err != nil {
+			t.Fatalf("failed to read merged image: %s", err)
+		}
+		images = append(images, b)
+		// the creation time must not depend on the current time
+		time.Sleep(1100 * time.Millisecond)
+	}
+
+	if !bytes.Equal(images[0], images[1]) {
+		t.Errorf("merged images differ")
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/image"
+)
+
+// SIFObjectOptions describes the data object added to a SIF image.
+type SIFObjectOptions struct {
+	// Datatype is the name of the data object type.
+	Datatype string
+	// Parttype, Fstype and Arch describe a partition, Fstype is
+	// detected from the data file when empty and Arch defaults to
+	// the host architecture.
+	Parttype string
+	Fstype   string
+	Arch     string
+	// Hashtype and Entity describe a signature.
+	Hashtype string
+	Entity   string
+	// Groupid is the group of the data object, 0 for none.
+	Groupid uint32
+	// Link is the ID of the descriptor the data object is linked
+	// to, LinkGroup the ID of the group, 0 for none.
+	Link      uint32
+	LinkGroup uint32
+	// Alignment of the data object in the image, 0 for page size.
+	Alignment int
+	// Name of the data object, defaults to the data file name.
+	Name string
+}
+
+// detectFstype returns the SIF file system type of the partition
+// image file.
+func detectFstype(file string) (sif.Fstype, error) {
+	img, err := image.Init(file, false)
+	if err != nil {
+		return 0, fmt.Errorf("while detecting file system type of %s: %s", file, err)
+	}
+	defer img.File.Close()
+
+	switch img.Type {
+	case image.SQUASHFS:
+		return sif.FsSquash, nil
+	case image.EXT3:
+		return sif.FsExt3, nil
+	}
+
+	return 0, fmt.Errorf("unsupported file system type for %s, use --partfs to specify it", file)
+}
+
+// descriptorInput returns the descriptor input for the data file
+// described by opts.
+func (opts SIFObjectOptions) descriptorInput(dataFile string) (sif.DescriptorInput, error) {
+	var input sif.DescriptorInput
+
+	datatype, err := sifTypeValue(sifDatatypes, "data type", opts.Datatype)
+	if err != nil {
+		return input, err
+	}
+
+	if opts.Link != 0 && opts.LinkGroup != 0 {
+		return input, fmt.Errorf("a data object can't be linked to both a descriptor and a group")
+	}
+
+	input = sif.DescriptorInput{
+		Datatype:  sif.Datatype(datatype),
+		Groupid:   sif.DescrGroupMask | opts.Groupid,
+		Link:      opts.Link,
+		Alignment: opts.Alignment,
+		Fname:     dataFile,
+	}
+	if opts.LinkGroup != 0 {
+		input.Link = sif.DescrGroupMask | opts.LinkGroup
+	}
+	if opts.Name != "" {
+		input.Fname = opts.Name
+	}
+
+	switch input.Datatype {
+	case sif.DataPartition:
+		if opts.Parttype == "" {
+			return input, fmt.Errorf("partition type is required for partition data objects")
+		}
+		parttype, err := sifTypeValue(sifParttypes, "partition type", opts.Parttype)
+		if err != nil {
+			return input, err
+		}
+
+		var fstype sif.Fstype
+		if opts.Fstype == "" {
+			fstype, err = detectFstype(dataFile)
+		} else {
+			var v int32
+			v, err = sifTypeValue(sifFstypes, "file system type", opts.Fstype)
+			fstype = sif.Fstype(v)
+		}
+		if err != nil {
+			return input, err
+		}
+
+		goarch := opts.Arch
+		if goarch == "" {
+			goarch = runtime.GOARCH
+		}
+		arch := sif.GetSIFArch(goarch)
+		if arch == sif.HdrArchUnknown {
+			return input, fmt.Errorf("unsupported architecture %s", goarch)
+		}
+
+		if err := input.SetPartExtra(fstype, sif.Parttype(parttype), arch); err != nil {
+			return input, err
+		}
+	case sif.DataSignature:
+		if opts.Hashtype == "" || opts.Entity == "" {
+			return input, fmt.Errorf("hash type and entity are required for signature data objects")
+		}
+		hashtype, err := sifTypeValue(sifHashtypes, "hash type", opts.Hashtype)
+		if err != nil {
+			return input, err
+		}
+		if err := input.SetSignExtra(sif.Hashtype(hashtype), opts.Entity); err != nil {
+			return input, err
+		}
+	}
+
+	return input, nil
+}
+
+// SIFNew creates an empty SIF image file.
+func SIFNew(file string) error {
+	cinfo := sif.CreateInfo{
+		Pathname:   file,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+	}
+
+	if _, err := sif.CreateContainer(cinfo); err != nil {
+		return fmt.Errorf("while creating SIF image %s: %s", file, err)
+	}
+
+	return nil
+}
+
+// SIFAdd adds the content of dataFile as a new data object described
+// by opts to the SIF image file.
+func SIFAdd(file, dataFile string, opts SIFObjectOptions) error {
+	input, err := opts.descriptorInput(dataFile)
+	if err != nil {
+		return err
+	}
+
+	fp, err := os.Open(dataFile)
+	if err != nil {
+		return fmt.Errorf("while opening data file: %s", err)
+	}
+	defer fp.Close()
+
+	fi, err := fp.Stat()
+	if err != nil {
+		return fmt.Errorf("while calling stat on data file: %s", err)
+	}
+
+	input.Fp = fp
+	input.Size = fi.Size()
+
+	fimg, err := loadSIF(file, false)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	index := freeDescriptorIndex(fimg)
+
+	if err := fimg.AddObject(input); err != nil {
+		return fmt.Errorf("while adding data object to %s: %s", file, err)
+	}
+
+	sylog.Infof("Data object %d added to %s", fimg.DescrArr[index].ID, file)
+	return nil
+}
+
+// deleteObject deletes the data object id from the loaded SIF image
+// and returns the index of its now free descriptor.
+func deleteObject(fimg *sif.FileImage, id uint32, zero bool) (int, error) {
+	_, index, err := fimg.GetFromDescrID(id)
+	if err != nil {
+		return -1, fmt.Errorf("while looking for descriptor %d: %s", id, err)
+	}
+
+	// by default the image is compacted when the data object is
+	// the last one, otherwise its data is left in place
+	flags := 0
+	if zero {
+		flags = sif.DelZero
+	}
+
+	if err := fimg.DeleteObject(id, flags); err != nil {
+		return -1, fmt.Errorf("while deleting data object %d: %s", id, err)
+	}
+
+	// the sif package only resets the descriptor on disk
+	fimg.DescrArr[index] = sif.Descriptor{}
+
+	return index, nil
+}
+
+// SIFDelete deletes the data object id from the SIF image file, the
+// data object content is overwritten with zeros when zero is true.
+func SIFDelete(id uint32, file string, zero bool) error {
+	fimg, err := loadSIF(file, false)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	if _, err := deleteObject(fimg, id, zero); err != nil {
+		return err
+	}
+
+	sylog.Infof("Data object %d deleted from %s", id, file)
+	return nil
+}
+
+// SIFReplace replaces the content of the data object id of the SIF
+// image file by the content of dataFile. The data object keeps its ID,
+// type, group, link and name, unless name is not empty.
+func SIFReplace(id uint32, file, dataFile, name string) error {
+	fp, err := os.Open(dataFile)
+	if err != nil {
+		return fmt.Errorf("while opening data file: %s", err)
+	}
+	defer fp.Close()
+
+	fi, err := fp.Stat()
+	if err != nil {
+		return fmt.Errorf("while calling stat on data file: %s", err)
+	}
+
+	fimg, err := loadSIF(file, false)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	descr, _, err := fimg.GetFromDescrID(id)
+	if err != nil {
+		return fmt.Errorf("while looking for descriptor %d: %s", id, err)
+	}
+
+	input := sif.DescriptorInput{
+		Datatype: descr.Datatype,
+		Groupid:  descr.Groupid,
+		Link:     descr.Link,
+		Fname:    descr.GetName(),
+		Fp:       fp,
+		Size:     fi.Size(),
+	}
+	if name != "" {
+		input.Fname = name
+	}
+	input.Extra.Write(descr.Extra[:])
+
+	index, err := deleteObject(fimg, id, false)
+	if err != nil {
+		return err
+	}
+
+	newIndex := freeDescriptorIndex(fimg)
+
+	if err := fimg.AddObject(input); err != nil {
+		return fmt.Errorf("while adding data object to %s: %s", file, err)
+	}
+
+	// the new descriptor may have been allocated in a lower free
+	// slot, move it back to the original slot to keep its ID and
+	// the links pointing to it
+	if newIndex != index {
+		fimg.DescrArr[index] = fimg.DescrArr[newIndex]
+		fimg.DescrArr[index].ID = id
+		fimg.DescrArr[newIndex] = sif.Descriptor{}
+		if fimg.PrimPartID == uint32(newIndex)+1 {
+			fimg.PrimPartID = id
+		}
+
+		if err := writeDescriptors(fimg); err != nil {
+			return fmt.Errorf("while writing descriptors to %s: %s", file, err)
+		}
+	}
+
+	sylog.Infof("Data object %d replaced in %s", id, file)
+	return nil
+}
+
+// SIFSetPrimary sets the system partition id as the primary system
+// partition of the SIF image file.
+func SIFSetPrimary(id uint32, file string) error {
+	fimg, err := loadSIF(file, false)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	if _, _, err := fimg.GetFromDescrID(id); err != nil {
+		return fmt.Errorf("while looking for descriptor %d: %s", id, err)
+	}
+
+	if err := fimg.SetPrimPart(id); err != nil {
+		return fmt.Errorf("while setting primary partition: %s", err)
+	}
+
+	return nil
+}
+
+// freeDescriptorIndex returns the index of the descriptor the sif
+// package will allocate for the next data object.
+func freeDescriptorIndex(fimg *sif.FileImage) int {
+	for i := range fimg.DescrArr {
+		if !fimg.DescrArr[i].Used {
+			return i
+		}
+	}
+	return len(fimg.DescrArr) - 1
+}
+
+// writeDescriptors writes the descriptor table of a loaded SIF image,
+// the sif package doesn't expose a way to update descriptors in place.
+func writeDescriptors(fimg *sif.FileImage) error {
+	if _, err := fimg.Fp.Seek(fimg.Header.Descroff, io.SeekStart); err != nil {
+		return err
+	}
+	for _, d := range fimg.DescrArr {
+		if err := binary.Write(fimg.Fp, binary.LittleEndian, d); err != nil {
+			return err
+		}
+	}
+	return fimg.Fp.Sync()
+}
@@ -6,14 +6,18 @@
 package assemblers
 
 import (
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"regexp"
 	"runtime"
 	"strconv"
 	"syscall"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/sylabs/sif/pkg/sif"
@@ -34,7 +38,93 @@ type encryptionOptions struct {
 	plaintext []byte
 }
 
-func createSIF(path string, definition, ociConf []byte, squashfile string, encOpts *encryptionOptions) (err error) {
+// squashfsTimeOffset is the offset of the creation time
+// in the squashfs superblock.
+const squashfsTimeOffset = 8
+
+// squashfsName is the name of the system partition descriptor
+// of reproducible images, in place of the temporary file name.
+const squashfsName = "squashfs.img"
+
+// setSquashfsTime sets the creation time of the squashfs image
+// at path, older mksquashfs don't allow to set it.
+func setSquashfsTime(path string, t time.Time) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(t.Unix()))
+	if _, err := f.WriteAt(b, squashfsTimeOffset); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// contentID returns an image ID derived from the image content,
+// so the same content always gets the same ID.
+func contentID(definition, ociConf []byte, squashfile string) (uuid.UUID, error) {
+	h := sha256.New()
+	h.Write(definition)
+	h.Write(ociConf)
+
+	f, err := os.Open(squashfile)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.NewV5(uuid.NamespaceOID, hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// SetSIFTimes sets the creation and modification times of the SIF
+// image at path and of its data objects, the data objects ownership
+// is reset to root too.
+func SetSIFTimes(path string, t time.Time) error {
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		return err
+	}
+	defer fimg.UnloadContainer()
+
+	fimg.Header.Ctime = t.Unix()
+	fimg.Header.Mtime = t.Unix()
+
+	for i := range fimg.DescrArr {
+		if !fimg.DescrArr[i].Used {
+			continue
+		}
+		fimg.DescrArr[i].Ctime = t.Unix()
+		fimg.DescrArr[i].Mtime = t.Unix()
+		fimg.DescrArr[i].UID = 0
+		fimg.DescrArr[i].Gid = 0
+	}
+
+	// the sif package doesn't expose a way to update
+	// the header and the descriptors in place
+	if _, err := fimg.Fp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(fimg.Fp, binary.LittleEndian, fimg.Header); err != nil {
+		return err
+	}
+	if _, err := fimg.Fp.Seek(fimg.Header.Descroff, io.SeekStart); err != nil {
+		return err
+	}
+	for _, d := range fimg.DescrArr {
+		if err := binary.Write(fimg.Fp, binary.LittleEndian, d); err != nil {
+			return err
+		}
+	}
+	return fimg.Fp.Sync()
+}
+
+func createSIF(path, arch string, definition, ociConf []byte, squashfile string, encOpts *encryptionOptions, epoch *time.Time) (err error) {
 	// general info for the new SIF file creation
 	cinfo := sif.CreateInfo{
 		Pathname:   path,
@@ -43,6 +133,13 @@ func createSIF(path string, definition, ociConf []byte, squashfile string, encOp
 		ID:         uuid.NewV4(),
 	}
 
+	if epoch != nil {
+		cinfo.ID, err = contentID(definition, ociConf, squashfile)
+		if err != nil {
+			return fmt.Errorf("while computing image ID: %s", err)
+		}
+	}
+
 	// data we need to create a definition file descriptor
 	definput := sif.DescriptorInput{
 		Datatype: sif.DataDeffile,
@@ -77,8 +174,11 @@ func createSIF(path string, definition, ociConf []byte, squashfile string, encOp
 		Link:     sif.DescrUnusedLink,
 		Fname:    squashfile,
 	}
+	if epoch != nil {
+		parinput.Fname = squashfsName
+	}
 	// open up the data object file for this descriptor
-	fp, err := os.Open(parinput.Fname)
+	fp, err := os.Open(squashfile)
 	if err != nil {
 		return fmt.Errorf("while opening partition file: %s", err)
 	}
@@ -99,7 +199,7 @@ func createSIF(path string, definition, ociConf []byte, squashfile string, encOp
 		sifType = sif.FsEncryptedSquashfs
 	}
 
-	err = parinput.SetPartExtra(sifType, sif.PartPrimSys, sif.GetSIFArch(runtime.GOARCH))
+	err = parinput.SetPartExtra(sifType, sif.PartPrimSys, sif.GetSIFArch(arch))
 	if err != nil {
 		return
 	}
@@ -108,23 +208,33 @@ func createSIF(path string, definition, ociConf []byte, squashfile string, encOp
 	cinfo.InputDescr = append(cinfo.InputDescr, parinput)
 
 	if encOpts != nil {
-		data, err := crypt.EncryptKey(encOpts.keyInfo, encOpts.plaintext)
+		// the key is wrapped separately for each recipient so
+		// recipients can be added or removed later without
+		// re-encrypting the file system
+		recipients, err := crypt.WrapKey(encOpts.keyInfo, encOpts.plaintext)
 		if err != nil {
 			return fmt.Errorf("while encrypting filesystem key: %s", err)
 		}
 
-		if data != nil {
-			syspartID := uint32(len(cinfo.InputDescr))
+		syspartID := uint32(len(cinfo.InputDescr))
+
+		for _, r := range recipients {
 			part := sif.DescriptorInput{
 				Datatype: sif.DataCryptoMessage,
 				Groupid:  sif.DescrDefaultGroup,
 				Link:     syspartID,
-				Data:     data,
-				Size:     int64(len(data)),
+				Data:     r.Data,
+				Size:     int64(len(r.Data)),
+				Fname:    r.ID,
+			}
+
+			format, message, err := crypt.MessageType(encOpts.keyInfo.Format)
+			if err != nil {
+				return err
 			}
 
 			// extra data needed for the creation of a signature descriptor
-			err := part.SetCryptoMsgExtra(sif.FormatPEM, sif.MessageRSAOAEP)
+			err = part.SetCryptoMsgExtra(format, message)
 			if err != nil {
 				return err
 			}
@@ -141,6 +251,12 @@ func createSIF(path string, definition, ociConf []byte, squashfile string, encOp
 		return fmt.Errorf("while creating container: %s", err)
 	}
 
+	if epoch != nil {
+		if err := SetSIFTimes(path, *epoch); err != nil {
+			return fmt.Errorf("while setting image times: %s", err)
+		}
+	}
+
 	// chown the sif file to the calling user
 	if uid, gid, ok := changeOwner(); ok {
 		if err := os.Chown(path, uid, gid); err != nil {
@@ -176,11 +292,22 @@ func (a *SIFAssembler) Assemble(b *types.Bundle, path string) error {
 	if a.GzipFlag {
 		flags = append(flags, "-comp", "gzip")
 	}
+	// older mksquashfs may order fragments differently
+	// when compressing with multiple processors
+	if b.Opts.SourceDateEpoch != nil {
+		flags = append(flags, "-processors", "1")
+	}
 
 	if err := s.Create([]string{b.RootfsPath}, fsPath, flags); err != nil {
 		return fmt.Errorf("while creating squashfs: %v", err)
 	}
 
+	if b.Opts.SourceDateEpoch != nil {
+		if err := setSquashfsTime(fsPath, *b.Opts.SourceDateEpoch); err != nil {
+			return fmt.Errorf("while setting squashfs time: %v", err)
+		}
+	}
+
 	var encOpts *encryptionOptions
 
 	if b.Opts.EncryptionKeyInfo != nil {
@@ -189,20 +316,28 @@ func (a *SIFAssembler) Assemble(b *types.Bundle, path string) error {
 			return fmt.Errorf("unable to obtain encryption key: %+v", err)
 		}
 
-		// A dm-crypt device needs to be created with squashfs
-		cryptDev := &crypt.Device{}
-
-		// TODO (schebro): Fix #3876
-		// Detach the following code from the squashfs creation. SIF can be
-		// created first and encrypted after. This gives the flexibility to
-		// encrypt an existing SIF
-		loopPath, err := cryptDev.EncryptFilesystem(fsPath, plaintext)
+		var cryptPath string
+
+		if b.Opts.EncryptionKeyInfo.Format == crypt.PGP {
+			// PGP encrypted images are decrypted in user space
+			// and don't require a dm-crypt device
+			cryptPath, err = crypt.EncryptFile(fsPath, plaintext)
+		} else {
+			// A dm-crypt device needs to be created with squashfs
+			cryptDev := &crypt.Device{}
+
+			// TODO (schebro): Fix #3876
+			// Detach the following code from the squashfs creation. SIF can be
+			// created first and encrypted after. This gives the flexibility to
+			// encrypt an existing SIF
+			cryptPath, err = cryptDev.EncryptFilesystem(fsPath, plaintext)
+		}
 		if err != nil {
 			return fmt.Errorf("unable to encrypt filesystem at %s: %+v", fsPath, err)
 		}
-		defer os.Remove(loopPath)
+		defer os.Remove(cryptPath)
 
-		fsPath = loopPath
+		fsPath = cryptPath
 
 		encOpts = &encryptionOptions{
 			keyInfo:   *b.Opts.EncryptionKeyInfo,
@@ -211,7 +346,12 @@ func (a *SIFAssembler) Assemble(b *types.Bundle, path string) error {
 
 	}
 
-	err = createSIF(path, b.Recipe.Raw, b.JSONObjects[types.OCIConfigJSON], fsPath, encOpts)
+	// the image targets the architecture of its bootstrap image
+	arch := b.Opts.Arch
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+	err = createSIF(path, arch, b.Recipe.Raw, b.JSONObjects[types.OCIConfigJSON], fsPath, encOpts, b.Opts.SourceDateEpoch)
 	if err != nil {
 		return fmt.Errorf("while creating SIF: %v", err)
 	}
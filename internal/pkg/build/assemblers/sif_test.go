@@ -6,11 +6,15 @@
 package assemblers_test
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sylabs/singularity/internal/pkg/build/assemblers"
 	"github.com/sylabs/singularity/internal/pkg/build/sources"
@@ -18,6 +22,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/test"
 	testCache "github.com/sylabs/singularity/internal/pkg/test/tool/cache"
 	"github.com/sylabs/singularity/pkg/build/types"
+	"github.com/sylabs/singularity/pkg/build/types/parser"
 	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
 )
 
@@ -132,3 +137,71 @@ func TestSIFAssemblerShub(t *testing.T) {
 
 	defer os.Remove(assemblerShubDest)
 }
+
+// TestSIFAssemblerReproducible checks that the same definition built twice
+// with the same source date epoch gives the same SIF image.
+func TestSIFAssemblerReproducible(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	mksquashfsPath, err := exec.LookPath("mksquashfs")
+	if err != nil {
+		t.Skipf("could not find mksquashfs: %v", err)
+	}
+
+	epoch := time.Unix(1500000000, 0)
+
+	build := func(path string) {
+		b, err := types.NewBundle(filepath.Join(os.TempDir(), "sbuild-SIFAssembler"), os.TempDir())
+		if err != nil {
+			t.Fatalf("unable to make bundle: %v", err)
+		}
+		defer b.Remove()
+
+		b.Recipe, err = parser.ParseDefinitionFile(strings.NewReader("Bootstrap: scratch\n\n%runscript\n    echo ok\n"))
+		if err != nil {
+			t.Fatalf("unable to parse definition: %v", err)
+		}
+		b.Opts.SourceDateEpoch = &epoch
+
+		file := filepath.Join(b.RootfsPath, "file")
+		if err := ioutil.WriteFile(file, []byte("content"), 0644); err != nil {
+			t.Fatalf("unable to write %s: %v", file, err)
+		}
+		for _, p := range []string{file, b.RootfsPath} {
+			if err := os.Chtimes(p, epoch, epoch); err != nil {
+				t.Fatalf("unable to set %s times: %v", p, err)
+			}
+		}
+
+		a := &assemblers.SIFAssembler{
+			MksquashfsPath: mksquashfsPath,
+		}
+		if err := a.Assemble(b, path); err != nil {
+			t.Fatalf("failed to assemble %s: %v", path, err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "sif-reproducible-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := filepath.Join(dir, "first.sif")
+	second := filepath.Join(dir, "second.sif")
+	build(first)
+	build(second)
+
+	b1, err := ioutil.ReadFile(first)
+	if err != nil {
+		t.Fatalf("unable to read %s: %v", first, err)
+	}
+	b2, err := ioutil.ReadFile(second)
+	if err != nil {
+		t.Fatalf("unable to read %s: %v", second, err)
+	}
+	if !bytes.Equal(b1, b2) {
+		t.Errorf("images built with the same source date epoch differ")
+	}
+}
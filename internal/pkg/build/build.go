@@ -6,12 +6,16 @@
 package build
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
 
@@ -19,6 +23,7 @@ import (
 	uuid "github.com/satori/go.uuid"
 	"github.com/sylabs/singularity/internal/pkg/build/apps"
 	"github.com/sylabs/singularity/internal/pkg/build/assemblers"
+	"github.com/sylabs/singularity/internal/pkg/build/conda"
 	"github.com/sylabs/singularity/internal/pkg/build/files"
 	"github.com/sylabs/singularity/internal/pkg/build/sources"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engine/config/oci"
@@ -43,6 +48,8 @@ import (
 type Build struct {
 	// stages of the build
 	stages []stage
+	// target is the index of the stage assembled as the output.
+	target int
 	// Conf contains cross stage build configuration.
 	Conf Config
 }
@@ -58,6 +65,29 @@ type Config struct {
 	NoCleanUp bool
 	// Opts for bundles.
 	Opts types.Options
+	// Jobs is the maximum number of independent stages built
+	// concurrently, stages are built one after the other if lower
+	// than 2.
+	Jobs int
+	// Stage is the name of the stage assembled as the output,
+	// the last stage is assembled if empty.
+	Stage string
+	// KeepStages is the directory where the root filesystem of
+	// every stage built is kept as a sandbox once the build is
+	// finished or failed, stages are not kept if empty.
+	KeepStages string
+}
+
+// archAgents are the bootstrap agents pulling images for the architecture
+// requested in the build options, other agents use the host architecture.
+var archAgents = map[string]bool{
+	"docker":         true,
+	"docker-archive": true,
+	"docker-daemon":  true,
+	"oci":            true,
+	"oci-archive":    true,
+	"library":        true,
+	"localimage":     true,
 }
 
 // NewBuild creates a new Build struct from a spec (URI, definition file, etc...).
@@ -95,6 +125,9 @@ func newBuild(defs []types.Definition, conf Config) (*Build, error) {
 		if d.Header == nil {
 			return nil, fmt.Errorf("multiple stages detected, all must have headers")
 		}
+		if conf.Opts.Arch != "" && !archAgents[d.Header["bootstrap"]] {
+			return nil, fmt.Errorf("bootstrap agent %s can't pull images for architecture %s", d.Header["bootstrap"], conf.Opts.Arch)
+		}
 
 		rootfsParent := conf.Opts.TmpDir
 		if conf.Format == "sandbox" {
@@ -112,6 +145,9 @@ func newBuild(defs []types.Definition, conf Config) (*Build, error) {
 		if err != nil {
 			return nil, err
 		}
+		if err := checkStageName(d.Header["stage"]); err != nil {
+			return nil, err
+		}
 		s.name = d.Header["stage"]
 		s.b.Recipe = d
 
@@ -128,22 +164,30 @@ func newBuild(defs []types.Definition, conf Config) (*Build, error) {
 		b.stages = append(b.stages, s)
 	}
 
-	// only need an assembler for last stage
-	lastStageIndex := len(defs) - 1
+	b.target = len(b.stages) - 1
+	if conf.Stage != "" {
+		i, err := b.findStageIndex(conf.Stage)
+		if err != nil {
+			return nil, err
+		}
+		b.target = i
+	}
+
+	// only need an assembler for the target stage
 	switch conf.Format {
 	case "sandbox":
-		b.stages[lastStageIndex].a = &assemblers.SandboxAssembler{}
+		b.stages[b.target].a = &assemblers.SandboxAssembler{}
 	case "sif":
 		mksquashfsPath, err := squashfs.GetPath()
 		if err != nil {
 			return nil, fmt.Errorf("while searching for mksquashfs: %v", err)
 		}
 
-		flag, err := ensureGzipComp(b.stages[lastStageIndex].b.TmpDir, mksquashfsPath)
+		flag, err := ensureGzipComp(b.stages[b.target].b.TmpDir, mksquashfsPath)
 		if err != nil {
 			return nil, fmt.Errorf("while ensuring correct compression algorithm: %v", err)
 		}
-		b.stages[lastStageIndex].a = &assemblers.SIFAssembler{
+		b.stages[b.target].a = &assemblers.SIFAssembler{
 			GzipFlag:       flag,
 			MksquashfsPath: mksquashfsPath,
 		}
@@ -223,7 +267,17 @@ func ensureGzipComp(tmpdir, mksquashfsPath string) (bool, error) {
 }
 
 // cleanUp removes remnants of build from file system unless NoCleanUp is specified.
+// The binfmt_misc handlers registered by the build are always removed.
 func (b Build) cleanUp() {
+	for _, s := range b.stages {
+		if s.binfmt == nil {
+			continue
+		}
+		if err := s.binfmt.Unregister(); err != nil {
+			sylog.Warningf("Could not remove binfmt_misc handler: %v", err)
+		}
+	}
+
 	if b.Conf.NoCleanUp {
 		var bundlePaths []string
 		for _, s := range b.stages {
@@ -256,75 +310,354 @@ func (b *Build) Full(ctx context.Context) error {
 	}()
 	// clean up build normally
 	defer b.cleanUp()
+	// keep stages before clean up, even if the build failed
+	if b.Conf.KeepStages != "" {
+		defer b.keepStages()
+	}
 
-	// build each stage one after the other
-	for i, stage := range b.stages {
-		if err := stage.runPreScript(); err != nil {
-			return err
+	if err := b.runStages(ctx); err != nil {
+		return err
+	}
+
+	if epoch := b.Conf.Opts.SourceDateEpoch; epoch != nil {
+		sylog.Debugf("Clamping file times to %s", epoch.UTC())
+		if err := clampTimes(b.stages[b.target].b.RootfsPath, *epoch); err != nil {
+			return fmt.Errorf("while clamping file times: %v", err)
 		}
+	}
 
-		// only update last stage if specified
-		update := stage.b.Opts.Update && !stage.b.Opts.Force && i == len(b.stages)-1
-		if update {
-			// updating, extract dest container to bundle
-			sylog.Infof("Building into existing container: %s", b.Conf.Dest)
-			p, err := sources.GetLocalPacker(b.Conf.Dest, stage.b)
-			if err != nil {
-				return err
-			}
+	sylog.Debugf("Calling assembler")
+	if err := b.stages[b.target].Assemble(b.Conf.Dest); err != nil {
+		return err
+	}
 
-			_, err = p.Pack(ctx)
-			if err != nil {
-				return err
+	sylog.Verbosef("Build complete: %s", b.Conf.Dest)
+	return nil
+}
+
+// stageDependencies returns the indexes of the stages each stage
+// copies files from with %files from sections.
+func (b *Build) stageDependencies() ([][]int, error) {
+	deps := make([][]int, len(b.stages))
+
+	for i, s := range b.stages {
+		for _, f := range s.b.Recipe.BuildData.Files {
+			args := strings.Fields(f.Args)
+			if len(args) != 2 {
+				continue
 			}
-		} else {
-			// regular build or force, start build from scratch
-			if b.Conf.Opts.ImgCache == nil {
-				return fmt.Errorf("undefined image cache")
+			j, err := b.findStageIndex(args[1])
+			if err != nil {
+				return nil, err
 			}
-			if err := stage.c.Get(ctx, stage.b); err != nil {
-				return fmt.Errorf("conveyor failed to get: %v", err)
+			if j == i {
+				return nil, fmt.Errorf("stage %s copies files from itself", args[1])
 			}
+			deps[i] = append(deps[i], j)
+		}
+	}
 
-			_, err := stage.c.Pack(ctx)
-			if err != nil {
-				return fmt.Errorf("packer failed to pack: %v", err)
-			}
+	return deps, nil
+}
+
+type stageResult struct {
+	index int
+	err   error
+}
+
+// runStages builds all stages, up to Jobs independent stages are
+// built concurrently, in which case their output is prefixed by the
+// stage name.
+func (b *Build) runStages(ctx context.Context) error {
+	deps, err := b.stageDependencies()
+	if err != nil {
+		return err
+	}
+
+	jobs := b.Conf.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	for i := range b.stages {
+		b.stages[i].stdout = os.Stdout
+		b.stages[i].stderr = os.Stderr
+		if jobs > 1 && len(b.stages) > 1 {
+			prefix := "[" + b.stages[i].displayName(i) + "] "
+			b.stages[i].stdout = newPrefixWriter(prefix, os.Stdout)
+			b.stages[i].stderr = newPrefixWriter(prefix, os.Stderr)
 		}
+		b.stages[i].b.Stdout = b.stages[i].stdout
+		b.stages[i].b.Stderr = b.stages[i].stderr
+	}
 
-		// create apps in bundle
-		a := apps.New()
-		for k, v := range stage.b.Recipe.CustomData {
-			a.HandleSection(k, v)
+	needed := b.stagesToBuild(deps)
+
+	index, err := runGraph(deps, jobs, func(i int) error {
+		// stages not required by the --stage target are skipped
+		if !needed[i] {
+			return nil
 		}
+		b.stages[i].started = true
+
+		sylog.Debugf("Building stage %s", b.stages[i].displayName(i))
+		defer b.stages[i].flush()
+		return b.buildStage(ctx, i)
+	})
+	if err != nil && index >= 0 {
+		return fmt.Errorf("while building stage %s: %v", b.stages[index].displayName(index), err)
+	}
+	return err
+}
+
+// stagesToBuild returns the stages built, every stage is built unless
+// a target stage is selected with Stage, then only the stages it
+// requires are.
+func (b *Build) stagesToBuild(deps [][]int) []bool {
+	if b.Conf.Stage != "" {
+		return neededStages(deps, b.target)
+	}
+
+	needed := make([]bool, len(deps))
+	for i := range needed {
+		needed[i] = true
+	}
+	return needed
+}
+
+// neededStages returns the stages required to build the stage
+// at index target, including itself.
+func neededStages(deps [][]int, target int) []bool {
+	needed := make([]bool, len(deps))
+
+	var visit func(int)
+	visit = func(i int) {
+		if needed[i] {
+			return
+		}
+		needed[i] = true
+		for _, d := range deps[i] {
+			visit(d)
+		}
+	}
+	visit(target)
+
+	return needed
+}
+
+// keepStages moves the root filesystem of the stages built to
+// the KeepStages directory, so they can be inspected as sandboxes.
+func (b *Build) keepStages() {
+	dir := b.Conf.KeepStages
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		sylog.Errorf("Could not keep stages: %v", err)
+		return
+	}
+
+	for i := range b.stages {
+		s := &b.stages[i]
+		if !s.started {
+			continue
+		}
+		// the target stage is the output with the sandbox format
+		if _, err := os.Stat(s.b.RootfsPath); err != nil {
+			continue
+		}
+
+		dest, err := moveDir(s.b.RootfsPath, dir, s.displayName(i))
+		if err != nil {
+			sylog.Errorf("Could not keep stage %s: %v", s.displayName(i), err)
+			continue
+		}
+		sylog.Infof("Stage %s kept in %s", s.displayName(i), dest)
+	}
+}
 
-		a.HandleBundle(stage.b)
-		stage.b.Recipe.BuildData.Post.Script += a.HandlePost()
+// checkStageName returns an error if the stage name can't be used as
+// a directory name, stages are kept in a directory named after them.
+func checkStageName(name string) error {
+	if name == "." || name == ".." || strings.ContainsRune(name, '/') {
+		return fmt.Errorf("invalid stage name %q", name)
+	}
+	return nil
+}
+
+// moveDir moves the directory src to the entry name of the directory
+// dir and returns its path, replacing it if it exists. The directory
+// is copied if src and dir are on different filesystems. Names which
+// don't resolve to an entry of dir are refused.
+func moveDir(src, dir, name string) (string, error) {
+	dst := filepath.Join(dir, name)
+	if filepath.Dir(dst) != filepath.Clean(dir) {
+		return "", fmt.Errorf("%s is not in %s", dst, dir)
+	}
+
+	if err := os.RemoveAll(dst); err != nil {
+		return "", err
+	}
+
+	err := os.Rename(src, dst)
+	if e, ok := err.(*os.LinkError); !ok || e.Err != syscall.EXDEV {
+		return dst, err
+	}
+
+	var stderr bytes.Buffer
+	cp := exec.Command("/bin/cp", "-a", src, dst)
+	cp.Stderr = &stderr
+	if err := cp.Run(); err != nil {
+		return "", fmt.Errorf("while copying %s to %s: %s: %s", src, dst, err, stderr.String())
+	}
+	return dst, nil
+}
 
-		if stage.b.RunSection("files") {
-			if err := stage.copyFiles(b); err != nil {
-				return fmt.Errorf("unable to copy files a stage to container fs: %v", err)
+// runGraph calls run for each node of the dependency graph deps once
+// all its dependencies succeeded, with up to jobs concurrent calls.
+// No more nodes are started once a call failed, the index of the
+// failed node is returned with its error.
+func runGraph(deps [][]int, jobs int, run func(int) error) (int, error) {
+	started := make([]bool, len(deps))
+	done := make([]bool, len(deps))
+	results := make(chan stageResult)
+	running := 0
+	failed := stageResult{index: -1}
+
+	ready := func(i int) bool {
+		for _, d := range deps[i] {
+			if !done[d] {
+				return false
 			}
 		}
+		return true
+	}
 
-		if engineRequired(stage.b.Recipe) {
-			if err := runBuildEngine(stage.b); err != nil {
-				return fmt.Errorf("while running engine: %v", err)
+	for {
+		for i := range deps {
+			if failed.err != nil || running >= jobs {
+				break
+			}
+			if started[i] || !ready(i) {
+				continue
 			}
+			started[i] = true
+			running++
+
+			go func(i int) {
+				results <- stageResult{index: i, err: run(i)}
+			}(i)
+		}
+		if running == 0 {
+			break
 		}
 
-		sylog.Debugf("Inserting Metadata")
-		if err := stage.insertMetadata(); err != nil {
-			return fmt.Errorf("while inserting metadata to bundle: %v", err)
+		r := <-results
+		running--
+		done[r.index] = true
+		if r.err != nil && failed.err == nil {
+			failed = r
 		}
 	}
 
-	sylog.Debugf("Calling assembler")
-	if err := b.stages[len(b.stages)-1].Assemble(b.Conf.Dest); err != nil {
+	if failed.err != nil {
+		return failed.index, failed.err
+	}
+	for i := range deps {
+		if !done[i] {
+			return -1, fmt.Errorf("circular %%files from dependency between stages")
+		}
+	}
+	return -1, nil
+}
+
+// buildStage builds the stage at index i of the build.
+func (b *Build) buildStage(ctx context.Context, i int) error {
+	stage := b.stages[i]
+
+	if err := stage.runPreScript(); err != nil {
 		return err
 	}
 
-	sylog.Verbosef("Build complete: %s", b.Conf.Dest)
+	// only update last stage if specified
+	update := stage.b.Opts.Update && !stage.b.Opts.Force && i == b.target
+	if update {
+		// updating, extract dest container to bundle
+		stage.logger().Infof("Building into existing container: %s", b.Conf.Dest)
+		p, err := sources.GetLocalPacker(b.Conf.Dest, stage.b)
+		if err != nil {
+			return err
+		}
+
+		_, err = p.Pack(ctx)
+		if err != nil {
+			return err
+		}
+	} else {
+		// regular build or force, start build from scratch
+		if b.Conf.Opts.ImgCache == nil {
+			return fmt.Errorf("undefined image cache")
+		}
+		if err := stage.c.Get(ctx, stage.b); err != nil {
+			return fmt.Errorf("conveyor failed to get: %v", err)
+		}
+
+		_, err := stage.c.Pack(ctx)
+		if err != nil {
+			return fmt.Errorf("packer failed to pack: %v", err)
+		}
+	}
+
+	// create apps in bundle
+	a := apps.New()
+	for k, v := range stage.b.Recipe.CustomData {
+		a.HandleSection(k, v)
+	}
+
+	a.HandleBundle(stage.b)
+	stage.b.Recipe.BuildData.Post.Script += a.HandlePost()
+
+	c := conda.New()
+	if stage.b.RunSection(conda.Section) {
+		for k, v := range stage.b.Recipe.CustomData {
+			c.HandleSection(k, v)
+		}
+	}
+
+	// the conda environment is installed for the detected architecture
+	if err := stage.setupEmulation(c.Defined()); err != nil {
+		return err
+	}
+	// stage is a copy, the handler is removed by cleanUp
+	b.stages[i].binfmt = stage.binfmt
+
+	// conda environment is installed by the engine before %post
+	var condaScript string
+	if c.Defined() {
+		if err := c.HandleBundle(stage.b); err != nil {
+			return fmt.Errorf("while preparing conda environment: %s", err)
+		}
+		condaScript = c.HandlePre()
+		if stage.b.Recipe.BuildData.Post.Script != "" {
+			stage.b.Recipe.BuildData.Post.Script = c.HandlePost() + stage.b.Recipe.BuildData.Post.Script
+		}
+	}
+
+	if stage.b.RunSection("files") {
+		if err := stage.copyFiles(b); err != nil {
+			return fmt.Errorf("unable to copy files a stage to container fs: %v", err)
+		}
+	}
+
+	if engineRequired(stage.b.Recipe) || condaScript != "" {
+		if err := runBuildEngineWithMounts(stage.b, condaScript, stage.stdout, stage.stderr); err != nil {
+			return err
+		}
+	}
+
+	sylog.Debugf("Inserting Metadata")
+	if err := stage.insertMetadata(); err != nil {
+		return fmt.Errorf("while inserting metadata to bundle: %v", err)
+	}
+
 	return nil
 }
 
@@ -333,8 +666,52 @@ func engineRequired(def types.Definition) bool {
 	return def.BuildData.Post.Script != "" || def.BuildData.Setup.Script != "" || def.BuildData.Test.Script != "" || len(def.BuildData.Files) != 0
 }
 
-// runBuildEngine creates an imgbuild engine and creates a container out of our bundle in order to execute %post %setup scripts in the bundle
-func runBuildEngine(b *types.Bundle) error {
+// runBuildEngineWithMounts runs the imgbuild engine with the mount points
+// of the secrets, cache mounts and emulator created in the bundle, they are
+// removed once the engine exits so nothing mounted ends up in the image.
+func runBuildEngineWithMounts(b *types.Bundle, condaScript string, stdout, stderr io.Writer) error {
+	var dirs []string
+	if len(b.Opts.Secrets) > 0 {
+		dirs = append(dirs, imgbuildConfig.SecretsDir)
+	}
+	for dir := range b.Opts.CacheMounts {
+		dirs = append(dirs, dir)
+	}
+	if b.Opts.Emulator != "" {
+		dirs = append(dirs, b.Opts.Emulator)
+	}
+	sort.Strings(dirs)
+
+	var cleanups []func() error
+	cleanup := func() error {
+		var err error
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			if e := cleanups[i](); e != nil && err == nil {
+				err = e
+			}
+		}
+		return err
+	}
+
+	for _, dir := range dirs {
+		c, err := mountpoint(b.RootfsPath, dir, dir == b.Opts.Emulator)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		cleanups = append(cleanups, c)
+	}
+
+	if err := runBuildEngine(b, condaScript, stdout, stderr); err != nil {
+		cleanup()
+		return fmt.Errorf("while running engine: %v", err)
+	}
+	return cleanup()
+}
+
+// runBuildEngine creates an imgbuild engine and creates a container out of our bundle in order to execute %post %setup scripts in the bundle,
+// condaScript installs the %condaenv environment if not empty
+func runBuildEngine(b *types.Bundle, condaScript string, stdout, stderr io.Writer) error {
 	if syscall.Getuid() != 0 {
 		return fmt.Errorf("attempted to build with scripts as non-root user or without --fakeroot")
 	}
@@ -345,6 +722,7 @@ func runBuildEngine(b *types.Bundle) error {
 	engineConfig := &imgbuildConfig.EngineConfig{
 		Bundle:    *b,
 		OciConfig: ociConfig,
+		Conda:     condaScript,
 	}
 
 	// surface build specific environment variables for scripts
@@ -353,6 +731,9 @@ func runBuildEngine(b *types.Bundle) error {
 
 	ociConfig.Process = &specs.Process{}
 	ociConfig.Process.Env = append(os.Environ(), sRootfs, sEnvironment)
+	if len(b.Opts.Secrets) > 0 {
+		ociConfig.Process.Env = append(ociConfig.Process.Env, "SINGULARITY_SECRETS="+imgbuildConfig.SecretsDir)
+	}
 
 	config := &config.Common{
 		EngineName:   imgbuildConfig.Name,
@@ -360,12 +741,16 @@ func runBuildEngine(b *types.Bundle) error {
 		EngineConfig: engineConfig,
 	}
 
-	return starter.Run(
-		"Singularity image-build",
-		config,
-		starter.WithStdout(os.Stdout),
-		starter.WithStderr(os.Stderr),
-	)
+	ops := []starter.CommandOp{
+		starter.WithStdout(stdout),
+		starter.WithStderr(stderr),
+	}
+	// the debug shell is interactive
+	if b.Opts.DebugShell {
+		ops = append(ops, starter.WithStdin(os.Stdin))
+	}
+
+	return starter.Run("Singularity image-build", config, ops...)
 }
 
 // makeDef gets a definition object from a spec.
@@ -417,7 +802,13 @@ func MakeAllDefs(spec string) ([]types.Definition, error) {
 	}
 	defer defFile.Close()
 
-	d, err := parser.All(defFile)
+	parse := parser.All
+	if parser.IsStructuredDefinition(spec) {
+		// definition in JSON or YAML form
+		parse = parser.ParseStructured
+	}
+
+	d, err := parse(defFile)
 	if err != nil {
 		return nil, fmt.Errorf("while parsing definition: %s: %v", spec, err)
 	}
@@ -457,7 +848,7 @@ func (s *stage) copyFiles(b *Build) error {
 		for _, transfer := range f.Files {
 			// sanity
 			if transfer.Src == "" {
-				sylog.Warningf("Attempt to copy file with no name, skipping.")
+				s.logger().Warningf("Attempt to copy file with no name, skipping.")
 				continue
 			}
 			// dest = source if not specified
@@ -469,7 +860,7 @@ func (s *stage) copyFiles(b *Build) error {
 			// prepend appropriate bundle path to supplied paths
 			transfer.Src = files.AddPrefix(b.stages[stageIndex].b.RootfsPath, transfer.Src)
 			transfer.Dst = files.AddPrefix(s.b.RootfsPath, transfer.Dst)
-			sylog.Infof("Copying %v to %v", transfer.Src, transfer.Dst)
+			s.logger().Infof("Copying %v to %v", transfer.Src, transfer.Dst)
 			if err := files.Copy(transfer.Src, transfer.Dst); err != nil {
 				return err
 			}
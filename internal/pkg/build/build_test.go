@@ -0,0 +1,141 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNeededStages(t *testing.T) {
+	tests := []struct {
+		name   string
+		deps   [][]int
+		target int
+		needed []bool
+	}{
+		{"SingleStage", [][]int{nil}, 0, []bool{true}},
+		{"IndependentStages", [][]int{nil, nil, nil}, 1, []bool{false, true, false}},
+		{"Dependencies", [][]int{nil, {0}, nil, {1}}, 3, []bool{true, true, false, true}},
+		{"ForwardDependency", [][]int{{2}, nil, nil}, 0, []bool{true, false, true}},
+		{"SharedDependency", [][]int{nil, {0}, {0}, {1, 2}}, 3, []bool{true, true, true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needed := neededStages(tt.deps, tt.target)
+			if fmt.Sprint(needed) != fmt.Sprint(tt.needed) {
+				t.Errorf("unexpected needed stages %v instead of %v", needed, tt.needed)
+			}
+		})
+	}
+}
+
+func TestStagesToBuild(t *testing.T) {
+	tests := []struct {
+		name   string
+		deps   [][]int
+		stage  string
+		target int
+		needed []bool
+	}{
+		// independent stages are built as well without --stage
+		{"IndependentStages", [][]int{nil, nil, nil}, "", 2, []bool{true, true, true}},
+		{"Dependencies", [][]int{nil, {0}, nil}, "", 2, []bool{true, true, true}},
+		{"TargetStage", [][]int{nil, nil, nil}, "two", 1, []bool{false, true, false}},
+		{"TargetDependencies", [][]int{nil, {0}, nil}, "two", 1, []bool{true, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Build{
+				stages: make([]stage, len(tt.deps)),
+				target: tt.target,
+				Conf:   Config{Stage: tt.stage},
+			}
+			needed := b.stagesToBuild(tt.deps)
+			if fmt.Sprint(needed) != fmt.Sprint(tt.needed) {
+				t.Errorf("unexpected stages built %v instead of %v", needed, tt.needed)
+			}
+		})
+	}
+}
+
+func TestMoveDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "movedir-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+
+	if err := os.MkdirAll(filepath.Join(src, "etc"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "etc", "file"), []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+	// an existing destination is replaced
+	if err := os.MkdirAll(filepath.Join(dst, "old"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %s", err)
+	}
+
+	for _, name := range []string{"", ".", "..", "../dst", "dst/sub"} {
+		if _, err := moveDir(src, dir, name); err == nil {
+			t.Fatalf("unexpected success moving to %q", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "old")); err != nil {
+		t.Fatalf("destination directory removed by a refused move: %s", err)
+	}
+
+	if moved, err := moveDir(src, dir, "dst"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if moved != dst {
+		t.Errorf("unexpected destination %s instead of %s", moved, dst)
+	}
+
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("source directory still exists")
+	}
+	if _, err := os.Stat(filepath.Join(dst, "old")); !os.IsNotExist(err) {
+		t.Errorf("destination directory was not replaced")
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dst, "etc", "file")); err != nil || string(b) != "test" {
+		t.Errorf("file was not moved: %v", err)
+	}
+}
+
+func TestCheckStageName(t *testing.T) {
+	tests := []struct {
+		name    string
+		stage   string
+		wantErr bool
+	}{
+		{name: "Unnamed", stage: ""},
+		{name: "Named", stage: "devel"},
+		{name: "Dot", stage: ".", wantErr: true},
+		{name: "DotDot", stage: "..", wantErr: true},
+		{name: "Traversal", stage: "../../etc", wantErr: true},
+		{name: "Absolute", stage: "/etc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkStageName(tt.stage)
+			if tt.wantErr && err == nil {
+				t.Errorf("unexpected success")
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package conda provides the %condaenv build section which installs a conda
+// environment described by an environment.yml file into the container and
+// activates it for every process started in the container.
+package conda
+
+import (
+	"debug/elf"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/pkg/build/types"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// Section is the name of the definition file section.
+	Section = "condaenv"
+	// DefaultPrefix is the path where the environment is installed
+	// when the environment file doesn't set a prefix.
+	DefaultPrefix = "/opt/conda"
+
+	// buildDir is the temporary directory holding micromamba, the
+	// environment file and the package cache during the build.
+	buildDir = "/.singularity.d/conda-build"
+	// envScript is sourced before 90-environment.sh so %environment
+	// can still override the conda environment variables.
+	envScript = "/.singularity.d/env/85-conda.sh"
+)
+
+const installScript = `
+# install conda environment from %%condaenv
+export MAMBA_ROOT_PREFIX=%[1]s/root
+%[1]s/micromamba create --yes --platform %[3]s --prefix %[2]s --file %[1]s/environment.yml
+%[1]s/micromamba clean --all --yes
+rm -rf %[1]s
+`
+
+const activateScript = `#!/bin/sh
+# Conda environment installed from %%condaenv
+CONDA_PREFIX="%[1]s"
+CONDA_DEFAULT_ENV="%[1]s"
+PATH="%[1]s/bin:$PATH"
+export CONDA_PREFIX CONDA_DEFAULT_ENV PATH
+
+if [ -d "%[1]s/etc/conda/activate.d" ]; then
+    for script in "%[1]s"/etc/conda/activate.d/*.sh; do
+        if [ -f "$script" ]; then
+            . "$script"
+        fi
+    done
+fi
+`
+
+// platforms maps the GOARCH-style architectures to the conda platforms.
+var platforms = map[string]string{
+	"386":     "linux-32",
+	"amd64":   "linux-64",
+	"arm":     "linux-armv7l",
+	"arm64":   "linux-aarch64",
+	"ppc64le": "linux-ppc64le",
+	"s390x":   "linux-s390x",
+}
+
+// environment is the subset of an environment.yml file
+// used to validate it and to get the installation prefix.
+type environment struct {
+	Name         string        `yaml:"name"`
+	Prefix       string        `yaml:"prefix"`
+	Channels     []string      `yaml:"channels"`
+	Dependencies []interface{} `yaml:"dependencies"`
+}
+
+// CondaEnv holds the content of the %condaenv section.
+type CondaEnv struct {
+	// content is either the path of an environment file
+	// on the host or an inline environment file
+	content  string
+	prefix   string
+	platform string
+	defined  bool
+	err      error
+}
+
+// New returns a %condaenv section handler.
+func New() *CondaEnv {
+	return &CondaEnv{}
+}
+
+// HandleSection receives a string of each section from the deffile.
+func (c *CondaEnv) HandleSection(ident, section string) {
+	if strings.Split(ident, " ")[0] != Section {
+		return
+	}
+	if c.defined {
+		c.err = fmt.Errorf("only one %%%s section is allowed per build stage", Section)
+		return
+	}
+	c.defined = true
+	c.content = dedent(section)
+}
+
+// Defined returns true if the definition has a %condaenv section.
+func (c *CondaEnv) Defined() bool {
+	return c.defined
+}
+
+// condaPlatform returns the conda platform of the GOARCH-style architecture
+// a, the host architecture if empty.
+func condaPlatform(a string) (string, error) {
+	if a == "" {
+		a = runtime.GOARCH
+	}
+	p, ok := platforms[a]
+	if !ok {
+		return "", fmt.Errorf("conda environments can't be installed in %s images", a)
+	}
+	return p, nil
+}
+
+// dedent removes the indentation common to all non empty lines
+// of the section, so an inline environment file is valid YAML.
+func dedent(section string) string {
+	lines := strings.Split(strings.TrimRight(section, " \t\n"), "\n")
+	indent := -1
+
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		n := len(l) - len(strings.TrimLeft(l, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			lines[i] = ""
+		} else {
+			lines[i] = l[indent:]
+		}
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// environmentFile returns the content of the environment file, the
+// section either references a file on the host or holds it inline.
+func (c *CondaEnv) environmentFile() ([]byte, error) {
+	if c.content == "" {
+		return nil, fmt.Errorf("%%%s section is empty", Section)
+	}
+	if !strings.Contains(c.content, "\n") && !strings.Contains(c.content, ":") {
+		b, err := ioutil.ReadFile(c.content)
+		if err != nil {
+			return nil, fmt.Errorf("while reading environment file: %s", err)
+		}
+		return b, nil
+	}
+	return []byte(c.content + "\n"), nil
+}
+
+// parseEnvironment validates the environment file and returns
+// the installation prefix.
+func parseEnvironment(b []byte) (string, error) {
+	var env environment
+
+	if err := yaml.Unmarshal(b, &env); err != nil {
+		return "", fmt.Errorf("while parsing environment file: %s", err)
+	}
+	if len(env.Dependencies) == 0 {
+		return "", fmt.Errorf("environment file doesn't list any dependencies")
+	}
+	if env.Prefix == "" {
+		return DefaultPrefix, nil
+	}
+	if !filepath.IsAbs(env.Prefix) {
+		return "", fmt.Errorf("environment prefix %s is not an absolute path", env.Prefix)
+	}
+	return filepath.Clean(env.Prefix), nil
+}
+
+// micromamba returns the path of the micromamba binary found on
+// the host, it must be statically linked to run in any container.
+func micromamba() (string, error) {
+	path, err := exec.LookPath("micromamba")
+	if err != nil {
+		return "", fmt.Errorf("micromamba is required to install a conda environment: %s", err)
+	}
+
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("while reading %s: %s", path, err)
+	}
+	defer f.Close()
+
+	if f.Section(".interp") != nil {
+		return "", fmt.Errorf("%s is not statically linked and may not run in the container", path)
+	}
+	return path, nil
+}
+
+// HandleBundle copies micromamba and the environment file into the
+// bundle and writes the activation script.
+func (c *CondaEnv) HandleBundle(b *types.Bundle) error {
+	if c.err != nil {
+		return c.err
+	}
+	if !c.defined {
+		return nil
+	}
+
+	env, err := c.environmentFile()
+	if err != nil {
+		return err
+	}
+	prefix, err := parseEnvironment(env)
+	if err != nil {
+		return err
+	}
+	// the environment is installed for the image architecture
+	platform, err := condaPlatform(b.Opts.Arch)
+	if err != nil {
+		return err
+	}
+	mamba, err := micromamba()
+	if err != nil {
+		return err
+	}
+
+	sylog.Debugf("Copying micromamba and environment file in bundle")
+
+	dir := filepath.Join(b.RootfsPath, buildDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("while creating %s: %s", buildDir, err)
+	}
+	if err := fs.CopyFile(mamba, filepath.Join(dir, "micromamba"), 0755); err != nil {
+		return fmt.Errorf("while copying micromamba: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "environment.yml"), env, 0644); err != nil {
+		return fmt.Errorf("while writing environment file: %s", err)
+	}
+
+	script := fmt.Sprintf(activateScript, prefix)
+	if err := ioutil.WriteFile(filepath.Join(b.RootfsPath, envScript), []byte(script), 0755); err != nil {
+		return fmt.Errorf("while writing conda activation script: %s", err)
+	}
+
+	c.prefix = prefix
+	c.platform = platform
+	return nil
+}
+
+// HandlePre returns the script installing the environment, it runs
+// in the container as its own build step before %post.
+func (c *CondaEnv) HandlePre() string {
+	if !c.defined {
+		return ""
+	}
+	return fmt.Sprintf(installScript, buildDir, c.prefix, c.platform)
+}
+
+// HandlePost returns a script that should be prepended to %post, it
+// activates the environment so %post runs inside it.
+func (c *CondaEnv) HandlePost() string {
+	if !c.defined {
+		return ""
+	}
+	return fmt.Sprintf(". %s\n", envScript)
+}
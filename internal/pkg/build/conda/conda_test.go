@@ -0,0 +1,228 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package conda
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleSection(t *testing.T) {
+	tests := []struct {
+		name     string
+		sections map[string]string
+		defined  bool
+		content  string
+		wantErr  bool
+	}{
+		{
+			name:     "NoSection",
+			sections: map[string]string{"appinstall foo": "echo foo"},
+		},
+		{
+			name:     "InlineEnvironment",
+			sections: map[string]string{"condaenv": "    name: test\n    dependencies:\n      - python\n\n"},
+			defined:  true,
+			content:  "name: test\ndependencies:\n  - python",
+		},
+		{
+			name:     "EnvironmentPath",
+			sections: map[string]string{"condaenv": "  /tmp/environment.yml\n"},
+			defined:  true,
+			content:  "/tmp/environment.yml",
+		},
+		{
+			name: "MultipleSections",
+			sections: map[string]string{
+				"condaenv":     "/tmp/environment.yml",
+				"condaenv foo": "/tmp/environment.yml",
+			},
+			defined: true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			for k, v := range tt.sections {
+				c.HandleSection(k, v)
+			}
+			if c.defined != tt.defined {
+				t.Errorf("unexpected defined value: %v", c.defined)
+			}
+			if tt.wantErr {
+				if c.err == nil {
+					t.Errorf("unexpected success")
+				}
+				return
+			}
+			if c.content != tt.content {
+				t.Errorf("unexpected content %q instead of %q", c.content, tt.content)
+			}
+		})
+	}
+}
+
+func TestParseEnvironment(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		prefix  string
+		wantErr bool
+	}{
+		{
+			name:   "DefaultPrefix",
+			env:    "name: test\nchannels:\n  - conda-forge\ndependencies:\n  - python=3.8\n  - pip:\n    - requests\n",
+			prefix: DefaultPrefix,
+		},
+		{
+			name:   "Prefix",
+			env:    "prefix: /opt/env/\ndependencies:\n  - python\n",
+			prefix: "/opt/env",
+		},
+		{
+			name:    "RelativePrefix",
+			env:     "prefix: env\ndependencies:\n  - python\n",
+			wantErr: true,
+		},
+		{
+			name:    "NoDependencies",
+			env:     "name: test\n",
+			wantErr: true,
+		},
+		{
+			name:    "BadYAML",
+			env:     "dependencies: [python\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, err := parseEnvironment([]byte(tt.env))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if prefix != tt.prefix {
+				t.Errorf("unexpected prefix %s instead of %s", prefix, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestEnvironmentFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conda-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "environment.yml")
+	env := "dependencies:\n  - python\n"
+	if err := ioutil.WriteFile(path, []byte(env), 0644); err != nil {
+		t.Fatalf("failed to write environment file: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		env     string
+		wantErr bool
+	}{
+		{"Path", path, env, false},
+		{"MissingPath", filepath.Join(dir, "missing.yml"), "", true},
+		{"Inline", "dependencies:\n  - python", env, false},
+		{"Empty", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &CondaEnv{content: tt.content, defined: true}
+			b, err := c.environmentFile()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(b) != tt.env {
+				t.Errorf("unexpected environment %q instead of %q", b, tt.env)
+			}
+		})
+	}
+}
+
+func TestHandleScripts(t *testing.T) {
+	c := New()
+	if c.HandlePre() != "" || c.HandlePost() != "" {
+		t.Errorf("unexpected scripts without %%%s section", Section)
+	}
+
+	c.HandleSection(Section, "/tmp/environment.yml")
+	c.prefix = "/opt/env"
+	c.platform = "linux-aarch64"
+
+	pre := c.HandlePre()
+	if !strings.Contains(pre, "--prefix /opt/env ") {
+		t.Errorf("installation script doesn't install in prefix: %s", pre)
+	}
+	if !strings.Contains(pre, "--platform linux-aarch64 ") {
+		t.Errorf("installation script doesn't install for the image platform: %s", pre)
+	}
+	// the installation runs as its own step, %post activates the environment
+	if strings.Contains(pre, envScript) {
+		t.Errorf("installation script activates the environment: %s", pre)
+	}
+	if post := c.HandlePost(); post != ". "+envScript+"\n" {
+		t.Errorf("unexpected %%post script %q", post)
+	}
+}
+
+func TestCondaPlatform(t *testing.T) {
+	tests := []struct {
+		arch     string
+		platform string
+		wantErr  bool
+	}{
+		{"amd64", "linux-64", false},
+		{"arm64", "linux-aarch64", false},
+		{"ppc64le", "linux-ppc64le", false},
+		{"mips64le", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arch, func(t *testing.T) {
+			p, err := condaPlatform(tt.arch)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("unexpected success")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if p != tt.platform {
+				t.Errorf("unexpected platform %s instead of %s", p, tt.platform)
+			}
+		})
+	}
+
+	if _, err := condaPlatform(""); err != nil {
+		t.Errorf("unexpected error for the host architecture: %s", err)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"fmt"
 
 	"github.com/sylabs/singularity/internal/pkg/build/sources"
+	"github.com/sylabs/singularity/internal/pkg/plugin"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/build/types"
 )
 
@@ -53,11 +55,18 @@ func conveyorPacker(def types.Definition) (ConveyorPacker, error) {
 		return &sources.YumConveyorPacker{}, nil
 	case "zypper":
 		return &sources.ZypperConveyorPacker{}, nil
+	case "apk":
+		return &sources.ApkConveyorPacker{}, nil
 	case "scratch":
 		return &sources.ScratchConveyorPacker{}, nil
 	case "":
 		return nil, fmt.Errorf("no bootstrap specification found")
 	default:
+		// build sources registered by plugins
+		if s, ok := plugin.GetBuildSource(def.Header["bootstrap"]); ok {
+			sylog.Debugf("Using build source %s from plugin %s", s.Name, s.PluginName)
+			return s.New(), nil
+		}
 		return nil, fmt.Errorf("invalid build source %s", def.Header["bootstrap"])
 	}
 }
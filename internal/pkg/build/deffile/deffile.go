@@ -0,0 +1,160 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package deffile checks and formats definition files. Unlike the build
+// parser, it keeps track of the line number of each header and section so
+// problems can be reported where they are found.
+package deffile
+
+import (
+	"regexp"
+	"strings"
+)
+
+// bootstrapRegexp matches the header line starting a new stage, the same
+// way the build parser splits multi-stage definition files.
+var bootstrapRegexp = regexp.MustCompile(`(?i)^bootstrap:`)
+
+// header is a line of a definition file header.
+type header struct {
+	// line number in the definition file.
+	line int
+	// key is the lowercase header keyword, empty for blank
+	// lines, comments and lines without a keyword.
+	key   string
+	value string
+	// comment is the comment following the value, or the whole
+	// line for comment lines.
+	comment string
+	// cont holds the continuation lines of a value ending with '\'.
+	cont []string
+	// raw is the original line.
+	raw string
+}
+
+// section is a section of a definition file.
+type section struct {
+	// line number of the section identifier in the definition file.
+	line int
+	// name is the lowercase section name without '%'.
+	name string
+	args string
+	body []string
+}
+
+// app returns the app name of an app section.
+func (s *section) app() string {
+	return strings.SplitN(s.args, " ", 2)[0]
+}
+
+// stage is a build stage of a definition file.
+type stage struct {
+	headers  []*header
+	sections []*section
+}
+
+// value returns the value of the header key.
+func (s *stage) value(key string) (string, bool) {
+	for _, h := range s.headers {
+		if h.key == key {
+			return h.value, true
+		}
+	}
+	return "", false
+}
+
+// sectionName returns the section name if line is a section identifier.
+func sectionName(line string) (string, bool) {
+	f := strings.Fields(line)
+	if len(f) == 0 || f[0][0] != '%' {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimLeft(f[0], "%")), true
+}
+
+// parse splits a definition file into stages, headers and sections.
+func parse(raw []byte) []*stage {
+	lines := strings.Split(strings.Replace(string(raw), "\r\n", "\n", -1), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var cur *stage
+	var sect *section
+	var prev *header
+
+	stages := []*stage{}
+	hasBootstrap := false
+
+	for i, l := range lines {
+		n := i + 1
+
+		// a Bootstrap line starts a new stage unless it's the first
+		// one found before any section
+		if bootstrapRegexp.MatchString(l) {
+			if cur == nil || hasBootstrap || len(cur.sections) > 0 {
+				cur = nil
+			}
+			hasBootstrap = true
+		}
+		if cur == nil {
+			cur = &stage{}
+			stages = append(stages, cur)
+			sect, prev = nil, nil
+		}
+
+		if name, ok := sectionName(l); ok {
+			sect = &section{line: n, name: name}
+			if f := strings.Fields(l); len(f) > 1 {
+				sect.args = strings.Join(f[1:], " ")
+			}
+			cur.sections = append(cur.sections, sect)
+			continue
+		}
+		if sect != nil {
+			sect.body = append(sect.body, l)
+			continue
+		}
+
+		// header line continuing the previous value
+		if prev != nil && strings.HasSuffix(prev.lastValue(), "\\") {
+			prev.cont = append(prev.cont, strings.TrimSpace(l))
+			continue
+		}
+
+		h := &header{line: n, raw: l}
+		cur.headers = append(cur.headers, h)
+		prev = nil
+
+		t := strings.TrimSpace(l)
+		if t == "" {
+			continue
+		}
+		if t[0] == '#' {
+			h.comment = t
+			continue
+		}
+		if i := strings.Index(t, "#"); i >= 0 {
+			h.comment = strings.TrimSpace(t[i:])
+			t = strings.TrimSpace(t[:i])
+		}
+		kv := strings.SplitN(t, ":", 2)
+		if len(kv) == 2 {
+			h.key = strings.ToLower(strings.TrimSpace(kv[0]))
+			h.value = strings.TrimSpace(kv[1])
+			prev = h
+		}
+	}
+
+	return stages
+}
+
+// lastValue returns the last line of a possibly continued header value.
+func (h *header) lastValue() string {
+	if len(h.cont) > 0 {
+		return h.cont[len(h.cont)-1]
+	}
+	return h.value
+}
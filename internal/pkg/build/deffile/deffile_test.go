@@ -0,0 +1,210 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package deffile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deffile-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(src, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		def      string
+		problems []Problem
+	}{
+		{
+			name: "Valid",
+			def: "Bootstrap: docker\nFrom: alpine\n\n" +
+				"%files\n    " + src + " /opt\n" +
+				"%post\n    if true; then\n        echo ok\n    fi\n" +
+				"%apprun foo\n    echo foo\n%applabels foo\n    A B\n",
+		},
+		{
+			name: "Headers",
+			def:  "# comment\nBootstrap: docker\nMirrorURL: http://mirror\nFoo: bar\nFrom: alpine\nFrom: busybox\n%post\n",
+			problems: []Problem{
+				{3, Warning, "header mirrorurl is not used by the docker bootstrap agent"},
+				{4, Error, "unknown header foo"},
+				{6, Warning, "duplicate header from, first defined on line 5"},
+			},
+		},
+		{
+			name: "NumberedHeaders",
+			def:  "Bootstrap: zypper\nOtherURL0: http://repo\n",
+		},
+		{
+			name: "MissingFrom",
+			def:  "Bootstrap: library\n%post\n",
+			problems: []Problem{
+				{1, Error, "the library bootstrap agent requires a From header"},
+			},
+		},
+		{
+			name: "UnknownAgent",
+			def:  "Bootstrap: unknown\n",
+			problems: []Problem{
+				{1, Error, `unknown bootstrap agent "unknown"`},
+			},
+		},
+		{
+			name: "NoBootstrap",
+			def:  "%post\n    true\n",
+			problems: []Problem{
+				{1, Error, "no Bootstrap header found"},
+			},
+		},
+		{
+			name: "Sections",
+			def:  "Bootstrap: scratch\n%foo\n%post\n%post\n%files\n%files from other\n",
+			problems: []Problem{
+				{2, Error, "unknown section %foo"},
+				{4, Warning, "duplicate section %post, first defined on line 3"},
+			},
+		},
+		{
+			name: "Files",
+			def:  "Bootstrap: scratch\n%files\n    # comment\n    " + src + "\n    " + filepath.Join(dir, "missing") + " /opt\n    " + filepath.Join(dir, "f*") + "\n",
+			problems: []Problem{
+				{5, Error, "source " + filepath.Join(dir, "missing") + " doesn't exist"},
+			},
+		},
+		{
+			name: "Apps",
+			def:  "Bootstrap: scratch\n%appinstall foo\n%applabels bar\n%apphelp\n%apptest foo\n",
+			problems: []Problem{
+				{3, Warning, "section %applabels bar has no matching %appinstall or %apprun section"},
+				{4, Error, "section %apphelp has no app name"},
+			},
+		},
+		{
+			name: "MultiStage",
+			def:  "Bootstrap: scratch\nStage: one\n%post\n    true\n\nBootstrap: scratch\nFrom: foo\n%post\n",
+			problems: []Problem{
+				{7, Warning, "header from is not used by the scratch bootstrap agent"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Lint([]byte(tt.def))
+			if !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("unexpected problems:\n%v\nwant:\n%v", problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestLintScript(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		line int
+	}{
+		{
+			name: "SyntaxError",
+			def:  "Bootstrap: scratch\n\n%post\n    echo ok\n    if true; then\n        echo ok\n    done\n",
+			line: 7,
+		},
+		{
+			name: "BashInPOSIX",
+			def:  "Bootstrap: scratch\n\n%post\n    a=(1 2)\n",
+			line: 4,
+		},
+		{
+			name: "Bash",
+			def:  "Bootstrap: scratch\n\n%post -c /bin/bash\n    a=(1 2)\n    [[ -n $a ]] && echo ok\n",
+		},
+		{
+			name: "BashSyntaxError",
+			def:  "Bootstrap: scratch\n\n%post -c /bin/bash\n    a=(1 2\n",
+			line: 4,
+		},
+		{
+			name: "NotShell",
+			def:  "Bootstrap: scratch\n\n%post -c /usr/bin/python3\n    if True:\n        print('ok')\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Lint([]byte(tt.def))
+			if tt.line == 0 {
+				if len(problems) != 0 {
+					t.Errorf("unexpected problems: %v", problems)
+				}
+				return
+			}
+			if len(problems) != 1 {
+				t.Fatalf("unexpected problems: %v", problems)
+			}
+			if problems[0].Line != tt.line || problems[0].Severity != Error {
+				t.Errorf("unexpected problem: %v", problems[0])
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		want string
+	}{
+		{
+			name: "Headers",
+			def:  "\n# comment\nbootstrap:docker\n\n\nFROM:   alpine   # latest\nosversion: 1\nOTHERURL0: http://repo\n",
+			want: "# comment\nBootstrap: docker\n\nFrom: alpine # latest\nOSVersion: 1\nOtherURL0: http://repo\n",
+		},
+		{
+			name: "Sections",
+			def:  "Bootstrap: scratch\n%POST   arg\n\n\techo a  \n\t\techo b\n\n\n\techo c\n%runscript\necho run\n\n\n",
+			want: "Bootstrap: scratch\n\n%post arg\n    echo a\n    \techo b\n\n    echo c\n\n%runscript\n    echo run\n",
+		},
+		{
+			name: "HereDocument",
+			def:  "Bootstrap: scratch\n%post\n  cat <<EOF > /file\n text  \nEOF\n",
+			want: "Bootstrap: scratch\n\n%post\n  cat <<EOF > /file\n text  \nEOF\n",
+		},
+		{
+			name: "MultiStage",
+			def:  "Bootstrap: scratch\nStage: one\n%post\n  true\nBootstrap: scratch\n%files from one\n  /a /b\n",
+			want: "Bootstrap: scratch\nStage: one\n\n%post\n    true\n\nBootstrap: scratch\n\n%files from one\n    /a /b\n",
+		},
+		{
+			name: "NoHeader",
+			def:  "%help\n  help\n",
+			want: "%help\n    help\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Format([]byte(tt.def)))
+			if got != tt.want {
+				t.Errorf("unexpected output:\n%q\nwant:\n%q", got, tt.want)
+			}
+			if again := string(Format([]byte(got))); again != got {
+				t.Errorf("format is not idempotent:\n%q", again)
+			}
+		})
+	}
+}
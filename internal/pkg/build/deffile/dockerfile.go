@@ -0,0 +1,371 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package deffile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/builder/dockerfile/instructions"
+	dockerparser "github.com/docker/docker/builder/dockerfile/parser"
+	"github.com/sylabs/singularity/pkg/build/types"
+)
+
+// safeWordRegexp matches the words which don't need to be quoted
+// in shell scripts.
+var safeWordRegexp = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// quote returns the word quoted for the shell if needed.
+func quote(word string) string {
+	if safeWordRegexp.MatchString(word) {
+		return word
+	}
+	return "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+}
+
+// quoteValue double quotes a value so variables are still expanded.
+func quoteValue(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`")
+	return `"` + r.Replace(v) + `"`
+}
+
+// unquote removes the quotes around an ENV or LABEL value, which
+// are kept by the Dockerfile parser.
+func unquote(v string) string {
+	if len(v) < 2 || v[0] != v[len(v)-1] {
+		return v
+	}
+	switch v[0] {
+	case '\'':
+		return v[1 : len(v)-1]
+	case '"':
+		r := strings.NewReplacer(`\\`, `\`, `\"`, `"`)
+		return r.Replace(v[1 : len(v)-1])
+	}
+	return v
+}
+
+// commandLine returns the shell command line of a RUN, CMD or
+// ENTRYPOINT instruction in shell or exec form.
+func commandLine(c instructions.ShellDependantCmdLine) string {
+	if c.PrependShell {
+		return strings.Join(c.CmdLine, " ")
+	}
+	words := make([]string, len(c.CmdLine))
+	for i, w := range c.CmdLine {
+		words[i] = quote(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// execLine returns the words to exec for a CMD or ENTRYPOINT
+// instruction, a shell form instruction runs with /bin/sh -c.
+func execLine(c instructions.ShellDependantCmdLine) string {
+	if c.PrependShell {
+		return "/bin/sh -c " + quote(strings.Join(c.CmdLine, " "))
+	}
+	return commandLine(c)
+}
+
+// dockerStage holds the state of a Dockerfile stage while
+// converting it.
+type dockerStage struct {
+	def        types.Definition
+	named      bool
+	post       []string
+	env        []string
+	workdir    string
+	run        bool
+	entrypoint *instructions.ShellDependantCmdLine
+	cmd        *instructions.ShellDependantCmdLine
+}
+
+// runscript returns the %runscript equivalent to the ENTRYPOINT
+// and CMD instructions: CMD is the default argument list of the
+// ENTRYPOINT and is replaced by the arguments of the container.
+func (s *dockerStage) runscript() string {
+	var lines []string
+
+	if s.workdir != "" {
+		lines = append(lines, "cd "+quote(s.workdir))
+	}
+
+	switch {
+	case s.entrypoint != nil && !s.entrypoint.PrependShell:
+		entrypoint := execLine(*s.entrypoint)
+		if s.cmd != nil && len(s.cmd.CmdLine) > 0 {
+			lines = append(lines,
+				`if [ $# -gt 0 ]; then`,
+				`    exec `+entrypoint+` "$@"`,
+				`else`,
+				`    exec `+entrypoint+` `+execLine(*s.cmd),
+				`fi`,
+			)
+		} else {
+			lines = append(lines, `exec `+entrypoint+` "$@"`)
+		}
+	case s.entrypoint != nil:
+		// arguments are ignored with a shell form ENTRYPOINT
+		lines = append(lines, `exec `+execLine(*s.entrypoint))
+	case s.cmd != nil && len(s.cmd.CmdLine) > 0:
+		lines = append(lines,
+			`if [ $# -gt 0 ]; then`,
+			`    exec "$@"`,
+			`else`,
+			`    exec `+execLine(*s.cmd),
+			`fi`,
+		)
+	default:
+		return ""
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// FromDockerfile converts the Dockerfile r to definitions, one per stage.
+// The instructions which can't be converted are ignored and reported as
+// warnings.
+func FromDockerfile(r io.Reader) ([]types.Definition, []Problem, error) {
+	result, err := dockerparser.Parse(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while parsing Dockerfile: %s", err)
+	}
+
+	var problems []Problem
+	var stages []*dockerStage
+
+	warn := func(line int, format string, a ...interface{}) {
+		problems = append(problems, Problem{line, Warning, fmt.Sprintf(format, a...)})
+	}
+
+	// ARG instructions before the first FROM can only be used in FROM
+	args := make(map[string]string)
+	expand := func(s string) string {
+		return os.Expand(s, func(k string) string { return args[k] })
+	}
+
+	for _, n := range result.AST.Children {
+		inst, err := instructions.ParseInstruction(n)
+		if err != nil {
+			return nil, nil, fmt.Errorf("while parsing Dockerfile line %d: %s", n.StartLine, err)
+		}
+
+		if st, ok := inst.(*instructions.Stage); ok {
+			stages = append(stages, newDockerStage(st, expand, stages, n.StartLine, warn))
+			continue
+		}
+		if len(stages) == 0 {
+			if a, ok := inst.(*instructions.ArgCommand); ok {
+				if a.Value != nil {
+					args[a.Key] = *a.Value
+				}
+				continue
+			}
+			return nil, nil, fmt.Errorf("Dockerfile line %d: instruction before FROM", n.StartLine)
+		}
+
+		s := stages[len(stages)-1]
+
+		switch c := inst.(type) {
+		case *instructions.RunCommand:
+			s.post = append(s.post, commandLine(c.ShellDependantCmdLine))
+			s.run = true
+		case *instructions.EnvCommand:
+			for _, kv := range c.Env {
+				line := "export " + kv.Key + "=" + quoteValue(unquote(kv.Value))
+				// ENV also applies to the following RUN instructions
+				s.post = append(s.post, line)
+				s.env = append(s.env, line)
+			}
+		case *instructions.ArgCommand:
+			if c.Value != nil {
+				s.post = append(s.post, c.Key+"=${"+c.Key+":-"+quoteValue(*c.Value)+"}")
+			}
+		case *instructions.WorkdirCommand:
+			s.workdir = destPath(s.workdir, c.Path)
+			s.post = append(s.post, "mkdir -p "+quote(s.workdir), "cd "+quote(s.workdir))
+		case *instructions.LabelCommand:
+			for _, kv := range c.Labels {
+				s.def.Labels[unquote(kv.Key)] = unquote(kv.Value)
+			}
+		case *instructions.MaintainerCommand:
+			s.def.Labels["maintainer"] = c.Maintainer
+		case *instructions.CopyCommand:
+			from := ""
+			if c.From != "" {
+				from = stageName(stages, c.From)
+				if from == "" {
+					warn(n.StartLine, "COPY --from=%s: only previous stages of the Dockerfile can be copied from", c.From)
+					continue
+				}
+			}
+			if c.Chown != "" {
+				warn(n.StartLine, "COPY --chown is not supported, files are owned by root")
+			}
+			if s.run {
+				warn(n.StartLine, "COPY after RUN: %%files are copied before %%post runs")
+			}
+			s.addFiles(from, c.SourcesAndDest)
+		case *instructions.AddCommand:
+			for _, src := range c.Sources() {
+				if strings.Contains(src, "://") || isArchive(src) {
+					warn(n.StartLine, "ADD %s: URLs and archives are copied as is", src)
+				}
+			}
+			if c.Chown != "" {
+				warn(n.StartLine, "ADD --chown is not supported, files are owned by root")
+			}
+			if s.run {
+				warn(n.StartLine, "ADD after RUN: %%files are copied before %%post runs")
+			}
+			s.addFiles("", c.SourcesAndDest)
+		case *instructions.EntrypointCommand:
+			s.entrypoint = &c.ShellDependantCmdLine
+		case *instructions.CmdCommand:
+			s.cmd = &c.ShellDependantCmdLine
+		default:
+			warn(n.StartLine, "unsupported instruction %s ignored", strings.ToUpper(n.Value))
+		}
+	}
+
+	if len(stages) == 0 {
+		return nil, nil, fmt.Errorf("no FROM instruction found in Dockerfile")
+	}
+
+	// a single unnamed stage doesn't need a name
+	if len(stages) == 1 && !stages[0].named {
+		delete(stages[0].def.Header, "stage")
+	}
+
+	defs := make([]types.Definition, 0, len(stages))
+	for _, s := range stages {
+		if len(s.post) > 0 {
+			s.def.BuildData.Post.Script = strings.Join(s.post, "\n")
+		}
+		if len(s.env) > 0 {
+			s.def.ImageData.Environment.Script = strings.Join(s.env, "\n")
+		}
+		s.def.ImageData.Runscript.Script = s.runscript()
+		defs = append(defs, s.def)
+	}
+
+	return defs, problems, nil
+}
+
+// newDockerStage returns the stage started by a FROM instruction.
+func newDockerStage(st *instructions.Stage, expand func(string) string, stages []*dockerStage, line int, warn func(int, string, ...interface{})) *dockerStage {
+	s := &dockerStage{
+		def: types.Definition{
+			Header: make(map[string]string),
+			ImageData: types.ImageData{
+				Labels: make(map[string]string),
+			},
+		},
+	}
+
+	base := expand(st.BaseName)
+	switch {
+	case base == "scratch":
+		s.def.Header["bootstrap"] = "scratch"
+	case stageName(stages, base) != "":
+		warn(line, "FROM %s: a previous stage can't be used as base image, the Docker image %s is used instead", base, base)
+		fallthrough
+	default:
+		s.def.Header["bootstrap"] = "docker"
+		s.def.Header["from"] = base
+	}
+	if strings.Contains(strings.ToLower(st.SourceCode), "--platform") {
+		warn(line, "FROM --platform is not supported")
+	}
+
+	// every stage of a multi-stage Dockerfile is named so
+	// files can be copied from it by index too
+	if st.Name != "" {
+		s.def.Header["stage"] = st.Name
+		s.named = true
+	} else {
+		s.def.Header["stage"] = "stage" + strconv.Itoa(len(stages))
+	}
+
+	return s
+}
+
+// stageName returns the name of the previous stage referenced by
+// name or index, or an empty string if there is no such stage.
+func stageName(stages []*dockerStage, ref string) string {
+	if i, err := strconv.Atoi(ref); err == nil {
+		if i >= 0 && i < len(stages) {
+			return stages[i].def.Header["stage"]
+		}
+		return ""
+	}
+	for _, s := range stages {
+		if s.def.Header["stage"] == strings.ToLower(ref) {
+			return s.def.Header["stage"]
+		}
+	}
+	return ""
+}
+
+// destPath returns the absolute path of dst relative to the working
+// directory, with a trailing slash when dst is a directory.
+func destPath(workdir, dst string) string {
+	if workdir == "" {
+		workdir = "/"
+	}
+	p := dst
+	if !path.IsAbs(dst) {
+		p = path.Join(workdir, dst)
+	}
+	base := path.Base(dst)
+	if p != "/" && (strings.HasSuffix(dst, "/") || base == "." || base == "..") {
+		p = path.Clean(p) + "/"
+	}
+	return p
+}
+
+// addFiles adds the sources and destination of a COPY or ADD
+// instruction to the %files section copying files from stage,
+// a relative destination is relative to the working directory.
+func (s *dockerStage) addFiles(stage string, sd instructions.SourcesAndDest) {
+	d := &s.def
+	args := ""
+	if stage != "" {
+		args = "from " + stage
+	}
+
+	i := -1
+	for j, f := range d.BuildData.Files {
+		if f.Args == args {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		d.BuildData.Files = append(d.BuildData.Files, types.Files{Args: args})
+		i = len(d.BuildData.Files) - 1
+	}
+
+	dst := destPath(s.workdir, sd.Dest())
+	for _, src := range sd.Sources() {
+		d.BuildData.Files[i].Files = append(d.BuildData.Files[i].Files, types.FileTransport{Src: src, Dst: dst})
+	}
+}
+
+// isArchive returns whether ADD would extract the source file.
+func isArchive(src string) bool {
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar.xz", ".txz"} {
+		if strings.HasSuffix(src, ext) {
+			return true
+		}
+	}
+	return false
+}
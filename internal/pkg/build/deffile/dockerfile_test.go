@@ -0,0 +1,192 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package deffile
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/singularity/pkg/build/types"
+	"github.com/sylabs/singularity/pkg/build/types/parser"
+)
+
+func TestFromDockerfile(t *testing.T) {
+	tests := []struct {
+		name       string
+		dockerfile string
+		def        string
+		problems   []Problem
+		wantErr    bool
+	}{
+		{
+			name: "Simple",
+			dockerfile: `ARG VERSION=3.10
+FROM alpine:${VERSION}
+LABEL org.label=value maintainer="me"
+ENV PATH=/opt/bin:$PATH GREETING="hello world"
+WORKDIR /opt
+COPY a b /opt/
+RUN apk add --no-cache python3 && \
+    echo done
+RUN ["echo", "hello world"]
+EXPOSE 80
+ENTRYPOINT ["python3", "-m", "http.server"]
+CMD ["8080"]
+`,
+			def: `bootstrap: docker
+from: alpine:3.10
+
+%labels
+	maintainer me
+	org.label value
+
+%files
+	a /opt/
+	b /opt/
+
+%environment
+export PATH="/opt/bin:$PATH"
+export GREETING="hello world"
+
+%runscript
+cd /opt
+if [ $# -gt 0 ]; then
+    exec python3 -m http.server "$@"
+else
+    exec python3 -m http.server 8080
+fi
+
+%post
+export PATH="/opt/bin:$PATH"
+export GREETING="hello world"
+mkdir -p /opt
+cd /opt
+apk add --no-cache python3 &&     echo done
+echo 'hello world'
+
+`,
+			problems: []Problem{
+				{10, Warning, "unsupported instruction EXPOSE ignored"},
+			},
+		},
+		{
+			name: "MultiStage",
+			dockerfile: `FROM golang AS build
+RUN go build -o /app .
+FROM scratch
+COPY --from=build /app /app
+COPY --from=0 /etc/ssl /etc/ssl
+CMD /app
+`,
+			def: `bootstrap: docker
+from: golang
+stage: build
+
+%post
+go build -o /app .
+
+bootstrap: scratch
+stage: stage1
+
+%files from build
+	/app /app
+	/etc/ssl /etc/ssl
+
+%runscript
+if [ $# -gt 0 ]; then
+    exec "$@"
+else
+    exec /bin/sh -c /app
+fi
+
+`,
+		},
+		{
+			name: "RelativeDestination",
+			dockerfile: `FROM alpine
+COPY a .
+WORKDIR /app
+COPY b c ./
+WORKDIR src
+ADD d lib/
+RUN make
+COPY e e.conf
+`,
+			def: `bootstrap: docker
+from: alpine
+
+%files
+	a /
+	b /app/
+	c /app/
+	d /app/src/lib/
+	e /app/src/e.conf
+
+%post
+mkdir -p /app
+cd /app
+mkdir -p /app/src
+cd /app/src
+make
+
+`,
+			problems: []Problem{
+				{8, Warning, "COPY after RUN: %files are copied before %post runs"},
+			},
+		},
+		{
+			name:       "UnknownStage",
+			dockerfile: "FROM alpine\nCOPY --from=nginx:latest /etc/nginx /etc/nginx\nUSER nobody\n",
+			def:        "bootstrap: docker\nfrom: alpine\n\n",
+			problems: []Problem{
+				{2, Warning, "COPY --from=nginx:latest: only previous stages of the Dockerfile can be copied from"},
+				{3, Warning, "unsupported instruction USER ignored"},
+			},
+		},
+		{
+			name:       "NoFrom",
+			dockerfile: "ARG A=1\n",
+			wantErr:    true,
+		},
+		{
+			name:       "BadInstruction",
+			dockerfile: "FROM alpine\nFOO bar\n",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defs, problems, err := FromDockerfile(strings.NewReader(tt.dockerfile))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var b bytes.Buffer
+			for i := range defs {
+				types.WriteDefinitionFile(&defs[i], &b)
+			}
+			if b.String() != tt.def {
+				t.Errorf("unexpected definition:\n%s\nwant:\n%s", b.String(), tt.def)
+			}
+			if !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("unexpected problems: %v", problems)
+			}
+
+			// the definition must be valid
+			if _, err := parser.All(&b); err != nil {
+				t.Errorf("unexpected parser error: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package deffile
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// indent is the indentation of section bodies.
+const indent = "    "
+
+// headerNames holds the canonical spelling of header keywords.
+var headerNames = map[string]string{
+	"bootstrap":   "Bootstrap",
+	"from":        "From",
+	"includecmd":  "IncludeCmd",
+	"mirrorurl":   "MirrorURL",
+	"updateurl":   "UpdateURL",
+	"osversion":   "OSVersion",
+	"include":     "Include",
+	"library":     "Library",
+	"registry":    "Registry",
+	"namespace":   "Namespace",
+	"stage":       "Stage",
+	"product":     "Product",
+	"user":        "User",
+	"regcode":     "Regcode",
+	"productpgp":  "ProductPGP",
+	"registerurl": "RegisterURL",
+	"modules":     "Modules",
+}
+
+var otherURLRegexp = regexp.MustCompile(`^otherurl(\d+)$`)
+
+// headerName returns the canonical spelling of a header keyword.
+func headerName(key string) string {
+	if name, ok := headerNames[key]; ok {
+		return name
+	}
+	if m := otherURLRegexp.FindStringSubmatch(key); m != nil {
+		return "OtherURL" + m[1]
+	}
+	return key
+}
+
+// Format returns the definition file raw in canonical form: header
+// keywords are spelled the same way, sections are separated by a single
+// blank line and their bodies are indented with four spaces. Section
+// bodies containing here-documents are kept as is since their content
+// may depend on the indentation.
+func Format(raw []byte) []byte {
+	var b bytes.Buffer
+
+	for i, s := range parse(raw) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		formatHeaders(&b, s.headers)
+		for j, sect := range s.sections {
+			if j > 0 || b.Len() > 0 && !bytes.HasSuffix(b.Bytes(), []byte("\n\n")) {
+				b.WriteString("\n")
+			}
+			formatSection(&b, sect)
+		}
+	}
+
+	return b.Bytes()
+}
+
+// formatHeaders writes the header lines, dropping leading, trailing
+// and repeated blank lines.
+func formatHeaders(b *bytes.Buffer, headers []*header) {
+	blank := false
+	written := false
+
+	for _, h := range headers {
+		t := strings.TrimSpace(h.raw)
+		if t == "" {
+			blank = written
+			continue
+		}
+		if blank {
+			b.WriteString("\n")
+			blank = false
+		}
+		written = true
+
+		if h.key == "" {
+			b.WriteString(t + "\n")
+			continue
+		}
+		b.WriteString(headerName(h.key) + ":")
+		if h.value != "" {
+			b.WriteString(" " + h.value)
+		}
+		if h.comment != "" {
+			b.WriteString(" " + h.comment)
+		}
+		b.WriteString("\n")
+		for _, c := range h.cont {
+			b.WriteString(indent + c + "\n")
+		}
+	}
+}
+
+// formatSection writes a section identifier and its body.
+func formatSection(b *bytes.Buffer, sect *section) {
+	b.WriteString("%" + sect.name)
+	if sect.args != "" {
+		b.WriteString(" " + sect.args)
+	}
+	b.WriteString("\n")
+
+	body := trimBlank(sect.body)
+	heredoc := false
+	for _, l := range body {
+		if strings.Contains(l, "<<") {
+			heredoc = true
+			break
+		}
+	}
+	if heredoc {
+		for _, l := range body {
+			b.WriteString(l + "\n")
+		}
+		return
+	}
+
+	prefix := commonIndent(body)
+	blank := false
+	for _, l := range body {
+		l = strings.TrimRight(l, " \t")
+		if l == "" {
+			blank = true
+			continue
+		}
+		if blank {
+			b.WriteString("\n")
+			blank = false
+		}
+		b.WriteString(indent + strings.TrimPrefix(l, prefix) + "\n")
+	}
+}
+
+// trimBlank removes the leading and trailing blank lines.
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// commonIndent returns the leading whitespace shared by all non
+// blank lines.
+func commonIndent(lines []string) string {
+	prefix := ""
+	first := true
+
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		ws := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+		if first {
+			prefix, first = ws, false
+			continue
+		}
+		for !strings.HasPrefix(ws, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
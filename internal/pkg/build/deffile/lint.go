@@ -0,0 +1,301 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package deffile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/plugin"
+	"github.com/sylabs/singularity/pkg/build/types/parser"
+	"mvdan.cc/sh/syntax"
+)
+
+// Severity is the severity of a problem found in a definition file.
+type Severity string
+
+const (
+	// Error is a problem making the build fail.
+	Error Severity = "error"
+	// Warning is a problem the build ignores but which is likely
+	// a mistake.
+	Warning Severity = "warning"
+)
+
+// Problem is a problem found in a definition file.
+type Problem struct {
+	Line     int
+	Severity Severity
+	Message  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%d: %s: %s", p.Line, p.Severity, p.Message)
+}
+
+// commonHeaders are the headers used by any bootstrap agent.
+var commonHeaders = []string{"bootstrap", "stage"}
+
+// agentHeaders lists the headers used by each bootstrap agent, the
+// first header listed is required when it is "from".
+var agentHeaders = map[string][]string{
+	"library":        {"from", "library"},
+	"oras":           {"from"},
+	"shub":           {"from"},
+	"localimage":     {"from"},
+	"docker":         {"from", "registry", "namespace", "includecmd"},
+	"docker-archive": {"from", "includecmd"},
+	"docker-daemon":  {"from", "includecmd"},
+	"oci":            {"from", "includecmd"},
+	"oci-archive":    {"from", "includecmd"},
+	"busybox":        {"mirrorurl"},
+	"debootstrap":    {"mirrorurl", "osversion", "include"},
+	"arch":           {},
+	"yum":            {"mirrorurl", "osversion", "include", "updateurl"},
+	"zypper": {
+		"mirrorurl", "osversion", "include", "updateurl", "product", "user",
+		"regcode", "productpgp", "registerurl", "modules", "otherurl&n",
+	},
+	"apk":     {"mirrorurl", "osversion", "include"},
+	"scratch": {},
+}
+
+// scriptSections are the sections holding shell scripts.
+var scriptSections = map[string]bool{
+	"setup":       true,
+	"pre":         true,
+	"post":        true,
+	"test":        true,
+	"runscript":   true,
+	"startscript": true,
+	"environment": true,
+	"appinstall":  true,
+	"apprun":      true,
+	"apptest":     true,
+	"appenv":      true,
+}
+
+// Lint checks the definition file raw and returns the problems found
+// sorted by line. Relative %files sources are resolved from the current
+// working directory, like the build does.
+func Lint(raw []byte) []Problem {
+	var problems []Problem
+
+	report := func(line int, sev Severity, format string, a ...interface{}) {
+		problems = append(problems, Problem{line, sev, fmt.Sprintf(format, a...)})
+	}
+
+	for _, s := range parse(raw) {
+		lintHeaders(s, report)
+		lintSections(s, report)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems
+}
+
+type reportFunc func(line int, sev Severity, format string, a ...interface{})
+
+// lintHeaders checks the stage headers against the headers used by
+// its bootstrap agent.
+func lintHeaders(s *stage, report reportFunc) {
+	// a stage made only of comments before the first
+	// Bootstrap line has nothing to check
+	if len(s.sections) == 0 {
+		if _, ok := s.value("bootstrap"); !ok {
+			return
+		}
+	}
+
+	seen := make(map[string]int)
+	line := 1
+	if len(s.headers) > 0 {
+		line = s.headers[0].line
+	} else if len(s.sections) > 0 {
+		line = s.sections[0].line
+	}
+
+	agent, ok := s.value("bootstrap")
+	if !ok {
+		report(line, Error, "no Bootstrap header found")
+	}
+	used, known := agentHeaders[agent]
+	if ok && !known {
+		if _, ok := plugin.GetBuildSource(agent); !ok {
+			report(line, Error, "unknown bootstrap agent %q", agent)
+		}
+	}
+
+	for _, h := range s.headers {
+		t := strings.TrimSpace(h.raw)
+		if h.key == "" {
+			if t != "" && t[0] != '#' {
+				report(h.line, Error, "header line %q has no keyword", t)
+			}
+			continue
+		}
+		if first, ok := seen[h.key]; ok {
+			report(h.line, Warning, "duplicate header %s, first defined on line %d", h.key, first)
+		} else {
+			seen[h.key] = h.line
+		}
+		if !parser.IsValidHeader(h.key) {
+			report(h.line, Error, "unknown header %s", h.key)
+		} else if known && !hasHeader(used, h.key) {
+			report(h.line, Warning, "header %s is not used by the %s bootstrap agent", h.key, agent)
+		}
+	}
+
+	if known && len(used) > 0 && used[0] == "from" {
+		if _, ok := s.value("from"); !ok {
+			report(line, Error, "the %s bootstrap agent requires a From header", agent)
+		}
+	}
+}
+
+// hasHeader returns whether key is one of the headers used, or
+// one of the headers common to all bootstrap agents.
+func hasHeader(used []string, key string) bool {
+	for _, headers := range [][]string{commonHeaders, used} {
+		for _, k := range headers {
+			if k == key {
+				return true
+			}
+			if strings.HasSuffix(k, "&n") && strings.HasPrefix(key, strings.TrimSuffix(k, "&n")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lintSections checks the stage sections.
+func lintSections(s *stage, report reportFunc) {
+	seen := make(map[string]int)
+	apps := make(map[string]bool)
+
+	for _, sect := range s.sections {
+		if !parser.IsValidSection(sect.name) {
+			report(sect.line, Error, "unknown section %%%s", sect.name)
+			continue
+		}
+
+		id := sect.name
+		if parser.IsAppSection(sect.name) {
+			if sect.args == "" {
+				report(sect.line, Error, "section %%%s has no app name", sect.name)
+				continue
+			}
+			id += " " + sect.app()
+			if sect.name == "appinstall" || sect.name == "apprun" {
+				apps[sect.app()] = true
+			}
+		}
+
+		// multiple %files sections are allowed to copy
+		// files from different stages
+		if sect.name != "files" {
+			if first, ok := seen[id]; ok {
+				report(sect.line, Warning, "duplicate section %%%s, first defined on line %d", id, first)
+			} else {
+				seen[id] = sect.line
+			}
+		}
+
+		switch {
+		case sect.name == "files" && sect.args == "", sect.name == "appfiles":
+			lintFiles(sect, report)
+		case scriptSections[sect.name]:
+			lintScript(sect, report)
+		}
+	}
+
+	for _, sect := range s.sections {
+		if parser.IsAppSection(sect.name) && sect.args != "" && !apps[sect.app()] {
+			report(sect.line, Warning, "section %%%s %s has no matching %%appinstall or %%apprun section", sect.name, sect.app())
+		}
+	}
+}
+
+// lintFiles checks that the sources of a %files section copying
+// files from the host exist.
+func lintFiles(sect *section, report reportFunc) {
+	for i, l := range sect.body {
+		f := strings.Fields(l)
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		matches, err := filepath.Glob(f[0])
+		if err != nil {
+			report(sect.line+i+1, Error, "bad source pattern %s: %s", f[0], err)
+		} else if len(matches) == 0 {
+			if _, err := os.Lstat(f[0]); err != nil {
+				report(sect.line+i+1, Error, "source %s doesn't exist", f[0])
+			}
+		}
+	}
+}
+
+// shellVariants maps the base name of the shell interpreters
+// to the language variant of their scripts.
+var shellVariants = map[string]syntax.LangVariant{
+	"sh":   syntax.LangPOSIX,
+	"dash": syntax.LangPOSIX,
+	"ash":  syntax.LangPOSIX,
+	"bash": syntax.LangBash,
+	"ksh":  syntax.LangMirBSDKorn,
+	"mksh": syntax.LangMirBSDKorn,
+}
+
+// scriptVariant returns the language variant of a script section
+// run by /bin/sh or by the interpreter given with -c in the section
+// arguments, false is returned if the interpreter isn't a known
+// shell.
+func scriptVariant(sect *section) (syntax.LangVariant, bool) {
+	interpreter := "/bin/sh"
+
+	args := strings.Fields(strings.Split(sect.args, "#")[0])
+	for i, a := range args {
+		if a == "-c" && i+1 < len(args) {
+			interpreter = args[i+1]
+			break
+		}
+	}
+
+	lang, ok := shellVariants[filepath.Base(interpreter)]
+	return lang, ok
+}
+
+// lintScript checks the shell syntax of a script section with a shell
+// parser, the scripts of other interpreters are not checked.
+func lintScript(sect *section, report reportFunc) {
+	lang, ok := scriptVariant(sect)
+	if !ok {
+		return
+	}
+
+	p := syntax.NewParser(syntax.Variant(lang))
+	_, err := p.Parse(strings.NewReader(strings.Join(sect.body, "\n")+"\n"), "")
+	if err == nil {
+		return
+	}
+
+	switch e := err.(type) {
+	case syntax.ParseError:
+		report(sect.line+int(e.Pos.Line()), Error, "%%%s: %s", sect.name, e.Text)
+	case syntax.LangError:
+		// the message follows the line and column numbers
+		msg := strings.SplitN(e.Error(), ": ", 2)[1]
+		report(sect.line+int(e.Pos.Line()), Error, "%%%s: %s", sect.name, msg)
+	default:
+		report(sect.line, Error, "%%%s: %s", sect.name, err)
+	}
+}
@@ -267,6 +267,9 @@ func addBuildLabels(labels map[string]string, b *types.Bundle) error {
 
 	// build date and time, lots of time formatting
 	currentTime := time.Now()
+	if b.Opts.SourceDateEpoch != nil {
+		currentTime = b.Opts.SourceDateEpoch.UTC()
+	}
 	year, month, day := currentTime.Date()
 	date := strconv.Itoa(day) + `_` + month.String() + `_` + strconv.Itoa(year)
 	hour, min, sec := currentTime.Clock()
@@ -0,0 +1,75 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// mountpoint creates the mount point dir in rootfs if it doesn't exist,
+// as an empty file if file is true, and returns a function removing the
+// directories and file created. The function fails if anything was left
+// in the mount point, parent directories created are kept if the build
+// scripts wrote in them.
+func mountpoint(rootfs, dir string, file bool) (func() error, error) {
+	var created []string
+	leaf := ""
+
+	cleanup := func() error {
+		for i := len(created) - 1; i >= 0; i-- {
+			err := os.Remove(created[i])
+			if err == nil {
+				continue
+			}
+			if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.ENOTEMPTY && created[i] != leaf {
+				continue
+			}
+			return fmt.Errorf("while removing mount point %s: %s", dir, err)
+		}
+		return nil
+	}
+
+	path := rootfs
+	components := strings.Split(strings.Trim(filepath.Clean(dir), "/"), "/")
+	for i, d := range components {
+		path = filepath.Join(path, d)
+		isFile := file && i == len(components)-1
+		// symbolic links are not followed as they could
+		// point outside of the root filesystem
+		fi, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			if isFile {
+				err = ioutil.WriteFile(path, nil, 0755)
+			} else {
+				err = os.Mkdir(path, 0755)
+			}
+			if err != nil {
+				cleanup()
+				return nil, fmt.Errorf("while creating mount point %s: %s", dir, err)
+			}
+			created = append(created, path)
+			leaf = path
+			continue
+		} else if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("while creating mount point %s: %s", dir, err)
+		}
+		if isFile && !fi.Mode().IsRegular() {
+			cleanup()
+			return nil, fmt.Errorf("can't mount %s: %s is not a file in the container", dir, strings.TrimPrefix(path, rootfs))
+		} else if !isFile && !fi.IsDir() {
+			cleanup()
+			return nil, fmt.Errorf("can't mount %s: %s is not a directory in the container", dir, strings.TrimPrefix(path, rootfs))
+		}
+	}
+
+	return cleanup, nil
+}
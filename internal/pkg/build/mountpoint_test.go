@@ -0,0 +1,126 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMountpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mountpoint-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := filepath.Join(dir, "run")
+	secrets := filepath.Join(run, "secrets")
+
+	// mount point created and removed
+	cleanup, err := mountpoint(dir, "/run/secrets", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fi, err := os.Stat(secrets); err != nil || !fi.IsDir() {
+		t.Fatalf("mount point not created: %v", err)
+	}
+	if err := cleanup(); err != nil {
+		t.Fatalf("unexpected cleanup error: %s", err)
+	}
+	if _, err := os.Stat(run); !os.IsNotExist(err) {
+		t.Fatalf("mount point not removed: %v", err)
+	}
+
+	// existing directories are kept
+	if err := os.MkdirAll(secrets, 0755); err != nil {
+		t.Fatalf("failed to create %s: %s", secrets, err)
+	}
+	cleanup, err = mountpoint(dir, "/run/secrets", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := cleanup(); err != nil {
+		t.Fatalf("unexpected cleanup error: %s", err)
+	}
+	if _, err := os.Stat(secrets); err != nil {
+		t.Fatalf("existing mount point removed: %s", err)
+	}
+	if err := os.RemoveAll(run); err != nil {
+		t.Fatalf("failed to remove %s: %s", run, err)
+	}
+
+	// parent directories written by build scripts are kept
+	cleanup, err = mountpoint(dir, "/run/secrets", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(run, "pid"), []byte("1"), 0644); err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+	if err := cleanup(); err != nil {
+		t.Fatalf("unexpected cleanup error: %s", err)
+	}
+	if _, err := os.Stat(secrets); !os.IsNotExist(err) {
+		t.Fatalf("mount point not removed: %v", err)
+	}
+	if _, err := os.Stat(run); err != nil {
+		t.Fatalf("parent directory removed: %s", err)
+	}
+	if err := os.RemoveAll(run); err != nil {
+		t.Fatalf("failed to remove %s: %s", run, err)
+	}
+
+	// files left in the mount point fail the cleanup
+	cleanup, err = mountpoint(dir, "/run/secrets", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(secrets, "token"), []byte("secret"), 0600); err != nil {
+		t.Fatalf("failed to write secret: %s", err)
+	}
+	if err := cleanup(); err == nil {
+		t.Fatalf("unexpected cleanup success with files left in mount point")
+	}
+	if err := os.RemoveAll(run); err != nil {
+		t.Fatalf("failed to remove %s: %s", run, err)
+	}
+
+	// file mount points
+	cleanup, err = mountpoint(dir, "/usr/bin/qemu-aarch64-static", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	emulator := filepath.Join(dir, "usr", "bin", "qemu-aarch64-static")
+	if fi, err := os.Stat(emulator); err != nil || !fi.Mode().IsRegular() {
+		t.Fatalf("file mount point not created: %v", err)
+	}
+	if err := cleanup(); err != nil {
+		t.Fatalf("unexpected cleanup error: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "usr")); !os.IsNotExist(err) {
+		t.Fatalf("file mount point not removed: %v", err)
+	}
+	if err := os.Mkdir(run, 0755); err != nil {
+		t.Fatalf("failed to create %s: %s", run, err)
+	}
+	if _, err := mountpoint(dir, "/run", true); err == nil {
+		t.Fatalf("unexpected success with directory as file mount point")
+	}
+	if err := os.Remove(run); err != nil {
+		t.Fatalf("failed to remove %s: %s", run, err)
+	}
+
+	// symbolic links are not followed
+	if err := os.Symlink("/run", run); err != nil {
+		t.Fatalf("failed to create symlink: %s", err)
+	}
+	if _, err := mountpoint(dir, "/run/secrets", false); err == nil {
+		t.Fatalf("unexpected success with /run symbolic link")
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// clampTimes sets the access and modification times of all files
+// in rootfs newer than t to t, symbolic links are not followed.
+func clampTimes(rootfs string, t time.Time) error {
+	ts := unix.NsecToTimespec(t.UnixNano())
+	times := []unix.Timespec{ts, ts}
+
+	return filepath.Walk(rootfs, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.ModTime().After(t) {
+			return nil
+		}
+		if err := unix.UtimesNanoAt(unix.AT_FDCWD, path, times, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return fmt.Errorf("while setting times of %s: %s", path, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClampTimes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clamp-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	epoch := time.Unix(1500000000, 0)
+	old := time.Unix(1000000000, 0)
+
+	newFile := filepath.Join(dir, "new")
+	oldFile := filepath.Join(dir, "old")
+	link := filepath.Join(dir, "link")
+
+	for _, f := range []string{newFile, oldFile} {
+		if err := ioutil.WriteFile(f, []byte("test"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %s", f, err)
+		}
+	}
+	if err := os.Chtimes(oldFile, old, old); err != nil {
+		t.Fatalf("failed to set times of %s: %s", oldFile, err)
+	}
+	if err := os.Symlink("missing", link); err != nil {
+		t.Fatalf("failed to create symlink: %s", err)
+	}
+
+	if err := clampTimes(dir, epoch); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		path string
+		time time.Time
+	}{
+		{dir, epoch},
+		{newFile, epoch},
+		{oldFile, old},
+		{link, epoch},
+	}
+
+	for _, tt := range tests {
+		fi, err := os.Lstat(tt.path)
+		if err != nil {
+			t.Fatalf("failed to stat %s: %s", tt.path, err)
+		}
+		if !fi.ModTime().Equal(tt.time) {
+			t.Errorf("unexpected modification time for %s: %s instead of %s", tt.path, fi.ModTime(), tt.time)
+		}
+	}
+}
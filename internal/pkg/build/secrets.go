@@ -0,0 +1,43 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ParseSecret parses a secret specification of the form id=name,src=path
+// and returns the ID and the host source file of the secret. The ID is
+// the base name of the source file if not specified.
+func ParseSecret(spec string) (id, src string, err error) {
+	for _, field := range strings.Split(spec, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return "", "", fmt.Errorf("invalid secret %q: %q must be key=value", spec, field)
+		}
+		switch kv[0] {
+		case "id":
+			id = kv[1]
+		case "src", "source":
+			src = kv[1]
+		default:
+			return "", "", fmt.Errorf("invalid secret %q: unknown key %q", spec, kv[0])
+		}
+	}
+
+	if src == "" {
+		return "", "", fmt.Errorf("invalid secret %q: missing src", spec)
+	}
+	if id == "" {
+		id = filepath.Base(src)
+	}
+	if id == "." || id == ".." || strings.ContainsRune(id, '/') {
+		return "", "", fmt.Errorf("invalid secret %q: %q is not a valid ID", spec, id)
+	}
+	return id, src, nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"testing"
+)
+
+func TestParseSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		id      string
+		src     string
+		wantErr bool
+	}{
+		{name: "IDAndSource", spec: "id=token,src=/tmp/token.txt", id: "token", src: "/tmp/token.txt"},
+		{name: "SourceFirst", spec: "source=/tmp/pip.conf,id=pip", id: "pip", src: "/tmp/pip.conf"},
+		{name: "DefaultID", spec: "src=/tmp/token.txt", id: "token.txt", src: "/tmp/token.txt"},
+		{name: "NoSource", spec: "id=token", wantErr: true},
+		{name: "NotKeyValue", spec: "/tmp/token.txt", wantErr: true},
+		{name: "UnknownKey", spec: "id=token,src=/tmp/token.txt,mode=0600", wantErr: true},
+		{name: "IDWithSlash", spec: "id=../token,src=/tmp/token.txt", wantErr: true},
+		{name: "DotID", spec: "id=..,src=/tmp/token.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, src, err := ParseSecret(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if id != tt.id || src != tt.src {
+				t.Errorf("got id=%q src=%q, want id=%q src=%q", id, src, tt.id, tt.src)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/pkg/build/types"
+	"github.com/sylabs/singularity/pkg/util/namespaces"
+)
+
+const (
+	apkDefaultVersion = "latest-stable"
+	apkKeysDir        = "/etc/apk/keys"
+	apkRepositories   = "/etc/apk/repositories"
+)
+
+// apkArch maps Go architectures to Alpine architectures
+var apkArch = map[string]string{
+	"386":     "x86",
+	"amd64":   "x86_64",
+	"arm":     "armhf",
+	"arm64":   "aarch64",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+}
+
+// ApkConveyorPacker holds stuff that needs to be packed into the bundle
+type ApkConveyorPacker struct {
+	b         *types.Bundle
+	mirrorurl string
+	osversion string
+	include   []string
+}
+
+// Get downloads container information from the specified source
+func (cp *ApkConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err error) {
+	cp.b = b
+
+	// prefer the static version of apk-tools on non Alpine hosts
+	apkPath, err := exec.LookPath("apk.static")
+	if err != nil {
+		apkPath, err = exec.LookPath("apk")
+		if err != nil {
+			return fmt.Errorf("neither apk.static nor apk in PATH... Perhaps install apk-tools-static: %v", err)
+		}
+	}
+
+	if err = cp.getRecipeHeaderInfo(); err != nil {
+		return err
+	}
+
+	if os.Getuid() != 0 {
+		return fmt.Errorf("you must be root to build with apk")
+	}
+
+	arch, ok := apkArch[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("architecture %s is not supported by apk", runtime.GOARCH)
+	}
+
+	// packages signatures are verified with the host keys
+	if !fs.IsDir(apkKeysDir) {
+		return fmt.Errorf("no apk signing keys found in %s, install alpine-keys on the host", apkKeysDir)
+	}
+
+	insideUserNs, _ := namespaces.IsInsideUserNamespace(os.Getpid())
+	if insideUserNs {
+		// unlocked once the environment is unmounted
+		fakerootEnvMutex.Lock()
+		defer fakerootEnvMutex.Unlock()
+
+		umountFn, err := cp.prepareFakerootEnv(ctx)
+		if umountFn != nil {
+			defer umountFn()
+		}
+		if err != nil {
+			return fmt.Errorf("while preparing fakeroot build environment: %s", err)
+		}
+	}
+
+	args := []string{
+		`--root`, cp.b.RootfsPath,
+		`--initdb`,
+		`--update-cache`,
+		`--no-progress`,
+		`--arch`, arch,
+		`--keys-dir`, apkKeysDir,
+	}
+	for _, repo := range cp.repositories() {
+		args = append(args, `--repository`, repo)
+	}
+	args = append(args, `add`)
+	args = append(args, cp.include...)
+
+	sylog.Debugf("\n\tApk Path: %s\n\tIncludes: %s\n\tDetected Arch: %s\n\tOSVersion: %s\n\tMirrorURL: %s\n", apkPath, strings.Join(cp.include, " "), arch, cp.osversion, cp.mirrorurl)
+
+	cmd := exec.CommandContext(ctx, apkPath, args...)
+	cmd.Stdout = cp.b.Stdout
+	cmd.Stderr = cp.b.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("while bootstrapping: %v", err)
+	}
+
+	// package manager configuration for %post
+	content := strings.Join(cp.repositories(), "\n") + "\n"
+	if err := ioutil.WriteFile(filepath.Join(cp.b.RootfsPath, apkRepositories), []byte(content), 0644); err != nil {
+		return fmt.Errorf("while writing %s: %v", apkRepositories, err)
+	}
+
+	// clean up apk cache
+	os.RemoveAll(filepath.Join(cp.b.RootfsPath, "/var/cache/apk"))
+
+	return nil
+}
+
+// Pack puts relevant objects in a Bundle!
+func (cp *ApkConveyorPacker) Pack(context.Context) (*types.Bundle, error) {
+	//change root directory permissions to 0755
+	if err := os.Chmod(cp.b.RootfsPath, 0755); err != nil {
+		return nil, fmt.Errorf("while changing bundle rootfs perms: %v", err)
+	}
+
+	if err := makeBaseEnv(cp.b.RootfsPath); err != nil {
+		return nil, fmt.Errorf("while inserting base environment: %v", err)
+	}
+
+	runscript := filepath.Join(cp.b.RootfsPath, "/.singularity.d/runscript")
+	if err := ioutil.WriteFile(runscript, []byte("#!/bin/sh\n"), 0755); err != nil {
+		return nil, fmt.Errorf("while inserting runscript: %v", err)
+	}
+
+	return cp.b, nil
+}
+
+func (cp *ApkConveyorPacker) getRecipeHeaderInfo() error {
+	var ok bool
+
+	cp.mirrorurl, ok = cp.b.Recipe.Header["mirrorurl"]
+	if !ok {
+		return fmt.Errorf("invalid apk header, no mirrorurl specified")
+	}
+	cp.mirrorurl = strings.TrimSuffix(cp.mirrorurl, "/")
+
+	cp.osversion = cp.b.Recipe.Header["osversion"]
+	if cp.osversion == "" {
+		cp.osversion = apkDefaultVersion
+	}
+
+	// alpine-base is always installed, packages can be pinned
+	// with the apk syntax, e.g. python3=3.7.5-r1
+	cp.include = append([]string{"alpine-base"}, strings.Fields(cp.b.Recipe.Header["include"])...)
+	cp.include = append(cp.include, strings.Fields(os.Getenv("INCLUDE"))...)
+
+	return nil
+}
+
+// repositories returns the main and community repositories of the
+// Alpine version, %{OSVERSION} in mirrorurl is replaced by the version
+// for mirrors with a different layout.
+func (cp *ApkConveyorPacker) repositories() []string {
+	if strings.Contains(cp.mirrorurl, `%{OSVERSION}`) {
+		return []string{strings.Replace(cp.mirrorurl, `%{OSVERSION}`, cp.osversion, -1)}
+	}
+	return []string{
+		fmt.Sprintf("%s/%s/main", cp.mirrorurl, cp.osversion),
+		fmt.Sprintf("%s/%s/community", cp.mirrorurl, cp.osversion),
+	}
+}
+
+// CleanUp removes any tmpfs owned by the conveyorPacker on the filesystem
+func (cp *ApkConveyorPacker) CleanUp() {
+	cp.b.Remove()
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+)
+
+// prepareFakerootEnv prepares a build environment to make fakeroot
+// working with apk, package scripts require the character devices
+// which can't be created in a user namespace.
+func (cp *ApkConveyorPacker) prepareFakerootEnv(ctx context.Context) (func(), error) {
+	devs := []string{
+		"/dev/null",
+		"/dev/random",
+		"/dev/urandom",
+		"/dev/zero",
+	}
+
+	devPath := filepath.Join(cp.b.RootfsPath, "dev")
+	if err := os.MkdirAll(devPath, 0755); err != nil {
+		return nil, fmt.Errorf("while creating %s: %s", devPath, err)
+	}
+
+	umountFn := func() {
+		for _, d := range devs {
+			path := filepath.Join(cp.b.RootfsPath, d)
+			syscall.Unmount(path, syscall.MNT_DETACH)
+		}
+	}
+
+	for _, p := range devs {
+		rootfsPath := filepath.Join(cp.b.RootfsPath, p)
+		if err := fs.Touch(rootfsPath); err != nil {
+			return umountFn, fmt.Errorf("while creating %s: %s", rootfsPath, err)
+		}
+		if err := syscall.Mount(p, rootfsPath, "", syscall.MS_BIND, ""); err != nil {
+			return umountFn, fmt.Errorf("while mounting %s to %s: %s", p, rootfsPath, err)
+		}
+	}
+
+	return umountFn, nil
+}
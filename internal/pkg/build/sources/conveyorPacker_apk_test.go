@@ -0,0 +1,62 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/build/sources"
+	"github.com/sylabs/singularity/internal/pkg/test"
+	"github.com/sylabs/singularity/pkg/build/types"
+)
+
+func TestApkConveyorPacker(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	if _, err := exec.LookPath("apk.static"); err != nil {
+		if _, err := exec.LookPath("apk"); err != nil {
+			t.Skip("skipping test, apk not installed")
+		}
+	}
+
+	test.EnsurePrivilege(t)
+
+	b, err := types.NewBundle(filepath.Join(os.TempDir(), "sbuild-apk"), os.TempDir())
+	if err != nil {
+		return
+	}
+
+	b.Recipe.Header = map[string]string{
+		"bootstrap": "apk",
+		"osversion": "v3.10",
+		"mirrorurl": "http://dl-cdn.alpinelinux.org/alpine/",
+		"include":   "bash",
+	}
+
+	cp := sources.ApkConveyorPacker{}
+
+	err = cp.Get(context.Background(), b)
+	// clean up tmpfs since assembler isnt called
+	defer cp.CleanUp()
+	if err != nil {
+		t.Fatalf("Apk Get failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(b.RootfsPath, "bin/bash")); err != nil {
+		t.Errorf("included package not installed: %v", err)
+	}
+
+	_, err = cp.Pack(context.Background())
+	if err != nil {
+		t.Fatalf("Apk Pack failed: %v", err)
+	}
+}
@@ -89,6 +89,10 @@ func (cp *ArchConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err err
 
 	insideUserNs, setgroupsAllowed := namespaces.IsInsideUserNamespace(os.Getpid())
 	if insideUserNs && setgroupsAllowed {
+		// unlocked once the environment is unmounted
+		fakerootEnvMutex.Lock()
+		defer fakerootEnvMutex.Unlock()
+
 		umountFn, err := cp.prepareFakerootEnv(ctx)
 		if umountFn != nil {
 			defer umountFn()
@@ -102,8 +106,8 @@ func (cp *ArchConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err err
 	args = append(args, instList...)
 
 	pacCmd := exec.Command(pacstrapPath, args...)
-	pacCmd.Stdout = os.Stdout
-	pacCmd.Stderr = os.Stderr
+	pacCmd.Stdout = cp.b.Stdout
+	pacCmd.Stderr = cp.b.Stderr
 	sylog.Debugf("\n\tPacstrap Path: %s\n\tPac Conf: %s\n\tRootfs: %s\n\tInstall List: %s\n", pacstrapPath, pacConf, cp.b.RootfsPath, instList)
 
 	if err = pacCmd.Run(); err != nil {
@@ -112,16 +116,16 @@ func (cp *ArchConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err err
 
 	//Pacman package signing setup
 	cmd := exec.Command("arch-chroot", cp.b.RootfsPath, "/bin/sh", "-c", "haveged -w 1024; pacman-key --init; pacman-key --populate archlinux")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = cp.b.Stdout
+	cmd.Stderr = cp.b.Stderr
 	if err = cmd.Run(); err != nil {
 		return fmt.Errorf("while setting up package signing: %v", err)
 	}
 
 	//Clean up haveged
 	cmd = exec.Command("arch-chroot", cp.b.RootfsPath, "pacman", "-Rs", "--noconfirm", "haveged")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = cp.b.Stdout
+	cmd.Stderr = cp.b.Stderr
 	if err = cmd.Run(); err != nil {
 		return fmt.Errorf("while cleaning up packages: %v", err)
 	}
@@ -16,12 +16,18 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/build/types"
 	"github.com/sylabs/singularity/pkg/util/namespaces"
 )
 
+// fakerootEnvMutex serializes the stages built concurrently which
+// prepare a fakeroot build environment, as they all mount over the
+// same host paths.
+var fakerootEnvMutex sync.Mutex
+
 // DebootstrapConveyorPacker holds stuff that needs to be packed into the bundle
 type DebootstrapConveyorPacker struct {
 	b         *types.Bundle
@@ -50,6 +56,10 @@ func (cp *DebootstrapConveyorPacker) Get(ctx context.Context, b *types.Bundle) (
 
 	insideUserNs, setgroupsAllowed := namespaces.IsInsideUserNamespace(os.Getpid())
 	if insideUserNs && setgroupsAllowed {
+		// unlocked once the environment is unmounted
+		fakerootEnvMutex.Lock()
+		defer fakerootEnvMutex.Unlock()
+
 		umountFn, err := cp.prepareFakerootEnv(ctx)
 		if umountFn != nil {
 			defer umountFn()
@@ -67,7 +77,7 @@ func (cp *DebootstrapConveyorPacker) Get(ctx context.Context, b *types.Bundle) (
 	// run debootstrap
 	out, err := cmd.CombinedOutput()
 
-	io.Copy(os.Stdout, bytes.NewReader(out))
+	io.Copy(cp.b.Stdout, bytes.NewReader(out))
 
 	if err != nil {
 		dumpLog := func(fn string) {
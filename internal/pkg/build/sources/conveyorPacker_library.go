@@ -62,9 +62,14 @@ func (cp *LibraryConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err
 
 	imageRef := library.NormalizeLibraryRef(b.Recipe.Header["from"])
 
-	libraryImage, err := libraryClient.GetImage(ctx, runtime.GOARCH, imageRef)
+	arch := b.Opts.Arch
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+
+	libraryImage, err := libraryClient.GetImage(ctx, arch, imageRef)
 	if err == client.ErrNotFound {
-		return fmt.Errorf("image does not exist in the library: %s (%s)", imageRef, runtime.GOARCH)
+		return fmt.Errorf("image does not exist in the library: %s (%s)", imageRef, arch)
 	}
 	if err != nil {
 		return fmt.Errorf("while getting image info: %v", err)
@@ -81,9 +86,9 @@ func (cp *LibraryConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err
 
 		imagePath = file.Name()
 
-		sylog.Infof("Downloading library image to tmp cache: %s", imagePath)
+		sylog.NewLogger(cp.b.Stderr).Infof("Downloading library image to tmp cache: %s", imagePath)
 
-		if err = library.DownloadImageNoProgress(ctx, libraryClient, imagePath, runtime.GOARCH, imageRef); err != nil {
+		if err = library.DownloadImageNoProgress(ctx, libraryClient, imagePath, arch, imageRef); err != nil {
 			return fmt.Errorf("unable to download image: %v", err)
 		}
 	} else {
@@ -92,9 +97,9 @@ func (cp *LibraryConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err
 		if exists, err := b.Opts.ImgCache.LibraryImageExists(libraryImage.Hash, imageName); err != nil {
 			return fmt.Errorf("unable to check if %v exists: %v", imagePath, err)
 		} else if !exists {
-			sylog.Infof("Downloading library image")
+			sylog.NewLogger(cp.b.Stderr).Infof("Downloading library image")
 
-			if err := library.DownloadImageNoProgress(ctx, libraryClient, imagePath, runtime.GOARCH, imageRef); err != nil {
+			if err := library.DownloadImageNoProgress(ctx, libraryClient, imagePath, arch, imageRef); err != nil {
 				return fmt.Errorf("unable to download image: %v", err)
 			}
 
@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"runtime"
 
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/build/types"
@@ -34,8 +35,14 @@ type LocalConveyorPacker struct {
 
 // GetLocalPacker ...
 func GetLocalPacker(src string, b *types.Bundle) (LocalPacker, error) {
+	// the root filesystem of multi-architecture images is taken
+	// for the architecture requested in the build options
+	arch := b.Opts.Arch
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
 
-	imageObject, err := image.Init(src, false)
+	imageObject, err := image.InitArch(src, false, arch)
 	if err != nil {
 		return nil, err
 	}
@@ -61,6 +61,7 @@ func (cp *OCIConveyorPacker) Get(ctx context.Context, b *sytypes.Bundle) (err er
 		DockerInsecureSkipTLSVerify: cp.b.Opts.NoHTTPS,
 		DockerAuthConfig:            cp.b.Opts.DockerAuthConfig,
 		OSChoice:                    "linux",
+		ArchitectureChoice:          cp.b.Opts.Arch,
 	}
 
 	// add registry and namespace to reference if specified
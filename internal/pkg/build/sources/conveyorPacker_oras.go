@@ -40,7 +40,7 @@ func (cp *OrasConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err err
 	if exists, err := b.Opts.ImgCache.OrasImageExists(sum, imageName); err != nil {
 		return fmt.Errorf("unable to check if %v exists: %v", cacheImagePath, err)
 	} else if !exists {
-		sylog.Infof("Downloading image with ORAS")
+		sylog.NewLogger(b.Stderr).Infof("Downloading image with ORAS")
 
 		if err := oras.DownloadImage(cacheImagePath, ref, b.Opts.DockerAuthConfig); err != nil {
 			return fmt.Errorf("unable to Download Image: %v", err)
@@ -86,7 +86,7 @@ func (c *YumConveyor) Get(ctx context.Context, b *types.Bundle) (err error) {
 	sylog.Debugf("\n\tInstall Command Path: %s\n\tDetected Arch: %s\n\tOSVersion: %s\n\tMirrorURL: %s\n\tUpdateURL: %s\n\tIncludes: %s\n", installCommandPath, runtime.GOARCH, c.osversion, c.mirrorurl, c.updateurl, c.include)
 	cmd := exec.Command(installCommandPath, args...)
 	// cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = c.b.Stderr
 	if err = cmd.Run(); err != nil {
 		return fmt.Errorf("while bootstrapping: %v", err)
 	}
@@ -269,14 +269,14 @@ func (c *YumConveyor) genYumConfig() (err error) {
 			return fmt.Errorf("while importing gpg key: %v", err)
 		}
 	} else {
-		sylog.Infof("Skipping GPG Key Import")
+		sylog.NewLogger(c.b.Stderr).Infof("Skipping GPG Key Import")
 	}
 
 	return nil
 }
 
 func (c *YumConveyor) importGPGKey() (err error) {
-	sylog.Infof("We have a GPG key!  Preparing RPM database.")
+	sylog.NewLogger(c.b.Stderr).Infof("We have a GPG key!  Preparing RPM database.")
 
 	// make sure gpg is being imported over https
 	if !strings.HasPrefix(c.gpg, "https://") {
@@ -289,20 +289,20 @@ func (c *YumConveyor) importGPGKey() (err error) {
 	}
 
 	cmd := exec.Command(c.rpmPath, "--root", c.b.RootfsPath, "--initdb")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = c.b.Stdout
+	cmd.Stderr = c.b.Stderr
 	if err = cmd.Run(); err != nil {
 		return fmt.Errorf("while initializing new rpm db: %v", err)
 	}
 
 	cmd = exec.Command(c.rpmPath, "--root", c.b.RootfsPath, "--import", c.gpg)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = c.b.Stdout
+	cmd.Stderr = c.b.Stderr
 	if err = cmd.Run(); err != nil {
 		return fmt.Errorf("while importing gpg key with rpm: %v", err)
 	}
 
-	sylog.Infof("GPG key import complete!")
+	sylog.NewLogger(c.b.Stderr).Infof("GPG key import complete!")
 
 	return nil
 }
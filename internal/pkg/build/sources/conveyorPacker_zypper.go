@@ -213,22 +213,22 @@ func (cp *ZypperConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err e
 	// Add mirrorURL/installURL as repo
 	if mirrorurl != "" {
 		cmd := exec.Command(zypperPath, `--root`, cp.b.RootfsPath, `ar`, mirrorurl, `repo`)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = cp.b.Stdout
+		cmd.Stderr = cp.b.Stderr
 		if err = cmd.Run(); err != nil {
 			return fmt.Errorf("while adding zypper mirror: %v", err)
 		}
 		// Refreshing gpg keys
 		cmd = exec.Command(zypperPath, `--root`, cp.b.RootfsPath, `--gpg-auto-import-keys`, `refresh`)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = cp.b.Stdout
+		cmd.Stderr = cp.b.Stderr
 		if err = cmd.Run(); err != nil {
 			return fmt.Errorf("while refreshing gpg keys: %v", err)
 		}
 		if updateurl != "" {
 			cmd := exec.Command(zypperPath, `--root`, cp.b.RootfsPath, `ar`, `-f`, updateurl, `update`)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
+			cmd.Stdout = cp.b.Stdout
+			cmd.Stderr = cp.b.Stderr
 			if err = cmd.Run(); err != nil {
 				return fmt.Errorf("while adding zypper update: %v", err)
 			}
@@ -260,8 +260,8 @@ func (cp *ZypperConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err e
 			return fmt.Errorf("cannot create rpm symlink")
 		}
 		cmd := exec.Command("rpmkeys", `--root`, cp.b.RootfsPath, `--import`, pgpfile)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = cp.b.Stdout
+		cmd.Stderr = cp.b.Stderr
 		if err = cmd.Run(); err != nil {
 			return fmt.Errorf("while importing pgp keys: %v", err)
 		}
@@ -279,8 +279,8 @@ func (cp *ZypperConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err e
 			args = append(args, `--url`, sleurl)
 		}
 		cmd := exec.Command(suseconnectPath, args...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = cp.b.Stdout
+		cmd.Stderr = cp.b.Stderr
 		if err = cmd.Run(); err != nil {
 			return fmt.Errorf("while registering: %v", err)
 		}
@@ -290,8 +290,8 @@ func (cp *ZypperConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err e
 				array[i] = strings.TrimSpace(array[i])
 				cmd := exec.Command(suseconnectPath, `--root`, cp.b.RootfsPath,
 					`--product`, array[i]+`/`+suseconnectModver)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
+				cmd.Stdout = cp.b.Stdout
+				cmd.Stderr = cp.b.Stderr
 				if err = cmd.Run(); err != nil {
 					return fmt.Errorf("while registering: %v", err)
 				}
@@ -301,8 +301,8 @@ func (cp *ZypperConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err e
 	for i := 0; otherurl[i] != ""; i++ {
 		sID := strconv.Itoa(i)
 		cmd := exec.Command(zypperPath, `--root`, cp.b.RootfsPath, `ar`, `-f`, otherurl[i], `repo-`+sID)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = cp.b.Stdout
+		cmd.Stderr = cp.b.Stderr
 		if err = cmd.Run(); err != nil {
 			return fmt.Errorf("while adding zypper url: %s %v", otherurl[i], err)
 		}
@@ -317,15 +317,15 @@ func (cp *ZypperConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err e
 
 	// Zypper install command
 	cmd := exec.Command(zypperPath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = cp.b.Stdout
+	cmd.Stderr = cp.b.Stderr
 
 	sylog.Debugf("\n\tZypper Path: %s\n\tDetected Arch: %s\n\tOSVersion: %s\n\tMirrorURL: %s\n\tIncludes: %s\n", zypperPath, runtime.GOARCH, osversion, mirrorurl, include)
 
 	// run zypper
 	if err = cmd.Run(); err != nil {
 		if ret, _ := system.GetExitCode(err); ret == 107 {
-			sylog.Warningf("Bootstrap succeeded, some RPM scripts failed")
+			sylog.NewLogger(cp.b.Stderr).Warningf("Bootstrap succeeded, some RPM scripts failed")
 		} else {
 			return fmt.Errorf("while bootstrapping from zypper: %v", err)
 		}
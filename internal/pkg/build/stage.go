@@ -6,12 +6,17 @@
 package build
 
 import (
+	"bytes"
 	"fmt"
-	"os"
+	"io"
 	"os/exec"
+	"runtime"
+	"strconv"
 	"syscall"
 
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/arch"
+	"github.com/sylabs/singularity/internal/pkg/util/binfmt"
 	"github.com/sylabs/singularity/pkg/build/types"
 )
 
@@ -25,6 +30,79 @@ type stage struct {
 	a Assembler
 	// b is an intermediate structure that encapsulates all information for the container, e.g., metadata, filesystems.
 	b *types.Bundle
+	// started is true once the stage build started.
+	started bool
+	// binfmt is the binfmt_misc handler running the stage scripts
+	// with QEMU user emulation, nil if they run natively.
+	binfmt *binfmt.Handler
+	// stdout and stderr receive the output of the stage scripts.
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// displayName returns the stage name, or its position in the
+// definition file if unnamed.
+func (s *stage) displayName(index int) string {
+	if s.name != "" {
+		return s.name
+	}
+	return strconv.Itoa(index + 1)
+}
+
+// logger returns the logger writing the stage messages along with the
+// stage output, so they are prefixed with the stage name too.
+func (s *stage) logger() *sylog.Logger {
+	return sylog.NewLogger(s.stderr)
+}
+
+// flush writes the last incomplete line of the stage output.
+func (s *stage) flush() {
+	for _, w := range []io.Writer{s.stdout, s.stderr} {
+		if p, ok := w.(*prefixWriter); ok {
+			p.Flush()
+		}
+	}
+}
+
+// prefixWriter prefixes each line written with a stage name, so the
+// output of stages built concurrently can be told apart.
+type prefixWriter struct {
+	prefix []byte
+	w      io.Writer
+	buf    []byte
+}
+
+func newPrefixWriter(prefix string, w io.Writer) *prefixWriter {
+	return &prefixWriter{prefix: []byte(prefix), w: w}
+}
+
+// Write writes complete lines with the prefix and buffers the last
+// incomplete line.
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		// a line is written at once to not be mixed with other stages
+		line := append(append([]byte{}, p.prefix...), p.buf[:i+1]...)
+		if _, err := p.w.Write(line); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes the buffered incomplete line if any.
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	_, err := p.Write([]byte("\n"))
+	return err
 }
 
 // Assemble assembles the bundle to the specified path.
@@ -41,10 +119,10 @@ func (s *stage) runPreScript() error {
 
 		// Run %pre script here
 		pre := exec.Command("/bin/sh", "-cex", s.b.Recipe.BuildData.Pre.Script)
-		pre.Stdout = os.Stdout
-		pre.Stderr = os.Stderr
+		pre.Stdout = s.stdout
+		pre.Stderr = s.stderr
 
-		sylog.Infof("Running pre scriptlet")
+		s.logger().Infof("Running pre scriptlet")
 		if err := pre.Start(); err != nil {
 			return fmt.Errorf("failed to start %%pre proc: %v", err)
 		}
@@ -54,3 +132,39 @@ func (s *stage) runPreScript() error {
 	}
 	return nil
 }
+
+// setupEmulation records the architecture of the stage root filesystem
+// in the bundle. If it differs from the host architecture and scripts
+// run in the container, they run with the QEMU user emulation of its
+// binfmt_misc handler, registered if needed. conda is true if a conda
+// environment is installed, its packages may run scripts too.
+func (s *stage) setupEmulation(conda bool) error {
+	a, err := arch.Rootfs(s.b.RootfsPath)
+	if err != nil || a == arch.Unknown {
+		// images without shell can't run scripts anyway
+		sylog.Debugf("Unable to detect the architecture of the image: %v", err)
+		return nil
+	}
+	if s.b.Opts.Arch != "" && s.b.Opts.Arch != a {
+		s.logger().Warningf("Requested architecture %s but the bootstrap image targets %s", s.b.Opts.Arch, a)
+	}
+	s.b.Opts.Arch = a
+
+	scripts := s.b.Recipe.BuildData.Post.Script != "" || s.b.Recipe.BuildData.Test.Script != "" || conda
+	if a == runtime.GOARCH || !scripts {
+		return nil
+	}
+
+	h, err := binfmt.Ensure(a)
+	if err != nil {
+		return fmt.Errorf("image targets %s, its scripts can't run on %s: %s", a, runtime.GOARCH, err)
+	}
+	s.binfmt = h
+	s.logger().Infof("Running scripts of the %s image with %s", a, h.Interpreter)
+	// the interpreter is looked up in the container unless
+	// it was opened when the handler was registered
+	if !h.FixBinary {
+		s.b.Opts.Emulator = h.Interpreter
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		output string
+	}{
+		{"NoWrite", nil, ""},
+		{"Line", []string{"hello\n"}, "[s] hello\n"},
+		{"Lines", []string{"hello\nworld\n"}, "[s] hello\n[s] world\n"},
+		{"SplitLine", []string{"hel", "lo\nwor", "ld\n"}, "[s] hello\n[s] world\n"},
+		{"IncompleteLine", []string{"hello\nworld"}, "[s] hello\n[s] world\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w := newPrefixWriter("[s] ", &buf)
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				} else if n != len(s) {
+					t.Fatalf("unexpected write count %d instead of %d", n, len(s))
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if buf.String() != tt.output {
+				t.Errorf("unexpected output %q instead of %q", buf.String(), tt.output)
+			}
+		})
+	}
+}
+
+func TestRunGraph(t *testing.T) {
+	tests := []struct {
+		name        string
+		deps        [][]int
+		jobs        int
+		fail        int
+		run         []int
+		concurrency int
+		failed      int
+		wantErr     bool
+	}{
+		{
+			name:        "Sequential",
+			deps:        [][]int{nil, nil, nil},
+			jobs:        1,
+			fail:        -1,
+			run:         []int{0, 1, 2},
+			concurrency: 1,
+			failed:      -1,
+		},
+		{
+			name:        "Independent",
+			deps:        [][]int{nil, nil, nil, {0, 1, 2}},
+			jobs:        4,
+			fail:        -1,
+			run:         []int{0, 1, 2, 3},
+			concurrency: 3,
+			failed:      -1,
+		},
+		{
+			name:        "BoundedJobs",
+			deps:        [][]int{nil, nil, nil, {0, 1, 2}},
+			jobs:        2,
+			fail:        -1,
+			run:         []int{0, 1, 2, 3},
+			concurrency: 2,
+			failed:      -1,
+		},
+		{
+			name:        "ForwardDependency",
+			deps:        [][]int{{1}, nil},
+			jobs:        2,
+			fail:        -1,
+			run:         []int{0, 1},
+			concurrency: 1,
+			failed:      -1,
+		},
+		{
+			name:        "Failure",
+			deps:        [][]int{nil, {0}},
+			jobs:        2,
+			fail:        0,
+			run:         []int{0},
+			concurrency: 1,
+			failed:      0,
+			wantErr:     true,
+		},
+		{
+			name:        "Circular",
+			deps:        [][]int{nil, {2}, {1}},
+			jobs:        2,
+			fail:        -1,
+			run:         []int{0},
+			concurrency: 1,
+			failed:      -1,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+
+			finished := make([]bool, len(tt.deps))
+			running := 0
+			concurrency := 0
+
+			run := func(i int) error {
+				mu.Lock()
+				for _, d := range tt.deps[i] {
+					if !finished[d] {
+						t.Errorf("node %d started before its dependency %d", i, d)
+					}
+				}
+				running++
+				if running > concurrency {
+					concurrency = running
+				}
+				mu.Unlock()
+
+				// let independent nodes overlap
+				time.Sleep(50 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				finished[i] = true
+				mu.Unlock()
+
+				if i == tt.fail {
+					return fmt.Errorf("node %d failed", i)
+				}
+				return nil
+			}
+
+			failed, err := runGraph(tt.deps, tt.jobs, run)
+			if tt.wantErr && err == nil {
+				t.Fatalf("unexpected success")
+			} else if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if failed != tt.failed {
+				t.Errorf("unexpected failed node %d instead of %d", failed, tt.failed)
+			}
+			if concurrency != tt.concurrency {
+				t.Errorf("unexpected concurrency %d instead of %d", concurrency, tt.concurrency)
+			}
+
+			var nodes []int
+			for i, f := range finished {
+				if f {
+					nodes = append(nodes, i)
+				}
+			}
+			if fmt.Sprint(nodes) != fmt.Sprint(tt.run) {
+				t.Errorf("unexpected nodes run %v instead of %v", nodes, tt.run)
+			}
+		})
+	}
+}
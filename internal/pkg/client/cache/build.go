@@ -0,0 +1,46 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+)
+
+const (
+	// BuildDir is the directory inside the cache.Dir where the cache
+	// mounts of builds are persisted
+	BuildDir = "build"
+)
+
+// getBuildCachePath returns the directory inside the cache.Dir() where
+// the cache mounts of builds are persisted
+func getBuildCachePath(c *Handle) (string, error) {
+	// This function may act on a cache object that is not fully initialized
+	// so it is not a method on a Handle but rather an independent
+	// function
+
+	// updateCacheSubdir checks if the cache is valid, no need to check here
+	return updateCacheSubdir(c, BuildDir)
+}
+
+// BuildCache creates a directory inside cache.Dir() persisting the
+// content of the container directory target across builds, named with
+// the SHA sum of the cleaned target path
+func (c *Handle) BuildCache(target string) string {
+	if c.disabled {
+		return ""
+	}
+
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(filepath.Clean(target))))
+	dir, err := updateCacheSubdir(c, filepath.Join(BuildDir, sum))
+	if err != nil {
+		return ""
+	}
+
+	return dir
+}
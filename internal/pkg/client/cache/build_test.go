@@ -0,0 +1,61 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/test"
+)
+
+func TestBuildCache(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	imageCacheDir, err := ioutil.TempDir("", "image-cache-")
+	if err != nil {
+		t.Fatalf("failed to create a temporary image cache")
+	}
+	defer os.RemoveAll(imageCacheDir)
+
+	c, err := NewHandle(Config{BaseDir: imageCacheDir})
+	if err != nil {
+		t.Fatalf("failed to create new image cache handle: %s", err)
+	}
+	c.checkIfCacheDisabled(t)
+
+	if c.Build != filepath.Join(imageCacheDir, CacheDir, BuildDir) {
+		t.Errorf("unexpected build cache path: %s", c.Build)
+	}
+
+	yum := c.BuildCache("/var/cache/yum")
+	if filepath.Dir(yum) != c.Build {
+		t.Errorf("unexpected cache mount path: %s", yum)
+	}
+	if fi, err := os.Stat(yum); err != nil || !fi.IsDir() {
+		t.Errorf("cache mount directory not created: %v", err)
+	}
+	if dir := c.BuildCache("/var/cache/yum/"); dir != yum {
+		t.Errorf("same directory gives different cache mounts: %s and %s", dir, yum)
+	}
+	if dir := c.BuildCache("/var/cache/apt"); dir == yum {
+		t.Errorf("different directories give the same cache mount: %s", dir)
+	}
+
+	// a disabled cache has no build cache
+	os.Setenv(DisableEnv, "1")
+	defer os.Unsetenv(DisableEnv)
+	c, err = NewHandle(Config{BaseDir: imageCacheDir})
+	if err != nil {
+		t.Fatalf("failed to create new image cache handle: %s", err)
+	}
+	if dir := c.BuildCache("/var/cache/yum"); dir != "" {
+		t.Errorf("unexpected cache mount with disabled cache: %s", dir)
+	}
+}
@@ -87,6 +87,9 @@ type Handle struct {
 	// Oras provides the location of the ORAS cache
 	Oras string
 
+	// Build provides the location of the build cache mounts
+	Build string
+
 	// disabled specifies if the test is disabled
 	disabled bool
 }
@@ -190,6 +193,10 @@ func NewHandle(cfg Config) (*Handle, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed getting the path to the ORAS cache")
 	}
+	newCache.Build, err = getBuildCachePath(newCache)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting the path to the build cache")
+	}
 
 	return newCache, nil
 }
@@ -295,6 +302,7 @@ func (c *Handle) cleanAllCaches() {
 		"shub":    c.Shub,
 		"oras":    c.Oras,
 		"net":     c.Net,
+		"build":   c.Build,
 	}
 
 	for name, dir := range cacheDirs {
@@ -124,6 +124,7 @@ func TestCleanAllCaches(t *testing.T) {
 		"shub":    c.Shub,
 		"oras":    c.Oras,
 		"net":     c.Net,
+		"build":   c.Build,
 	}
 
 	testfile := "test"
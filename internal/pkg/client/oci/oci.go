@@ -125,6 +125,11 @@ func calculateRefHash(ctx context.Context, ref types.ImageReference, sys *types.
 		return "", err
 	}
 
+	// manifest lists are the same for every architecture
+	if sys != nil && sys.ArchitectureChoice != "" {
+		man = append(man, sys.ArchitectureChoice...)
+	}
+
 	hash := fmt.Sprintf("%x", sha256.Sum256(man))
 	return hash, nil
 }
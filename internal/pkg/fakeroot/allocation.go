@@ -0,0 +1,364 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fakeroot
+
+import (
+	"fmt"
+	"os"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/pkg/runtime/engine/config"
+)
+
+const (
+	// AllocationNone disables the automatic allocation of ranges.
+	AllocationNone = "none"
+	// AllocationUID derives the range of a user from its UID.
+	AllocationUID = "uid"
+	// AllocationPool allocates the first free range of the pool
+	// on first use and records it.
+	AllocationPool = "pool"
+	// maxID is the subordinate ID following the highest one.
+	maxID = uint64(1) << 32
+)
+
+// AllocationPolicy defines how subordinate ID ranges are allocated
+// to users without an entry in the subuid/subgid files.
+type AllocationPolicy struct {
+	Mode string
+	// Start is the first ID of the allocation range.
+	Start uint64
+	// End is the ID following the last ID of the allocation range.
+	End uint64
+	// UIDMin is the UID using the first range with AllocationUID.
+	UIDMin uint32
+}
+
+// NewAllocationPolicy returns a validated allocation policy.
+func NewAllocationPolicy(mode string, start, end, uidMin uint64) (*AllocationPolicy, error) {
+	p := &AllocationPolicy{
+		Mode:   mode,
+		Start:  start,
+		End:    end,
+		UIDMin: uint32(uidMin),
+	}
+
+	switch mode {
+	case AllocationNone:
+		return p, nil
+	case AllocationUID, AllocationPool:
+	default:
+		return nil, fmt.Errorf("unknown fakeroot allocation policy %q", mode)
+	}
+
+	if start < uint64(startMin) {
+		return nil, fmt.Errorf("fakeroot range start must be greater or equal to %d", startMin)
+	}
+	if end > maxID {
+		return nil, fmt.Errorf("fakeroot range end must be lower or equal to %d", maxID)
+	}
+	if start+uint64(validRangeCount) > end {
+		return nil, fmt.Errorf("fakeroot range %d-%d can't hold a range of %d IDs", start, end, validRangeCount)
+	}
+	if uidMin >= maxID {
+		return nil, fmt.Errorf("fakeroot uid min %d is not a valid UID", uidMin)
+	}
+	return p, nil
+}
+
+// GetAllocationPolicy returns the allocation policy set in the
+// singularity configuration.
+func GetAllocationPolicy(c *config.FileConfig) (*AllocationPolicy, error) {
+	p, err := NewAllocationPolicy(
+		c.FakerootAllocation,
+		uint64(c.FakerootRangeStart),
+		uint64(c.FakerootRangeEnd),
+		uint64(c.FakerootUIDMin),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fakeroot allocation configuration: %s", err)
+	}
+	return p, nil
+}
+
+// uidStart returns the start of the range derived from uid.
+func (p *AllocationPolicy) uidStart(uid uint32) (uint32, error) {
+	if uid < p.UIDMin {
+		return 0, fmt.Errorf("UID %d is lower than the fakeroot uid min %d", uid, p.UIDMin)
+	}
+	start := p.Start + uint64(uid-p.UIDMin)*uint64(validRangeCount)
+	if start+uint64(validRangeCount) > p.End {
+		return 0, fmt.Errorf("UID %d is outside of the fakeroot allocation range", uid)
+	}
+	return uint32(start), nil
+}
+
+// overlappingEntry returns the first valid entry overlapping the range
+// of count IDs starting at start.
+func (c *Config) overlappingEntry(start uint32, count uint32) *Entry {
+	end := uint64(start) + uint64(count)
+
+	for _, e := range c.entries {
+		if e.invalid {
+			continue
+		}
+		if uint64(e.Start) < end && uint64(start) < uint64(e.Start)+uint64(e.Count) {
+			return e
+		}
+	}
+	return nil
+}
+
+// hasUserEntry returns true if there is any valid entry for uid.
+func (c *Config) hasUserEntry(uid uint32) bool {
+	for _, e := range c.entries {
+		if !e.invalid && e.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// freeRange returns the start of the first range of the pool which
+// doesn't overlap any entry.
+func (c *Config) freeRange(p *AllocationPolicy) (uint32, error) {
+	for s := p.Start; s+uint64(validRangeCount) <= p.End; s += uint64(validRangeCount) {
+		if c.overlappingEntry(uint32(s), validRangeCount) == nil {
+			return uint32(s), nil
+		}
+	}
+	return 0, fmt.Errorf("no free range available in the fakeroot allocation range")
+}
+
+// getAllocatedRange returns the mapping found for uid in the configuration
+// file path, or allocated according to the policy if the user has no
+// entry. The returned start is non zero when the range was allocated from
+// the pool and must be recorded.
+func getAllocatedRange(path string, uid uint32, policy *AllocationPolicy) (*specs.LinuxIDMapping, uint32, error) {
+	noAllocation := policy == nil || policy.Mode == AllocationNone
+	if _, err := os.Stat(path); os.IsNotExist(err) && noAllocation {
+		return nil, 0, ErrNoMapping
+	}
+
+	config, err := GetConfig(path, false, getPwNam)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer config.Close()
+
+	userinfo, err := getPwUID(uid)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not retrieve user with UID %d: %s", uid, err)
+	}
+
+	e, err := config.GetUserEntry(userinfo.Name)
+	if err == nil {
+		if e.disabled {
+			return nil, 0, ErrMappingDisabled
+		}
+		return &specs.LinuxIDMapping{ContainerID: 1, HostID: e.Start, Size: e.Count}, 0, nil
+	}
+
+	// entries set by the administrator always take precedence
+	if config.hasUserEntry(uid) {
+		return nil, 0, err
+	} else if noAllocation {
+		return nil, 0, ErrNoMapping
+	}
+
+	var start uint32
+	var record uint32
+
+	if policy.Mode == AllocationUID {
+		start, err = policy.uidStart(uid)
+		if err != nil {
+			return nil, 0, err
+		}
+		if e := config.overlappingEntry(start, validRangeCount); e != nil {
+			return nil, 0, fmt.Errorf("range allocated for UID %d overlaps with entry line %d of %s", uid, e.lineno, path)
+		}
+	} else {
+		start, err = config.freeRange(policy)
+		if err != nil {
+			return nil, 0, err
+		}
+		record = start
+	}
+
+	return &specs.LinuxIDMapping{ContainerID: 1, HostID: start, Size: validRangeCount}, record, nil
+}
+
+// Allocation holds the ranges allocated from the pool to a user on first
+// use. It's determined without privileges and must be recorded later by
+// a privileged process.
+type Allocation struct {
+	UID    uint32 `json:"uid"`
+	SubUID uint32 `json:"subuid,omitempty"`
+	SubGID uint32 `json:"subgid,omitempty"`
+}
+
+// GetIDRanges returns the fakeroot UID and GID mappings of uid, users
+// without entries in SubUIDFile and SubGIDFile get ranges allocated
+// according to policy. A non nil Allocation is returned when ranges
+// were allocated from the pool and must be recorded with Record.
+func GetIDRanges(uid uint32, policy *AllocationPolicy) (*specs.LinuxIDMapping, *specs.LinuxIDMapping, *Allocation, error) {
+	var alloc *Allocation
+
+	uidRange, subUID, err := getAllocatedRange(SubUIDFile, uid, policy)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	gidRange, subGID, err := getAllocatedRange(SubGIDFile, uid, policy)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if subUID != 0 || subGID != 0 {
+		alloc = &Allocation{
+			UID:    uid,
+			SubUID: subUID,
+			SubGID: subGID,
+		}
+	}
+	return uidRange, gidRange, alloc, nil
+}
+
+// Record records the allocated ranges in SubUIDFile and SubGIDFile,
+// it requires privileges. An error is returned if a range was allocated
+// to another user in the meantime.
+func (a *Allocation) Record() error {
+	if a.SubUID != 0 {
+		if err := recordRange(SubUIDFile, a.UID, a.SubUID); err != nil {
+			return err
+		}
+	}
+	if a.SubGID != 0 {
+		if err := recordRange(SubGIDFile, a.UID, a.SubGID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordRange adds the range starting at start for uid in the
+// configuration file path.
+func recordRange(path string, uid uint32, start uint32) error {
+	config, err := GetLockedConfig(path, getPwNam)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range config.entries {
+		if e.invalid || e.UID != uid {
+			continue
+		}
+		config.Close()
+		// already recorded by a concurrent process of the same user
+		if e.Start == start && e.Count == validRangeCount {
+			return nil
+		}
+		return fmt.Errorf("a fakeroot range was allocated for UID %d in %s in the meantime, please retry", uid, path)
+	}
+	if config.overlappingEntry(start, validRangeCount) != nil {
+		config.Close()
+		return fmt.Errorf("fakeroot range %d was allocated to another user in %s in the meantime, please retry", start, path)
+	}
+
+	config.requireUpdate = true
+	config.entries = append(config.entries, &Entry{
+		UID:   uid,
+		Start: start,
+		Count: validRangeCount,
+		line:  fmt.Sprintf("%d:%d:%d", uid, start, validRangeCount),
+	})
+
+	if err := config.Close(); err != nil {
+		return fmt.Errorf("while recording fakeroot range in %s: %s", path, err)
+	}
+	return nil
+}
+
+// Audit checks the subuid/subgid configuration file path and returns
+// the list of issues found. When policy is not nil, entries overlapping
+// the allocation range which were not allocated by the policy are
+// reported too.
+func Audit(path string, policy *AllocationPolicy) ([]string, error) {
+	var issues []string
+
+	config, err := GetConfig(path, false, getPwNam)
+	if err != nil {
+		return nil, err
+	}
+	defer config.Close()
+
+	report := func(lineno int, format string, a ...interface{}) {
+		issues = append(issues, fmt.Sprintf("%s:%d: %s", path, lineno, fmt.Sprintf(format, a...)))
+	}
+
+	for _, lineno := range config.malformed {
+		report(lineno, "malformed entry")
+	}
+
+	var valid []*Entry
+	users := make(map[uint32][]*Entry)
+
+	for _, e := range config.entries {
+		if e.invalid {
+			report(e.lineno, "invalid user, range start or count")
+			continue
+		}
+		if uint64(e.Start)+uint64(e.Count) > maxID {
+			report(e.lineno, "range exceeds the highest subordinate ID")
+			continue
+		}
+		valid = append(valid, e)
+		if e.UID == maxUID {
+			report(e.lineno, "entry for an unknown user")
+			continue
+		}
+		users[e.UID] = append(users[e.UID], e)
+	}
+
+	for i, e := range valid {
+		for _, o := range valid[i+1:] {
+			if uint64(o.Start) < uint64(e.Start)+uint64(e.Count) && uint64(e.Start) < uint64(o.Start)+uint64(o.Count) {
+				report(o.lineno, "range overlaps with entry line %d", e.lineno)
+			}
+		}
+	}
+
+	for _, e := range valid {
+		entries, ok := users[e.UID]
+		if !ok {
+			continue
+		}
+		// report users once, in file order
+		delete(users, e.UID)
+
+		usable := false
+		for _, e := range entries {
+			if e.Count >= validRangeCount {
+				usable = true
+				break
+			}
+		}
+		if !usable {
+			report(entries[0].lineno, "user has no range of at least %d IDs, fakeroot is not usable", validRangeCount)
+		}
+	}
+
+	if policy != nil && policy.Mode == AllocationUID {
+		for _, e := range valid {
+			if uint64(e.Start) >= policy.End || uint64(e.Start)+uint64(e.Count) <= policy.Start {
+				continue
+			}
+			if start, err := policy.uidStart(e.UID); err != nil || start != e.Start || e.Count != validRangeCount {
+				report(e.lineno, "range overlaps with the fakeroot allocation range but doesn't match the range allocated to UID %d", e.UID)
+			}
+		}
+	}
+
+	return issues, nil
+}
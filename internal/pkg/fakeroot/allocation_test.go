@@ -0,0 +1,243 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fakeroot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/internal/pkg/util/user"
+)
+
+const allocStart = uint64(1 << 30)
+
+func writeSubIDFile(t *testing.T, content string) string {
+	f, err := fs.MakeTmpFile("", "subid-", 0644)
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("failed to write temporary file: %s", err)
+	}
+	return f.Name()
+}
+
+func TestNewAllocationPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		start   uint64
+		end     uint64
+		wantErr bool
+	}{
+		{"None", AllocationNone, 0, 0, false},
+		{"UID", AllocationUID, allocStart, 3 << 30, false},
+		{"Pool", AllocationPool, allocStart, maxID, false},
+		{"UnknownMode", "random", allocStart, 3 << 30, true},
+		{"StartTooLow", AllocationPool, 1000, 3 << 30, true},
+		{"EndTooHigh", AllocationPool, allocStart, maxID + 1, true},
+		{"RangeTooSmall", AllocationUID, allocStart, allocStart + 1000, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAllocationPolicy(tt.mode, tt.start, tt.end, 1000)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v (wantErr %v)", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetAllocatedRange(t *testing.T) {
+	getPwUID = getPwUIDMock
+	getPwNam = getPwNamMock
+	defer func() {
+		getPwUID = user.GetPwUID
+		getPwNam = user.GetPwNam
+	}()
+
+	count := uint64(validRangeCount)
+	path := writeSubIDFile(t, fmt.Sprintf("root:100000:65536\n1:165536:1\n5:%d:65536\n", allocStart+count))
+	defer os.Remove(path)
+
+	uidPolicy, _ := NewAllocationPolicy(AllocationUID, allocStart, allocStart+4*count, 1)
+	poolPolicy, _ := NewAllocationPolicy(AllocationPool, allocStart, allocStart+4*count, 1)
+
+	tests := []struct {
+		name      string
+		uid       uint32
+		policy    *AllocationPolicy
+		wantStart uint32
+		record    bool
+		wantErr   bool
+	}{
+		{name: "Entry", uid: 0, policy: poolPolicy, wantStart: 100000},
+		{name: "NoPolicy", uid: 3, wantErr: true},
+		{name: "SmallEntry", uid: 1, policy: poolPolicy, wantErr: true},
+		{name: "UIDPolicy", uid: 3, policy: uidPolicy, wantStart: uint32(allocStart + 2*count)},
+		// range of UID 2 is used by UID 5
+		{name: "UIDPolicyOverlap", uid: 2, policy: uidPolicy, wantErr: true},
+		{name: "UIDPolicyOutOfRange", uid: 4, policy: &AllocationPolicy{AllocationUID, allocStart, allocStart + 2*count, 1}, wantErr: true},
+		{name: "PoolPolicy", uid: 3, policy: poolPolicy, wantStart: uint32(allocStart), record: true},
+		{name: "PoolExhausted", uid: 3, policy: &AllocationPolicy{AllocationPool, allocStart + count, allocStart + 2*count, 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idRange, record, err := getAllocatedRange(path, tt.uid, tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v (wantErr %v)", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if idRange.HostID != tt.wantStart {
+				t.Errorf("got range start %d, want %d", idRange.HostID, tt.wantStart)
+			}
+			if (record != 0) != tt.record {
+				t.Errorf("got range to record %d, want record %v", record, tt.record)
+			}
+		})
+	}
+}
+
+func TestRecordRange(t *testing.T) {
+	getPwNam = getPwNamMock
+	defer func() {
+		getPwNam = user.GetPwNam
+	}()
+
+	count := uint32(validRangeCount)
+	start := uint32(allocStart)
+	path := writeSubIDFile(t, fmt.Sprintf("root:100000:65536\n4:%d:65536\n", start+count))
+	defer os.Remove(path)
+
+	if err := recordRange(path, 3, start); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// already recorded by a concurrent process
+	if err := recordRange(path, 3, start); err != nil {
+		t.Errorf("unexpected error recording the same range: %s", err)
+	}
+	// allocated to another user in the meantime
+	if err := recordRange(path, 2, start); err == nil {
+		t.Errorf("unexpected success recording an allocated range")
+	}
+	// another range was recorded for the user in the meantime
+	if err := recordRange(path, 3, start+2*count); err == nil {
+		t.Errorf("unexpected success recording a second range")
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", path, err)
+	}
+	want := fmt.Sprintf("root:100000:65536\n4:%d:65536\n3:%d:65536\n", start+count, start)
+	if string(b) != want {
+		t.Errorf("got content %q, want %q", string(b), want)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	getPwNam = getPwNamMock
+	defer func() {
+		getPwNam = user.GetPwNam
+	}()
+
+	count := uint64(validRangeCount)
+	content := fmt.Sprintf(`# comment
+root:100000:65536
+daemon:150000:65536
+bad entry
+bin:-1:65536
+nouser:300000:65536
+sys:400000:1000
+sys:500000:2000
+4294967295:4294967000:65536
+sync:%d:65536
+games:%d:65536
+`, allocStart+3*count, allocStart+100)
+	path := writeSubIDFile(t, content)
+	defer os.Remove(path)
+
+	policy, _ := NewAllocationPolicy(AllocationUID, allocStart, allocStart+16*count, 1)
+
+	tests := []struct {
+		name   string
+		policy *AllocationPolicy
+		issues []string
+	}{
+		{
+			name: "NoPolicy",
+			issues: []string{
+				path + ":4: malformed entry",
+				path + ":5: invalid user, range start or count",
+				path + ":6: entry for an unknown user",
+				path + ":9: range exceeds the highest subordinate ID",
+				path + ":3: range overlaps with entry line 2",
+				path + ":7: user has no range of at least 65536 IDs, fakeroot is not usable",
+			},
+		},
+		{
+			name:   "UIDPolicy",
+			policy: policy,
+			issues: []string{
+				path + ":4: malformed entry",
+				path + ":5: invalid user, range start or count",
+				path + ":6: entry for an unknown user",
+				path + ":9: range exceeds the highest subordinate ID",
+				path + ":3: range overlaps with entry line 2",
+				path + ":7: user has no range of at least 65536 IDs, fakeroot is not usable",
+				path + ":11: range overlaps with the fakeroot allocation range but doesn't match the range allocated to UID 5",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := Audit(path, tt.policy)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(issues, tt.issues) {
+				t.Errorf("got issues:\n%v\nwant:\n%v", issues, tt.issues)
+			}
+		})
+	}
+}
+
+func TestGetIDRangesDisabled(t *testing.T) {
+	getPwUID = getPwUIDMock
+	getPwNam = getPwNamMock
+	defer func() {
+		getPwUID = user.GetPwUID
+		getPwNam = user.GetPwNam
+	}()
+
+	path := writeSubIDFile(t, "!daemon:100000:65536\nbin:200000:100\n")
+	defer os.Remove(path)
+
+	// only users without any entry can fall back to emulated fakeroot
+	if _, err := GetIDRange(path, 1); err != ErrMappingDisabled {
+		t.Errorf("got error %v, want %v", err, ErrMappingDisabled)
+	}
+	if _, err := GetIDRange(path, 2); err == nil || err == ErrNoMapping {
+		t.Errorf("got error %v for user with a too small range", err)
+	}
+	if _, err := GetIDRange(path, 3); err != ErrNoMapping {
+		t.Errorf("got error %v, want %v", err, ErrNoMapping)
+	}
+	if _, err := GetIDRange(path+".missing", 3); err != ErrNoMapping {
+		t.Errorf("got error %v for missing file, want %v", err, ErrNoMapping)
+	}
+}
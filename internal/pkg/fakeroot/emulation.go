@@ -0,0 +1,54 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fakeroot
+
+import (
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// emulatedSyscalls are the syscalls failing in a user namespace where only
+// the user UID/GID are mapped to root, they are handled by the emulation
+// supervisor which records the fake ownership, device type and extended
+// attributes of the files.
+var emulatedSyscalls = []string{
+	// ownership changes to unmapped IDs
+	"chown", "chown32", "fchown", "fchown32", "fchownat", "lchown", "lchown32",
+	// device creation
+	"mknod", "mknodat",
+	// security and trusted extended attributes
+	"setxattr", "lsetxattr", "fsetxattr",
+	"getxattr", "lgetxattr", "fgetxattr",
+	"removexattr", "lremovexattr", "fremovexattr",
+}
+
+// reportingSyscalls are the syscalls reporting the file attributes
+// recorded by the emulation supervisor.
+var reportingSyscalls = []string{
+	"stat", "lstat", "fstat", "newfstatat", "statx",
+	"stat64", "lstat64", "fstat64", "fstatat64",
+}
+
+// EmulationSeccompProfile returns the seccomp filter of the emulated
+// fakeroot mode, used when the user has no subordinate ID ranges. Its
+// UID/GID are the only IDs mapped to root in the user namespace, so
+// the syscalls changing ownership, creating devices or setting
+// privileged extended attributes are traced and emulated by the
+// supervisor started with Supervise.
+func EmulationSeccompProfile() *specs.LinuxSeccomp {
+	return &specs.LinuxSeccomp{
+		DefaultAction: specs.ActAllow,
+		Syscalls: []specs.LinuxSyscall{
+			{
+				Names:  emulatedSyscalls,
+				Action: specs.ActTrace,
+			},
+			{
+				Names:  reportingSyscalls,
+				Action: specs.ActTrace,
+			},
+		},
+	}
+}
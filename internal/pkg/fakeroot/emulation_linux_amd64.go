@@ -0,0 +1,671 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fakeroot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/sylabs/singularity/internal/pkg/security/seccomp"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+const (
+	ptraceOptTraceSeccomp = 0x80
+	ptraceOptExitKill     = 0x100000
+	ptraceEventSeccomp    = 7
+	ptraceGetSiginfo      = 0x4202
+
+	sysStatx = 332
+
+	atFdCwd           = -100
+	atSymlinkNofollow = 0x100
+	atEmptyPath       = 0x1000
+
+	xattrCreate  = 0x1
+	xattrReplace = 0x2
+	xattrNameMax = 255
+	xattrSizeMax = 65536
+	pathMax      = 4096
+
+	// statSize is the size of struct stat, statxSize is the
+	// size of struct statx up to the device ID fields
+	statSize  = 144
+	statxSize = 144
+)
+
+// EmulationSupported returns whether the emulated fakeroot mode
+// is supported on this architecture.
+func EmulationSupported() bool {
+	return true
+}
+
+// fileID identifies a file by its device and inode numbers.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// fakeFile holds the attributes recorded for a file by the emulated
+// syscalls and reported by the stat syscalls.
+type fakeFile struct {
+	uid uint32
+	gid uint32
+	// mode is the file type of the device files created with mknod,
+	// they are created as regular files
+	mode   uint32
+	rdev   uint64
+	xattrs map[string][]byte
+}
+
+// tracee holds the state of a traced thread.
+type tracee struct {
+	// regs are the registers at the entry of the traced syscall
+	regs syscall.PtraceRegs
+	// exit is set to handle the syscall exit of the traced syscall
+	exit func(pid int, regs *syscall.PtraceRegs)
+}
+
+type statRequest struct {
+	path   string
+	follow bool
+	reply  chan statReply
+}
+
+type statReply struct {
+	st    syscall.Stat_t
+	errno syscall.Errno
+}
+
+// supervisor traces the processes of the emulated fakeroot mode.
+type supervisor struct {
+	files   map[fileID]*fakeFile
+	tracees map[int]*tracee
+	stats   chan statRequest
+}
+
+// Supervise executes args with environment env in the emulated fakeroot
+// mode and returns its wait status once it exits. The traced process and
+// its children are stopped by the seccomp filter returned by
+// EmulationSeccompProfile on ownership changes, device creation and
+// privileged extended attribute changes, those syscalls are emulated by
+// recording the requested attributes, which are reported back by the
+// stat syscalls. Files are identified by their device and inode numbers,
+// recorded attributes are not persisted in the files.
+//
+// The current thread must not run any other goroutine, the seccomp filter
+// is loaded for this thread and inherited by the traced process.
+func Supervise(args []string, env []string) (syscall.WaitStatus, error) {
+	var status syscall.WaitStatus
+
+	if !seccomp.Enabled() {
+		return status, fmt.Errorf("emulated fakeroot requires seccomp support")
+	}
+
+	// ptrace requests must be done by the thread which started the
+	// traced process
+	runtime.LockOSThread()
+
+	// the filter only applies to this thread, the stat syscalls of the
+	// supervisor are delegated to the other threads
+	s := &supervisor{
+		files:   make(map[fileID]*fakeFile),
+		tracees: make(map[int]*tracee),
+		stats:   make(chan statRequest),
+	}
+	go s.statWorker()
+	defer close(s.stats)
+
+	if err := seccomp.LoadSeccompConfig(EmulationSeccompProfile(), false, 0); err != nil {
+		return status, fmt.Errorf("could not apply emulated fakeroot seccomp filter: %s", err)
+	}
+
+	proc, err := os.StartProcess(args[0], args, &os.ProcAttr{
+		Env:   env,
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
+		Sys:   &syscall.SysProcAttr{Ptrace: true},
+	})
+	if err != nil {
+		return status, fmt.Errorf("could not execute %s: %s", args[0], err)
+	}
+	pid := proc.Pid
+
+	// the process stops with SIGTRAP once executed
+	if _, err := syscall.Wait4(pid, &status, syscall.WALL, nil); err != nil {
+		return status, fmt.Errorf("while waiting %s: %s", args[0], err)
+	} else if !status.Stopped() {
+		return status, fmt.Errorf("%s exited before being traced", args[0])
+	}
+
+	options := syscall.PTRACE_O_TRACESYSGOOD |
+		syscall.PTRACE_O_TRACEFORK |
+		syscall.PTRACE_O_TRACEVFORK |
+		syscall.PTRACE_O_TRACECLONE |
+		syscall.PTRACE_O_TRACEEXEC |
+		ptraceOptTraceSeccomp |
+		ptraceOptExitKill
+	if err := syscall.PtraceSetOptions(pid, options); err != nil {
+		syscall.Kill(pid, syscall.SIGKILL)
+		return status, fmt.Errorf("could not set ptrace options: %s", err)
+	}
+	s.tracees[pid] = &tracee{}
+	if err := syscall.PtraceCont(pid, 0); err != nil {
+		syscall.Kill(pid, syscall.SIGKILL)
+		return status, fmt.Errorf("could not resume %s: %s", args[0], err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			syscall.Kill(pid, sig.(syscall.Signal))
+		}
+	}()
+
+	for {
+		wpid, err := syscall.Wait4(-1, &status, syscall.WALL, nil)
+		if err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			syscall.Kill(pid, syscall.SIGKILL)
+			return status, fmt.Errorf("error while waiting traced processes: %s", err)
+		}
+
+		if status.Exited() || status.Signaled() {
+			delete(s.tracees, wpid)
+			if wpid == pid {
+				return status, nil
+			}
+		} else if status.Stopped() {
+			s.stopped(wpid, status)
+		}
+	}
+}
+
+// stopped handles a stop of the traced thread pid.
+func (s *supervisor) stopped(pid int, status syscall.WaitStatus) {
+	sig := status.StopSignal()
+
+	t, ok := s.tracees[pid]
+	if !ok {
+		t = &tracee{}
+		s.tracees[pid] = t
+		// new threads and processes start with SIGSTOP
+		if sig == syscall.SIGSTOP {
+			syscall.PtraceCont(pid, 0)
+			return
+		}
+	}
+
+	switch {
+	case sig == syscall.SIGTRAP|0x80:
+		s.syscallExit(pid, t)
+	case sig == syscall.SIGTRAP && status.TrapCause() == ptraceEventSeccomp:
+		s.syscallEntry(pid, t)
+	case sig == syscall.SIGTRAP && status.TrapCause() > 0:
+		// fork, vfork, clone and exec events
+		syscall.PtraceCont(pid, 0)
+	case isGroupStop(pid, sig):
+		// job control is not supported
+		syscall.PtraceCont(pid, 0)
+	default:
+		syscall.PtraceCont(pid, int(sig))
+	}
+}
+
+// isGroupStop returns whether the stop of pid with signal sig is
+// a group-stop rather than a signal delivery.
+func isGroupStop(pid int, sig syscall.Signal) bool {
+	switch sig {
+	case syscall.SIGSTOP, syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU:
+	default:
+		return false
+	}
+	var siginfo [128]byte
+	_, _, errno := syscall.Syscall6(syscall.SYS_PTRACE, ptraceGetSiginfo, uintptr(pid), 0, uintptr(unsafe.Pointer(&siginfo[0])), 0, 0)
+	return errno == syscall.EINVAL
+}
+
+// syscallEntry handles a syscall stopped by the seccomp filter.
+func (s *supervisor) syscallEntry(pid int, t *tracee) {
+	if err := syscall.PtraceGetRegs(pid, &t.regs); err != nil {
+		syscall.PtraceCont(pid, 0)
+		return
+	}
+	regs := t.regs
+
+	var ret int64
+
+	switch regs.Orig_rax {
+	case syscall.SYS_CHOWN:
+		ret = s.chown(pid, atFdCwd, regs.Rdi, uint32(regs.Rsi), uint32(regs.Rdx), 0)
+	case syscall.SYS_LCHOWN:
+		ret = s.chown(pid, atFdCwd, regs.Rdi, uint32(regs.Rsi), uint32(regs.Rdx), atSymlinkNofollow)
+	case syscall.SYS_FCHOWN:
+		ret = s.chown(pid, int(int32(regs.Rdi)), 0, uint32(regs.Rsi), uint32(regs.Rdx), atEmptyPath)
+	case syscall.SYS_FCHOWNAT:
+		ret = s.chown(pid, int(int32(regs.Rdi)), regs.Rsi, uint32(regs.Rdx), uint32(regs.R10), int(regs.R8))
+	case syscall.SYS_MKNOD:
+		s.mknod(pid, t, &regs, atFdCwd, regs.Rdi, &regs.Rsi, &regs.Rdx)
+		return
+	case syscall.SYS_MKNODAT:
+		s.mknod(pid, t, &regs, int(int32(regs.Rdi)), regs.Rsi, &regs.Rdx, &regs.R10)
+		return
+	case syscall.SYS_SETXATTR, syscall.SYS_LSETXATTR, syscall.SYS_FSETXATTR:
+		f := xattrFile(regs.Orig_rax == syscall.SYS_LSETXATTR, regs.Orig_rax == syscall.SYS_FSETXATTR, regs.Rdi)
+		var emulated bool
+		ret, emulated = s.setxattr(pid, f, regs.Rsi, regs.Rdx, regs.R10, int(regs.R8))
+		if !emulated {
+			syscall.PtraceCont(pid, 0)
+			return
+		}
+	case syscall.SYS_GETXATTR, syscall.SYS_LGETXATTR, syscall.SYS_FGETXATTR:
+		f := xattrFile(regs.Orig_rax == syscall.SYS_LGETXATTR, regs.Orig_rax == syscall.SYS_FGETXATTR, regs.Rdi)
+		var emulated bool
+		ret, emulated = s.getxattr(pid, f, regs.Rsi, regs.Rdx, regs.R10)
+		if !emulated {
+			syscall.PtraceCont(pid, 0)
+			return
+		}
+	case syscall.SYS_REMOVEXATTR, syscall.SYS_LREMOVEXATTR, syscall.SYS_FREMOVEXATTR:
+		f := xattrFile(regs.Orig_rax == syscall.SYS_LREMOVEXATTR, regs.Orig_rax == syscall.SYS_FREMOVEXATTR, regs.Rdi)
+		var emulated bool
+		ret, emulated = s.removexattr(pid, f, regs.Rsi)
+		if !emulated {
+			syscall.PtraceCont(pid, 0)
+			return
+		}
+	case syscall.SYS_STAT, syscall.SYS_LSTAT, syscall.SYS_FSTAT:
+		s.stat(pid, t, regs.Rsi, false)
+		return
+	case syscall.SYS_NEWFSTATAT:
+		s.stat(pid, t, regs.Rdx, false)
+		return
+	case sysStatx:
+		s.stat(pid, t, regs.R8, true)
+		return
+	default:
+		syscall.PtraceCont(pid, 0)
+		return
+	}
+
+	// skip the syscall and set its return value
+	regs.Orig_rax = ^uint64(0)
+	regs.Rax = uint64(ret)
+	syscall.PtraceSetRegs(pid, &regs)
+	syscall.PtraceCont(pid, 0)
+}
+
+// syscallExit handles the exit of a syscall traced by syscallEntry.
+func (s *supervisor) syscallExit(pid int, t *tracee) {
+	var regs syscall.PtraceRegs
+
+	exit := t.exit
+	t.exit = nil
+
+	if exit != nil && syscall.PtraceGetRegs(pid, &regs) == nil {
+		exit(pid, &regs)
+	}
+	syscall.PtraceCont(pid, 0)
+}
+
+// chown records the owner of the file designated by dirfd, the path
+// at pathAddr and flags like fchownat.
+func (s *supervisor) chown(pid int, dirfd int, pathAddr uint64, uid, gid uint32, flags int) int64 {
+	st, errno := s.lookup(pid, dirfd, pathAddr, flags)
+	if errno != 0 {
+		return -int64(errno)
+	}
+	f := s.file(st)
+	if uid != ^uint32(0) {
+		f.uid = uid
+	}
+	if gid != ^uint32(0) {
+		f.gid = gid
+	}
+	return 0
+}
+
+// mknod creates the character and block devices as regular files and
+// records their device type and number once created, modeArg and devArg
+// are the syscall arguments in regs.
+func (s *supervisor) mknod(pid int, t *tracee, regs *syscall.PtraceRegs, dirfd int, pathAddr uint64, modeArg *uint64, devArg *uint64) {
+	mode := uint32(*modeArg)
+	dev := *devArg
+
+	if mode&syscall.S_IFMT != syscall.S_IFCHR && mode&syscall.S_IFMT != syscall.S_IFBLK {
+		syscall.PtraceCont(pid, 0)
+		return
+	}
+
+	*modeArg = uint64(syscall.S_IFREG | mode&^syscall.S_IFMT)
+	*devArg = 0
+	if err := syscall.PtraceSetRegs(pid, regs); err != nil {
+		syscall.PtraceCont(pid, 0)
+		return
+	}
+
+	t.exit = func(pid int, regs *syscall.PtraceRegs) {
+		// restore the arguments modified at the entry
+		regs.Rsi, regs.Rdx, regs.R10 = t.regs.Rsi, t.regs.Rdx, t.regs.R10
+		syscall.PtraceSetRegs(pid, regs)
+
+		if int64(regs.Rax) != 0 {
+			return
+		}
+		st, errno := s.lookup(pid, dirfd, pathAddr, atSymlinkNofollow)
+		if errno != 0 {
+			sylog.Debugf("Could not record device created by process %d: %s", pid, errno)
+			return
+		}
+		f := s.file(st)
+		f.mode = mode & syscall.S_IFMT
+		f.rdev = dev
+	}
+	syscall.PtraceSyscall(pid, 0)
+}
+
+// xattrTarget designates the file of an extended attribute syscall.
+type xattrTarget struct {
+	dirfd    int
+	pathAddr uint64
+	flags    int
+}
+
+// xattrFile returns the file designated by the first argument arg of
+// an extended attribute syscall.
+func xattrFile(nofollow bool, fd bool, arg uint64) xattrTarget {
+	if fd {
+		return xattrTarget{dirfd: int(int32(arg)), flags: atEmptyPath}
+	} else if nofollow {
+		return xattrTarget{dirfd: atFdCwd, pathAddr: arg, flags: atSymlinkNofollow}
+	}
+	return xattrTarget{dirfd: atFdCwd, pathAddr: arg}
+}
+
+// privilegedXattr returns whether setting the extended attribute name
+// requires privileges out of the user namespace.
+func privilegedXattr(name string) bool {
+	return strings.HasPrefix(name, "trusted.") || strings.HasPrefix(name, "security.")
+}
+
+// setxattr records the privileged extended attributes, the others
+// are not emulated.
+func (s *supervisor) setxattr(pid int, target xattrTarget, nameAddr, valueAddr, size uint64, flags int) (int64, bool) {
+	name, err := readString(pid, nameAddr, xattrNameMax+1)
+	if err != nil || !privilegedXattr(name) {
+		return 0, false
+	}
+	if size > xattrSizeMax {
+		return -int64(syscall.E2BIG), true
+	}
+	value := make([]byte, size)
+	if n, err := syscall.PtracePeekData(pid, uintptr(valueAddr), value); err != nil || n != len(value) {
+		return -int64(syscall.EFAULT), true
+	}
+
+	st, errno := s.lookup(pid, target.dirfd, target.pathAddr, target.flags)
+	if errno != 0 {
+		return -int64(errno), true
+	}
+	f := s.file(st)
+	if f.xattrs == nil {
+		f.xattrs = make(map[string][]byte)
+	}
+	_, exists := f.xattrs[name]
+	if flags&xattrCreate != 0 && exists {
+		return -int64(syscall.EEXIST), true
+	} else if flags&xattrReplace != 0 && !exists {
+		return -int64(syscall.ENODATA), true
+	}
+	f.xattrs[name] = value
+	return 0, true
+}
+
+// getxattr reports the recorded extended attributes, the others are
+// not emulated.
+func (s *supervisor) getxattr(pid int, target xattrTarget, nameAddr, valueAddr, size uint64) (int64, bool) {
+	name, err := readString(pid, nameAddr, xattrNameMax+1)
+	if err != nil || !privilegedXattr(name) {
+		return 0, false
+	}
+	st, errno := s.lookup(pid, target.dirfd, target.pathAddr, target.flags)
+	if errno != 0 {
+		return 0, false
+	}
+	f, ok := s.files[fileID{st.Dev, st.Ino}]
+	if !ok {
+		return 0, false
+	}
+	value, ok := f.xattrs[name]
+	if !ok {
+		return 0, false
+	}
+
+	if size == 0 {
+		return int64(len(value)), true
+	} else if size < uint64(len(value)) {
+		return -int64(syscall.ERANGE), true
+	}
+	if _, err := syscall.PtracePokeData(pid, uintptr(valueAddr), value); err != nil {
+		return -int64(syscall.EFAULT), true
+	}
+	return int64(len(value)), true
+}
+
+// removexattr removes the recorded extended attributes, the others are
+// not emulated.
+func (s *supervisor) removexattr(pid int, target xattrTarget, nameAddr uint64) (int64, bool) {
+	name, err := readString(pid, nameAddr, xattrNameMax+1)
+	if err != nil || !privilegedXattr(name) {
+		return 0, false
+	}
+	st, errno := s.lookup(pid, target.dirfd, target.pathAddr, target.flags)
+	if errno != 0 {
+		return 0, false
+	}
+	f, ok := s.files[fileID{st.Dev, st.Ino}]
+	if !ok {
+		return 0, false
+	}
+	if _, ok := f.xattrs[name]; !ok {
+		return 0, false
+	}
+	delete(f.xattrs, name)
+	return 0, true
+}
+
+// stat reports the recorded attributes in the stat or statx structure
+// at bufAddr once the syscall returns.
+func (s *supervisor) stat(pid int, t *tracee, bufAddr uint64, statx bool) {
+	if len(s.files) == 0 {
+		syscall.PtraceCont(pid, 0)
+		return
+	}
+
+	t.exit = func(pid int, regs *syscall.PtraceRegs) {
+		if int64(regs.Rax) != 0 {
+			return
+		}
+		size := statSize
+		if statx {
+			size = statxSize
+		}
+		buf := make([]byte, size)
+		if n, err := syscall.PtracePeekData(pid, uintptr(bufAddr), buf); err != nil || n != size {
+			return
+		}
+		if s.patchStat(buf, statx) {
+			syscall.PtracePokeData(pid, uintptr(bufAddr), buf)
+		}
+	}
+	syscall.PtraceSyscall(pid, 0)
+}
+
+// patchStat sets the recorded attributes in the stat or statx structure
+// buf, it returns false if there are no attributes recorded for the file.
+func (s *supervisor) patchStat(buf []byte, statx bool) bool {
+	le := binary.LittleEndian
+
+	if statx {
+		id := fileID{
+			dev: mkdev(le.Uint32(buf[136:]), le.Uint32(buf[140:])),
+			ino: le.Uint64(buf[32:]),
+		}
+		f, ok := s.files[id]
+		if !ok {
+			return false
+		}
+		le.PutUint32(buf[20:], f.uid)
+		le.PutUint32(buf[24:], f.gid)
+		if f.mode != 0 {
+			mode := uint32(le.Uint16(buf[28:]))&^syscall.S_IFMT | f.mode
+			le.PutUint16(buf[28:], uint16(mode))
+			le.PutUint32(buf[128:], major(f.rdev))
+			le.PutUint32(buf[132:], minor(f.rdev))
+		}
+		return true
+	}
+
+	id := fileID{
+		dev: le.Uint64(buf[0:]),
+		ino: le.Uint64(buf[8:]),
+	}
+	f, ok := s.files[id]
+	if !ok {
+		return false
+	}
+	le.PutUint32(buf[28:], f.uid)
+	le.PutUint32(buf[32:], f.gid)
+	if f.mode != 0 {
+		le.PutUint32(buf[24:], le.Uint32(buf[24:])&^syscall.S_IFMT|f.mode)
+		le.PutUint64(buf[40:], f.rdev)
+	}
+	return true
+}
+
+// file returns the attributes recorded for the file st, they are
+// initialized with the file attributes on first use.
+func (s *supervisor) file(st *syscall.Stat_t) *fakeFile {
+	id := fileID{st.Dev, st.Ino}
+	f, ok := s.files[id]
+	if !ok {
+		f = &fakeFile{uid: st.Uid, gid: st.Gid}
+		s.files[id] = f
+	}
+	return f
+}
+
+// lookup returns the attributes of the file designated by dirfd, the
+// path at pathAddr and flags like fstatat in the traced thread pid.
+func (s *supervisor) lookup(pid int, dirfd int, pathAddr uint64, flags int) (*syscall.Stat_t, syscall.Errno) {
+	path := ""
+	if pathAddr != 0 || flags&atEmptyPath == 0 {
+		var err error
+		path, err = readString(pid, pathAddr, pathMax)
+		if err != nil {
+			return nil, syscall.EFAULT
+		}
+	}
+	if path == "" && flags&atEmptyPath == 0 {
+		return nil, syscall.ENOENT
+	}
+
+	// file descriptor links must be followed
+	follow := flags&atSymlinkNofollow == 0 || path == ""
+
+	reply := make(chan statReply)
+	s.stats <- statRequest{
+		path:   tracePath(pid, dirfd, path),
+		follow: follow,
+		reply:  reply,
+	}
+	r := <-reply
+	if r.errno != 0 {
+		return nil, r.errno
+	}
+	return &r.st, 0
+}
+
+// statWorker executes the stat requests of the supervisor out of the
+// thread filtered by seccomp.
+func (s *supervisor) statWorker() {
+	for r := range s.stats {
+		var reply statReply
+		var err error
+
+		if r.follow {
+			err = syscall.Stat(r.path, &reply.st)
+		} else {
+			err = syscall.Lstat(r.path, &reply.st)
+		}
+		if err != nil {
+			reply.errno = syscall.EIO
+			if errno, ok := err.(syscall.Errno); ok {
+				reply.errno = errno
+			}
+		}
+		r.reply <- reply
+	}
+}
+
+// tracePath returns the path from the supervisor to the path relative
+// to dirfd in the traced thread pid, which may use another mount
+// namespace and root directory.
+func tracePath(pid int, dirfd int, path string) string {
+	switch {
+	case strings.HasPrefix(path, "/"):
+		return fmt.Sprintf("/proc/%d/root%s", pid, path)
+	case dirfd == atFdCwd && path == "":
+		return fmt.Sprintf("/proc/%d/cwd", pid)
+	case dirfd == atFdCwd:
+		return fmt.Sprintf("/proc/%d/cwd/%s", pid, path)
+	case path == "":
+		return fmt.Sprintf("/proc/%d/fd/%d", pid, dirfd)
+	}
+	return fmt.Sprintf("/proc/%d/fd/%d/%s", pid, dirfd, path)
+}
+
+// readString reads the NUL terminated string at addr in the memory of
+// the traced thread pid, it fails if the string exceeds max bytes.
+func readString(pid int, addr uint64, max int) (string, error) {
+	var chunk [64]byte
+
+	str := make([]byte, 0, len(chunk))
+	for len(str) < max {
+		n, err := syscall.PtracePeekData(pid, uintptr(addr)+uintptr(len(str)), chunk[:])
+		if i := bytes.IndexByte(chunk[:n], 0); i >= 0 {
+			return string(append(str, chunk[:i]...)), nil
+		} else if err != nil {
+			return "", err
+		} else if n == 0 {
+			return "", syscall.EFAULT
+		}
+		str = append(str, chunk[:n]...)
+	}
+	return "", syscall.ENAMETOOLONG
+}
+
+func mkdev(major, minor uint32) uint64 {
+	return uint64(minor&0xff) | uint64(major&0xfff)<<8 |
+		uint64(minor&^0xff)<<12 | uint64(major&^0xfff)<<32
+}
+
+func major(dev uint64) uint32 {
+	return uint32((dev>>8)&0xfff | (dev>>32)&^0xfff)
+}
+
+func minor(dev uint64) uint32 {
+	return uint32(dev&0xff | (dev>>12)&^0xff)
+}
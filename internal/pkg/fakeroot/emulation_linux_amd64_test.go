@@ -0,0 +1,121 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fakeroot
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestDeviceNumbers(t *testing.T) {
+	tests := []struct {
+		major uint32
+		minor uint32
+	}{
+		{0, 0},
+		{1, 3},
+		{8, 17},
+		{259, 65536},
+		{4095, 255},
+		{4096, 1 << 20},
+	}
+	for _, tt := range tests {
+		dev := mkdev(tt.major, tt.minor)
+		if major(dev) != tt.major || minor(dev) != tt.minor {
+			t.Errorf("got %d:%d for device %d:%d", major(dev), minor(dev), tt.major, tt.minor)
+		}
+	}
+	// must match the kernel new_encode_dev for 32 bits numbers
+	if dev := mkdev(1, 3); dev != 0x103 {
+		t.Errorf("unexpected device number %#x for 1:3", dev)
+	}
+}
+
+func TestTracePath(t *testing.T) {
+	tests := []struct {
+		dirfd    int
+		path     string
+		expected string
+	}{
+		{atFdCwd, "/etc/passwd", "/proc/10/root/etc/passwd"},
+		{3, "/etc/passwd", "/proc/10/root/etc/passwd"},
+		{atFdCwd, "passwd", "/proc/10/cwd/passwd"},
+		{atFdCwd, "", "/proc/10/cwd"},
+		{3, "passwd", "/proc/10/fd/3/passwd"},
+		{3, "", "/proc/10/fd/3"},
+	}
+	for _, tt := range tests {
+		if path := tracePath(10, tt.dirfd, tt.path); path != tt.expected {
+			t.Errorf("got %s for %d/%q, want %s", path, tt.dirfd, tt.path, tt.expected)
+		}
+	}
+}
+
+func TestPatchStat(t *testing.T) {
+	f, err := ioutil.TempFile("", "emulation-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(f.Name(), &st); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, statSize)
+	copy(buf, (*[statSize]byte)(unsafe.Pointer(&st))[:])
+
+	s := &supervisor{files: make(map[fileID]*fakeFile)}
+	if s.patchStat(buf, false) {
+		t.Fatalf("unexpected patch of a file without recorded attributes")
+	}
+
+	fake := s.file(&st)
+	fake.uid = 100
+	fake.gid = 200
+	fake.mode = syscall.S_IFCHR
+	fake.rdev = mkdev(1, 3)
+
+	if !s.patchStat(buf, false) {
+		t.Fatalf("recorded attributes not reported")
+	}
+	patched := *(*syscall.Stat_t)(unsafe.Pointer(&buf[0]))
+	if patched.Uid != 100 || patched.Gid != 200 {
+		t.Errorf("got owner %d:%d, want 100:200", patched.Uid, patched.Gid)
+	}
+	if patched.Mode != syscall.S_IFCHR|st.Mode&^syscall.S_IFMT {
+		t.Errorf("got mode %o, want %o", patched.Mode, syscall.S_IFCHR|st.Mode&^syscall.S_IFMT)
+	}
+	if patched.Rdev != fake.rdev || patched.Size != st.Size {
+		t.Errorf("unexpected device %#x or size %d", patched.Rdev, patched.Size)
+	}
+
+	le := binary.LittleEndian
+
+	statx := make([]byte, statxSize)
+	le.PutUint16(statx[28:], uint16(st.Mode))
+	le.PutUint64(statx[32:], st.Ino)
+	le.PutUint32(statx[136:], major(st.Dev))
+	le.PutUint32(statx[140:], minor(st.Dev))
+
+	if !s.patchStat(statx, true) {
+		t.Fatalf("recorded attributes not reported by statx")
+	}
+	if uid, gid := le.Uint32(statx[20:]), le.Uint32(statx[24:]); uid != 100 || gid != 200 {
+		t.Errorf("got statx owner %d:%d, want 100:200", uid, gid)
+	}
+	if mode := le.Uint16(statx[28:]); uint32(mode)&syscall.S_IFMT != syscall.S_IFCHR {
+		t.Errorf("got statx mode %o", mode)
+	}
+	if maj, min := le.Uint32(statx[128:]), le.Uint32(statx[132:]); maj != 1 || min != 3 {
+		t.Errorf("got statx device %d:%d, want 1:3", maj, min)
+	}
+}
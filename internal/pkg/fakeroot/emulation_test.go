@@ -0,0 +1,62 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fakeroot
+
+import (
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestEmulationSeccompProfile(t *testing.T) {
+	profile := EmulationSeccompProfile()
+
+	if profile.DefaultAction != specs.ActAllow {
+		t.Errorf("unexpected default action %s", profile.DefaultAction)
+	}
+
+	emulated := make(map[string]bool)
+	for _, rule := range profile.Syscalls {
+		if rule.Action != specs.ActTrace {
+			t.Errorf("unexpected action %s for %v", rule.Action, rule.Names)
+		}
+		if len(rule.Args) > 0 {
+			t.Errorf("unexpected argument conditions for %v", rule.Names)
+		}
+		for _, name := range rule.Names {
+			if emulated[name] {
+				t.Errorf("duplicate syscall %s", name)
+			}
+			emulated[name] = true
+		}
+	}
+
+	// the syscalls changing ownership to unmapped IDs and
+	// reporting the recorded ownership must all be traced
+	tests := []string{
+		"chown", "fchown", "fchownat", "lchown", "chown32",
+		"mknod", "mknodat",
+		"setxattr", "fgetxattr", "fremovexattr",
+		"stat", "lstat", "fstat", "newfstatat", "statx",
+	}
+	for _, name := range tests {
+		if !emulated[name] {
+			t.Errorf("syscall %s is not traced", name)
+		}
+	}
+
+	// the other syscalls must not be traced, the user and group
+	// changes must fail as they don't change the credentials
+	others := []string{
+		"open", "execve", "chmod", "getuid", "setns",
+		"setgroups", "setuid", "setgid", "setresuid", "setfsgid",
+	}
+	for _, name := range others {
+		if emulated[name] {
+			t.Errorf("syscall %s is unexpectedly traced", name)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build linux,!amd64
+
+package fakeroot
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// EmulationSupported returns whether the emulated fakeroot mode
+// is supported on this architecture.
+func EmulationSupported() bool {
+	return false
+}
+
+// Supervise returns an error as the emulated fakeroot mode is not
+// supported on this architecture.
+func Supervise(args []string, env []string) (syscall.WaitStatus, error) {
+	return 0, fmt.Errorf("emulated fakeroot is not supported on %s", runtime.GOARCH)
+}
@@ -8,6 +8,7 @@ package fakeroot
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -43,6 +44,7 @@ const (
 // Entry represents an entry line of subuid/subgid configuration file.
 type Entry struct {
 	line     string
+	lineno   int
 	UID      uint32
 	Start    uint32
 	Count    uint32
@@ -54,6 +56,8 @@ type Entry struct {
 // file and manages its configuration.
 type Config struct {
 	entries       []*Entry
+	malformed     []int
+	locked        bool
 	file          *os.File
 	readOnly      bool
 	requireUpdate bool
@@ -92,26 +96,66 @@ func GetConfig(filename string, edit bool, getUserFn GetUserFn) (*Config, error)
 		return nil, fmt.Errorf("failed to open: %s: %s", filename, err)
 	}
 
-	config.entries = make([]*Entry, 0)
+	config.parse()
 
-	scanner := bufio.NewScanner(config.file)
-	for scanner.Scan() {
-		config.parseEntry(scanner.Text())
+	return config, nil
+}
+
+// GetLockedConfig is like GetConfig with edit set to true, but the
+// configuration file is locked before being parsed and until Close is
+// called, so concurrent edits are serialized.
+func GetLockedConfig(filename string, getUserFn GetUserFn) (*Config, error) {
+	config := &Config{
+		getUserFn: user.GetPwNam,
+		locked:    true,
+	}
+	if getUserFn != nil {
+		config.getUserFn = getUserFn
 	}
 
+	umask := syscall.Umask(0)
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0644)
+	syscall.Umask(umask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open: %s: %s", filename, err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error while acquiring lock in %s: %s", filename, err)
+	}
+	config.file = f
+
+	config.parse()
+
 	return config, nil
 }
 
+// parse parses all entries of the configuration file.
+func (c *Config) parse() {
+	c.entries = make([]*Entry, 0)
+
+	lineno := 0
+	scanner := bufio.NewScanner(c.file)
+	for scanner.Scan() {
+		lineno++
+		c.parseEntry(scanner.Text(), lineno)
+	}
+}
+
 // parseEntry parses a line and adds an entry.
-func (c *Config) parseEntry(line string) {
+func (c *Config) parseEntry(line string, lineno int) {
 	e := new(Entry)
 	e.line = line
+	e.lineno = lineno
 
 	fields := strings.Split(line, fieldSeparator)
 	// entry doesn't have the right number of fields,
 	// don't add it to the list of entries that need to be removed
 	// from the file during the close operation
 	if len(fields) < minFields {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && trimmed[0] != '#' {
+			c.malformed = append(c.malformed, lineno)
+		}
 		return
 	}
 
@@ -134,6 +178,11 @@ func (c *Config) parseEntry(line string) {
 	}
 
 	username := fields[0]
+	if username == "" {
+		e.invalid = true
+		e.UID = maxUID
+		return
+	}
 
 	// include disabled users
 	if username[0] == disabledPrefix {
@@ -174,11 +223,14 @@ func (c *Config) Close() error {
 		buf.WriteString(entry.line + "\n")
 	}
 
-	fd, err := lock.Exclusive(filename)
-	if err != nil {
-		return fmt.Errorf("error while acquiring lock in %s: %s", filename, err)
+	// the lock is already held by a locked configuration
+	if !c.locked {
+		fd, err := lock.Exclusive(filename)
+		if err != nil {
+			return fmt.Errorf("error while acquiring lock in %s: %s", filename, err)
+		}
+		defer lock.Release(fd)
 	}
-	defer lock.Release(fd)
 
 	if err := c.file.Truncate(0); err != nil {
 		return fmt.Errorf("error while truncating %s to 0: %s", filename, err)
@@ -335,6 +387,14 @@ func (c *Config) GetUserEntry(username string) (*Entry, error) {
 	return nil, fmt.Errorf("no mapping entry found in %s for %s", c.file.Name(), username)
 }
 
+// ErrMappingDisabled is returned when the user fakeroot mapping
+// has been disabled by the administrator.
+var ErrMappingDisabled = errors.New("your fakeroot mapping has been disabled by the administrator")
+
+// ErrNoMapping is returned when the user has no fakeroot mapping
+// entry and no range can be allocated.
+var ErrNoMapping = errors.New("you have no fakeroot mapping entry")
+
 // getPwUID is also used for mocking purpose
 var getPwUID = user.GetPwUID
 var getPwNam = user.GetPwNam
@@ -342,26 +402,6 @@ var getPwNam = user.GetPwNam
 // GetIDRange determines UID/GID mappings based on configuration
 // file provided in path.
 func GetIDRange(path string, uid uint32) (*specs.LinuxIDMapping, error) {
-	config, err := GetConfig(path, false, getPwNam)
-	if err != nil {
-		return nil, err
-	}
-	defer config.Close()
-
-	userinfo, err := getPwUID(uid)
-	if err != nil {
-		return nil, fmt.Errorf("could not retrieve user with UID %d: %s", uid, err)
-	}
-	e, err := config.GetUserEntry(userinfo.Name)
-	if err != nil {
-		return nil, err
-	}
-	if e.disabled {
-		return nil, fmt.Errorf("your fakeroot mapping has been disabled by the administrator")
-	}
-	return &specs.LinuxIDMapping{
-		ContainerID: 1,
-		HostID:      e.Start,
-		Size:        e.Count,
-	}, nil
+	idRange, _, err := getAllocatedRange(path, uid, nil)
+	return idRange, err
 }
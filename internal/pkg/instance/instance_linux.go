@@ -16,6 +16,7 @@ import (
 	"syscall"
 
 	"github.com/sylabs/singularity/internal/pkg/util/user"
+	"github.com/sylabs/singularity/pkg/network"
 	"github.com/sylabs/singularity/pkg/syfs"
 )
 
@@ -38,15 +39,17 @@ const (
 
 // File represents an instance file storing instance information
 type File struct {
-	Path   string `json:"-"`
-	Pid    int    `json:"pid"`
-	PPid   int    `json:"ppid"`
-	Name   string `json:"name"`
-	User   string `json:"user"`
-	Image  string `json:"image"`
-	Config []byte `json:"config"`
-	UserNs bool   `json:"userns"`
-	IP     string `json:"ip"`
+	Path     string                 `json:"-"`
+	Pid      int                    `json:"pid"`
+	PPid     int                    `json:"ppid"`
+	Name     string                 `json:"name"`
+	User     string                 `json:"user"`
+	Image    string                 `json:"image"`
+	Config   []byte                 `json:"config"`
+	UserNs   bool                   `json:"userns"`
+	IP       string                 `json:"ip"`
+	Networks []string               `json:"networks,omitempty"`
+	Ports    []network.PortMapEntry `json:"ports,omitempty"`
 }
 
 // ProcName returns processus name based on instance name
@@ -6,6 +6,9 @@
 package plugin
 
 import (
+	"fmt"
+	"reflect"
+
 	"github.com/sylabs/singularity/pkg/plugin"
 )
 
@@ -23,6 +26,22 @@ type EngineConfigMutator struct {
 	plugin.EngineConfigMutator
 }
 
+var buildSources = make(map[string]BuildSource)
+
+// BuildSource is a bootstrap agent registered by a plugin, Name is
+// the value of the Bootstrap header selecting it.
+type BuildSource struct {
+	PluginName string
+	Name       string
+	plugin.ConveyorPacker
+}
+
+// New returns a new zero value of the build source ConveyorPacker.
+func (s BuildSource) New() plugin.ConveyorPacker {
+	t := reflect.TypeOf(s.ConveyorPacker).Elem()
+	return reflect.New(t).Interface().(plugin.ConveyorPacker)
+}
+
 func CLIMutators() []CLIMutator {
 	return cliMutators
 }
@@ -31,6 +50,12 @@ func EngineConfigMutators() []EngineConfigMutator {
 	return engineConfigMutators
 }
 
+// GetBuildSource returns the build source registered with name by a plugin.
+func GetBuildSource(name string) (BuildSource, bool) {
+	s, ok := buildSources[name]
+	return s, ok
+}
+
 type registrar struct {
 	pluginName string
 }
@@ -47,3 +72,21 @@ func (r registrar) AddEngineConfigMutator(m plugin.EngineConfigMutator) error {
 	})
 	return nil
 }
+
+func (r registrar) AddBuildSource(name string, cp plugin.ConveyorPacker) error {
+	if name == "" {
+		return fmt.Errorf("build source name must not be empty")
+	}
+	if cp == nil || reflect.TypeOf(cp).Kind() != reflect.Ptr || reflect.TypeOf(cp).Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("build source %s must be a pointer to a struct", name)
+	}
+	if s, ok := buildSources[name]; ok {
+		return fmt.Errorf("build source %s already registered by plugin %s", name, s.PluginName)
+	}
+	buildSources[name] = BuildSource{
+		PluginName:     r.pluginName,
+		Name:           name,
+		ConveyorPacker: cp,
+	}
+	return nil
+}
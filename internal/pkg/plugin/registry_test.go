@@ -0,0 +1,77 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sylabs/singularity/pkg/build/types"
+)
+
+type testConveyorPacker struct {
+	b *types.Bundle
+}
+
+func (cp *testConveyorPacker) Get(ctx context.Context, b *types.Bundle) error {
+	cp.b = b
+	return nil
+}
+
+func (cp *testConveyorPacker) Pack(context.Context) (*types.Bundle, error) {
+	return cp.b, nil
+}
+
+type testValueConveyorPacker struct{}
+
+func (testValueConveyorPacker) Get(context.Context, *types.Bundle) error {
+	return nil
+}
+
+func (testValueConveyorPacker) Pack(context.Context) (*types.Bundle, error) {
+	return nil, nil
+}
+
+func TestAddBuildSource(t *testing.T) {
+	defer func() {
+		buildSources = make(map[string]BuildSource)
+	}()
+
+	r := registrar{"test-plugin"}
+	other := registrar{"other-plugin"}
+
+	if err := r.AddBuildSource("test", &testConveyorPacker{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := other.AddBuildSource("test", &testConveyorPacker{}); err == nil {
+		t.Errorf("unexpected success registering build source twice")
+	}
+	if err := r.AddBuildSource("", &testConveyorPacker{}); err == nil {
+		t.Errorf("unexpected success registering build source without name")
+	}
+	if err := r.AddBuildSource("value", testValueConveyorPacker{}); err == nil {
+		t.Errorf("unexpected success registering build source value")
+	}
+
+	if _, ok := GetBuildSource("missing"); ok {
+		t.Errorf("unexpected build source missing")
+	}
+	s, ok := GetBuildSource("test")
+	if !ok {
+		t.Fatalf("build source test not found")
+	}
+	if s.PluginName != "test-plugin" {
+		t.Errorf("got plugin name %s, want test-plugin", s.PluginName)
+	}
+
+	// each build gets its own instance
+	a := s.New()
+	b := s.New()
+	a.Get(context.Background(), &types.Bundle{})
+	if bundle, _ := b.Pack(context.Background()); bundle != nil {
+		t.Errorf("build source instances share their state")
+	}
+}
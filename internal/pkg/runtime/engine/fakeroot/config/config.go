@@ -5,6 +5,8 @@
 
 package fakeroot
 
+import "github.com/sylabs/singularity/internal/pkg/fakeroot"
+
 // Name of the engine
 const Name = "fakeroot"
 
@@ -15,4 +17,10 @@ type EngineConfig struct {
 	Envs     []string `json:"envs"`
 	Home     string   `json:"home"`
 	BuildEnv bool     `json:"buildEnv"`
+	// Allocation is the fakeroot range allocation to record, it's
+	// set during stage 1 and recorded by the master process
+	Allocation *fakeroot.Allocation `json:"allocation,omitempty"`
+	// Emulation is set when the user has no fakeroot mapping, only
+	// the user UID/GID are mapped and ownership changes are emulated
+	Emulation bool `json:"emulation,omitempty"`
 }
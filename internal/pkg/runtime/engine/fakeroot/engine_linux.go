@@ -22,6 +22,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/security/seccomp"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/internal/pkg/util/priv"
 	"github.com/sylabs/singularity/pkg/runtime/engine/config"
 	"github.com/sylabs/singularity/pkg/util/capabilities"
 	"github.com/sylabs/singularity/pkg/util/fs/proc"
@@ -62,6 +63,14 @@ func (e *EngineOperations) Config() config.EngineConfig {
 func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 	g := generate.Generator{Config: &specs.Spec{}}
 
+	if e.EngineConfig == nil {
+		return fmt.Errorf("bad fakeroot engine configuration provided")
+	}
+	// the allocation to record is only set by PrepareConfig,
+	// never trust the one provided by the user
+	e.EngineConfig.Allocation = nil
+	e.EngineConfig.Emulation = false
+
 	configurationFile := buildcfg.SINGULARITY_CONF_FILE
 
 	// check for ownership of singularity.conf
@@ -74,20 +83,8 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 		return fmt.Errorf("unable to parse singularity.conf file: %s", err)
 	}
 
-	if starterConfig.GetIsSUID() {
-		if !fileConfig.AllowSetuid {
-			return fmt.Errorf("fakeroot requires to set 'allow setuid = yes' in %s", configurationFile)
-		}
-	} else {
-		sylog.Verbosef("Fakeroot requested with unprivileged workflow, fallback to newuidmap/newgidmap")
-		sylog.Debugf("Search for newuidmap binary")
-		if err := starterConfig.SetNewUIDMapPath(); err != nil {
-			return err
-		}
-		sylog.Debugf("Search for newgidmap binary")
-		if err := starterConfig.SetNewGIDMapPath(); err != nil {
-			return err
-		}
+	if starterConfig.GetIsSUID() && !fileConfig.AllowSetuid {
+		return fmt.Errorf("fakeroot requires to set 'allow setuid = yes' in %s", configurationFile)
 	}
 
 	g.AddOrReplaceLinuxNamespace(specs.UserNamespace, "")
@@ -97,24 +94,58 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 	uid := uint32(os.Getuid())
 	gid := uint32(os.Getgid())
 
-	g.AddLinuxUIDMapping(uid, 0, 1)
-	idRange, err := fakerootutil.GetIDRange(fakerootutil.SubUIDFile, uid)
-	if err != nil {
-		return fmt.Errorf("could not use fakeroot: %s", err)
+	// automatic allocation requires the setuid workflow as
+	// newuidmap/newgidmap only allow the ranges found in
+	// /etc/subuid and /etc/subgid
+	var policy *fakerootutil.AllocationPolicy
+	if starterConfig.GetIsSUID() {
+		policy, err = fakerootutil.GetAllocationPolicy(fileConfig)
+		if err != nil {
+			return err
+		}
 	}
-	g.AddLinuxUIDMapping(idRange.HostID, idRange.ContainerID, idRange.Size)
-	starterConfig.AddUIDMappings(g.Config.Linux.UIDMappings)
 
+	g.AddLinuxUIDMapping(uid, 0, 1)
 	g.AddLinuxGIDMapping(gid, 0, 1)
-	idRange, err = fakerootutil.GetIDRange(fakerootutil.SubGIDFile, uid)
-	if err != nil {
+
+	uidRange, gidRange, alloc, err := fakerootutil.GetIDRanges(uid, policy)
+	if err == nil {
+		e.EngineConfig.Allocation = alloc
+
+		g.AddLinuxUIDMapping(uidRange.HostID, uidRange.ContainerID, uidRange.Size)
+		g.AddLinuxGIDMapping(gidRange.HostID, gidRange.ContainerID, gidRange.Size)
+
+		if !starterConfig.GetIsSUID() {
+			sylog.Verbosef("Fakeroot requested with unprivileged workflow, fallback to newuidmap/newgidmap")
+			sylog.Debugf("Search for newuidmap binary")
+			if err := starterConfig.SetNewUIDMapPath(); err != nil {
+				return err
+			}
+			sylog.Debugf("Search for newgidmap binary")
+			if err := starterConfig.SetNewGIDMapPath(); err != nil {
+				return err
+			}
+		}
+		starterConfig.SetHybridWorkflow(true)
+		starterConfig.SetAllowSetgroups(true)
+	} else if err == fakerootutil.ErrNoMapping && fileConfig.FakerootEmulation && e.EngineConfig.BuildEnv {
+		if !seccomp.Enabled() || !fakerootutil.EmulationSupported() {
+			return fmt.Errorf("could not use fakeroot: %s, emulated fakeroot requires seccomp support on amd64", err)
+		}
+		sylog.Warningf("%s: using emulated fakeroot, only your UID/GID are mapped to root", err)
+		e.EngineConfig.Emulation = true
+
+		// the mappings are written by the starter directly,
+		// without setuid the master process lives in the
+		// container user namespace
+		starterConfig.SetHybridWorkflow(starterConfig.GetIsSUID())
+		starterConfig.SetAllowSetgroups(false)
+	} else {
 		return fmt.Errorf("could not use fakeroot: %s", err)
 	}
-	g.AddLinuxGIDMapping(idRange.HostID, idRange.ContainerID, idRange.Size)
-	starterConfig.AddGIDMappings(g.Config.Linux.GIDMappings)
 
-	starterConfig.SetHybridWorkflow(true)
-	starterConfig.SetAllowSetgroups(true)
+	starterConfig.AddUIDMappings(g.Config.Linux.UIDMappings)
+	starterConfig.AddGIDMappings(g.Config.Linux.GIDMappings)
 
 	starterConfig.SetTargetUID(0)
 	starterConfig.SetTargetGID([]int{0})
@@ -134,8 +165,22 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 	return nil
 }
 
-// CreateContainer does nothing for the fakeroot engine.
+// CreateContainer records the fakeroot ranges allocated during stage 1,
+// privileges are escalated for that purpose in setuid workflow.
 func (e *EngineOperations) CreateContainer(context.Context, int, net.Conn) error {
+	alloc := e.EngineConfig.Allocation
+	if alloc == nil {
+		return nil
+	}
+
+	priv.Escalate()
+	err := alloc.Record()
+	priv.Drop()
+	if err != nil {
+		return fmt.Errorf("while recording fakeroot allocation: %s", err)
+	}
+	sylog.Verbosef("Recorded fakeroot subordinate ID ranges of UID %d", alloc.UID)
+
 	return nil
 }
 
@@ -213,6 +258,17 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 		}
 	}
 
+	if e.EngineConfig.Emulation {
+		status, err := fakerootutil.Supervise(args, env)
+		if err != nil {
+			return fmt.Errorf("emulated fakeroot failed: %s", err)
+		}
+		if status.Signaled() {
+			os.Exit(128 + int(status.Signal()))
+		}
+		os.Exit(status.ExitStatus())
+	}
+
 	if seccomp.Enabled() {
 		if err := seccomp.LoadSeccompConfig(fakerootSeccompProfile(), false, 0); err != nil {
 			sylog.Warningf("could not apply seccomp filter, some bootstrap may not work correctly")
@@ -13,9 +13,16 @@ import (
 // Name of the engine.
 const Name = "imgbuild"
 
+// SecretsDir is the directory where build secrets are exposed
+// to the %post script.
+const SecretsDir = "/run/secrets"
+
 // EngineConfig is the config for the Singularity engine used to
 // run a minimal image during image build process.
 type EngineConfig struct {
 	types.Bundle `json:"bundle"`
 	OciConfig    *oci.Config `json:"ociConfig"`
+	// Conda is the script installing the %condaenv environment,
+	// run before %post.
+	Conda string `json:"conda"`
 }
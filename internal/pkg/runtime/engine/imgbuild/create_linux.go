@@ -113,6 +113,16 @@ func (e *EngineOperations) CreateContainer(ctx context.Context, pid int, rpcConn
 		return fmt.Errorf("mount /var/tmp failed: %s", err)
 	}
 
+	if len(e.EngineConfig.Opts.Secrets) > 0 {
+		secretsPath, err := e.mountSecrets(rpcOps, sessionPath, sessionRootFs)
+		if err != nil {
+			return err
+		}
+		// %setup runs on the host where secrets are only
+		// available from the session directory
+		e.EngineConfig.OciConfig.Process.Env = append(e.EngineConfig.OciConfig.Process.Env, "SINGULARITY_SECRETS="+secretsPath)
+	}
+
 	// run setup/files sections here to allow injection of custom /etc/hosts or /etc/resolv.conf
 	if e.EngineConfig.RunSection("setup") && e.EngineConfig.Recipe.BuildData.Setup.Script != "" {
 		// Run %setup script here
@@ -178,6 +188,28 @@ func (e *EngineOperations) CreateContainer(ctx context.Context, pid int, rpcConn
 		return fmt.Errorf("mount %s failed: %s", sessionHosts, err)
 	}
 
+	// the emulator running the scripts of an image of a foreign
+	// architecture is bound at the same path in the container, the
+	// mount point is created beforehand by the builder
+	if emulator := e.EngineConfig.Opts.Emulator; emulator != "" {
+		dest = filepath.Join(sessionRootFs, emulator)
+		sylog.Debugf("Mounting emulator %s at %s\n", emulator, dest)
+		if err := rpcOps.Mount(emulator, dest, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("mount emulator %s failed: %s", emulator, err)
+		}
+	}
+
+	// cache mounts are bound after %setup and %files, only %post
+	// uses them, the mount points are created beforehand by the builder
+	for _, dir := range e.cacheMountDirs() {
+		src := e.EngineConfig.Opts.CacheMounts[dir]
+		dest = filepath.Join(sessionRootFs, dir)
+		sylog.Debugf("Mounting cache %s at %s\n", src, dest)
+		if err := rpcOps.Mount(src, dest, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("mount cache %s failed: %s", src, err)
+		}
+	}
+
 	sylog.Debugf("Chdir into %s\n", sessionRootFs)
 	err = syscall.Chdir(sessionRootFs)
 	if err != nil {
@@ -230,6 +262,48 @@ func stageFile(source, destDir string) (string, error) {
 	return sessionFile, nil
 }
 
+// mountSecrets copies the build secrets to a tmpfs filesystem mounted
+// in the session directory and binds it read-only in the container at
+// imgbuildConfig.SecretsDir, the mount point is created beforehand by
+// the builder. It returns the path of the secrets in the session directory.
+func (e *EngineOperations) mountSecrets(rpcOps *client.RPC, sessionPath, sessionRootFs string) (string, error) {
+	secretsPath := filepath.Join(sessionPath, "secrets")
+	if err := os.Mkdir(secretsPath, 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %s", secretsPath, err)
+	}
+
+	flags := uintptr(syscall.MS_NOSUID | syscall.MS_NOEXEC | syscall.MS_NODEV)
+	sylog.Debugf("Mounting secrets tmpfs at %s\n", secretsPath)
+	if err := rpcOps.Mount("tmpfs", secretsPath, "tmpfs", flags, "mode=0700"); err != nil {
+		return "", fmt.Errorf("failed to mount tmpfs filesystem on %s: %s", secretsPath, err)
+	}
+
+	for id, src := range e.EngineConfig.Opts.Secrets {
+		content, err := ioutil.ReadFile(src)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret %s: %s", id, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(secretsPath, id), content, 0400); err != nil {
+			return "", fmt.Errorf("failed to copy secret %s: %s", id, err)
+		}
+	}
+
+	if err := rpcOps.Mount("", secretsPath, "", syscall.MS_REMOUNT|syscall.MS_RDONLY|flags, ""); err != nil {
+		return "", fmt.Errorf("remount %s failed: %s", secretsPath, err)
+	}
+
+	dest := filepath.Join(sessionRootFs, imgbuildConfig.SecretsDir)
+	sylog.Debugf("Mounting secrets at %s\n", dest)
+	if err := rpcOps.Mount(secretsPath, dest, "", syscall.MS_BIND, ""); err != nil {
+		return "", fmt.Errorf("mount %s failed: %s", secretsPath, err)
+	}
+	if err := rpcOps.Mount("", dest, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|flags, ""); err != nil {
+		return "", fmt.Errorf("remount %s failed: %s", dest, err)
+	}
+
+	return secretsPath, nil
+}
+
 func (e *EngineOperations) copyFiles() error {
 	filesSection := types.Files{}
 	for _, f := range e.EngineConfig.Recipe.BuildData.Files {
@@ -283,6 +357,10 @@ func (e *EngineOperations) runScriptSection(name string, s types.Script, setEnv
 	cmd.Stdin = &b
 
 	if err := cmd.Run(); err != nil {
+		// %setup runs on the host, there is no container to debug
+		if e.EngineConfig.Opts.DebugShell && name != "setup" && e.debugShell(name, envs, err) {
+			return
+		}
 		sylog.Fatalf("failed to execute %%%s proc: %v\n", name, err)
 	}
 }
@@ -8,6 +8,7 @@ package imgbuild
 import (
 	"fmt"
 	"os"
+	"sort"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engine"
@@ -87,6 +88,17 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 	return nil
 }
 
+// cacheMountDirs returns the container directories of the cache mounts
+// sorted so parent directories come before the nested ones.
+func (e *EngineOperations) cacheMountDirs() []string {
+	dirs := make([]string, 0, len(e.EngineConfig.Opts.CacheMounts))
+	for dir := range e.EngineConfig.Opts.CacheMounts {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
 func init() {
 	engine.RegisterOperations(
 		imgbuildConfig.Name,
@@ -10,14 +10,17 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"os/exec"
 	"strings"
 	"syscall"
 
 	"github.com/opencontainers/runtime-tools/generate"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/env"
+	"github.com/sylabs/singularity/pkg/build/types"
 )
 
-// StartProcess runs the %post script
+// StartProcess runs the %condaenv installation and the %post script
 // StartProcess is called during stage2 after RPC server finished
 // environment preparation. This will execute `%post` section of a
 // container recipe file.
@@ -30,11 +33,26 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 	// clean environment in which %post and %test scripts are run in
 	e.cleanEnv()
 
+	if e.EngineConfig.Conda != "" {
+		// install the conda environment whether %post runs or not
+		e.runScriptSection("condaenv", types.Script{Script: e.EngineConfig.Conda}, true)
+	}
+
 	if e.EngineConfig.RunSection("post") && e.EngineConfig.Recipe.BuildData.Post.Script != "" {
 		// Run %post script here
 		e.runScriptSection("post", e.EngineConfig.Recipe.BuildData.Post, true)
 	}
 
+	// cache mounts are only available to %post, nested
+	// mounts are unmounted first
+	dirs := e.cacheMountDirs()
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		if err := syscall.Unmount(dir, syscall.MNT_DETACH); err != nil {
+			sylog.Warningf("Failed to unmount cache %s: %s", dir, err)
+		}
+	}
+
 	if e.EngineConfig.RunSection("test") {
 		if !e.EngineConfig.Opts.NoTest && e.EngineConfig.Recipe.BuildData.Test.Script != "" {
 			// Run %test script
@@ -46,6 +64,28 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 	return nil
 }
 
+// debugShell starts an interactive shell in the container after the
+// failure of the section name, with the same environment as the section.
+// It returns true if the build must be resumed, when the shell exits
+// with a zero status.
+func (e *EngineOperations) debugShell(name string, envs []string, err error) bool {
+	sylog.Errorf("%%%s failed: %v", name, err)
+	sylog.Infof("Starting debug shell, exit with status 0 to resume the build or with a non-zero status to abort it")
+
+	cmd := exec.Command("/bin/sh", "-i")
+	cmd.Env = append(envs, fmt.Sprintf("PS1=Singularity %%%s> ", name))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		sylog.Infof("Debug shell exited with %v, aborting the build", err)
+		return false
+	}
+	sylog.Infof("Resuming the build after %%%s", name)
+	return true
+}
+
 // MonitorContainer is called from master once the container has
 // been spawned. It will block until the container exists.
 //
@@ -109,7 +149,7 @@ func (e *EngineOperations) cleanEnv() {
 	// expose build specific environment variables for scripts
 	for _, envVar := range environment {
 		e := strings.SplitN(envVar, "=", 2)
-		if e[0] == "SINGULARITY_ROOTFS" || e[0] == "SINGULARITY_ENVIRONMENT" {
+		if e[0] == "SINGULARITY_ROOTFS" || e[0] == "SINGULARITY_ENVIRONMENT" || e[0] == "SINGULARITY_SECRETS" {
 			generator.Config.Process.Env = append(generator.Config.Process.Env, envVar)
 		}
 
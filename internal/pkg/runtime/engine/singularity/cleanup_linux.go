@@ -69,6 +69,16 @@ func (e *EngineOperations) CleanupContainer(ctx context.Context, fatal error, st
 		}
 	}
 
+	if e.EngineConfig.DNS != nil {
+		e.EngineConfig.DNS.Stop()
+	}
+
+	if e.EngineConfig.Slirp != nil {
+		if err := e.EngineConfig.Slirp.Stop(); err != nil {
+			sylog.Errorf("could not stop slirp network: %v", err)
+		}
+	}
+
 	if e.EngineConfig.Cgroups != nil {
 		if err := e.EngineConfig.Cgroups.Remove(); err != nil {
 			sylog.Errorf("could not remove cgroups: %v", err)
@@ -9,15 +9,18 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
 
+	"github.com/containernetworking/cni/libcni"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/cgroups"
+	"github.com/sylabs/singularity/internal/pkg/instance"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engine/singularity/rpc/client"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/fs"
@@ -33,6 +36,7 @@ import (
 	"github.com/sylabs/singularity/pkg/image"
 	"github.com/sylabs/singularity/pkg/network"
 	singularity "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
+	"github.com/sylabs/singularity/pkg/syfs"
 	"github.com/sylabs/singularity/pkg/util/fs/proc"
 	"github.com/sylabs/singularity/pkg/util/loop"
 	"github.com/sylabs/singularity/pkg/util/namespaces"
@@ -67,6 +71,9 @@ type container struct {
 	skippedMount  []string
 	suidFlag      uintptr
 	devSourcePath string
+	// dnsUpstreams are the name servers the embedded
+	// DNS server forwards requests to
+	dnsUpstreams []string
 }
 
 func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, pid int) error {
@@ -677,11 +684,15 @@ func (c *container) addRootfsMount(system *mount.System) error {
 	switch imageObject.Partitions[0].Type {
 	case image.SQUASHFS:
 		mountType = "squashfs"
+		// a writable SIF image only writes into its overlay
+		// partition, the root filesystem stays immutable
+		flags |= syscall.MS_RDONLY
 	case image.EXT3:
 		mountType = "ext3"
 	case image.ENCRYPTSQUASHFS:
 		mountType = "encryptfs"
 		key = c.engine.EngineConfig.GetEncryptionKey()
+		flags |= syscall.MS_RDONLY
 	case image.SANDBOX:
 		sylog.Debugf("Mounting directory rootfs: %v\n", rootfs)
 		flags |= syscall.MS_BIND
@@ -1756,6 +1767,12 @@ func (c *container) addResolvConfMount(system *mount.System) error {
 
 		dns := c.engine.EngineConfig.GetDNS()
 
+		// the slirp network forwards DNS requests to the host resolvers,
+		// which may be unreachable loopback addresses from the container
+		if dns == "" && c.netNS && c.engine.EngineConfig.GetNetwork() == network.SlirpNetwork {
+			dns = network.SlirpDNS
+		}
+
 		if dns == "" {
 			r, err := os.Open(resolvConf)
 			if err != nil {
@@ -1773,6 +1790,13 @@ func (c *container) addResolvConfMount(system *mount.System) error {
 				return err
 			}
 		}
+		// containers on CNI networks query the embedded DNS server
+		// which resolves the instance names on the same networks
+		if c.embeddedDNS() {
+			c.dnsUpstreams = network.Nameservers(content)
+			content = network.DNSResolvConf(content)
+		}
+
 		if err := c.session.AddFile(resolvConf, content); err != nil {
 			sylog.Warningf("failed to add resolv.conf session file: %s", err)
 		}
@@ -1790,6 +1814,56 @@ func (c *container) addResolvConfMount(system *mount.System) error {
 	return nil
 }
 
+// embeddedDNS returns true if the container is attached to CNI networks
+// and uses the embedded DNS server.
+func (c *container) embeddedDNS() bool {
+	n := c.engine.EngineConfig.GetNetwork()
+	return c.netNS && n != "none" && n != network.SlirpNetwork && c.engine.EngineConfig.File.ConfigResolvConf
+}
+
+// startDNS starts the embedded DNS server in the network namespace of the
+// container process pid, it answers the names of the running instances
+// attached to the same networks.
+func (c *container) startDNS(pid int, networks []string) error {
+	lookup := func(name string) []net.IP {
+		instances, err := instance.List("", "*", instance.SingSubDir)
+		if err != nil {
+			sylog.Debugf("could not retrieve instance list: %s", err)
+			return nil
+		}
+		var ips []net.IP
+		for _, i := range instances {
+			ip := net.ParseIP(i.IP)
+			if ip == nil || !strings.EqualFold(i.Name, name) {
+				continue
+			}
+			for _, n := range i.Networks {
+				if networkIn(n, networks) {
+					ips = append(ips, ip)
+					break
+				}
+			}
+		}
+		return ips
+	}
+
+	dns := network.NewDNSServer(lookup, c.dnsUpstreams)
+	if err := dns.Start(fmt.Sprintf("/proc/%d/ns/net", pid)); err != nil {
+		return err
+	}
+	c.engine.EngineConfig.DNS = dns
+	return nil
+}
+
+func networkIn(name string, networks []string) bool {
+	for _, n := range networks {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *container) addHostnameMount(system *mount.System) error {
 	hostnameFile := "/etc/hostname"
 
@@ -1856,8 +1930,10 @@ func (c *container) prepareNetworkSetup(system *mount.System, pid int) (func(con
 
 	if !c.netNS || net == noneNet {
 		return nil, nil
+	} else if net == network.SlirpNetwork {
+		return c.prepareSlirpNetwork(pid)
 	} else if (c.userNS || euid != 0) && !fakeroot {
-		return nil, fmt.Errorf("network requires root or --fakeroot, users need to specify --network=%s with --net", noneNet)
+		return nil, fmt.Errorf("network requires root or --fakeroot, users need to specify --network=%s or --network=%s with --net", noneNet, network.SlirpNetwork)
 	}
 
 	// we hold a reference to container network namespace
@@ -1872,10 +1948,46 @@ func (c *container) prepareNetworkSetup(system *mount.System, pid int) (func(con
 	}
 	networks := strings.Split(c.engine.EngineConfig.GetNetwork(), ",")
 
+	// subnets allowed for the bridge networks of regular users
+	var pool []string
+	if os.Getuid() != 0 {
+		pool = c.engine.EngineConfig.File.BridgeNetworkSubnets
+	}
+	subnetPool, err := network.ParseSubnetPool(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	// user-defined bridge networks of the calling user, their configuration
+	// is writable by the user so the subnet is checked again
+	bridgeNetworks := make(map[string]*network.BridgeNetwork)
+	for _, n := range networks {
+		bridge, err := network.LoadBridgeNetwork(syfs.NetworkDir(), n, os.Getuid())
+		if err == nil {
+			if err := bridge.CheckHost(subnetPool); err != nil {
+				return nil, err
+			}
+			bridgeNetworks[n] = bridge
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("while loading network %s: %s", n, err)
+		}
+	}
+
 	if fakeroot && euid != 0 && net != fakerootNet {
-		// set as debug message to avoid annoying warning
-		sylog.Debugf("only '%s' network is allowed for regular user, you requested '%s'", fakerootNet, net)
-		networks = []string{fakerootNet}
+		// regular users are only allowed to use the fakeroot
+		// network and their own bridge networks
+		allowed := make([]string, 0, len(networks))
+		for _, n := range networks {
+			if bridgeNetworks[n] != nil {
+				allowed = append(allowed, n)
+			}
+		}
+		if len(allowed) == 0 {
+			// set as debug message to avoid annoying warning
+			sylog.Debugf("only '%s' network is allowed for regular user, you requested '%s'", fakerootNet, net)
+			allowed = []string{fakerootNet}
+		}
+		networks = allowed
 	}
 
 	cniPath := &network.CNIPath{}
@@ -1889,7 +2001,20 @@ func (c *container) prepareNetworkSetup(system *mount.System, pid int) (func(con
 		cniPath.Plugin = defaultCNIPluginPath
 	}
 
-	setup, err := network.NewSetup(networks, strconv.Itoa(pid), nspath, cniPath)
+	confList := make([]*libcni.NetworkConfigList, len(networks))
+	for i, n := range networks {
+		var err error
+		if bridge, ok := bridgeNetworks[n]; ok {
+			confList[i], err = bridge.NetworkConfigList()
+		} else {
+			confList[i], err = libcni.LoadConfList(cniPath.Conf, n)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("network setup failed: %s", err)
+		}
+	}
+
+	setup, err := network.NewSetupFromConfig(confList, strconv.Itoa(pid), nspath, cniPath)
 	if err != nil {
 		return nil, fmt.Errorf("network setup failed: %s", err)
 	}
@@ -1899,15 +2024,16 @@ func (c *container) prepareNetworkSetup(system *mount.System, pid int) (func(con
 	}
 
 	return func(ctx context.Context) error {
-		if fakeroot {
-			// prevent port hijacking between user processes
-			if err := setup.SetPortProtection(fakerootNet, 0); err != nil {
+		// prevent port hijacking between user processes and
+		// report published ports already in use
+		for _, n := range networks {
+			if err := setup.SetPortProtection(n, 0); err != nil {
 				return err
 			}
-			if euid != 0 {
-				priv.Escalate()
-				defer priv.Drop()
-			}
+		}
+		if fakeroot && euid != 0 {
+			priv.Escalate()
+			defer priv.Drop()
 		}
 
 		setup.SetEnvPath("/bin:/sbin:/usr/bin:/usr/sbin")
@@ -1916,6 +2042,39 @@ func (c *container) prepareNetworkSetup(system *mount.System, pid int) (func(con
 			return fmt.Errorf("%s", err)
 		}
 		c.engine.EngineConfig.Network = setup
+
+		if c.embeddedDNS() {
+			if err := c.startDNS(pid, setup.GetNetworks()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// prepareSlirpNetwork returns the function starting slirp4netns to
+// provide the unprivileged user-mode network to the container process.
+func (c *container) prepareSlirpNetwork(pid int) (func(context.Context) error, error) {
+	if strings.Contains(c.engine.EngineConfig.GetNetwork(), ",") {
+		return nil, fmt.Errorf("%s network can't be combined with other networks", network.SlirpNetwork)
+	}
+	if euid := os.Geteuid(); euid != 0 && !c.userNS {
+		return nil, fmt.Errorf("%s network requires a user namespace for unprivileged users", network.SlirpNetwork)
+	}
+
+	slirp, err := network.NewSlirp(c.engine.EngineConfig.File.Slirp4netnsPath, pid, c.userNS)
+	if err != nil {
+		return nil, err
+	}
+	if err := slirp.SetArgs(c.engine.EngineConfig.GetNetworkArgs()); err != nil {
+		return nil, fmt.Errorf("error while setting network arguments: %s", err)
+	}
+
+	return func(ctx context.Context) error {
+		if err := slirp.Start(ctx); err != nil {
+			return err
+		}
+		c.engine.EngineConfig.Slirp = slirp
 		return nil
 	}, nil
 }
@@ -13,6 +13,8 @@ import (
 
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engine/singularity/rpc/client"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/priv"
 	"github.com/sylabs/singularity/pkg/runtime/engine/config"
 	singularityConfig "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
 )
@@ -45,6 +47,16 @@ func (e *EngineOperations) CreateContainer(ctx context.Context, pid int, rpcConn
 		return fmt.Errorf("unable to parse singularity.conf file: %s", err)
 	}
 
+	if alloc := e.EngineConfig.FakerootAllocation; alloc != nil {
+		priv.Escalate()
+		err := alloc.Record()
+		priv.Drop()
+		if err != nil {
+			return fmt.Errorf("while recording fakeroot allocation: %s", err)
+		}
+		sylog.Verbosef("Recorded fakeroot subordinate ID ranges of UID %d", alloc.UID)
+	}
+
 	rpcOps := &client.RPC{
 		Client: rpc.NewClient(rpcConn),
 		Name:   e.CommonConfig.EngineName,
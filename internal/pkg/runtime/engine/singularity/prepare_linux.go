@@ -65,6 +65,10 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 		return fmt.Errorf("bad engine configuration provided")
 	}
 
+	// the allocation to record is only set by PrepareConfig,
+	// never trust the one provided by the user
+	e.EngineConfig.FakerootAllocation = nil
+
 	configurationFile := buildcfg.SINGULARITY_CONF_FILE
 	e.EngineConfig.File, err = config.ParseFile(configurationFile)
 	if err != nil {
@@ -529,20 +533,30 @@ func (e *EngineOperations) prepareContainerConfig(starterConfig *starter.Config)
 		uid := uint32(os.Getuid())
 		gid := uint32(os.Getgid())
 
-		e.EngineConfig.OciConfig.AddLinuxUIDMapping(uid, 0, 1)
-		idRange, err := fakerootutil.GetIDRange(fakerootutil.SubUIDFile, uid)
+		// automatic allocation requires the setuid workflow as
+		// newuidmap/newgidmap only allow the ranges found in
+		// /etc/subuid and /etc/subgid
+		var policy *fakerootutil.AllocationPolicy
+		if starterConfig.GetIsSUID() {
+			p, err := fakerootutil.GetAllocationPolicy(e.EngineConfig.File)
+			if err != nil {
+				return err
+			}
+			policy = p
+		}
+
+		uidRange, gidRange, alloc, err := fakerootutil.GetIDRanges(uid, policy)
 		if err != nil {
 			return fmt.Errorf("could not use fakeroot: %s", err)
 		}
-		e.EngineConfig.OciConfig.AddLinuxUIDMapping(idRange.HostID, idRange.ContainerID, idRange.Size)
+		e.EngineConfig.FakerootAllocation = alloc
+
+		e.EngineConfig.OciConfig.AddLinuxUIDMapping(uid, 0, 1)
+		e.EngineConfig.OciConfig.AddLinuxUIDMapping(uidRange.HostID, uidRange.ContainerID, uidRange.Size)
 		starterConfig.AddUIDMappings(e.EngineConfig.OciConfig.Linux.UIDMappings)
 
 		e.EngineConfig.OciConfig.AddLinuxGIDMapping(gid, 0, 1)
-		idRange, err = fakerootutil.GetIDRange(fakerootutil.SubGIDFile, uid)
-		if err != nil {
-			return fmt.Errorf("could not use fakeroot: %s", err)
-		}
-		e.EngineConfig.OciConfig.AddLinuxGIDMapping(idRange.HostID, idRange.ContainerID, idRange.Size)
+		e.EngineConfig.OciConfig.AddLinuxGIDMapping(gidRange.HostID, gidRange.ContainerID, gidRange.Size)
 		starterConfig.AddGIDMappings(e.EngineConfig.OciConfig.Linux.GIDMappings)
 
 		e.EngineConfig.OciConfig.SetupPrivileged(true)
@@ -1082,7 +1096,7 @@ func (e *EngineOperations) loadImages(starterConfig *starter.Config) error {
 		// overlay partition, assuming that the root
 		// filesystem is squashfs or encrypted squashfs
 		if img.Writable && img.Partitions[0].Type != image.EXT3 && writableOverlayPath == "" {
-			return fmt.Errorf("no SIF writable overlay partition found in %s", img.Path)
+			return fmt.Errorf("no SIF writable overlay partition found in %s, add one with 'singularity overlay create'", img.Path)
 		}
 	}
 
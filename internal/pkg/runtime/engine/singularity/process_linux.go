@@ -8,7 +8,6 @@ package singularity
 import (
 	"context"
 	"debug/elf"
-	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,37 +27,15 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/instance"
 	"github.com/sylabs/singularity/internal/pkg/security"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/arch"
 	"github.com/sylabs/singularity/internal/pkg/util/user"
+	"github.com/sylabs/singularity/pkg/network"
 	singularity "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
 const defaultShell = "/bin/sh"
 
-// Convert an ELF architecture into a GOARCH-style string. This is not an
-// exhaustive list, so there is a default for rare cases. Adapted from
-// https://golang.org/src/cmd/internal/objfile/elf.go
-func elfToGoArch(elfFile *elf.File) string {
-	switch elfFile.Machine {
-	case elf.EM_386:
-		return "386"
-	case elf.EM_X86_64:
-		return "amd64"
-	case elf.EM_ARM:
-		return "arm"
-	case elf.EM_AARCH64:
-		return "arm64"
-	case elf.EM_PPC64:
-		if elfFile.ByteOrder == binary.LittleEndian {
-			return "ppc64le"
-		}
-		return "ppc64"
-	case elf.EM_S390:
-		return "s390x"
-	}
-	return "UNKNOWN"
-}
-
 // StartProcess is called during stage2 after RPC server finished
 // environment preparation. This is the container process itself.
 //
@@ -172,7 +149,7 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 				return fmt.Errorf("failed to open %s for inspection: %s", shell, errElf)
 			}
 			defer self.Close()
-			if elfArch := elfToGoArch(self); elfArch != runtime.GOARCH {
+			if elfArch := arch.ElfToGoArch(self); elfArch != runtime.GOARCH {
 				return fmt.Errorf("image targets %s, cannot run on %s", elfArch, runtime.GOARCH)
 			}
 			// Assume a missing shared library on ENOENT
@@ -333,6 +310,12 @@ func (e *EngineOperations) PostStartProcess(ctx context.Context, pid int) error
 			sylog.Warningf("Could not get ip for %s: %s", pw.Name, err)
 		}
 		file.IP = ip
+		if e.EngineConfig.Network != nil {
+			file.Networks = e.EngineConfig.Network.GetNetworks()
+			file.Ports = e.EngineConfig.Network.GetPortMaps()
+		} else if e.EngineConfig.Slirp != nil {
+			file.Ports = e.EngineConfig.Slirp.PortMaps()
+		}
 
 		// by default we add all namespaces except the user namespace which
 		// is added conditionally. This delegates checks to the C starter code
@@ -576,6 +559,9 @@ func preStartProcess(e *EngineOperations) error {
 }
 
 func (e *EngineOperations) getIP() (string, error) {
+	if e.EngineConfig.Slirp != nil {
+		return network.SlirpIP, nil
+	}
 	if e.EngineConfig.Network == nil {
 		return "", nil
 	}
@@ -155,6 +155,37 @@ func Debugf(format string, a ...interface{}) {
 	writef(os.Stderr, debug, format, a...)
 }
 
+// Logger writes messages to a writer other than stderr, like the
+// output of a build stage prefixed with its name.
+type Logger struct {
+	w io.Writer
+}
+
+// NewLogger returns a Logger writing messages to w.
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+// Warningf writes a WARNING level message to the logger writer.
+func (l *Logger) Warningf(format string, a ...interface{}) {
+	writef(l.w, warn, format, a...)
+}
+
+// Infof writes an INFO level message to the logger writer.
+func (l *Logger) Infof(format string, a ...interface{}) {
+	writef(l.w, info, format, a...)
+}
+
+// Verbosef writes a VERBOSE level message to the logger writer.
+func (l *Logger) Verbosef(format string, a ...interface{}) {
+	writef(l.w, verbose, format, a...)
+}
+
+// Debugf writes a DEBUG level message to the logger writer.
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	writef(l.w, debug, format, a...)
+}
+
 // SetLevel explicitly sets the loggerLevel
 func SetLevel(l int) {
 	loggerLevel = messageLevel(l)
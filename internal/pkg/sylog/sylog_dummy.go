@@ -35,6 +35,26 @@ func Verbosef(format string, a ...interface{}) {}
 // Debugf is a dummy function doing nothing
 func Debugf(format string, a ...interface{}) {}
 
+// Logger is a dummy logger doing nothing.
+type Logger struct{}
+
+// NewLogger is a dummy function returning a dummy logger.
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{}
+}
+
+// Warningf is a dummy function doing nothing.
+func (l *Logger) Warningf(format string, a ...interface{}) {}
+
+// Infof is a dummy function doing nothing.
+func (l *Logger) Infof(format string, a ...interface{}) {}
+
+// Verbosef is a dummy function doing nothing.
+func (l *Logger) Verbosef(format string, a ...interface{}) {}
+
+// Debugf is a dummy function doing nothing.
+func (l *Logger) Debugf(format string, a ...interface{}) {}
+
 // SetLevel is a dummy function doing nothing.
 func SetLevel(l int) {}
 
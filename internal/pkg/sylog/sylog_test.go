@@ -208,6 +208,29 @@ func TestWritef(t *testing.T) {
 	}
 }
 
+func TestLogger(t *testing.T) {
+	const str = "just a test"
+
+	SetLevel(int(info))
+	DisableColor()
+
+	var buf bytes.Buffer
+	l := NewLogger(&buf)
+
+	l.Infof("%s", str)
+	expectedResult := prefix(info) + str + "\n"
+	if buf.String() != expectedResult {
+		t.Fatalf("logger returned %s instead of %s", buf.String(), expectedResult)
+	}
+
+	// messages above the logger level are discarded
+	buf.Reset()
+	l.Verbosef("%s", str)
+	if buf.String() != "" {
+		t.Fatalf("logger returned %s instead of an empty string", buf.String())
+	}
+}
+
 func TestGetLevel(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -0,0 +1,106 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package arch provides the detection of the architecture of binaries
+// and container root filesystems
+package arch
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Unknown is returned for ELF architectures without GOARCH equivalent.
+const Unknown = "UNKNOWN"
+
+// maxSymlinks is the maximum number of symbolic links followed while
+// resolving a path in a root filesystem.
+const maxSymlinks = 40
+
+// ElfToGoArch converts an ELF architecture into a GOARCH-style string.
+// This is not an exhaustive list, so there is a default for rare cases.
+// Adapted from https://golang.org/src/cmd/internal/objfile/elf.go
+func ElfToGoArch(elfFile *elf.File) string {
+	switch elfFile.Machine {
+	case elf.EM_386:
+		return "386"
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_PPC64:
+		if elfFile.ByteOrder == binary.LittleEndian {
+			return "ppc64le"
+		}
+		return "ppc64"
+	case elf.EM_S390:
+		return "s390x"
+	}
+	return Unknown
+}
+
+// File returns the GOARCH-style architecture of the ELF binary at path.
+func File(path string) (string, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("while inspecting %s: %s", path, err)
+	}
+	defer f.Close()
+
+	return ElfToGoArch(f), nil
+}
+
+// Rootfs returns the GOARCH-style architecture of the root filesystem
+// at rootfs, based on its /bin/sh shell. Symbolic links are resolved
+// within rootfs.
+func Rootfs(rootfs string) (string, error) {
+	path, err := resolve(rootfs, "/bin/sh")
+	if err != nil {
+		return "", err
+	}
+	return File(path)
+}
+
+// resolve returns the host path of path in the root filesystem rootfs,
+// absolute symbolic links being relative to rootfs.
+func resolve(rootfs, path string) (string, error) {
+	for i := 0; i < maxSymlinks; i++ {
+		resolved := rootfs
+		components := strings.Split(strings.Trim(filepath.Clean(path), "/"), "/")
+
+		link := false
+		for j, c := range components {
+			resolved = filepath.Join(resolved, c)
+			fi, err := os.Lstat(resolved)
+			if err != nil {
+				return "", fmt.Errorf("while resolving %s: %s", path, err)
+			}
+			if fi.Mode()&os.ModeSymlink == 0 {
+				continue
+			}
+
+			target, err := os.Readlink(resolved)
+			if err != nil {
+				return "", fmt.Errorf("while resolving %s: %s", path, err)
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join("/", strings.Join(components[:j], "/"), target)
+			}
+			path = filepath.Join(target, strings.Join(components[j+1:], "/"))
+			link = true
+			break
+		}
+		if !link {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("while resolving %s: too many levels of symbolic links", path)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package arch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRootfs(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to get test executable: %s", err)
+	}
+	content, err := ioutil.ReadFile(exe)
+	if err != nil {
+		t.Fatalf("failed to read test executable: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		links   map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "Binary",
+			files: map[string]string{"bin/sh": ""},
+		},
+		{
+			name:  "AbsoluteLink",
+			files: map[string]string{"bin/busybox": ""},
+			links: map[string]string{"bin/sh": "/bin/busybox"},
+		},
+		{
+			name:  "RelativeLinks",
+			files: map[string]string{"usr/bin/dash": ""},
+			links: map[string]string{"bin": "usr/bin", "usr/bin/sh": "dash"},
+		},
+		{
+			name:    "LinkOutsideRootfs",
+			links:   map[string]string{"bin": "../../../../../../bin"},
+			wantErr: true,
+		},
+		{
+			name:    "LinkLoop",
+			links:   map[string]string{"bin/sh": "/bin/sh"},
+			wantErr: true,
+		},
+		{
+			name:    "NotELF",
+			files:   map[string]string{"bin/sh": "#!/bin/busybox"},
+			wantErr: true,
+		},
+		{
+			name:    "Missing",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootfs, err := ioutil.TempDir("", "rootfs-")
+			if err != nil {
+				t.Fatalf("failed to create temporary directory: %s", err)
+			}
+			defer os.RemoveAll(rootfs)
+
+			for path, c := range tt.files {
+				path = filepath.Join(rootfs, path)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("failed to create %s: %s", filepath.Dir(path), err)
+				}
+				b := content
+				if c != "" {
+					b = []byte(c)
+				}
+				if err := ioutil.WriteFile(path, b, 0755); err != nil {
+					t.Fatalf("failed to write %s: %s", path, err)
+				}
+			}
+			for path, target := range tt.links {
+				path = filepath.Join(rootfs, path)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("failed to create %s: %s", filepath.Dir(path), err)
+				}
+				if err := os.Symlink(target, path); err != nil {
+					t.Fatalf("failed to create symlink %s: %s", path, err)
+				}
+			}
+
+			arch, err := Rootfs(rootfs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if arch != runtime.GOARCH {
+				t.Errorf("unexpected architecture %s, want %s", arch, runtime.GOARCH)
+			}
+		})
+	}
+}
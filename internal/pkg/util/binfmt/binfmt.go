@@ -0,0 +1,331 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package binfmt provides the lookup and registration of binfmt_misc
+// handlers running the binaries of foreign architectures with QEMU user
+// emulation
+package binfmt
+
+import (
+	"bufio"
+	"debug/elf"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// MiscDir is the directory where binfmt_misc is mounted.
+const MiscDir = "/proc/sys/fs/binfmt_misc"
+
+// qemu describes the QEMU user emulation of an architecture, the magic
+// and mask matching its ELF binaries are taken from qemu-binfmt-conf.sh.
+type qemu struct {
+	arch  string
+	magic string
+	mask  string
+}
+
+// emulations are the QEMU user emulations by GOARCH-style architecture.
+var emulations = map[string]qemu{
+	"386": {
+		arch:  "i386",
+		magic: `\x7fELF\x01\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x03\x00`,
+		mask:  `\xff\xff\xff\xff\xff\xfe\xfe\x00\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\xff`,
+	},
+	"amd64": {
+		arch:  "x86_64",
+		magic: `\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00`,
+		mask:  `\xff\xff\xff\xff\xff\xfe\xfe\x00\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\xff`,
+	},
+	"arm": {
+		arch:  "arm",
+		magic: `\x7fELF\x01\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x28\x00`,
+		mask:  `\xff\xff\xff\xff\xff\xff\xff\x00\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\xff`,
+	},
+	"arm64": {
+		arch:  "aarch64",
+		magic: `\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\xb7\x00`,
+		mask:  `\xff\xff\xff\xff\xff\xff\xff\x00\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\xff`,
+	},
+	"ppc64": {
+		arch:  "ppc64",
+		magic: `\x7fELF\x02\x02\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x15`,
+		mask:  `\xff\xff\xff\xff\xff\xff\xff\x00\xff\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff`,
+	},
+	"ppc64le": {
+		arch:  "ppc64le",
+		magic: `\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x15\x00`,
+		mask:  `\xff\xff\xff\xff\xff\xff\xff\x00\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\x00`,
+	},
+	"s390x": {
+		arch:  "s390x",
+		magic: `\x7fELF\x02\x02\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x16`,
+		mask:  `\xff\xff\xff\xff\xff\xff\xff\x00\xff\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff`,
+	},
+}
+
+// Handler is an enabled binfmt_misc handler.
+type Handler struct {
+	// Name of the handler in binfmt_misc.
+	Name string
+	// Interpreter is the host path of the emulator.
+	Interpreter string
+	// FixBinary is true if the interpreter was opened when the
+	// handler was registered (F flag), so it is found from any
+	// mount namespace.
+	FixBinary bool
+	// Registered is true if the handler was registered by Ensure,
+	// it is removed by Unregister.
+	Registered bool
+
+	dir string
+}
+
+// ensureMutex serializes the lookup and registration of the
+// handlers by concurrent builds of the same process.
+var ensureMutex sync.Mutex
+
+// Ensure returns the binfmt_misc handler emulating the GOARCH-style
+// architecture arch. If none is registered, the static QEMU binary
+// qemu-<arch>-static found in PATH is registered until Unregister is
+// called.
+func Ensure(arch string) (*Handler, error) {
+	q, ok := emulations[arch]
+	if !ok {
+		return nil, fmt.Errorf("no emulation known for architecture %s", arch)
+	}
+
+	ensureMutex.Lock()
+	defer ensureMutex.Unlock()
+
+	h, err := find(MiscDir, q)
+	if err != nil {
+		return nil, err
+	}
+	if h != nil {
+		sylog.Debugf("Using binfmt_misc handler %s for %s", h.Name, arch)
+		if err := checkStatic(h.Interpreter); err != nil {
+			return nil, err
+		}
+		return h, nil
+	}
+
+	interpreter, err := exec.LookPath("qemu-" + q.arch + "-static")
+	if err != nil {
+		return nil, fmt.Errorf("no binfmt_misc handler registered for %s and qemu-%s-static not found", arch, q.arch)
+	}
+	if err := checkStatic(interpreter); err != nil {
+		return nil, err
+	}
+
+	sylog.Infof("Registering %s as binfmt_misc handler for %s", interpreter, arch)
+	return register(MiscDir, q, interpreter)
+}
+
+// find returns the enabled handler of dir matching the binaries of the
+// emulation q, or nil if none is registered.
+func find(dir string, q qemu) (*Handler, error) {
+	if _, err := os.Stat(filepath.Join(dir, "register")); err != nil {
+		return nil, fmt.Errorf("binfmt_misc is not available: %s", err)
+	}
+
+	magic, err := unescape(q.magic)
+	if err != nil {
+		return nil, err
+	}
+	mask, err := unescape(q.mask)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("while reading binfmt_misc handlers: %s", err)
+	}
+	for _, e := range entries {
+		if e.Name() == "register" || e.Name() == "status" {
+			continue
+		}
+		h, m, hmask, err := parseHandler(filepath.Join(dir, e.Name()))
+		if err != nil {
+			sylog.Debugf("Ignoring binfmt_misc handler %s: %s", e.Name(), err)
+			continue
+		}
+		if h != nil && matches(m, hmask, magic, mask) {
+			return h, nil
+		}
+	}
+	return nil, nil
+}
+
+// parseHandler parses the binfmt_misc handler file at path, it returns
+// the handler with its magic and mask if it is enabled and matches on
+// magic at offset 0. The mask is nil if the handler has none.
+func parseHandler(path string) (*Handler, []byte, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer f.Close()
+
+	h := &Handler{Name: filepath.Base(path)}
+	var magic, mask []byte
+	enabled := false
+	offset := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "enabled":
+			enabled = true
+		case "interpreter":
+			if len(fields) > 1 {
+				h.Interpreter = fields[1]
+			}
+		case "flags:":
+			if len(fields) > 1 {
+				h.FixBinary = strings.ContainsRune(fields[1], 'F')
+			}
+		case "offset":
+			if len(fields) > 1 {
+				if offset, err = strconv.Atoi(fields[1]); err != nil {
+					return nil, nil, nil, fmt.Errorf("bad offset: %s", err)
+				}
+			}
+		case "magic":
+			if len(fields) > 1 {
+				if magic, err = hex.DecodeString(fields[1]); err != nil {
+					return nil, nil, nil, fmt.Errorf("bad magic: %s", err)
+				}
+			}
+		case "mask":
+			if len(fields) > 1 {
+				if mask, err = hex.DecodeString(fields[1]); err != nil {
+					return nil, nil, nil, fmt.Errorf("bad mask: %s", err)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if !enabled || offset != 0 || magic == nil || h.Interpreter == "" {
+		return nil, nil, nil, nil
+	}
+	return h, magic, mask, nil
+}
+
+// matches returns whether the handler with the magic handlerMagic and
+// the mask handlerMask, nil if it has none, matches all the binaries
+// described by magic and mask: the handler must only check the bits
+// fixed by mask, with the same values.
+func matches(handlerMagic, handlerMask, magic, mask []byte) bool {
+	for i := range handlerMagic {
+		m := byte(0xff)
+		if handlerMask != nil {
+			if i >= len(handlerMask) {
+				return false
+			}
+			m = handlerMask[i]
+		}
+		if m == 0 {
+			continue
+		}
+		if i >= len(magic) || m&^mask[i] != 0 || handlerMagic[i]&m != magic[i]&m {
+			return false
+		}
+	}
+	return true
+}
+
+// register registers the interpreter as handler in dir for the binaries
+// of the emulation q, the interpreter is opened right away (F flag) so
+// it doesn't need to be available in containers.
+func register(dir string, q qemu, interpreter string) (*Handler, error) {
+	name := "qemu-" + q.arch
+	rule := fmt.Sprintf(":%s:M::%s:%s:%s:F", name, q.magic, q.mask, interpreter)
+
+	f, err := os.OpenFile(filepath.Join(dir, "register"), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("while registering binfmt_misc handler: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(rule); err != nil {
+		return nil, fmt.Errorf("while registering binfmt_misc handler: %s", err)
+	}
+	return &Handler{Name: name, Interpreter: interpreter, FixBinary: true, Registered: true, dir: dir}, nil
+}
+
+// Unregister removes the handler if it was registered by Ensure,
+// handlers which were already registered are left untouched.
+func (h *Handler) Unregister() error {
+	if !h.Registered {
+		return nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(h.dir, h.Name), os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("while unregistering binfmt_misc handler %s: %s", h.Name, err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString("-1"); err != nil {
+		return fmt.Errorf("while unregistering binfmt_misc handler %s: %s", h.Name, err)
+	}
+	h.Registered = false
+	return nil
+}
+
+// unescape decodes a string of \xNN escape sequences like used in
+// binfmt_misc rules.
+func unescape(s string) ([]byte, error) {
+	var b []byte
+	for len(s) > 0 {
+		if strings.HasPrefix(s, `\x`) && len(s) >= 4 {
+			v, err := strconv.ParseUint(s[2:4], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("bad escape sequence %s: %s", s[:4], err)
+			}
+			b = append(b, byte(v))
+			s = s[4:]
+			continue
+		}
+		b = append(b, s[0])
+		s = s[1:]
+	}
+	return b, nil
+}
+
+// checkStatic returns an error if the interpreter at path is not a
+// static binary, which can't run from a container of another
+// architecture.
+func checkStatic(path string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		return fmt.Errorf("while inspecting interpreter %s: %s", path, err)
+	}
+	defer f.Close()
+
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_INTERP {
+			return fmt.Errorf("interpreter %s is not a static binary", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package binfmt
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const aarch64Handler = `enabled
+interpreter /usr/bin/qemu-aarch64-static
+flags: F
+offset 0
+magic 7f454c460201010000000000000000000200b700
+mask ffffffffffffff00fffffffffffffffffeffffff
+`
+
+const armHandler = `enabled
+interpreter /usr/bin/qemu-arm
+flags: OC
+offset 0
+magic 7f454c4601010100000000000000000002002800
+mask ffffffffffffff00fffffffffffffffffeffffff
+`
+
+const disabledHandler = `disabled
+interpreter /usr/bin/qemu-s390x-static
+flags: F
+offset 0
+magic 7f454c4602020100000000000000000000020016
+mask ffffffffffffff00fffffffffffffffffffeffff
+`
+
+func TestEmulations(t *testing.T) {
+	for arch, q := range emulations {
+		magic, err := unescape(q.magic)
+		if err != nil {
+			t.Errorf("%s: bad magic: %s", arch, err)
+		}
+		mask, err := unescape(q.mask)
+		if err != nil {
+			t.Errorf("%s: bad mask: %s", arch, err)
+		}
+		if len(magic) != 20 || len(mask) != 20 {
+			t.Errorf("%s: unexpected magic or mask length: %d, %d", arch, len(magic), len(mask))
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binfmt-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := find(dir, emulations["arm64"]); err == nil {
+		t.Fatalf("unexpected success without binfmt_misc")
+	}
+
+	files := map[string]string{
+		"register":     "",
+		"status":       "enabled\n",
+		"qemu-aarch64": aarch64Handler,
+		"arm":          armHandler,
+		"qemu-s390x":   disabledHandler,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		arch        string
+		name        string
+		interpreter string
+		fixBinary   bool
+	}{
+		{arch: "arm64", name: "qemu-aarch64", interpreter: "/usr/bin/qemu-aarch64-static", fixBinary: true},
+		{arch: "arm", name: "arm", interpreter: "/usr/bin/qemu-arm"},
+		{arch: "s390x"},
+		{arch: "ppc64le"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arch, func(t *testing.T) {
+			h, err := find(dir, emulations[tt.arch])
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.name == "" {
+				if h != nil {
+					t.Fatalf("unexpected handler found: %+v", h)
+				}
+				return
+			}
+			if h == nil {
+				t.Fatalf("handler not found")
+			}
+			if h.Name != tt.name || h.Interpreter != tt.interpreter || h.FixBinary != tt.fixBinary {
+				t.Errorf("unexpected handler: %+v", h)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatalf("bad hex string %s: %s", s, err)
+		}
+		return b
+	}
+
+	q := emulations["arm64"]
+	magic, _ := unescape(q.magic)
+	mask, _ := unescape(q.mask)
+
+	tests := []struct {
+		name        string
+		magic       string
+		mask        string
+		wantMatches bool
+	}{
+		{
+			name:        "SameMask",
+			magic:       "7f454c460201010000000000000000000200b700",
+			mask:        "ffffffffffffff00fffffffffffffffffeffffff",
+			wantMatches: true,
+		},
+		{
+			name:        "LooserMask",
+			magic:       "7f454c460201010000000000000000000200b700",
+			mask:        "ffffffff0000000000000000000000000000ffff",
+			wantMatches: true,
+		},
+		{
+			name:  "StricterMask",
+			magic: "7f454c460201010000000000000000000200b700",
+			mask:  "ffffffffffffffffffffffffffffffffffffffff",
+		},
+		{
+			name:  "NoMask",
+			magic: "7f454c460201010000000000000000000200b700",
+		},
+		{
+			name:        "ShortMagic",
+			magic:       "7f454c46",
+			wantMatches: true,
+		},
+		{
+			name:  "OtherArchitecture",
+			magic: "7f454c4601010100000000000000000002002800",
+			mask:  "ffffffffffffff00fffffffffffffffffeffffff",
+		},
+		{
+			name:  "LongMagic",
+			magic: "7f454c460201010000000000000000000200b70001",
+			mask:  "ffffffffffffff00fffffffffffffffffeffffffff",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handlerMask []byte
+			if tt.mask != "" {
+				handlerMask = decode(tt.mask)
+			}
+			if got := matches(decode(tt.magic), handlerMask, magic, mask); got != tt.wantMatches {
+				t.Errorf("got %v, want %v", got, tt.wantMatches)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binfmt-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "register")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", file, err)
+	}
+
+	h, err := register(dir, emulations["arm64"], "/usr/bin/qemu-aarch64-static")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if h.Name != "qemu-aarch64" || !h.FixBinary {
+		t.Errorf("unexpected handler: %+v", h)
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", file, err)
+	}
+	want := `:qemu-aarch64:M::` + emulations["arm64"].magic + `:` + emulations["arm64"].mask + `:/usr/bin/qemu-aarch64-static:F`
+	if string(b) != want {
+		t.Errorf("unexpected rule %q, want %q", b, want)
+	}
+
+	file = filepath.Join(dir, h.Name)
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", file, err)
+	}
+	if err := h.Unregister(); err != nil {
+		t.Fatalf("unexpected unregister error: %s", err)
+	}
+	if b, err := ioutil.ReadFile(file); err != nil || string(b) != "-1" {
+		t.Errorf("handler not unregistered: %q, %v", b, err)
+	}
+
+	// handlers not registered by Ensure are left untouched
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", file, err)
+	}
+	for _, h := range []*Handler{h, {Name: h.Name, dir: dir}} {
+		if err := h.Unregister(); err != nil {
+			t.Fatalf("unexpected unregister error: %s", err)
+		}
+	}
+	if b, err := ioutil.ReadFile(file); err != nil || len(b) != 0 {
+		t.Errorf("handler unexpectedly unregistered: %q, %v", b, err)
+	}
+}
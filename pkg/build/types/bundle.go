@@ -7,9 +7,11 @@ package types
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	ocitypes "github.com/containers/image/types"
 	"github.com/sylabs/singularity/internal/pkg/client/cache"
@@ -28,6 +30,11 @@ type Bundle struct {
 
 	RootfsPath string `json:"rootfsPath"` // where actual fs to chroot will appear
 	TmpDir     string `json:"tmpPath"`    // where temp files required during build will appear
+
+	// Stdout and Stderr receive the output of the commands run and
+	// the messages logged while building the bundle.
+	Stdout io.Writer `json:"-"`
+	Stderr io.Writer `json:"-"`
 }
 
 // Options defines build time behavior to be executed on the bundle.
@@ -48,6 +55,9 @@ type Options struct {
 	EncryptionKeyInfo *crypt.KeyInfo
 	// NoTest indicates if build should skip running the test script.
 	NoTest bool `json:"noTest"`
+	// DebugShell starts an interactive shell in the container when
+	// the %post or %test script fails.
+	DebugShell bool `json:"debugShell"`
 	// Force automatically deletes an existing container at build destination while performing build.
 	Force bool `json:"force"`
 	// Update detects and builds using an existing sandbox container at build destination.
@@ -61,6 +71,25 @@ type Options struct {
 	NoCache bool
 	// ImgCache stores a pointer to the image cache to use.
 	ImgCache *cache.Handle
+	// SourceDateEpoch is the time recorded in place of the current time
+	// and clamping all file times to build a reproducible image.
+	// A nil value indicates a regular build.
+	SourceDateEpoch *time.Time `json:"sourceDateEpoch"`
+	// Secrets maps the ID of secrets to the host files holding them,
+	// they are only exposed to the %setup and %post scripts.
+	Secrets map[string]string `json:"secrets"`
+	// CacheMounts maps container directories to the host directories
+	// bound on them during %post, persisting their content across
+	// builds without storing it in the image.
+	CacheMounts map[string]string `json:"cacheMounts"`
+	// Arch is the GOARCH-style architecture of the image built, the
+	// bootstrap image is pulled for it. The host architecture is
+	// used if empty.
+	Arch string `json:"arch"`
+	// Emulator is the host path of the static QEMU binary bound at
+	// the same path in the container to run the scripts of an image
+	// of a foreign architecture, when its binfmt_misc handler needs it.
+	Emulator string `json:"emulator"`
 }
 
 // NewEncryptedBundle creates an Encrypted Bundle environment.
@@ -125,6 +154,8 @@ func newBundle(rootfs, tempDir string, keyInfo *crypt.KeyInfo) (*Bundle, error)
 		RootfsPath:  rootfs,
 		TmpDir:      tmpPath,
 		JSONObjects: make(map[string][]byte),
+		Stdout:      os.Stdout,
+		Stderr:      os.Stderr,
 		Opts: Options{
 			EncryptionKeyInfo: keyInfo,
 		},
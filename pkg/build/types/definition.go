@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 )
 
@@ -125,7 +126,15 @@ func writeSectionIfExists(w io.Writer, ident string, s Script) {
 		if len(s.Args) > 0 {
 			fmt.Fprintf(w, " %s", s.Args)
 		}
-		fmt.Fprintf(w, "\n%s\n\n", s.Script)
+		fmt.Fprintf(w, "\n%s\n\n", strings.TrimRight(s.Script, "\n"))
+	}
+}
+
+// writeCustomSections writes the app and build sections
+// stored as custom data.
+func writeCustomSections(w io.Writer, c map[string]string) {
+	for _, k := range sortedKeys(c) {
+		writeSectionIfExists(w, k, Script{Script: c[k]})
 	}
 }
 
@@ -139,7 +148,7 @@ func writeFilesIfExists(w io.Writer, f []Files) {
 			fmt.Fprintln(w)
 
 			for _, ft := range f.Files {
-				fmt.Fprintf(w, "\t%s\t%s\n", ft.Src, ft.Dst)
+				fmt.Fprintf(w, "\t%s %s\n", ft.Src, ft.Dst)
 			}
 			fmt.Fprintln(w)
 		}
@@ -149,18 +158,41 @@ func writeFilesIfExists(w io.Writer, f []Files) {
 func writeLabelsIfExists(w io.Writer, l map[string]string) {
 	if len(l) > 0 {
 		fmt.Fprintln(w, "%labels")
-		for k, v := range l {
-			fmt.Fprintf(w, "\t%s %s\n", k, v)
+		for _, k := range sortedKeys(l) {
+			fmt.Fprintf(w, "\t%s %s\n", k, l[k])
 		}
 		fmt.Fprintln(w)
 	}
 }
 
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteDefinitionFile writes the Definition d to w in the
+// definition file format.
+func WriteDefinitionFile(d *Definition, w io.Writer) {
+	populateRaw(d, w)
+}
+
 // populateRaw is a helper func to output a Definition struct
 // into a definition file.
 func populateRaw(d *Definition, w io.Writer) {
-	for k, v := range d.Header {
-		fmt.Fprintf(w, "%s: %s\n", k, v)
+	// bootstrap comes first as it starts a new stage
+	// in multi-stage definition files
+	if v, ok := d.Header["bootstrap"]; ok {
+		fmt.Fprintf(w, "%s: %s\n", "bootstrap", v)
+	}
+	for _, k := range sortedKeys(d.Header) {
+		if k != "bootstrap" {
+			fmt.Fprintf(w, "%s: %s\n", k, d.Header[k])
+		}
 	}
 	fmt.Fprintln(w)
 
@@ -170,9 +202,14 @@ func populateRaw(d *Definition, w io.Writer) {
 	writeSectionIfExists(w, "help", d.ImageData.Help)
 	writeSectionIfExists(w, "environment", d.ImageData.Environment)
 	writeSectionIfExists(w, "runscript", d.ImageData.Runscript)
-	writeSectionIfExists(w, "test", d.ImageData.Test)
+	test := d.ImageData.Test
+	if test.Script == "" {
+		test = d.BuildData.Test
+	}
+	writeSectionIfExists(w, "test", test)
 	writeSectionIfExists(w, "startscript", d.ImageData.Startscript)
 	writeSectionIfExists(w, "pre", d.BuildData.Pre)
 	writeSectionIfExists(w, "setup", d.BuildData.Setup)
 	writeSectionIfExists(w, "post", d.BuildData.Post)
+	writeCustomSections(w, d.CustomData)
 }
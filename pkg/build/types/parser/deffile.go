@@ -313,7 +313,7 @@ func populateDefinition(sections map[string]*types.Script, files *[]types.Files,
 		var keys []string
 		for k := range sections {
 			sectionName := strings.Split(k, " ")
-			if !appSections[sectionName[0]] {
+			if !appSections[sectionName[0]] && !buildSections[sectionName[0]] {
 				keys = append(keys, k)
 			}
 		}
@@ -369,15 +369,8 @@ func doHeader(h string, d *types.Definition) error {
 			}
 			continue
 		}
-		if _, ok := validHeaders[key]; !ok {
-			rgx := regexp.MustCompile(`\d+$`)
-			tmpKey := rgx.ReplaceAllString(key, "&n")
-			if ok = tmpKey != key; ok {
-				_, ok = validHeaders[tmpKey]
-			}
-			if !ok {
-				return fmt.Errorf("invalid header keyword found: %s", key)
-			}
+		if !IsValidHeader(key) {
+			return fmt.Errorf("invalid header keyword found: %s", key)
 		}
 		header[key] = val
 	}
@@ -537,6 +530,37 @@ var appSections = map[string]bool{
 	"apprun":     true,
 }
 
+// buildSections contains the sections handled at build time
+// which are stored as custom data
+var buildSections = map[string]bool{
+	"condaenv": true,
+}
+
+// IsValidSection returns whether name is the name of a standard, app
+// or build section of a definition file.
+func IsValidSection(name string) bool {
+	name = strings.ToLower(name)
+	return validSections[name] || appSections[name] || buildSections[name]
+}
+
+// IsAppSection returns whether name is the name of an app section.
+func IsAppSection(name string) bool {
+	return appSections[strings.ToLower(name)]
+}
+
+// IsValidHeader returns whether key is a valid header keyword, numbered
+// keywords like OtherURL0 are matched against their "&n" form.
+func IsValidHeader(key string) bool {
+	key = strings.ToLower(key)
+	if validHeaders[key] {
+		return true
+	}
+	tmpKey := headerIndexRegexp.ReplaceAllString(key, "&n")
+	return tmpKey != key && validHeaders[tmpKey]
+}
+
+var headerIndexRegexp = regexp.MustCompile(`\d+$`)
+
 // validHeaders just contains a list of all the valid headers a definition file
 // could contain. If any others are found, an error will generate
 var validHeaders = map[string]bool{
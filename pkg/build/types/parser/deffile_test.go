@@ -184,6 +184,7 @@ func TestParseDefinitionFile(t *testing.T) {
 		{"SectionArgs", "testdata_good/sectionargs/sectionargs", "testdata_good/sectionargs/sectionargs.json"},
 		{"MultipleFiless", "testdata_good/multiplefiles/multiplefiles", "testdata_good/multiplefiles/multiplefiles.json"},
 		{"Shebang", "testdata_good/shebang/shebang", "testdata_good/shebang/shebang.json"},
+		{"CondaEnv", "testdata_good/condaenv/condaenv", "testdata_good/condaenv/condaenv.json"},
 	}
 
 	for _, tt := range tests {
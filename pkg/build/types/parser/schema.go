@@ -0,0 +1,134 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package parser
+
+// SchemaVersion is the version of the structured definition format
+// described by Schema.
+const SchemaVersion = "1"
+
+// Schema is the JSON schema of definitions in JSON or YAML form. A
+// structured definition holds the version of the format and the stages
+// of the definition, each stage being a types.Definition.
+const Schema = `{
+  "$schema": "http://json-schema.org/draft-04/schema#",
+  "id": "https://sylabs.io/schemas/singularity/definition-v1.json",
+  "title": "Singularity definition",
+  "type": "object",
+  "required": ["version", "stages"],
+  "additionalProperties": false,
+  "properties": {
+    "version": {
+      "description": "Version of the definition format",
+      "type": "string",
+      "enum": ["1"]
+    },
+    "stages": {
+      "description": "Build stages, the last stage is the container built",
+      "type": "array",
+      "minItems": 1,
+      "items": { "$ref": "#/definitions/stage" }
+    }
+  },
+  "definitions": {
+    "script": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "args": { "type": "string" },
+        "script": { "type": "string" }
+      }
+    },
+    "stage": {
+      "type": "object",
+      "required": ["header"],
+      "additionalProperties": false,
+      "properties": {
+        "header": {
+          "description": "Header keywords in lower case, like bootstrap, from or stage",
+          "type": "object",
+          "required": ["bootstrap"],
+          "additionalProperties": { "type": "string" }
+        },
+        "imageData": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "metadata": { "type": ["string", "null"] },
+            "labels": {
+              "type": ["object", "null"],
+              "additionalProperties": { "type": "string" }
+            },
+            "imageScripts": {
+              "type": "object",
+              "additionalProperties": false,
+              "properties": {
+                "help": { "$ref": "#/definitions/script" },
+                "environment": { "$ref": "#/definitions/script" },
+                "runScript": { "$ref": "#/definitions/script" },
+                "test": { "$ref": "#/definitions/script" },
+                "startScript": { "$ref": "#/definitions/script" }
+              }
+            }
+          }
+        },
+        "buildData": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "files": {
+              "type": ["array", "null"],
+              "items": {
+                "type": "object",
+                "additionalProperties": false,
+                "properties": {
+                  "args": {
+                    "description": "Section arguments, like from <stage>",
+                    "type": "string"
+                  },
+                  "files": {
+                    "type": ["array", "null"],
+                    "items": {
+                      "type": "object",
+                      "required": ["source"],
+                      "additionalProperties": false,
+                      "properties": {
+                        "source": { "type": "string" },
+                        "destination": { "type": "string" }
+                      }
+                    }
+                  }
+                }
+              }
+            },
+            "buildScripts": {
+              "type": "object",
+              "additionalProperties": false,
+              "properties": {
+                "pre": { "$ref": "#/definitions/script" },
+                "setup": { "$ref": "#/definitions/script" },
+                "post": { "$ref": "#/definitions/script" },
+                "test": { "$ref": "#/definitions/script" }
+              }
+            }
+          }
+        },
+        "customData": {
+          "description": "App sections, keyed by section and app name like \"apprun foo\", and build sections",
+          "type": ["object", "null"],
+          "additionalProperties": false,
+          "patternProperties": {
+            "^(app(install|labels|files|env|test|help|run) [^ ]+|condaenv)$": { "type": "string" }
+          }
+        },
+        "raw": {
+          "description": "Base64 encoded definition file, generated if empty",
+          "type": ["string", "null"]
+        }
+      }
+    }
+  }
+}
+`
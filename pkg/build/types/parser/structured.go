@@ -0,0 +1,224 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/sylabs/singularity/pkg/build/types"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Structured is the JSON and YAML form of a definition file, described
+// by Schema.
+type Structured struct {
+	Version string             `json:"version"`
+	Stages  []types.Definition `json:"stages"`
+}
+
+// IsStructuredDefinition returns whether the file at path holds a
+// definition in JSON or YAML form, based on its extension.
+func IsStructuredDefinition(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// ParseStructured receives a reader from a definition in JSON or YAML
+// form, validates it against Schema and returns its stages. The raw
+// definition of each stage is generated if not set, the raw definition
+// of the last stage being the entire definition like All does.
+func ParseStructured(r io.Reader) ([]types.Definition, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("while attempting to read in definition: %v", err)
+	}
+
+	// JSON is YAML, both are converted to JSON to be validated
+	// and decoded the same way
+	doc, err := yaml.YAMLToJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing definition: %v", err)
+	}
+
+	// header keywords are case insensitive like in definition files
+	var v interface{}
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return nil, fmt.Errorf("while parsing definition: %v", err)
+	}
+	lowerHeaders(v)
+
+	result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(Schema), gojsonschema.NewGoLoader(v))
+	if err != nil {
+		return nil, fmt.Errorf("while validating definition: %v", err)
+	}
+	if !result.Valid() {
+		var errs []string
+		for _, e := range result.Errors() {
+			errs = append(errs, e.String())
+		}
+		return nil, fmt.Errorf("invalid definition: %s", strings.Join(errs, "; "))
+	}
+
+	doc, err = json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("while decoding definition: %v", err)
+	}
+	var s Structured
+	if err := json.Unmarshal(doc, &s); err != nil {
+		return nil, fmt.Errorf("while decoding definition: %v", err)
+	}
+
+	var all bytes.Buffer
+	for i := range s.Stages {
+		d := &s.Stages[i]
+
+		for k := range d.Header {
+			if !IsValidHeader(k) {
+				return nil, fmt.Errorf("stage %d: invalid header keyword found: %s", i+1, k)
+			}
+		}
+		if d.Labels == nil {
+			d.Labels = make(map[string]string)
+		}
+
+		if len(d.Raw) == 0 {
+			var buf bytes.Buffer
+			types.WriteDefinitionFile(d, &buf)
+			d.Raw = buf.Bytes()
+		}
+		all.Write(d.Raw)
+	}
+
+	// set raw of last stage to be entire specification
+	s.Stages[len(s.Stages)-1].Raw = all.Bytes()
+
+	return s.Stages, nil
+}
+
+// lowerHeaders converts the header keywords of the stages of a decoded
+// structured definition to lower case.
+func lowerHeaders(v interface{}) {
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	stages, ok := doc["stages"].([]interface{})
+	if !ok {
+		return
+	}
+	for _, s := range stages {
+		stage, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		header, ok := stage["header"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		lower := make(map[string]interface{}, len(header))
+		for k, v := range header {
+			lower[strings.ToLower(k)] = v
+		}
+		stage["header"] = lower
+	}
+}
+
+// WriteJSON writes the definition stages to w in JSON form. Empty values
+// and the raw definitions are left out.
+func WriteJSON(w io.Writer, stages []types.Definition) error {
+	b, err := marshalStructured(stages)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, b, "", "  "); err != nil {
+		return fmt.Errorf("while formatting definition: %v", err)
+	}
+	out.WriteString("\n")
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// WriteYAML writes the definition stages to w in YAML form. Empty values
+// and the raw definitions are left out.
+func WriteYAML(w io.Writer, stages []types.Definition) error {
+	b, err := marshalStructured(stages)
+	if err != nil {
+		return err
+	}
+	y, err := yaml.JSONToYAML(b)
+	if err != nil {
+		return fmt.Errorf("while converting definition to YAML: %v", err)
+	}
+	_, err = w.Write(y)
+	return err
+}
+
+// marshalStructured returns the JSON form of the definition stages.
+func marshalStructured(stages []types.Definition) ([]byte, error) {
+	s := Structured{
+		Version: SchemaVersion,
+		Stages:  make([]types.Definition, len(stages)),
+	}
+	for i, d := range stages {
+		d.Raw = nil
+		s.Stages[i] = d
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("while encoding definition: %v", err)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("while encoding definition: %v", err)
+	}
+	v, _ = prune(v)
+
+	return json.Marshal(v)
+}
+
+// prune removes the null values, empty strings, objects and arrays from
+// a decoded JSON value and the trailing newlines of strings, it returns
+// false if the value itself is empty.
+func prune(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case nil:
+		return nil, false
+	case string:
+		t = strings.TrimRight(t, "\n")
+		return t, t != ""
+	case map[string]interface{}:
+		for k, e := range t {
+			if p, ok := prune(e); ok {
+				t[k] = p
+			} else {
+				delete(t, k)
+			}
+		}
+		return t, len(t) > 0
+	case []interface{}:
+		var l []interface{}
+		for _, e := range t {
+			if p, ok := prune(e); ok {
+				l = append(l, p)
+			}
+		}
+		return l, len(l) > 0
+	}
+	return v, true
+}
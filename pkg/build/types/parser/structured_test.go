@@ -0,0 +1,180 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(Schema), &v); err != nil {
+		t.Fatalf("invalid schema: %s", err)
+	}
+}
+
+func TestParseStructured(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     string
+		stages  int
+		wantErr bool
+	}{
+		{
+			name:   "JSON",
+			def:    `{"version": "1", "stages": [{"header": {"bootstrap": "docker", "from": "alpine"}}]}`,
+			stages: 1,
+		},
+		{
+			name: "YAML",
+			def: `version: "1"
+stages:
+  - header:
+      bootstrap: docker
+      from: alpine
+      stage: build
+    buildData:
+      buildScripts:
+        post:
+          script: |
+            apk add gcc
+  - header:
+      Bootstrap: scratch
+    buildData:
+      files:
+        - args: from build
+          files:
+            - source: /usr/bin/gcc
+    customData:
+      apprun gcc: exec /usr/bin/gcc "$@"
+`,
+			stages: 2,
+		},
+		{
+			name:    "BadVersion",
+			def:     `{"version": "2", "stages": [{"header": {"bootstrap": "docker"}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "NoStage",
+			def:     `{"version": "1", "stages": []}`,
+			wantErr: true,
+		},
+		{
+			name:    "NoBootstrap",
+			def:     `{"version": "1", "stages": [{"header": {"from": "alpine"}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "UnknownField",
+			def:     `{"version": "1", "stages": [{"header": {"bootstrap": "docker"}, "scripts": {}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "UnknownSection",
+			def:     `{"version": "1", "stages": [{"header": {"bootstrap": "docker"}, "customData": {"foo": ""}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "UnknownHeader",
+			def:     `{"version": "1", "stages": [{"header": {"bootstrap": "docker", "foo": "bar"}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "BadYAML",
+			def:     "version: [",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := ParseStructured(strings.NewReader(tt.def))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(stages) != tt.stages {
+				t.Fatalf("unexpected number of stages: %d", len(stages))
+			}
+
+			// the raw definition of the last stage holds every
+			// stage and can be parsed back
+			raw := stages[len(stages)-1].Raw
+			defs, err := All(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("unexpected error while parsing raw definition: %s\n%s", err, raw)
+			}
+			if len(defs) != tt.stages {
+				t.Errorf("unexpected number of stages in raw definition:\n%s", raw)
+			}
+		})
+	}
+}
+
+func TestStructuredRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		defPath string
+	}{
+		{"Docker", "testdata_good/docker/docker"},
+		{"MultipleFiles", "testdata_good/multiplefiles/multiplefiles"},
+		{"MultiStage", "testdata_multi/simple/simple"},
+		{"CondaEnv", "testdata_good/condaenv/condaenv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(tt.defPath)
+			if err != nil {
+				t.Fatal("failed to read deffile:", err)
+			}
+			defer f.Close()
+
+			defs, err := All(f)
+			if err != nil {
+				t.Fatalf("failed to parse deffile: %s", err)
+			}
+
+			var js, y bytes.Buffer
+			if err := WriteJSON(&js, defs); err != nil {
+				t.Fatalf("failed to write JSON: %s", err)
+			}
+			if err := WriteYAML(&y, defs); err != nil {
+				t.Fatalf("failed to write YAML: %s", err)
+			}
+
+			for _, doc := range []*bytes.Buffer{&js, &y} {
+				stages, err := ParseStructured(bytes.NewReader(doc.Bytes()))
+				if err != nil {
+					t.Fatalf("failed to parse structured definition: %s\n%s", err, doc)
+				}
+
+				// raw definitions generated are parsed like the original
+				stages, err = All(bytes.NewReader(stages[len(stages)-1].Raw))
+				if err != nil {
+					t.Fatalf("failed to parse generated definition: %s", err)
+				}
+
+				var again bytes.Buffer
+				if err := WriteJSON(&again, stages); err != nil {
+					t.Fatalf("failed to write JSON: %s", err)
+				}
+				if again.String() != js.String() {
+					t.Errorf("round trip mismatch:\n%s\nwant:\n%s", again.String(), js.String())
+				}
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"syscall"
 
@@ -93,6 +94,10 @@ type Image struct {
 	Writable   bool      `json:"writable"`
 	Partitions []Section `json:"partitions"`
 	Sections   []Section `json:"sections"`
+
+	// arch is the GOARCH-style architecture whose root filesystem
+	// is used for multi-architecture images.
+	arch string
 }
 
 // AuthorizedPath checks if image is in a path supplied in paths
@@ -218,6 +223,13 @@ func ResolvePath(path string) (string, error) {
 
 // Init initializes an image object based on given path.
 func Init(path string, writable bool) (*Image, error) {
+	return InitArch(path, writable, runtime.GOARCH)
+}
+
+// InitArch initializes an image object based on given path, using the
+// root filesystem of the GOARCH-style architecture arch for images
+// holding several architectures.
+func InitArch(path string, writable bool, arch string) (*Image, error) {
 	sylog.Debugf("Image format detection")
 
 	resolvedPath, err := ResolvePath(path)
@@ -228,6 +240,7 @@ func Init(path string, writable bool) (*Image, error) {
 	img := &Image{
 		Path: resolvedPath,
 		Name: filepath.Base(resolvedPath),
+		arch: arch,
 	}
 
 	for _, rf := range registeredFormats {
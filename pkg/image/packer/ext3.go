@@ -0,0 +1,136 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package packer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Ext3 represents an ext3 packer
+type Ext3 struct {
+	MkfsPath   string
+	FsckPath   string
+	ResizePath string
+}
+
+// NewExt3 initializes and returns an Ext3 packer instance
+func NewExt3() *Ext3 {
+	e := &Ext3{}
+	e.MkfsPath, _ = lookSbinPath("mkfs.ext3")
+	e.FsckPath, _ = lookSbinPath("e2fsck")
+	e.ResizePath, _ = lookSbinPath("resize2fs")
+	return e
+}
+
+// lookSbinPath searches for the executable file in PATH and in the
+// sbin directories which are usually not part of unprivileged users
+// PATH.
+func lookSbinPath(file string) (string, error) {
+	if path, err := exec.LookPath(file); err == nil {
+		return path, nil
+	}
+	for _, dir := range []string{"/usr/local/sbin", "/usr/sbin", "/sbin"} {
+		if path, err := exec.LookPath(filepath.Join(dir, file)); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found", file)
+}
+
+// HasMkfs returns if mkfs.ext3 binary has set or not
+func (e Ext3) HasMkfs() bool {
+	return e.MkfsPath != ""
+}
+
+// HasResize returns if e2fsck and resize2fs binaries have been found
+func (e Ext3) HasResize() bool {
+	return e.FsckPath != "" && e.ResizePath != ""
+}
+
+// Create makes a sparse ext3 filesystem image of size bytes in the
+// destination file, populated with the content of the source directory
+// (requires e2fsprogs >= 1.43). Additional mkfs.ext3 options can be
+// passed with opts.
+func (e Ext3) Create(src string, dest string, size int64, opts []string) error {
+	var stderr bytes.Buffer
+
+	if !e.HasMkfs() {
+		return fmt.Errorf("could not create ext3 image, mkfs.ext3 not found")
+	}
+
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("could not create ext3 image: %s", err)
+	}
+	// the image file is created sparse, only the filesystem
+	// metadata are written by mkfs.ext3
+	err = f.Truncate(size)
+	f.Close()
+	if err != nil {
+		os.Remove(dest)
+		return fmt.Errorf("could not set ext3 image size: %s", err)
+	}
+
+	args := []string{"-q", "-F", "-d", src}
+	args = append(args, opts...)
+	args = append(args, dest)
+
+	cmd := exec.Command(e.MkfsPath, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(dest)
+		return fmt.Errorf("create command failed: %v: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// Resize checks the ext3 filesystem image file and resizes it to size
+// bytes, the image file is grown sparse or truncated accordingly.
+func (e Ext3) Resize(file string, size int64) error {
+	if !e.HasResize() {
+		return fmt.Errorf("could not resize ext3 image, e2fsck or resize2fs not found")
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("could not resize ext3 image: %s", err)
+	}
+
+	// resize2fs requires a freshly checked filesystem, e2fsck
+	// exit code 1 means that errors were corrected
+	cmd := exec.Command(e.FsckPath, "-f", "-p", file)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+			return fmt.Errorf("check command failed: %v: %s", err, out)
+		}
+	}
+
+	if size > fi.Size() {
+		if err := os.Truncate(file, size); err != nil {
+			return fmt.Errorf("could not grow ext3 image: %s", err)
+		}
+	}
+
+	cmd = exec.Command(e.ResizePath, file, fmt.Sprintf("%dK", size>>10))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		// restore the original size if the image was grown
+		if size > fi.Size() {
+			os.Truncate(file, fi.Size())
+		}
+		return fmt.Errorf("resize command failed: %v: %s", err, out)
+	}
+
+	if size < fi.Size() {
+		if err := os.Truncate(file, size); err != nil {
+			return fmt.Errorf("could not shrink ext3 image: %s", err)
+		}
+	}
+	return nil
+}
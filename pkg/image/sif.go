@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 	"syscall"
 
 	"github.com/sylabs/sif/pkg/sif"
@@ -73,52 +74,44 @@ func (f *sifFormat) initializer(img *Image, fi os.FileInfo) error {
 	// workflow described above. However, SIF is currently build upon the assumption
 	// that the architecture is assigned based on the architecture defined by a Go
 	// runtime, which is not 100% compliant with the intended workflow.
-	sifArch := string(fimg.Header.Arch[:sif.HdrArchLen-1])
-	if sifArch != sif.HdrArchUnknown && sifArch != sif.GetSIFArch(runtime.GOARCH) {
-		return fmt.Errorf("the image's architecture (%s) is incompatible with the host's (%s)", sif.GetGoArch(sifArch), runtime.GOARCH)
+	arch := img.arch
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+	rootfs, err := systemPartition(&fimg, arch)
+	if err != nil {
+		return err
 	}
 
 	groupID := -1
 
-	// Get the default system partition image
-	for _, desc := range fimg.DescrArr {
-		if !desc.Used {
-			continue
-		}
-		ptype, err := desc.GetPartType()
-		if err != nil {
-			continue
-		}
-		if ptype != sif.PartPrimSys {
-			continue
-		}
-		fstype, err := desc.GetFsType()
+	if rootfs != nil {
+		fstype, err := rootfs.GetFsType()
 		if err != nil {
-			continue
+			return fmt.Errorf("while getting system partition filesystem type: %s", err)
 		}
 
 		// checks if the partition length is greater that the file
 		// size which may reveal a corrupted image (see issue #3996)
-		if fimg.Filesize < desc.Filelen+desc.Fileoff {
+		if fimg.Filesize < rootfs.Filelen+rootfs.Fileoff {
 			return fmt.Errorf("SIF image %s is corrupted: wrong partition size", img.File.Name())
 		}
 
-		htype, err := checkPartitionType(img, fstype, desc.Fileoff)
+		htype, err := checkPartitionType(img, fstype, rootfs.Fileoff)
 		if err != nil {
 			return fmt.Errorf("while checking system partition header: %s", err)
 		}
 
 		img.Partitions = []Section{
 			{
-				Offset: uint64(desc.Fileoff),
-				Size:   uint64(desc.Filelen),
+				Offset: uint64(rootfs.Fileoff),
+				Size:   uint64(rootfs.Filelen),
 				Name:   RootFs,
 				Type:   htype,
 			},
 		}
 
-		groupID = int(desc.Groupid)
-		break
+		groupID = int(rootfs.Groupid)
 	}
 
 	for _, desc := range fimg.DescrArr {
@@ -180,6 +173,55 @@ func (f *sifFormat) initializer(img *Image, fi os.FileInfo) error {
 	return nil
 }
 
+// systemPartition returns the system partition of the SIF image for the
+// GOARCH-style architecture arch. A multi-architecture image holds one
+// system partition per architecture, the primary one being used when it
+// matches or doesn't record any architecture. It returns nil if the
+// image has no usable system partition.
+func systemPartition(fimg *sif.FileImage, arch string) (*sif.Descriptor, error) {
+	var rootfs *sif.Descriptor
+	var archs []string
+	primary := false
+
+	sifArch := sif.GetSIFArch(arch)
+
+	for i, desc := range fimg.DescrArr {
+		if !desc.Used {
+			continue
+		}
+		ptype, err := desc.GetPartType()
+		if err != nil {
+			continue
+		}
+		if ptype != sif.PartPrimSys && ptype != sif.PartSystem {
+			continue
+		}
+		b, err := desc.GetArch()
+		if err != nil {
+			continue
+		}
+		partArch := string(b[:sif.HdrArchLen-1])
+
+		if partArch == sifArch || (ptype == sif.PartPrimSys && partArch == sif.HdrArchUnknown) {
+			if rootfs == nil || ptype == sif.PartPrimSys {
+				rootfs = &fimg.DescrArr[i]
+			}
+			continue
+		}
+		if ptype == sif.PartPrimSys {
+			primary = true
+		}
+		archs = append(archs, sif.GetGoArch(partArch))
+	}
+
+	// system partitions other than the primary one are only
+	// considered when they match
+	if rootfs == nil && primary {
+		return nil, fmt.Errorf("the image's architecture (%s) is incompatible with the %s architecture", strings.Join(archs, ", "), arch)
+	}
+	return rootfs, nil
+}
+
 func (f *sifFormat) openMode(writable bool) int {
 	if writable {
 		return os.O_RDWR
@@ -77,6 +77,17 @@ func TestSIFInitializer(t *testing.T) {
 	}
 	defer fp2.Close()
 
+	fp3, err := os.Open(testSquash)
+	if err != nil {
+		t.Fatalf("failed to open %s: %s", testSquash, err)
+	}
+	defer fp3.Close()
+
+	foreignArch := "arm64"
+	if runtime.GOARCH == foreignArch {
+		foreignArch = "amd64"
+	}
+
 	onePart := sif.DescriptorInput{
 		Datatype: sif.DataPartition,
 		Groupid:  sif.DescrDefaultGroup,
@@ -122,6 +133,32 @@ func TestSIFInitializer(t *testing.T) {
 	}
 	primPart.Extra.WriteString(sif.GetSIFArch(runtime.GOARCH))
 
+	foreignPrimPart := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    "foreignPrimPart",
+		Fp:       fp1,
+		Extra: *bytes.NewBuffer([]byte{
+			0x01, 0x00, 0x00, 0x00, // fstype
+			0x02, 0x00, 0x00, 0x00, // part type
+		}),
+	}
+	foreignPrimPart.Extra.WriteString(sif.GetSIFArch(foreignArch))
+
+	sysPart := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    "sysPart",
+		Fp:       fp3,
+		Extra: *bytes.NewBuffer([]byte{
+			0x01, 0x00, 0x00, 0x00, // fstype
+			0x01, 0x00, 0x00, 0x00, // part type
+		}),
+	}
+	sysPart.Extra.WriteString(sif.GetSIFArch(runtime.GOARCH))
+
 	overlayPart := sif.DescriptorInput{
 		Datatype: sif.DataPartition,
 		Groupid:  sif.DescrDefaultGroup,
@@ -137,6 +174,7 @@ func TestSIFInitializer(t *testing.T) {
 	tests := []struct {
 		name               string
 		path               string
+		arch               string
 		writable           bool
 		expectedSuccess    bool
 		expectedPartitions int
@@ -190,6 +228,31 @@ func TestSIFInitializer(t *testing.T) {
 			expectedPartitions: 2,
 			expectedSections:   0,
 		},
+		{
+			name:               "ForeignPrimaryPartitionSIF",
+			path:               createSIF(t, []sif.DescriptorInput{foreignPrimPart}, false),
+			writable:           false,
+			expectedSuccess:    false,
+			expectedPartitions: 0,
+			expectedSections:   0,
+		},
+		{
+			name:               "ForeignPrimaryPartitionArchSIF",
+			path:               createSIF(t, []sif.DescriptorInput{foreignPrimPart}, false),
+			arch:               foreignArch,
+			writable:           false,
+			expectedSuccess:    true,
+			expectedPartitions: 1,
+			expectedSections:   0,
+		},
+		{
+			name:               "MultiArchSIF",
+			path:               createSIF(t, []sif.DescriptorInput{foreignPrimPart, sysPart, overlayPart}, false),
+			writable:           false,
+			expectedSuccess:    true,
+			expectedPartitions: 2,
+			expectedSections:   0,
+		},
 		{
 			name:               "SectionSIF",
 			path:               createSIF(t, []sif.DescriptorInput{oneSection}, false),
@@ -217,6 +280,7 @@ func TestSIFInitializer(t *testing.T) {
 		img := &Image{
 			Path: tt.path,
 			Name: tt.path,
+			arch: tt.arch,
 		}
 
 		img.Writable = true
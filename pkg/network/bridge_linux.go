@@ -0,0 +1,390 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/containernetworking/cni/libcni"
+)
+
+// BridgeNetwork describes a named bridge network generated by the network
+// create command, containers on the same bridge network can reach each other.
+type BridgeNetwork struct {
+	Name   string
+	Bridge string
+	Subnet *net.IPNet
+}
+
+// reservedNetworks lists the network names which can't be used by
+// bridge networks
+var reservedNetworks = map[string]bool{
+	"bridge":     true,
+	"ptp":        true,
+	"ipvlan":     true,
+	"macvlan":    true,
+	"fakeroot":   true,
+	"none":       true,
+	SlirpNetwork: true,
+}
+
+var bridgeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+
+// privateSubnets are the IPv4 ranges bridge network subnets must be part of
+var privateSubnets = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+
+// NewBridgeNetwork returns a bridge network named name using the IPv4 subnet.
+// The bridge interface name is derived from uid and name, so networks owned
+// by different users never share a bridge.
+func NewBridgeNetwork(name string, subnet string, uid int) (*BridgeNetwork, error) {
+	if !bridgeNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("%q is not a valid network name", name)
+	}
+	if reservedNetworks[name] {
+		return nil, fmt.Errorf("network name %s is reserved", name)
+	}
+
+	ip, ipnet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %s: %s", subnet, err)
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("subnet %s is not an IPv4 subnet", subnet)
+	}
+	if !ip.Equal(ipnet.IP) {
+		return nil, fmt.Errorf("subnet %s has host bits set, use %s", subnet, ipnet)
+	}
+	if ones, _ := ipnet.Mask.Size(); ones > 29 {
+		return nil, fmt.Errorf("subnet %s is too small", subnet)
+	}
+
+	private := false
+	for _, s := range privateSubnets {
+		_, p, _ := net.ParseCIDR(s)
+		pOnes, _ := p.Mask.Size()
+		ones, _ := ipnet.Mask.Size()
+		if p.Contains(ipnet.IP) && ones >= pOnes {
+			private = true
+			break
+		}
+	}
+	if !private {
+		return nil, fmt.Errorf("subnet %s is not part of a private IPv4 range (%v)", subnet, privateSubnets)
+	}
+
+	h := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", uid, name)))
+
+	return &BridgeNetwork{
+		Name:   name,
+		Bridge: fmt.Sprintf("sbr-%x", h[:5]),
+		Subnet: ipnet,
+	}, nil
+}
+
+// SubnetsOverlap returns true if the subnets a and b overlap
+func SubnetsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// routeFile is the IPv4 routing table of the current network namespace.
+var routeFile = "/proc/net/route"
+
+// ParseSubnetPool parses the IPv4 subnets of pool, as set by the bridge
+// network subnets directive of singularity.conf.
+func ParseSubnetPool(pool []string) ([]*net.IPNet, error) {
+	subnets := make([]*net.IPNet, 0, len(pool))
+	for _, s := range pool {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(s)
+		if err != nil || subnet.IP.To4() == nil {
+			return nil, fmt.Errorf("invalid bridge network subnet %q", s)
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
+
+// InSubnetPool returns true if subnet is part of one of the subnets of
+// pool.
+func InSubnetPool(subnet *net.IPNet, pool []*net.IPNet) bool {
+	ones, _ := subnet.Mask.Size()
+	for _, p := range pool {
+		pOnes, _ := p.Mask.Size()
+		if p.Contains(subnet.IP) && ones >= pOnes {
+			return true
+		}
+	}
+	return false
+}
+
+// HostSubnets returns the IPv4 subnets of the host interfaces and the
+// destinations of the host routes, default routes excepted. Interfaces
+// and routes of the interfaces named in exclude are ignored.
+func HostSubnets(exclude ...string) ([]*net.IPNet, error) {
+	excluded := make(map[string]bool, len(exclude))
+	for _, e := range exclude {
+		excluded[e] = true
+	}
+
+	var subnets []*net.IPNet
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("while listing host interfaces: %s", err)
+	}
+	for _, iface := range ifaces {
+		if excluded[iface.Name] {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("while listing %s addresses: %s", iface.Name, err)
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				_, subnet, _ := net.ParseCIDR(ipnet.String())
+				subnets = append(subnets, subnet)
+			}
+		}
+	}
+
+	f, err := os.Open(routeFile)
+	if err != nil {
+		return nil, fmt.Errorf("while reading host routes: %s", err)
+	}
+	defer f.Close()
+
+	routes, err := parseRoutes(f, excluded)
+	if err != nil {
+		return nil, fmt.Errorf("while reading host routes: %s", err)
+	}
+	return append(subnets, routes...), nil
+}
+
+// parseRoutes returns the destinations of the routes of the IPv4 routing
+// table r, in /proc/net/route format, except default routes and routes
+// of the excluded interfaces.
+func parseRoutes(r io.Reader, excluded map[string]bool) ([]*net.IPNet, error) {
+	var subnets []*net.IPNet
+
+	scanner := bufio.NewScanner(r)
+	// skip header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || excluded[fields[0]] {
+			continue
+		}
+		dst, err := routeAddr(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		mask, err := routeAddr(fields[7])
+		if err != nil {
+			return nil, err
+		}
+		if ones, _ := net.IPMask(mask).Size(); ones == 0 {
+			continue
+		}
+		subnets = append(subnets, &net.IPNet{IP: dst, Mask: net.IPMask(mask)})
+	}
+	return subnets, scanner.Err()
+}
+
+// routeAddr decodes an IPv4 address of /proc/net/route, the hexadecimal
+// value of the address in network byte order read as a host integer.
+func routeAddr(s string) (net.IP, error) {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route address %q", s)
+	}
+	addr := uint32(v)
+	b := *(*[net.IPv4len]byte)(unsafe.Pointer(&addr))
+	return net.IPv4(b[0], b[1], b[2], b[3]).To4(), nil
+}
+
+// CheckHost returns an error if the subnet of the bridge network isn't
+// part of pool, when pool isn't empty, or overlaps a subnet of the host
+// interfaces or routes other than those of its own bridge. It's called
+// before the network is set up, as the configuration of a user network
+// could have been written without the network create command.
+func (n *BridgeNetwork) CheckHost(pool []*net.IPNet) error {
+	if len(pool) > 0 && !InSubnetPool(n.Subnet, pool) {
+		return fmt.Errorf("network %s subnet %s is not part of the allowed bridge network subnets", n.Name, n.Subnet)
+	}
+
+	used, err := HostSubnets(n.Bridge)
+	if err != nil {
+		return err
+	}
+	for _, u := range used {
+		if SubnetsOverlap(n.Subnet, u) {
+			return fmt.Errorf("network %s subnet %s overlaps with %s used by the host", n.Name, n.Subnet, u)
+		}
+	}
+	return nil
+}
+
+// Gateway returns the IP address of the bridge interface, the first
+// address of the subnet.
+func (n *BridgeNetwork) Gateway() net.IP {
+	ip := make(net.IP, len(n.Subnet.IP.To4()))
+	copy(ip, n.Subnet.IP.To4())
+	ip[3]++
+	return ip
+}
+
+// bridgeConfList is the CNI configuration list of a bridge network
+type bridgeConfList struct {
+	CNIVersion string        `json:"cniVersion"`
+	Name       string        `json:"name"`
+	Plugins    []interface{} `json:"plugins"`
+}
+
+type bridgePlugin struct {
+	Type        string `json:"type"`
+	Bridge      string `json:"bridge"`
+	IsGateway   bool   `json:"isGateway"`
+	IPMasq      bool   `json:"ipMasq"`
+	HairpinMode bool   `json:"hairpinMode"`
+	IPAM        struct {
+		Type   string              `json:"type"`
+		Subnet string              `json:"subnet"`
+		Routes []map[string]string `json:"routes"`
+	} `json:"ipam"`
+}
+
+type firewallPlugin struct {
+	Type string `json:"type"`
+}
+
+type portmapPlugin struct {
+	Type         string          `json:"type"`
+	Capabilities map[string]bool `json:"capabilities"`
+	SNAT         bool            `json:"snat"`
+}
+
+// ConfList returns the CNI configuration list of the bridge network: a
+// bridge plugin with host-local IPAM, the firewall and portmap plugins.
+func (n *BridgeNetwork) ConfList() ([]byte, error) {
+	bridge := bridgePlugin{
+		Type:        "bridge",
+		Bridge:      n.Bridge,
+		IsGateway:   true,
+		IPMasq:      true,
+		HairpinMode: true,
+	}
+	bridge.IPAM.Type = "host-local"
+	bridge.IPAM.Subnet = n.Subnet.String()
+	bridge.IPAM.Routes = []map[string]string{{"dst": "0.0.0.0/0"}}
+
+	conf := bridgeConfList{
+		CNIVersion: "0.4.0",
+		Name:       n.Name,
+		Plugins: []interface{}{
+			bridge,
+			firewallPlugin{Type: "firewall"},
+			portmapPlugin{
+				Type:         "portmap",
+				Capabilities: map[string]bool{"portMappings": true},
+				SNAT:         true,
+			},
+		},
+	}
+
+	b, err := json.MarshalIndent(conf, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// BridgeNetworkFromConfList returns the bridge network described by the
+// CNI configuration list b. An error is returned if the configuration
+// list differs from the one generated for the network owned by uid, so
+// a modified configuration is never used.
+func BridgeNetworkFromConfList(b []byte, uid int) (*BridgeNetwork, error) {
+	var conf struct {
+		Name    string         `json:"name"`
+		Plugins []bridgePlugin `json:"plugins"`
+	}
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return nil, fmt.Errorf("while parsing network configuration: %s", err)
+	}
+	if len(conf.Plugins) == 0 || conf.Plugins[0].Type != "bridge" {
+		return nil, fmt.Errorf("network %s is not a bridge network", conf.Name)
+	}
+
+	n, err := NewBridgeNetwork(conf.Name, conf.Plugins[0].IPAM.Subnet, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	generated, err := n.ConfList()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(bytes.TrimSpace(b), bytes.TrimSpace(generated)) {
+		return nil, fmt.Errorf("network %s configuration has been modified, it must be recreated", conf.Name)
+	}
+
+	return n, nil
+}
+
+// NetworkConfigList returns the CNI network configuration list of the
+// bridge network.
+func (n *BridgeNetwork) NetworkConfigList() (*libcni.NetworkConfigList, error) {
+	b, err := n.ConfList()
+	if err != nil {
+		return nil, err
+	}
+	return libcni.ConfListFromBytes(b)
+}
+
+// BridgeNetworkPath returns the path of the CNI configuration list of the
+// bridge network name in the directory dir.
+func BridgeNetworkPath(dir, name string) string {
+	return filepath.Join(dir, name+".conflist")
+}
+
+// LoadBridgeNetwork loads the bridge network name owned by uid from the
+// directory dir, os.IsNotExist can be used on the returned error to check
+// if the network doesn't exist.
+func LoadBridgeNetwork(dir, name string, uid int) (*BridgeNetwork, error) {
+	if !bridgeNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("%q is not a valid network name", name)
+	}
+
+	b, err := ioutil.ReadFile(BridgeNetworkPath(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := BridgeNetworkFromConfList(b, uid)
+	if err != nil {
+		return nil, err
+	}
+	if n.Name != name {
+		return nil, fmt.Errorf("network %s configuration has been modified, it must be recreated", name)
+	}
+	return n, nil
+}
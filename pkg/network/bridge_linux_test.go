@@ -0,0 +1,239 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestNewBridgeNetwork(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		subnet  string
+		wantErr bool
+	}{
+		{
+			name:    "Valid",
+			network: "mynet",
+			subnet:  "10.89.0.0/24",
+		},
+		{
+			name:    "ValidLargeSubnet",
+			network: "my_net-1.0",
+			subnet:  "172.16.0.0/16",
+		},
+		{
+			name:    "InvalidName",
+			network: "my/net",
+			subnet:  "10.89.0.0/24",
+			wantErr: true,
+		},
+		{
+			name:    "ReservedName",
+			network: "fakeroot",
+			subnet:  "10.89.0.0/24",
+			wantErr: true,
+		},
+		{
+			name:    "HostBits",
+			network: "mynet",
+			subnet:  "10.89.0.1/24",
+			wantErr: true,
+		},
+		{
+			name:    "TooSmall",
+			network: "mynet",
+			subnet:  "10.89.0.0/30",
+			wantErr: true,
+		},
+		{
+			name:    "PublicSubnet",
+			network: "mynet",
+			subnet:  "8.8.8.0/24",
+			wantErr: true,
+		},
+		{
+			name:    "LargerThanPrivateRange",
+			network: "mynet",
+			subnet:  "172.0.0.0/8",
+			wantErr: true,
+		},
+		{
+			name:    "IPv6",
+			network: "mynet",
+			subnet:  "fd00::/64",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewBridgeNetwork(tt.network, tt.subnet, 1000)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v (wantErr %v)", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if n.Subnet.String() != tt.subnet {
+				t.Errorf("got subnet %s, want %s", n.Subnet, tt.subnet)
+			}
+			if len(n.Bridge) > 15 {
+				t.Errorf("bridge name %s exceeds interface name length", n.Bridge)
+			}
+		})
+	}
+}
+
+func TestBridgeNetworkBridgeName(t *testing.T) {
+	a, err := NewBridgeNetwork("mynet", "10.89.0.0/24", 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := NewBridgeNetwork("mynet", "10.89.0.0/24", 1001)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if a.Bridge == b.Bridge {
+		t.Errorf("networks of different users share bridge %s", a.Bridge)
+	}
+	if gw := a.Gateway().String(); gw != "10.89.0.1" {
+		t.Errorf("got gateway %s, want 10.89.0.1", gw)
+	}
+}
+
+func TestLoadBridgeNetwork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bridge-network-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	n, err := NewBridgeNetwork("mynet", "10.89.1.0/24", 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := n.ConfList()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := n.NetworkConfigList(); err != nil {
+		t.Fatalf("invalid CNI configuration list: %s", err)
+	}
+
+	path := BridgeNetworkPath(dir, n.Name)
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+
+	loaded, err := LoadBridgeNetwork(dir, n.Name, 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if loaded.Bridge != n.Bridge || loaded.Subnet.String() != n.Subnet.String() {
+		t.Errorf("loaded network %+v differs from %+v", loaded, n)
+	}
+
+	// the bridge name is bound to the owner
+	if _, err := LoadBridgeNetwork(dir, n.Name, 1001); err == nil {
+		t.Errorf("unexpected success loading network of another user")
+	}
+
+	// a modified configuration is rejected
+	modified := bytes.Replace(b, []byte(`"ipMasq": true`), []byte(`"ipMasq": false`), 1)
+	if err := ioutil.WriteFile(path, modified, 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+	if _, err := LoadBridgeNetwork(dir, n.Name, 1000); err == nil {
+		t.Errorf("unexpected success loading modified network")
+	}
+
+	if _, err := LoadBridgeNetwork(dir, "missing", 1000); !os.IsNotExist(err) {
+		t.Errorf("got error %v, want not exist error", err)
+	}
+}
+
+// routeHex returns the IPv4 address ip as written in /proc/net/route.
+func routeHex(ip string) string {
+	b := net.ParseIP(ip).To4()
+	return fmt.Sprintf("%08X", *(*uint32)(unsafe.Pointer(&b[0])))
+}
+
+func TestParseRoutes(t *testing.T) {
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+	for _, r := range [][3]string{
+		{"eth0", "0.0.0.0", "0.0.0.0"},
+		{"eth0", "192.168.1.0", "255.255.255.0"},
+		{"wg0", "10.20.0.0", "255.255.0.0"},
+		{"sbr-mynet", "10.89.0.0", "255.255.255.0"},
+	} {
+		table += fmt.Sprintf("%s\t%s\t00000000\t0001\t0\t0\t0\t%s\t0\t0\t0\n", r[0], routeHex(r[1]), routeHex(r[2]))
+	}
+
+	subnets, err := parseRoutes(strings.NewReader(table), map[string]bool{"sbr-mynet": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var got []string
+	for _, s := range subnets {
+		got = append(got, s.String())
+	}
+	if want := "192.168.1.0/24,10.20.0.0/16"; strings.Join(got, ",") != want {
+		t.Errorf("unexpected routes %v, want %s", got, want)
+	}
+
+	if _, err := parseRoutes(strings.NewReader("header\neth0\tnothex\t0\t0\t0\t0\t0\t0\n"), nil); err == nil {
+		t.Errorf("unexpected success with invalid route")
+	}
+}
+
+func TestInSubnetPool(t *testing.T) {
+	pool, err := ParseSubnetPool([]string{"10.89.0.0/16", " 172.20.0.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := ParseSubnetPool([]string{"10.89.0.0"}); err == nil {
+		t.Errorf("unexpected success with invalid subnet")
+	}
+
+	tests := []struct {
+		subnet string
+		want   bool
+	}{
+		{subnet: "10.89.3.0/24", want: true},
+		{subnet: "10.89.0.0/16", want: true},
+		{subnet: "172.20.0.0/25", want: true},
+		{subnet: "10.0.0.0/8", want: false},
+		{subnet: "172.20.1.0/24", want: false},
+		{subnet: "192.168.1.0/24", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subnet, func(t *testing.T) {
+			_, subnet, _ := net.ParseCIDR(tt.subnet)
+			if got := InSubnetPool(subnet, pool); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	n, err := NewBridgeNetwork("mynet", "192.168.99.0/24", 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := n.CheckHost(pool); err == nil {
+		t.Errorf("unexpected success with subnet outside of the pool")
+	}
+}
@@ -0,0 +1,407 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// DNSResolverIP is the address of the embedded DNS server inside the
+// container network namespace
+const DNSResolverIP = "127.0.0.11"
+
+const (
+	dnsPort          = "53"
+	dnsHeaderLen     = 12
+	dnsMaxUDPLen     = 65535
+	dnsTypeA         = 1
+	dnsTypeANY       = 255
+	dnsClassIN       = 1
+	dnsFlagQR        = 0x8000
+	dnsFlagAA        = 0x0400
+	dnsFlagRD        = 0x0100
+	dnsFlagRA        = 0x0080
+	dnsOpcodeMask    = 0x7800
+	dnsRcodeServFail = 2
+	dnsUpstreamWait  = 5 * time.Second
+)
+
+// LookupFunc returns the IPv4 addresses of a container name, or nil if
+// the name is unknown and must be resolved by upstream servers
+type LookupFunc func(name string) []net.IP
+
+// DNSServer is a minimal DNS server answering container names on a
+// network and forwarding any other request to upstream servers.
+type DNSServer struct {
+	lookup    LookupFunc
+	upstreams []string
+	udp       *net.UDPConn
+	tcp       *net.TCPListener
+	wg        sync.WaitGroup
+}
+
+// NewDNSServer returns a DNS server resolving names with lookup and
+// forwarding other requests to the upstream name servers.
+func NewDNSServer(lookup LookupFunc, upstreams []string) *DNSServer {
+	s := &DNSServer{lookup: lookup}
+	for _, u := range upstreams {
+		s.upstreams = append(s.upstreams, net.JoinHostPort(u, dnsPort))
+	}
+	return s
+}
+
+// Start starts the DNS server on DNSResolverIP in the network namespace
+// nsPath. The sockets are created in the network namespace while the
+// requests are served and forwarded from the calling process network
+// namespace, so the host name servers stay reachable.
+func (s *DNSServer) Start(nsPath string) error {
+	if err := s.listen(nsPath); err != nil {
+		return fmt.Errorf("could not start DNS server: %s", err)
+	}
+
+	s.wg.Add(2)
+	go s.serveUDP()
+	go s.serveTCP()
+
+	return nil
+}
+
+// listen creates the server sockets in the network namespace nsPath
+func (s *DNSServer) listen(nsPath string) error {
+	runtime.LockOSThread()
+
+	hostNS, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer hostNS.Close()
+
+	ns, err := os.Open(nsPath)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer ns.Close()
+
+	if err := unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("while joining network namespace: %s", err)
+	}
+	defer func() {
+		// if the thread can't go back to the host network
+		// namespace, it stays locked and is terminated with
+		// the goroutine
+		if nsErr := unix.Setns(int(hostNS.Fd()), unix.CLONE_NEWNET); nsErr != nil {
+			sylog.Debugf("Could not restore network namespace: %s", nsErr)
+			return
+		}
+		runtime.UnlockOSThread()
+	}()
+
+	addr := net.JoinHostPort(DNSResolverIP, dnsPort)
+
+	udpAddr, _ := net.ResolveUDPAddr("udp", addr)
+	s.udp, err = net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	s.tcp, err = net.ListenTCP("tcp", tcpAddr)
+	if err != nil {
+		s.udp.Close()
+		return err
+	}
+	return nil
+}
+
+// Stop stops the DNS server
+func (s *DNSServer) Stop() error {
+	if s.udp == nil {
+		return nil
+	}
+	s.udp.Close()
+	s.tcp.Close()
+	s.wg.Wait()
+	s.udp = nil
+	return nil
+}
+
+func (s *DNSServer) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, dnsMaxUDPLen)
+	for {
+		n, addr, err := s.udp.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		query := make([]byte, n)
+		copy(query, buf[:n])
+
+		go func() {
+			if resp := s.handle("udp", query); resp != nil {
+				s.udp.WriteToUDP(resp, addr)
+			}
+		}()
+	}
+}
+
+func (s *DNSServer) serveTCP() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+
+			r := bufio.NewReader(conn)
+			for {
+				conn.SetDeadline(time.Now().Add(dnsUpstreamWait * 2))
+				query, err := readTCPMessage(r)
+				if err != nil {
+					return
+				}
+				resp := s.handle("tcp", query)
+				if resp == nil || writeTCPMessage(conn, resp) != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// handle returns the response to the DNS query
+func (s *DNSServer) handle(proto string, query []byte) []byte {
+	q, err := parseDNSQuestion(query)
+	if err != nil {
+		sylog.Debugf("Ignoring DNS query: %s", err)
+		return nil
+	}
+
+	if q != nil {
+		if ips := s.lookup(q.name); ips != nil {
+			return q.answer(query, ips)
+		}
+	}
+
+	resp, err := s.forward(proto, query)
+	if err != nil {
+		sylog.Debugf("DNS forward failed: %s", err)
+		if q == nil {
+			return nil
+		}
+		return q.failure(query)
+	}
+	return resp
+}
+
+// forward sends the query to the upstream servers until one responds
+func (s *DNSServer) forward(proto string, query []byte) ([]byte, error) {
+	err := fmt.Errorf("no upstream DNS server")
+
+	for _, upstream := range s.upstreams {
+		var conn net.Conn
+		var resp []byte
+
+		conn, err = net.DialTimeout(proto, upstream, dnsUpstreamWait)
+		if err != nil {
+			continue
+		}
+		conn.SetDeadline(time.Now().Add(dnsUpstreamWait))
+
+		if proto == "tcp" {
+			if err = writeTCPMessage(conn, query); err == nil {
+				resp, err = readTCPMessage(bufio.NewReader(conn))
+			}
+		} else if _, err = conn.Write(query); err == nil {
+			buf := make([]byte, dnsMaxUDPLen)
+			var n int
+			n, err = conn.Read(buf)
+			resp = buf[:n]
+		}
+		conn.Close()
+
+		if err == nil {
+			return resp, nil
+		}
+	}
+
+	return nil, err
+}
+
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	b := make([]byte, 2, 2+len(msg))
+	binary.BigEndian.PutUint16(b, uint16(len(msg)))
+	_, err := w.Write(append(b, msg...))
+	return err
+}
+
+// dnsQuestion is the single question of a standard DNS query
+type dnsQuestion struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+	// end is the offset of the end of the question section
+	end int
+}
+
+// parseDNSQuestion parses the question of a standard query, nil is
+// returned for messages which must be forwarded as is.
+func parseDNSQuestion(msg []byte) (*dnsQuestion, error) {
+	if len(msg) < dnsHeaderLen {
+		return nil, fmt.Errorf("message too short")
+	}
+
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&dnsFlagQR != 0 {
+		return nil, fmt.Errorf("message is not a query")
+	}
+	if flags&dnsOpcodeMask != 0 || binary.BigEndian.Uint16(msg[4:]) != 1 {
+		return nil, nil
+	}
+
+	var labels []string
+
+	off := dnsHeaderLen
+	for {
+		if off >= len(msg) {
+			return nil, fmt.Errorf("truncated question")
+		}
+		l := int(msg[off])
+		off++
+		if l == 0 {
+			break
+		}
+		if l > 63 || off+l > len(msg) {
+			// compressed or malformed names are left to upstream
+			return nil, nil
+		}
+		labels = append(labels, string(msg[off:off+l]))
+		off += l
+	}
+	if off+4 > len(msg) {
+		return nil, fmt.Errorf("truncated question")
+	}
+
+	return &dnsQuestion{
+		name:   strings.ToLower(strings.Join(labels, ".")),
+		qtype:  binary.BigEndian.Uint16(msg[off:]),
+		qclass: binary.BigEndian.Uint16(msg[off+2:]),
+		end:    off + 4,
+	}, nil
+}
+
+// header returns the response header for the query with ancount answers
+func (q *dnsQuestion) header(query []byte, rcode uint16, ancount int) []byte {
+	flags := binary.BigEndian.Uint16(query[2:])
+
+	h := make([]byte, dnsHeaderLen)
+	copy(h, query[:2])
+	binary.BigEndian.PutUint16(h[2:], dnsFlagQR|dnsFlagRA|(flags&dnsFlagRD)|rcode)
+	binary.BigEndian.PutUint16(h[4:], 1)
+	binary.BigEndian.PutUint16(h[6:], uint16(ancount))
+	return h
+}
+
+// answer returns the authoritative response with the IPv4 addresses of
+// the queried name, an empty response is returned for other record types
+// so container names are never resolved by upstream servers.
+func (q *dnsQuestion) answer(query []byte, ips []net.IP) []byte {
+	var answers bytes.Buffer
+	count := 0
+
+	if q.qclass == dnsClassIN && (q.qtype == dnsTypeA || q.qtype == dnsTypeANY) {
+		for _, ip := range ips {
+			ip4 := ip.To4()
+			if ip4 == nil {
+				continue
+			}
+			// pointer to the question name, type A, class IN,
+			// no caching as container addresses are dynamic
+			rr := make([]byte, 12)
+			binary.BigEndian.PutUint16(rr[0:], 0xc000|dnsHeaderLen)
+			binary.BigEndian.PutUint16(rr[2:], dnsTypeA)
+			binary.BigEndian.PutUint16(rr[4:], dnsClassIN)
+			binary.BigEndian.PutUint32(rr[6:], 0)
+			binary.BigEndian.PutUint16(rr[10:], net.IPv4len)
+			answers.Write(rr)
+			answers.Write(ip4)
+			count++
+		}
+	}
+
+	resp := q.header(query, 0, count)
+	binary.BigEndian.PutUint16(resp[2:], binary.BigEndian.Uint16(resp[2:])|dnsFlagAA)
+	resp = append(resp, query[dnsHeaderLen:q.end]...)
+	return append(resp, answers.Bytes()...)
+}
+
+// failure returns a server failure response to the query
+func (q *dnsQuestion) failure(query []byte) []byte {
+	resp := q.header(query, dnsRcodeServFail, 0)
+	return append(resp, query[dnsHeaderLen:q.end]...)
+}
+
+// Nameservers returns the name servers listed in the resolv.conf content
+func Nameservers(resolvConf []byte) []string {
+	var servers []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(resolvConf))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
+// DNSResolvConf returns the resolv.conf content with the name servers
+// replaced by the embedded DNS server, other options are preserved.
+func DNSResolvConf(resolvConf []byte) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "nameserver %s\n", DNSResolverIP)
+
+	scanner := bufio.NewScanner(bytes.NewReader(resolvConf))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "nameserver" {
+			continue
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dnsQuery returns a standard query with recursion desired for name
+func dnsQuery(id uint16, name string, qtype uint16) []byte {
+	msg := make([]byte, dnsHeaderLen)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], dnsFlagRD)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+
+	for _, label := range strings.Split(name, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(msg[len(msg)-4:], qtype)
+	binary.BigEndian.PutUint16(msg[len(msg)-2:], dnsClassIN)
+	return msg
+}
+
+func TestDNSServerHandle(t *testing.T) {
+	lookup := func(name string) []net.IP {
+		if name == "db" {
+			return []net.IP{net.ParseIP("10.89.0.2")}
+		}
+		return nil
+	}
+	s := NewDNSServer(lookup, nil)
+
+	tests := []struct {
+		name    string
+		query   []byte
+		rcode   uint16
+		answers []net.IP
+	}{
+		{
+			name:    "InstanceA",
+			query:   dnsQuery(1, "db", dnsTypeA),
+			answers: []net.IP{net.ParseIP("10.89.0.2").To4()},
+		},
+		{
+			name:    "InstanceCaseInsensitive",
+			query:   dnsQuery(2, "DB", dnsTypeA),
+			answers: []net.IP{net.ParseIP("10.89.0.2").To4()},
+		},
+		{
+			name:  "InstanceAAAA",
+			query: dnsQuery(3, "db", 28),
+		},
+		{
+			// no upstream server is configured
+			name:  "Forwarded",
+			query: dnsQuery(4, "sylabs.io", dnsTypeA),
+			rcode: dnsRcodeServFail,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.handle("udp", tt.query)
+			if len(resp) < dnsHeaderLen {
+				t.Fatalf("unexpected response length %d", len(resp))
+			}
+			if !bytes.Equal(resp[:2], tt.query[:2]) {
+				t.Errorf("response ID doesn't match query ID")
+			}
+			flags := binary.BigEndian.Uint16(resp[2:])
+			if flags&dnsFlagQR == 0 || flags&dnsFlagRD == 0 {
+				t.Errorf("unexpected response flags %#x", flags)
+			}
+			if rcode := flags & 0xf; rcode != tt.rcode {
+				t.Errorf("got rcode %d, want %d", rcode, tt.rcode)
+			}
+			if count := int(binary.BigEndian.Uint16(resp[6:])); count != len(tt.answers) {
+				t.Fatalf("got %d answers, want %d", count, len(tt.answers))
+			}
+
+			// answers follow the question, the address is the
+			// last field of each record
+			var ips []net.IP
+			off := len(tt.query)
+			for range tt.answers {
+				off += 12
+				ips = append(ips, net.IP(resp[off:off+net.IPv4len]))
+				off += net.IPv4len
+			}
+			if len(tt.answers) > 0 && !reflect.DeepEqual(ips, tt.answers) {
+				t.Errorf("got answers %v, want %v", ips, tt.answers)
+			}
+		})
+	}
+
+	// responses are ignored
+	resp := dnsQuery(5, "db", dnsTypeA)
+	resp[2] |= dnsFlagQR >> 8
+	if s.handle("udp", resp) != nil {
+		t.Errorf("unexpected response to a response message")
+	}
+}
+
+func TestDNSResolvConf(t *testing.T) {
+	resolvConf := []byte("# comment\nnameserver 127.0.0.53\nsearch example.com\nnameserver 8.8.8.8\noptions edns0\n")
+
+	servers := Nameservers(resolvConf)
+	if !reflect.DeepEqual(servers, []string{"127.0.0.53", "8.8.8.8"}) {
+		t.Errorf("unexpected name servers %v", servers)
+	}
+
+	content := string(DNSResolvConf(resolvConf))
+	want := "nameserver " + DNSResolverIP + "\n# comment\nsearch example.com\noptions edns0\n"
+	if content != want {
+		t.Errorf("got resolv.conf %q, want %q", content, want)
+	}
+}
@@ -227,6 +227,68 @@ func (m *Setup) SetCapability(network string, capName string, args interface{})
 	return nil
 }
 
+// ParsePortMap parses a port mapping of the form
+// hostPort[:containerPort]/protocol
+func ParsePortMap(value string) (*PortMapEntry, error) {
+	pm := &PortMapEntry{}
+
+	splittedPort := strings.SplitN(value, "/", 2)
+	if len(splittedPort) != 2 {
+		return nil, fmt.Errorf("badly formatted portmap argument '%s', must be of form portmap=hostPort:containerPort/protocol", value)
+	}
+	pm.Protocol = splittedPort[1]
+	if pm.Protocol != "tcp" && pm.Protocol != "udp" {
+		return nil, fmt.Errorf("only tcp and udp protocol can be specified")
+	}
+	ports := strings.Split(splittedPort[0], ":")
+	if len(ports) != 1 && len(ports) != 2 {
+		return nil, fmt.Errorf("portmap port argument is badly formatted")
+	}
+	if n, err := strconv.ParseUint(ports[0], 0, 16); err == nil {
+		pm.HostPort = int(n)
+		if pm.HostPort <= 0 || pm.HostPort > 65535 {
+			return nil, fmt.Errorf("host port must be greater than 0 and less than 65535")
+		}
+	} else {
+		return nil, fmt.Errorf("can't convert host port '%s': %s", ports[0], err)
+	}
+	if len(ports) == 2 {
+		if n, err := strconv.ParseUint(ports[1], 0, 16); err == nil {
+			pm.ContainerPort = int(n)
+			if pm.ContainerPort <= 0 || pm.ContainerPort > 65535 {
+				return nil, fmt.Errorf("container port must be greater than 0 and less than 65535")
+			}
+		} else {
+			return nil, fmt.Errorf("can't convert container port '%s': %s", ports[1], err)
+		}
+	} else {
+		pm.ContainerPort = pm.HostPort
+	}
+	return pm, nil
+}
+
+// ParsePublish parses a published port of the form
+// hostPort:containerPort[/protocol], the protocol defaults to tcp
+func ParsePublish(value string) (*PortMapEntry, error) {
+	if !strings.Contains(value, ":") {
+		return nil, fmt.Errorf("badly formatted published port '%s', must be of form hostPort:containerPort[/protocol]", value)
+	}
+	if !strings.Contains(value, "/") {
+		value += "/tcp"
+	}
+	return ParsePortMap(value)
+}
+
+// String returns the port mapping in the form
+// [hostIP:]hostPort->containerPort/protocol
+func (pm PortMapEntry) String() string {
+	host := strconv.Itoa(pm.HostPort)
+	if pm.HostIP != "" {
+		host = net.JoinHostPort(pm.HostIP, host)
+	}
+	return fmt.Sprintf("%s->%d/%s", host, pm.ContainerPort, pm.Protocol)
+}
+
 // SetArgs affects arguments to corresponding network plugins
 func (m *Setup) SetArgs(args []string) error {
 	if len(m.networks) < 1 {
@@ -269,39 +331,9 @@ func (m *Setup) SetArgs(args []string) error {
 			key := kv[0]
 			value := kv[1]
 			if key == "portmap" {
-				pm := &PortMapEntry{}
-
-				splittedPort := strings.SplitN(value, "/", 2)
-				if len(splittedPort) != 2 {
-					return fmt.Errorf("badly formatted portmap argument '%s', must be of form portmap=hostPort:containerPort/protocol", value)
-				}
-				pm.Protocol = splittedPort[1]
-				if pm.Protocol != "tcp" && pm.Protocol != "udp" {
-					return fmt.Errorf("only tcp and udp protocol can be specified")
-				}
-				ports := strings.Split(splittedPort[0], ":")
-				if len(ports) != 1 && len(ports) != 2 {
-					return fmt.Errorf("portmap port argument is badly formatted")
-				}
-				if n, err := strconv.ParseUint(ports[0], 0, 16); err == nil {
-					pm.HostPort = int(n)
-					if pm.HostPort <= 0 || pm.HostPort > 65535 {
-						return fmt.Errorf("host port must be greater than 0 and less than 65535")
-					}
-				} else {
-					return fmt.Errorf("can't convert host port '%s': %s", ports[0], err)
-				}
-				if len(ports) == 2 {
-					if n, err := strconv.ParseUint(ports[1], 0, 16); err == nil {
-						pm.ContainerPort = int(n)
-						if pm.ContainerPort <= 0 || pm.ContainerPort > 65535 {
-							return fmt.Errorf("container port must be greater than 0 and less than 65535")
-						}
-					} else {
-						return fmt.Errorf("can't convert container port '%s': %s", ports[1], err)
-					}
-				} else {
-					pm.ContainerPort = pm.HostPort
+				pm, err := ParsePortMap(value)
+				if err != nil {
+					return err
 				}
 				if err := m.SetCapability(networkName, "portMappings", *pm); err != nil {
 					return err
@@ -424,6 +456,22 @@ func (m *Setup) SetPortProtection(network string, lowPort int) error {
 	return nil
 }
 
+// GetPortMaps returns the port mappings of all configured networks
+func (m *Setup) GetPortMaps() []PortMapEntry {
+	var portMaps []PortMapEntry
+	for i := range m.runtimeConf {
+		if entries, ok := m.runtimeConf[i].CapabilityArgs["portMappings"].([]PortMapEntry); ok {
+			portMaps = append(portMaps, entries...)
+		}
+	}
+	return portMaps
+}
+
+// GetNetworks returns the names of the configured networks
+func (m *Setup) GetNetworks() []string {
+	return m.networks
+}
+
 // SetEnvPath allows to define custom paths for PATH environment
 // variables used during CNI plugin execution
 func (m *Setup) SetEnvPath(envPath string) {
@@ -514,6 +514,53 @@ func testBadBridge(nsPath string, cniPath *CNIPath, stdin io.WriteCloser, stdout
 	return nil
 }
 
+func TestParsePublish(t *testing.T) {
+	tests := []struct {
+		name    string
+		publish string
+		str     string
+		wantErr bool
+	}{
+		{
+			name:    "DefaultProtocol",
+			publish: "8080:80",
+			str:     "8080->80/tcp",
+		},
+		{
+			name:    "UDP",
+			publish: "5353:53/udp",
+			str:     "5353->53/udp",
+		},
+		{
+			name:    "MissingContainerPort",
+			publish: "8080",
+			wantErr: true,
+		},
+		{
+			name:    "BadProtocol",
+			publish: "8080:80/sctp",
+			wantErr: true,
+		},
+		{
+			name:    "BadPort",
+			publish: "8080:http",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm, err := ParsePublish(tt.publish)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v (wantErr %v)", err, tt.wantErr)
+			}
+			if err == nil && pm.String() != tt.str {
+				t.Errorf("got %s, want %s", pm, tt.str)
+			}
+		})
+	}
+}
+
 func TestAddDelNetworks(t *testing.T) {
 	test.EnsurePrivilege(t)
 
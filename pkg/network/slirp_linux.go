@@ -0,0 +1,237 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SlirpNetwork is the name of the user-mode network provided
+	// by slirp4netns
+	SlirpNetwork = "slirp"
+	// SlirpIP is the IP address of the container in the slirp network
+	SlirpIP = "10.0.2.100"
+	// SlirpDNS is the IP address of the DNS forwarder of the slirp network
+	SlirpDNS = "10.0.2.3"
+)
+
+const (
+	slirpTapName = "tap0"
+	slirpMTU     = "65520"
+)
+
+// Slirp contains the setup of a user-mode network provided by slirp4netns,
+// it doesn't require any privileges and gives the container network
+// namespace an outbound connectivity through the host network stack.
+type Slirp struct {
+	path     string
+	pid      int
+	userNS   bool
+	portMaps []PortMapEntry
+	cmd      *exec.Cmd
+	exitFd   *os.File
+}
+
+// NewSlirp returns a Slirp setup for the network namespace of the process
+// pid using the slirp4netns binary found at path, or in PATH if empty.
+// When userNS is true, slirp4netns joins the user namespace of the process
+// to configure the network namespace.
+func NewSlirp(path string, pid int, userNS bool) (*Slirp, error) {
+	if path == "" {
+		var err error
+		path, err = exec.LookPath("slirp4netns")
+		if err != nil {
+			return nil, fmt.Errorf("slirp4netns not found, it is required by %s network", SlirpNetwork)
+		}
+	}
+	return &Slirp{
+		path:   path,
+		pid:    pid,
+		userNS: userNS,
+	}, nil
+}
+
+// SetArgs parses the network arguments, only portmap arguments are
+// supported by the slirp network
+func (s *Slirp) SetArgs(args []string) error {
+	for _, arg := range args {
+		// an argument may be prefixed by the network name
+		if i := strings.IndexByte(arg, ':'); i >= 0 && i < strings.IndexByte(arg, '=') {
+			splitted := strings.SplitN(arg, ":", 2)
+			if splitted[0] != SlirpNetwork {
+				return fmt.Errorf("network %s wasn't specified in --network option", splitted[0])
+			}
+			arg = splitted[1]
+		}
+		argList, err := parseArg(arg)
+		if err != nil {
+			return err
+		}
+		for _, kv := range argList {
+			if kv[0] != "portmap" {
+				return fmt.Errorf("argument %s is not supported by %s network", kv[0], SlirpNetwork)
+			}
+			pm, err := ParsePortMap(kv[1])
+			if err != nil {
+				return err
+			}
+			s.portMaps = append(s.portMaps, *pm)
+		}
+	}
+	return nil
+}
+
+// PortMaps returns the port mappings set with SetArgs
+func (s *Slirp) PortMaps() []PortMapEntry {
+	return s.portMaps
+}
+
+// Start starts slirp4netns, waits until the network namespace is
+// configured and adds the port mappings. slirp4netns is stopped when
+// Stop is called or when the calling process exits.
+func (s *Slirp) Start(ctx context.Context) error {
+	// slirp4netns exits when the write end of the exit pipe is closed
+	exitR, exitW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("could not create slirp4netns exit pipe: %s", err)
+	}
+	defer exitR.Close()
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		exitW.Close()
+		return fmt.Errorf("could not create slirp4netns ready pipe: %s", err)
+	}
+	defer readyR.Close()
+
+	apiDir, err := ioutil.TempDir("", "slirp4netns-")
+	if err != nil {
+		exitW.Close()
+		readyW.Close()
+		return fmt.Errorf("could not create slirp4netns API directory: %s", err)
+	}
+	defer os.RemoveAll(apiDir)
+
+	apiSocket := filepath.Join(apiDir, "api.sock")
+
+	args := []string{
+		"--configure",
+		"--mtu=" + slirpMTU,
+		"--disable-host-loopback",
+		"--exit-fd=3",
+		"--ready-fd=4",
+		"--api-socket=" + apiSocket,
+	}
+	if s.userNS {
+		args = append(args, fmt.Sprintf("--userns-path=/proc/%d/ns/user", s.pid))
+	}
+	args = append(args, "--netns-type=path", fmt.Sprintf("/proc/%d/ns/net", s.pid), slirpTapName)
+
+	var stderr bytes.Buffer
+
+	s.cmd = exec.Command(s.path, args...)
+	s.cmd.ExtraFiles = []*os.File{exitR, readyW}
+	s.cmd.Stderr = &stderr
+
+	err = s.cmd.Start()
+	readyW.Close()
+	if err != nil {
+		exitW.Close()
+		return fmt.Errorf("could not start slirp4netns: %s", err)
+	}
+	s.exitFd = exitW
+
+	// slirp4netns writes '1' once the network namespace is configured
+	b := make([]byte, 1)
+	if n, err := readyR.Read(b); err != nil || n != 1 || b[0] != '1' {
+		s.Stop()
+		return fmt.Errorf("slirp4netns failed to configure network: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	for _, pm := range s.portMaps {
+		if err := addHostForward(ctx, apiSocket, pm); err != nil {
+			s.Stop()
+			return fmt.Errorf("could not forward port %d/%s: %s", pm.HostPort, pm.Protocol, err)
+		}
+	}
+
+	return nil
+}
+
+// Stop stops slirp4netns
+func (s *Slirp) Stop() error {
+	if s.exitFd == nil {
+		return nil
+	}
+	s.exitFd.Close()
+	s.exitFd = nil
+
+	if err := s.cmd.Wait(); err != nil {
+		return fmt.Errorf("slirp4netns exited with error: %s", err)
+	}
+	return nil
+}
+
+// addHostForward sends a port forwarding request for the port mapping
+// to the slirp4netns API socket
+func addHostForward(ctx context.Context, apiSocket string, pm PortMapEntry) error {
+	hostIP := pm.HostIP
+	if hostIP == "" {
+		hostIP = "0.0.0.0"
+	}
+
+	request := map[string]interface{}{
+		"execute": "add_hostfwd",
+		"arguments": map[string]interface{}{
+			"proto":      pm.Protocol,
+			"host_addr":  hostIP,
+			"host_port":  pm.HostPort,
+			"guest_addr": SlirpIP,
+			"guest_port": pm.ContainerPort,
+		},
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", apiSocket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return err
+	}
+	if err := conn.(*net.UnixConn).CloseWrite(); err != nil {
+		return err
+	}
+
+	var response struct {
+		Error *struct {
+			Desc string `json:"desc"`
+		} `json:"error"`
+	}
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &response); err != nil {
+		return fmt.Errorf("bad response from slirp4netns: %s", err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s", response.Error.Desc)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSlirpSetArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		portMaps []PortMapEntry
+		wantErr  bool
+	}{
+		{
+			name: "NoArgs",
+		},
+		{
+			name: "Portmap",
+			args: []string{"portmap=8080:80/tcp"},
+			portMaps: []PortMapEntry{
+				{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+			},
+		},
+		{
+			name: "PortmapNetworkPrefix",
+			args: []string{"slirp:portmap=53/udp;portmap=2222:22/tcp"},
+			portMaps: []PortMapEntry{
+				{HostPort: 53, ContainerPort: 53, Protocol: "udp"},
+				{HostPort: 2222, ContainerPort: 22, Protocol: "tcp"},
+			},
+		},
+		{
+			name:    "OtherNetwork",
+			args:    []string{"bridge:portmap=8080:80/tcp"},
+			wantErr: true,
+		},
+		{
+			name:    "UnsupportedArg",
+			args:    []string{"ipRange=10.0.0.0/24"},
+			wantErr: true,
+		},
+		{
+			name:    "BadProtocol",
+			args:    []string{"portmap=8080:80/sctp"},
+			wantErr: true,
+		},
+		{
+			name:    "BadPort",
+			args:    []string{"portmap=80800:80/tcp"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Slirp{}
+
+			err := s.SetArgs(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unexpected success with arguments %v", tt.args)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error with arguments %v: %s", tt.args, err)
+			}
+
+			if !reflect.DeepEqual(s.PortMaps(), tt.portMaps) {
+				t.Errorf("unexpected port mappings %v, expected %v", s.PortMaps(), tt.portMaps)
+			}
+		})
+	}
+}
@@ -6,6 +6,9 @@
 package plugin
 
 import (
+	"context"
+
+	"github.com/sylabs/singularity/pkg/build/types"
 	"github.com/sylabs/singularity/pkg/cmdline"
 	"github.com/sylabs/singularity/pkg/runtime/engine/config"
 )
@@ -51,6 +54,7 @@ type Initializer interface {
 type Registry interface {
 	AddCLIMutator(m CLIMutator) error
 	AddEngineConfigMutator(m EngineConfigMutator) error
+	AddBuildSource(name string, cp ConveyorPacker) error
 }
 
 type CLIMutator struct {
@@ -60,3 +64,17 @@ type CLIMutator struct {
 type EngineConfigMutator struct {
 	Mutate func(*config.Common)
 }
+
+// ConveyorPacker is the interface implemented by the build sources
+// registered with AddBuildSource, the name of a build source is the
+// value of the definition file Bootstrap header selecting it, built-in
+// sources take precedence over plugin sources with the same name. A new
+// zero value of the ConveyorPacker concrete type is used for each
+// build, so the registered value must be a pointer to a struct.
+type ConveyorPacker interface {
+	// Get downloads or extracts the source into the bundle.
+	Get(context.Context, *types.Bundle) error
+	// Pack installs the source into the bundle root filesystem
+	// and returns the bundle.
+	Pack(context.Context) (*types.Bundle, error)
+}
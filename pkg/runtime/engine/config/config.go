@@ -49,8 +49,15 @@ type FileConfig struct {
 	LimitContainerPaths     []string `directive:"limit container paths"`
 	RootDefaultCapabilities string   `default:"full" authorized:"full,file,no" directive:"root default capabilities"`
 	MemoryFSType            string   `default:"tmpfs" authorized:"tmpfs,ramfs" directive:"memory fs type"`
+	FakerootAllocation      string   `default:"none" authorized:"none,uid,pool" directive:"fakeroot allocation"`
+	FakerootRangeStart      uint     `default:"1073741824" directive:"fakeroot range start"`
+	FakerootRangeEnd        uint     `default:"3221225472" directive:"fakeroot range end"`
+	FakerootUIDMin          uint     `default:"1000" directive:"fakeroot uid min"`
+	FakerootEmulation       bool     `default:"no" authorized:"yes,no" directive:"fakeroot emulation"`
 	CniConfPath             string   `directive:"cni configuration path"`
 	CniPluginPath           string   `directive:"cni plugin path"`
 	MksquashfsPath          string   `directive:"mksquashfs path"`
 	CryptsetupPath          string   `directive:"cryptsetup path"`
+	Slirp4netnsPath         string   `directive:"slirp4netns path"`
+	BridgeNetworkSubnets    []string `default:"10.89.0.0/16" directive:"bridge network subnets"`
 }
@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/sylabs/singularity/internal/pkg/cgroups"
+	"github.com/sylabs/singularity/internal/pkg/fakeroot"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engine/config/oci"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/network"
@@ -26,9 +27,14 @@ type EngineConfig struct {
 	OciConfig *oci.Config                `json:"ociConfig"`
 	File      *config.FileConfig         `json:"-"`
 	Network   *network.Setup             `json:"-"`
+	Slirp     *network.Slirp             `json:"-"`
+	DNS       *network.DNSServer         `json:"-"`
 	Cgroups   *cgroups.Manager           `json:"-"`
 	CryptDev  string                     `json:"-"`
 	Plugin    map[string]json.RawMessage `json:"plugin"` // Plugin is the raw JSON representation of the plugin configurations
+	// FakerootAllocation is the fakeroot range allocation to record, it's
+	// set during stage 1 and recorded by the master process
+	FakerootAllocation *fakeroot.Allocation `json:"fakerootAllocation,omitempty"`
 }
 
 // FuseInfo stores the FUSE-related information required or provided by
@@ -134,6 +134,32 @@ func getDataPartitionToSign(fimg *sif.FileImage, dataType sif.Datatype) ([]*sif.
 	return data, nil
 }
 
+// isOverlayPartition returns true if the descriptor is a writable
+// overlay partition, its content changes when the container runs so
+// it's never signed nor verified.
+func isOverlayPartition(d *sif.Descriptor) bool {
+	if d.Datatype != sif.DataPartition {
+		return false
+	}
+	ptype, err := d.GetPartType()
+	return err == nil && ptype == sif.PartOverlay
+}
+
+// getSystemPartitions returns the system partitions other than the
+// primary one.
+func getSystemPartitions(fimg *sif.FileImage) []*sif.Descriptor {
+	var descr []*sif.Descriptor
+	for i, d := range fimg.DescrArr {
+		if !d.Used || d.Datatype != sif.DataPartition {
+			continue
+		}
+		if ptype, err := d.GetPartType(); err == nil && ptype == sif.PartSystem {
+			descr = append(descr, &fimg.DescrArr[i])
+		}
+	}
+	return descr
+}
+
 // descrToSign determines via argument or interactively which descriptor to sign
 func descrToSign(fimg *sif.FileImage, id uint32, isGroup bool) ([]*sif.Descriptor, error) {
 	descr := make([]*sif.Descriptor, 1)
@@ -145,6 +171,10 @@ func descrToSign(fimg *sif.FileImage, id uint32, isGroup bool) ([]*sif.Descripto
 			return nil, fmt.Errorf("no primary partition found")
 		}
 
+		// multi-architecture images hold a system partition
+		// for each of their other architectures
+		descr = append(descr, getSystemPartitions(fimg)...)
+
 		// signableDatatypes is a list of all the signable Datatypes, all
 		// but DataSignature, since theres no need to sign a signature.
 		signableDatatypes := []sif.Datatype{
@@ -164,10 +194,16 @@ func descrToSign(fimg *sif.FileImage, id uint32, isGroup bool) ([]*sif.Descripto
 		var search = sif.Descriptor{
 			Groupid: id | sif.DescrGroupMask,
 		}
-		descr, _, err = fimg.GetFromDescr(search)
+		group, _, err := fimg.GetFromDescr(search)
 		if err != nil {
 			return nil, fmt.Errorf("no descriptors found for groupid %d", id)
 		}
+		descr = descr[:0]
+		for _, d := range group {
+			if !isOverlayPartition(d) {
+				descr = append(descr, d)
+			}
+		}
 	} else {
 		descr[0], _, err = fimg.GetFromDescrID(id)
 		if err != nil {
@@ -298,8 +334,8 @@ func getSigsAllPart(fimg *sif.FileImage) ([]signatureLink, error) {
 		if !d.Used {
 			continue
 		}
-		// No need to verify a signature.
-		if d.Datatype == sif.DataSignature {
+		// No need to verify a signature nor a writable overlay.
+		if d.Datatype == sif.DataSignature || isOverlayPartition(&d) {
 			continue
 		}
 
@@ -368,11 +404,19 @@ func getSigsGroup(fimg *sif.FileImage, id uint32) ([]signatureLink, error) {
 		return nil, fmt.Errorf("no signatures found for groupid %v", id)
 	}
 
-	sigLink := make([]signatureLink, len(sindex), len(dindex))
+	// writable overlay partitions are not part of the group signature
+	var groupIndex []int
+	for _, idx := range dindex {
+		if !isOverlayPartition(&fimg.DescrArr[idx]) {
+			groupIndex = append(groupIndex, idx)
+		}
+	}
+
+	sigLink := make([]signatureLink, len(sindex))
 
 	for i, s := range sindex {
 		sigLink[i].sigIndex = s
-		sigLink[i].groupIndex = append(sigLink[i].groupIndex, dindex...)
+		sigLink[i].groupIndex = append(sigLink[i].groupIndex, groupIndex...)
 	}
 
 	return sigLink, nil
@@ -18,6 +18,7 @@ import (
 
 const (
 	RemoteConfFile = "remote.yaml"
+	NetworkDirName = "network"
 	singularityDir = ".singularity"
 )
 
@@ -60,6 +61,12 @@ func RemoteConf() string {
 	return filepath.Join(ConfigDir(), RemoteConfFile)
 }
 
+// NetworkDir returns the directory where the user-defined networks
+// of the current user are stored.
+func NetworkDir() string {
+	return filepath.Join(ConfigDir(), NetworkDirName)
+}
+
 // ConfigDirForUsername returns the directory where the singularity
 // configuration and data for the specified username is located.
 func ConfigDirForUsername(username string) (string, error) {
@@ -15,9 +15,15 @@ import (
 	"encoding/pem"
 	"io"
 	"io/ioutil"
+	"os"
 
 	"github.com/pkg/errors"
 	"github.com/sylabs/sif/pkg/sif"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	// openpgp defaults to RIPEMD160 when a key doesn't carry hash
+	// preferences and fails if the hash function is not available
+	_ "golang.org/x/crypto/ripemd160"
 )
 
 var (
@@ -25,20 +31,35 @@ var (
 	ErrUnsupportedKeyURI    = errors.New("unsupported key URI")
 	ErrNoEncryptedKeyData   = errors.New("no encrypted key data")
 	ErrNoPEMData            = errors.New("No PEM data")
+	ErrNoPGPKeyData         = errors.New("no PGP key data")
+	ErrNoMatchingRecipient  = errors.New("image is not encrypted for this key")
 )
 
 const (
 	Unknown = iota
 	Passphrase
 	PEM
+	PGP
 )
 
+// MessagePGPEncryptedKey identifies an OpenPGP message holding the key
+// used to encrypt a file system in user space. The sif package only
+// defines clear signatures for OpenPGP formatted messages.
+const MessagePGPEncryptedKey sif.Messagetype = 0x101
+
+// dataKeySize is the size of the random key used to encrypt a file
+// system in user space (AES-256).
+const dataKeySize = 32
+
 // KeyInfo contains information for passing around
 // or extracting a passphrase for an encrypted container
 type KeyInfo struct {
 	Format   int
 	Material string
 	Path     string
+	// Recipients holds paths to additional public keys, in the
+	// same format, the encryption key is wrapped for
+	Recipients []string
 }
 
 func getRandomBytes(size int) ([]byte, error) {
@@ -58,6 +79,11 @@ func NewPlaintextKey(k KeyInfo) ([]byte, error) {
 		// encrypt a secret
 		return getRandomBytes(64)
 
+	case PGP:
+		// generate a random data key which will be encrypted
+		// for the recipients of the PGP public key(s)
+		return getRandomBytes(dataKeySize)
+
 	case Passphrase:
 		// return the original value unmodified
 		return []byte(k.Material), nil
@@ -88,6 +114,20 @@ func EncryptKey(k KeyInfo, plaintext []byte) ([]byte, error) {
 
 		return buf.Bytes(), nil
 
+	case PGP:
+		entities, err := loadPGPKeys(k.Path)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading public key for key encryption")
+		}
+
+		var buf bytes.Buffer
+
+		if err := savePGPMessage(&buf, entities, plaintext); err != nil {
+			return nil, errors.Wrap(err, "encrypting key")
+		}
+
+		return buf.Bytes(), nil
+
 	case Passphrase:
 		return nil, nil
 
@@ -104,24 +144,52 @@ func PlaintextKey(k KeyInfo, image string) ([]byte, error) {
 			return nil, errors.Wrap(err, "loading private key for key decryption")
 		}
 
-		pemKey, err := getEncryptionKeyFromImage(image)
+		pemKeys, err := getEncryptionKeysFromImage(image, sif.FormatPEM, sif.MessageRSAOAEP)
 		if err != nil {
 			return nil, errors.Wrapf(err, "loading encrypted key SIF image %s", image)
 		}
 
-		pemBuf := bytes.NewReader(pemKey)
+		// the key may be wrapped for several recipients, try
+		// each of them until one decrypts with the private key
+		for _, pemKey := range pemKeys {
+			pemBuf := bytes.NewReader(pemKey)
+
+			encKey, err := loadPEMMessage(pemBuf)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unpacking PEM message from SIF image %s", image)
+			}
+
+			plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, encKey, nil)
+			if err == nil {
+				return plaintext, nil
+			}
+		}
+
+		return nil, errors.Wrapf(ErrNoMatchingRecipient, "decrypting key from image %s", image)
 
-		encKey, err := loadPEMMessage(pemBuf)
+	case PGP:
+		entities, err := loadPGPKeys(k.Path)
 		if err != nil {
-			return nil, errors.Wrapf(err, "unpacking PEM message from SIF image %s", image)
+			return nil, errors.Wrap(err, "loading private key for key decryption")
+		}
+
+		if err := decryptPGPKeys(entities, k.Material); err != nil {
+			return nil, errors.Wrap(err, "loading private key for key decryption")
 		}
 
-		plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, encKey, nil)
+		pgpKeys, err := getEncryptionKeysFromImage(image, sif.FormatOpenPGP, MessagePGPEncryptedKey)
 		if err != nil {
-			return nil, errors.Wrapf(err, "decrypting key from image %s", image)
+			return nil, errors.Wrapf(err, "loading encrypted key SIF image %s", image)
+		}
+
+		for _, pgpKey := range pgpKeys {
+			plaintext, err := loadPGPMessage(bytes.NewReader(pgpKey), entities)
+			if err == nil {
+				return plaintext, nil
+			}
 		}
 
-		return plaintext, nil
+		return nil, errors.Wrapf(ErrNoMatchingRecipient, "decrypting key from image %s", image)
 
 	case Passphrase:
 		return []byte(k.Material), nil
@@ -192,43 +260,139 @@ func savePEMMessage(w io.Writer, msg []byte) error {
 	return pem.Encode(w, b)
 }
 
-func getEncryptionKeyFromImage(fn string) ([]byte, error) {
-	img, err := sif.LoadContainer(fn, true)
+// PGPKeyEncrypted returns true if the PGP private key(s) found in
+// the file fn are protected by a passphrase.
+func PGPKeyEncrypted(fn string) (bool, error) {
+	entities, err := loadPGPKeys(fn)
 	if err != nil {
-		return nil, errors.Wrapf(err, "loading container image from %s", fn)
+		return false, err
 	}
-	defer img.UnloadContainer()
 
-	primDescr, _, err := img.GetPartPrimSys()
-	if err != nil {
-		return nil, errors.Wrapf(err, "retrieving primary system partition from %s", fn)
+	for _, e := range entities {
+		if e.PrivateKey != nil && e.PrivateKey.Encrypted {
+			return true, nil
+		}
+		for _, sk := range e.Subkeys {
+			if sk.PrivateKey != nil && sk.PrivateKey.Encrypted {
+				return true, nil
+			}
+		}
 	}
 
-	descr, _, err := img.GetLinkedDescrsByType(primDescr.ID, sif.DataCryptoMessage)
+	return false, nil
+}
+
+// loadPGPKeys loads one or more public or private PGP keys from the
+// file fn, the file may be in binary or ASCII armored format.
+func loadPGPKeys(fn string) (openpgp.EntityList, error) {
+	f, err := os.Open(fn)
 	if err != nil {
-		return nil, errors.Wrapf(err, "retrieving linked descriptors for primary system partition from %s", fn)
+		return nil, err
 	}
+	defer f.Close()
 
-	for _, d := range descr {
-		format, err := d.GetFormatType()
+	entities, err := openpgp.ReadKeyRing(f)
+	if err != nil {
+		// cannot load keys from file, perhaps it's ASCII armored?
+		// rewind and try again
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		entities, err = openpgp.ReadArmoredKeyRing(f)
 		if err != nil {
-			return nil, errors.Wrapf(err, "while retrieving cryptographic message format")
+			return nil, err
 		}
+	}
 
-		message, err := d.GetMessageType()
-		if err != nil {
-			return nil, errors.Wrapf(err, "while retrieving cryptographic message type")
+	if len(entities) == 0 {
+		return nil, errors.Wrapf(ErrNoPGPKeyData, "reading %s", fn)
+	}
+
+	return entities, nil
+}
+
+// decryptPGPKeys decrypts the passphrase protected private keys and
+// subkeys found in entities.
+func decryptPGPKeys(entities openpgp.EntityList, passphrase string) error {
+	for _, e := range entities {
+		if e.PrivateKey == nil {
+			return errors.Errorf("key %X is not a private key", e.PrimaryKey.Fingerprint)
 		}
+		if e.PrivateKey.Encrypted {
+			if err := e.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return errors.Wrapf(err, "decrypting key %X", e.PrimaryKey.Fingerprint)
+			}
+		}
+		for _, sk := range e.Subkeys {
+			if sk.PrivateKey != nil && sk.PrivateKey.Encrypted {
+				if err := sk.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+					return errors.Wrapf(err, "decrypting subkey %X", sk.PublicKey.Fingerprint)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func loadPGPMessage(r io.Reader, keyring openpgp.KeyRing) ([]byte, error) {
+	block, err := armor.Decode(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading PGP message")
+	}
+
+	md, err := openpgp.ReadMessage(block.Body, keyring, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(md.UnverifiedBody)
+}
+
+func savePGPMessage(w io.Writer, recipients openpgp.EntityList, msg []byte) error {
+	aw, err := armor.Encode(w, "PGP MESSAGE", nil)
+	if err != nil {
+		return err
+	}
+
+	pw, err := openpgp.Encrypt(aw, recipients, nil, nil, nil)
+	if err != nil {
+		return err
+	}
 
-		// currently only support one type of message
-		if format != sif.FormatPEM || message != sif.MessageRSAOAEP {
+	if _, err := pw.Write(msg); err != nil {
+		return err
+	}
+
+	if err := pw.Close(); err != nil {
+		return err
+	}
+
+	return aw.Close()
+}
+
+// getEncryptionKeysFromImage returns the data of all cryptographic
+// messages of the given format and type linked to the primary system
+// partition of the image fn.
+func getEncryptionKeysFromImage(fn string, format sif.Formattype, message sif.Messagetype) ([][]byte, error) {
+	img, err := sif.LoadContainer(fn, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading container image from %s", fn)
+	}
+	defer img.UnloadContainer()
+
+	recipients, err := imageRecipients(&img)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading encrypted keys from %s", fn)
+	}
+
+	var keys [][]byte
+
+	for _, r := range recipients {
+		if r.format != format || r.message != message {
 			continue
 		}
 
-		// TODO(ian): For now, assume the first linked message is what we
-		// are looking for. We should consider what we want to do in the
-		// case of multiple linked messages
-		data := d.GetData(&img)
+		data := r.descr.GetData(&img)
 		if data == nil {
 			return nil, errors.Wrapf(ErrNoEncryptedKeyData, "retrieving encrypted key data from %s", fn)
 		}
@@ -236,8 +400,12 @@ func getEncryptionKeyFromImage(fn string) ([]byte, error) {
 		key := make([]byte, len(data))
 		copy(key, data)
 
-		return key, nil
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.Wrapf(ErrEncryptedKeyNotFound, "reading from %s", fn)
 	}
 
-	return nil, errors.Wrapf(ErrEncryptedKeyNotFound, "reading from %s", fn)
+	return keys, nil
 }
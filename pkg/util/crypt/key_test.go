@@ -6,11 +6,15 @@
 package crypt
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/pkg/errors"
 	"github.com/sylabs/singularity/internal/pkg/test"
+	"golang.org/x/crypto/openpgp"
 )
 
 const (
@@ -144,3 +148,71 @@ func TestPlaintextKey(t *testing.T) {
 		})
 	}
 }
+
+func TestPGPKey(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to generate PGP key: %s", err)
+	}
+
+	pub, err := ioutil.TempFile("", "pgp-pub-")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %s", err)
+	}
+	defer os.Remove(pub.Name())
+
+	if err := entity.Serialize(pub); err != nil {
+		t.Fatalf("failed to serialize public key: %s", err)
+	}
+	pub.Close()
+
+	priv, err := ioutil.TempFile("", "pgp-priv-")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %s", err)
+	}
+	defer os.Remove(priv.Name())
+
+	if err := entity.SerializePrivate(priv, nil); err != nil {
+		t.Fatalf("failed to serialize private key: %s", err)
+	}
+	priv.Close()
+
+	pubKey := KeyInfo{Format: PGP, Path: pub.Name()}
+
+	plaintext, err := NewPlaintextKey(pubKey)
+	if err != nil {
+		t.Fatalf("failed to generate plaintext key: %s", err)
+	}
+
+	data, err := EncryptKey(pubKey, plaintext)
+	if err != nil {
+		t.Fatalf("failed to encrypt key: %s", err)
+	}
+
+	if _, err := EncryptKey(KeyInfo{Format: PGP, Path: invalidPemPath}, plaintext); err == nil {
+		t.Fatalf("unexpected success while encrypting key with missing PGP key")
+	}
+
+	encrypted, err := PGPKeyEncrypted(priv.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if encrypted {
+		t.Fatalf("private key reported as encrypted")
+	}
+
+	entities, err := loadPGPKeys(priv.Name())
+	if err != nil {
+		t.Fatalf("failed to load private key: %s", err)
+	}
+
+	key, err := loadPGPMessage(bytes.NewReader(data), entities)
+	if err != nil {
+		t.Fatalf("failed to decrypt key: %s", err)
+	}
+	if !bytes.Equal(key, plaintext) {
+		t.Fatalf("decrypted key doesn't match original key")
+	}
+}
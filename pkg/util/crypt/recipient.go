@@ -0,0 +1,376 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	"golang.org/x/crypto/openpgp"
+)
+
+var (
+	ErrRecipientExists   = errors.New("image is already encrypted for this key")
+	ErrRecipientNotFound = errors.New("no such recipient")
+	ErrLastRecipient     = errors.New("cannot remove the last recipient")
+)
+
+const (
+	pemRecipientPrefix = "rsa:"
+	pgpRecipientPrefix = "pgp:"
+)
+
+// Recipient holds the encryption key wrapped for a single public key.
+type Recipient struct {
+	// ID identifies the public key, it's stored as the name
+	// of the cryptographic message descriptor.
+	ID   string
+	Data []byte
+}
+
+// ImageRecipient describes an encryption key wrapped for a public
+// key and stored in a SIF image.
+type ImageRecipient struct {
+	// DescrID is the ID of the cryptographic message descriptor.
+	DescrID uint32
+	// ID identifies the public key, it's empty for images
+	// created before keys were wrapped per recipient.
+	ID     string
+	Format int
+}
+
+type imageRecipient struct {
+	descr   *sif.Descriptor
+	format  sif.Formattype
+	message sif.Messagetype
+}
+
+// MessageType returns the SIF cryptographic message format and type
+// used to store a key wrapped with a key of the given format.
+func MessageType(format int) (sif.Formattype, sif.Messagetype, error) {
+	switch format {
+	case PEM:
+		return sif.FormatPEM, sif.MessageRSAOAEP, nil
+	case PGP:
+		return sif.FormatOpenPGP, MessagePGPEncryptedKey, nil
+	default:
+		return 0, 0, ErrUnsupportedKeyURI
+	}
+}
+
+func pemRecipientID(pub *rsa.PublicKey) string {
+	sum := sha256.Sum256(x509.MarshalPKCS1PublicKey(pub))
+	return pemRecipientPrefix + hex.EncodeToString(sum[:])
+}
+
+func pgpRecipientID(e *openpgp.Entity) string {
+	return fmt.Sprintf("%s%X", pgpRecipientPrefix, e.PrimaryKey.Fingerprint)
+}
+
+// wrapKey wraps the plaintext key for each public key found in the
+// file path, a PGP key file may hold several keys.
+func wrapKey(format int, path string, plaintext []byte) ([]Recipient, error) {
+	switch format {
+	case PEM:
+		pubKey, err := loadPEMPublicKey(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading public key %s for key encryption", path)
+		}
+
+		ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pubKey, plaintext, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "encrypting key")
+		}
+
+		var buf bytes.Buffer
+
+		if err := savePEMMessage(&buf, ciphertext); err != nil {
+			return nil, errors.Wrap(err, "serializing encrypted key")
+		}
+
+		return []Recipient{{ID: pemRecipientID(pubKey), Data: buf.Bytes()}}, nil
+
+	case PGP:
+		entities, err := loadPGPKeys(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading public key %s for key encryption", path)
+		}
+
+		recipients := make([]Recipient, 0, len(entities))
+
+		for _, e := range entities {
+			var buf bytes.Buffer
+
+			if err := savePGPMessage(&buf, openpgp.EntityList{e}, plaintext); err != nil {
+				return nil, errors.Wrapf(err, "encrypting key for %X", e.PrimaryKey.Fingerprint)
+			}
+
+			recipients = append(recipients, Recipient{ID: pgpRecipientID(e), Data: buf.Bytes()})
+		}
+
+		return recipients, nil
+
+	default:
+		return nil, ErrUnsupportedKeyURI
+	}
+}
+
+// WrapKey wraps the plaintext key separately for the public key
+// k.Path and for each of k.Recipients. A passphrase doesn't wrap
+// the key and returns no recipient.
+func WrapKey(k KeyInfo, plaintext []byte) ([]Recipient, error) {
+	if k.Format == Passphrase {
+		if len(k.Recipients) > 0 {
+			return nil, fmt.Errorf("recipients are not supported with passphrase encryption")
+		}
+		return nil, nil
+	}
+
+	var recipients []Recipient
+
+	seen := make(map[string]bool)
+
+	for _, path := range append([]string{k.Path}, k.Recipients...) {
+		r, err := wrapKey(k.Format, path, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		for _, rr := range r {
+			if seen[rr.ID] {
+				continue
+			}
+			seen[rr.ID] = true
+			recipients = append(recipients, rr)
+		}
+	}
+
+	return recipients, nil
+}
+
+// RecipientIDs returns the IDs of the public keys found in the file
+// path, the format is guessed from the file content.
+func RecipientIDs(path string) ([]string, error) {
+	if pubKey, err := loadPEMPublicKey(path); err == nil {
+		return []string{pemRecipientID(pubKey)}, nil
+	}
+
+	entities, err := loadPGPKeys(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a PEM public key nor a PGP key: %s", path, err)
+	}
+
+	ids := make([]string, 0, len(entities))
+	for _, e := range entities {
+		ids = append(ids, pgpRecipientID(e))
+	}
+
+	return ids, nil
+}
+
+// imageRecipients returns the cryptographic messages linked to the
+// primary system partition of a loaded SIF image.
+func imageRecipients(img *sif.FileImage) ([]imageRecipient, error) {
+	primDescr, _, err := img.GetPartPrimSys()
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving primary system partition")
+	}
+
+	descr, _, err := img.GetLinkedDescrsByType(primDescr.ID, sif.DataCryptoMessage)
+	if err == sif.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "retrieving linked descriptors for primary system partition")
+	}
+
+	recipients := make([]imageRecipient, 0, len(descr))
+
+	for _, d := range descr {
+		format, err := d.GetFormatType()
+		if err != nil {
+			return nil, errors.Wrapf(err, "while retrieving cryptographic message format")
+		}
+
+		message, err := d.GetMessageType()
+		if err != nil {
+			return nil, errors.Wrapf(err, "while retrieving cryptographic message type")
+		}
+
+		recipients = append(recipients, imageRecipient{descr: d, format: format, message: message})
+	}
+
+	return recipients, nil
+}
+
+// ImageRecipients returns the recipients the encryption key of the
+// image fn is wrapped for.
+func ImageRecipients(fn string) ([]ImageRecipient, error) {
+	img, err := sif.LoadContainer(fn, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading container image from %s", fn)
+	}
+	defer img.UnloadContainer()
+
+	recipients, err := imageRecipients(&img)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading recipients from %s", fn)
+	}
+
+	list := make([]ImageRecipient, 0, len(recipients))
+
+	for _, r := range recipients {
+		format := Unknown
+		if r.format == sif.FormatPEM && r.message == sif.MessageRSAOAEP {
+			format = PEM
+		} else if r.format == sif.FormatOpenPGP && r.message == MessagePGPEncryptedKey {
+			format = PGP
+		}
+		list = append(list, ImageRecipient{
+			DescrID: r.descr.ID,
+			ID:      r.descr.GetName(),
+			Format:  format,
+		})
+	}
+
+	return list, nil
+}
+
+// AddRecipients wraps the encryption key of the image fn for the
+// public keys found in the files paths, without re-encrypting the
+// image file system. The encryption key is first recovered with the
+// private key k, paths must hold public keys in the same format.
+func AddRecipients(fn string, k KeyInfo, paths []string) error {
+	if k.Format != PEM && k.Format != PGP {
+		return fmt.Errorf("recipients are only supported with PEM or PGP keys")
+	}
+
+	messageFormat, messageType, err := MessageType(k.Format)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := PlaintextKey(k, fn)
+	if err != nil {
+		return err
+	}
+
+	var recipients []Recipient
+	for _, path := range paths {
+		r, err := wrapKey(k.Format, path, plaintext)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, r...)
+	}
+
+	img, err := sif.LoadContainer(fn, false)
+	if err != nil {
+		return errors.Wrapf(err, "loading container image from %s", fn)
+	}
+	defer img.UnloadContainer()
+
+	primDescr, _, err := img.GetPartPrimSys()
+	if err != nil {
+		return errors.Wrapf(err, "retrieving primary system partition from %s", fn)
+	}
+
+	existing, err := imageRecipients(&img)
+	if err != nil {
+		return errors.Wrapf(err, "reading recipients from %s", fn)
+	}
+
+	seen := make(map[string]bool)
+	for _, r := range existing {
+		seen[r.descr.GetName()] = true
+	}
+
+	for _, r := range recipients {
+		if seen[r.ID] {
+			return errors.Wrapf(ErrRecipientExists, "adding %s", r.ID)
+		}
+		seen[r.ID] = true
+
+		input := sif.DescriptorInput{
+			Datatype: sif.DataCryptoMessage,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     primDescr.ID,
+			Data:     r.Data,
+			Size:     int64(len(r.Data)),
+			Fname:    r.ID,
+		}
+
+		if err := input.SetCryptoMsgExtra(messageFormat, messageType); err != nil {
+			return err
+		}
+
+		if err := img.AddObject(input); err != nil {
+			return errors.Wrapf(err, "adding recipient %s to %s", r.ID, fn)
+		}
+	}
+
+	return nil
+}
+
+// RemoveRecipient removes the encryption key wrapped for recipient
+// from the image fn, recipient is either a recipient ID as returned
+// by ImageRecipients or the path to the recipient public key. The
+// key material is zeroed in the image.
+func RemoveRecipient(fn string, recipient string) error {
+	ids := []string{recipient}
+
+	if !strings.HasPrefix(recipient, pemRecipientPrefix) && !strings.HasPrefix(recipient, pgpRecipientPrefix) {
+		if !fs.IsFile(recipient) {
+			return errors.Wrapf(ErrRecipientNotFound, "looking for %s", recipient)
+		}
+		var err error
+		ids, err = RecipientIDs(recipient)
+		if err != nil {
+			return err
+		}
+	}
+
+	img, err := sif.LoadContainer(fn, false)
+	if err != nil {
+		return errors.Wrapf(err, "loading container image from %s", fn)
+	}
+	defer img.UnloadContainer()
+
+	recipients, err := imageRecipients(&img)
+	if err != nil {
+		return errors.Wrapf(err, "reading recipients from %s", fn)
+	}
+
+	var remove []uint32
+	for _, r := range recipients {
+		for _, id := range ids {
+			if strings.EqualFold(r.descr.GetName(), id) {
+				remove = append(remove, r.descr.ID)
+			}
+		}
+	}
+
+	if len(remove) == 0 {
+		return errors.Wrapf(ErrRecipientNotFound, "looking for %s", recipient)
+	} else if len(remove) == len(recipients) {
+		return ErrLastRecipient
+	}
+
+	for _, id := range remove {
+		if err := img.DeleteObject(id, sif.DelZero); err != nil {
+			return errors.Wrapf(err, "removing recipient descriptor %d from %s", id, fn)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package crypt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/test"
+	"golang.org/x/crypto/openpgp"
+)
+
+// writePGPKeyPair generates a PGP key and writes its public and
+// private parts in dir.
+func writePGPKeyPair(t *testing.T, dir, name string) (string, string) {
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to generate PGP key: %s", err)
+	}
+
+	pubPath := filepath.Join(dir, name+".pub")
+	privPath := filepath.Join(dir, name+".priv")
+
+	var pub, priv bytes.Buffer
+
+	if err := entity.Serialize(&pub); err != nil {
+		t.Fatalf("failed to serialize public key: %s", err)
+	}
+	if err := entity.SerializePrivate(&priv, nil); err != nil {
+		t.Fatalf("failed to serialize private key: %s", err)
+	}
+	if err := ioutil.WriteFile(pubPath, pub.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write public key: %s", err)
+	}
+	if err := ioutil.WriteFile(privPath, priv.Bytes(), 0600); err != nil {
+		t.Fatalf("failed to write private key: %s", err)
+	}
+
+	return pubPath, privPath
+}
+
+// createEncryptedSIF creates a SIF image with a fake encrypted
+// primary partition and the key wrapped for the recipients of k.
+func createEncryptedSIF(t *testing.T, path string, k KeyInfo, plaintext []byte) {
+	cinfo := sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+	}
+
+	data := []byte("encrypted file system")
+
+	part := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Data:     data,
+		Size:     int64(len(data)),
+	}
+	if err := part.SetPartExtra(sif.FsEncryptedSquashfs, sif.PartPrimSys, sif.GetSIFArch(runtime.GOARCH)); err != nil {
+		t.Fatalf("failed to set partition extra data: %s", err)
+	}
+	cinfo.InputDescr = append(cinfo.InputDescr, part)
+
+	recipients, err := WrapKey(k, plaintext)
+	if err != nil {
+		t.Fatalf("failed to wrap key: %s", err)
+	}
+
+	format, message, err := MessageType(k.Format)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, r := range recipients {
+		msg := sif.DescriptorInput{
+			Datatype: sif.DataCryptoMessage,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     1,
+			Data:     r.Data,
+			Size:     int64(len(r.Data)),
+			Fname:    r.ID,
+		}
+		if err := msg.SetCryptoMsgExtra(format, message); err != nil {
+			t.Fatalf("failed to set message extra data: %s", err)
+		}
+		cinfo.InputDescr = append(cinfo.InputDescr, msg)
+	}
+
+	if _, err := sif.CreateContainer(cinfo); err != nil {
+		t.Fatalf("failed to create SIF image: %s", err)
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	dir, err := ioutil.TempDir("", "recipients-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	alicePub, alicePriv := writePGPKeyPair(t, dir, "alice")
+	bobPub, bobPriv := writePGPKeyPair(t, dir, "bob")
+	carolPub, carolPriv := writePGPKeyPair(t, dir, "carol")
+
+	image := filepath.Join(dir, "image.sif")
+
+	k := KeyInfo{Format: PGP, Path: alicePub, Recipients: []string{bobPub, alicePub}}
+
+	plaintext, err := NewPlaintextKey(k)
+	if err != nil {
+		t.Fatalf("failed to generate plaintext key: %s", err)
+	}
+
+	createEncryptedSIF(t, image, k, plaintext)
+
+	recipients, err := ImageRecipients(image)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// alice's key is given twice but is only wrapped once
+	if len(recipients) != 2 {
+		t.Fatalf("unexpected number of recipients: %d instead of 2", len(recipients))
+	}
+	for _, r := range recipients {
+		if r.Format != PGP {
+			t.Fatalf("unexpected recipient format %d for %s", r.Format, r.ID)
+		}
+	}
+
+	checkKey := func(priv string, expectedError error) {
+		key, err := PlaintextKey(KeyInfo{Format: PGP, Path: priv}, image)
+		if errors.Cause(err) != expectedError {
+			t.Fatalf("unexpected error for %s: %v instead of %v", priv, err, expectedError)
+		}
+		if err == nil && !bytes.Equal(key, plaintext) {
+			t.Fatalf("decrypted key doesn't match original key for %s", priv)
+		}
+	}
+
+	checkKey(alicePriv, nil)
+	checkKey(bobPriv, nil)
+	checkKey(carolPriv, ErrNoMatchingRecipient)
+
+	// carol can't grant access to herself
+	if err := AddRecipients(image, KeyInfo{Format: PGP, Path: carolPriv}, []string{carolPub}); errors.Cause(err) != ErrNoMatchingRecipient {
+		t.Fatalf("unexpected error while adding recipient: %v", err)
+	}
+	if err := AddRecipients(image, KeyInfo{Format: PGP, Path: bobPriv}, []string{carolPub}); err != nil {
+		t.Fatalf("unexpected error while adding recipient: %s", err)
+	}
+	if err := AddRecipients(image, KeyInfo{Format: PGP, Path: bobPriv}, []string{carolPub}); errors.Cause(err) != ErrRecipientExists {
+		t.Fatalf("unexpected error while adding existing recipient: %v", err)
+	}
+	checkKey(carolPriv, nil)
+
+	ids, err := RecipientIDs(alicePub)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := RemoveRecipient(image, ids[0]); err != nil {
+		t.Fatalf("unexpected error while removing recipient by ID: %s", err)
+	}
+	if err := RemoveRecipient(image, ids[0]); errors.Cause(err) != ErrRecipientNotFound {
+		t.Fatalf("unexpected error while removing missing recipient: %v", err)
+	}
+	if err := RemoveRecipient(image, bobPub); err != nil {
+		t.Fatalf("unexpected error while removing recipient by key: %s", err)
+	}
+	if err := RemoveRecipient(image, carolPub); err != ErrLastRecipient {
+		t.Fatalf("unexpected error while removing last recipient: %v", err)
+	}
+
+	checkKey(alicePriv, ErrNoMatchingRecipient)
+	checkKey(bobPriv, ErrNoMatchingRecipient)
+	checkKey(carolPriv, nil)
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// A file system encrypted in user space is stored as a stream of
+// AES-256-GCM sealed chunks preceded by a small header:
+//
+//	magic (8 bytes) | nonce prefix (8 bytes) | chunk 0 | chunk 1 | ...
+//
+// Each chunk holds at most streamChunkSize bytes of plaintext plus the
+// GCM tag, its nonce is the nonce prefix followed by the chunk index.
+// The last chunk is authenticated with a different additional data so
+// a truncated stream is detected while decrypting.
+const (
+	streamMagic       = "SYCRYPT1"
+	streamNoncePrefix = 8
+	streamChunkSize   = 64 * 1024
+)
+
+var (
+	chunkData  = []byte{0}
+	chunkFinal = []byte{1}
+)
+
+var (
+	// ErrInvalidStream is returned when the encrypted stream header is invalid.
+	ErrInvalidStream = errors.New("invalid encrypted stream")
+	// ErrTruncatedStream is returned when the encrypted stream ends without a final chunk.
+	ErrTruncatedStream = errors.New("truncated encrypted stream")
+)
+
+func newStreamCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("invalid key size %d, %d bytes required", len(key), dataKeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, index uint32) []byte {
+	nonce := make([]byte, streamNoncePrefix+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamNoncePrefix:], index)
+	return nonce
+}
+
+type streamWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	index  uint32
+	buf    bytes.Buffer
+	closed bool
+}
+
+// NewWriter returns a writer encrypting data with key and writing
+// the result to w. Close must be called to write the final chunk, it
+// doesn't close w.
+func NewWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := newStreamCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := getRandomBytes(streamNoncePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write([]byte(streamMagic)); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{w: w, aead: aead, prefix: prefix}, nil
+}
+
+func (s *streamWriter) seal(plaintext, ad []byte) error {
+	if s.index == ^uint32(0) {
+		return fmt.Errorf("encrypted stream too large")
+	}
+	ciphertext := s.aead.Seal(nil, chunkNonce(s.prefix, s.index), plaintext, ad)
+	s.index++
+	_, err := s.w.Write(ciphertext)
+	return err
+}
+
+// Write implements io.Writer, a chunk is written only once more data
+// than a chunk could hold is buffered, so the last chunk is always
+// written by Close.
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, fmt.Errorf("write on closed encrypted stream")
+	}
+
+	n, _ := s.buf.Write(p)
+
+	for s.buf.Len() > streamChunkSize {
+		if err := s.seal(s.buf.Next(streamChunkSize), chunkData); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+// Close writes the final chunk.
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.seal(s.buf.Next(s.buf.Len()), chunkFinal)
+}
+
+type streamReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	index  uint32
+	chunk  []byte
+	plain  []byte
+	buf    []byte
+	final  bool
+}
+
+// NewReader returns a reader decrypting the data read from r with
+// key. Data following the final chunk in r is ignored.
+func NewReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newStreamCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(streamMagic)+streamNoncePrefix)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errors.Wrap(ErrInvalidStream, err.Error())
+	}
+	if string(header[:len(streamMagic)]) != streamMagic {
+		return nil, ErrInvalidStream
+	}
+
+	return &streamReader{
+		r:      r,
+		aead:   aead,
+		prefix: header[len(streamMagic):],
+		chunk:  make([]byte, streamChunkSize+aead.Overhead()),
+		plain:  make([]byte, 0, streamChunkSize),
+	}, nil
+}
+
+func (s *streamReader) open() error {
+	n, err := io.ReadFull(s.r, s.chunk)
+	if err == io.EOF {
+		return ErrTruncatedStream
+	} else if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	nonce := chunkNonce(s.prefix, s.index)
+
+	plaintext, err := s.aead.Open(s.plain[:0], nonce, s.chunk[:n], chunkData)
+	if err != nil {
+		plaintext, err = s.aead.Open(s.plain[:0], nonce, s.chunk[:n], chunkFinal)
+		if err != nil {
+			return fmt.Errorf("while decrypting chunk %d: %s", s.index, err)
+		}
+		s.final = true
+	}
+
+	s.index++
+	s.buf = plaintext
+
+	return nil
+}
+
+// Read implements io.Reader.
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.final {
+			return 0, io.EOF
+		}
+		if err := s.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+
+	return n, nil
+}
+
+// EncryptFile takes the path to a file containing a non-encrypted
+// filesystem, encrypts it using the provided key, and returns a path
+// to a file that can be later decrypted in user space with NewReader.
+// NOTE: it is the callers responsibility to remove the returned file.
+func EncryptFile(path string, key []byte) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to open %s: %s", path, err)
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile("", "crypt-")
+	if err != nil {
+		sylog.Debugf("Error creating temporary crypt file")
+		return "", err
+	}
+	defer out.Close()
+
+	w, err := NewWriter(out, key)
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+
+	if _, err := io.Copy(w, in); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("unable to encrypt %s: %s", path, err)
+	}
+
+	if err := w.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("unable to encrypt %s: %s", path, err)
+	}
+
+	return out.Name(), nil
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package crypt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/test"
+)
+
+func encryptStream(t *testing.T, key, data []byte) []byte {
+	var buf bytes.Buffer
+
+	w, err := NewWriter(&buf, key)
+	if err != nil {
+		t.Fatalf("unexpected error while creating writer: %s", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("unexpected error while writing: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error while closing writer: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestStream(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	key, err := getRandomBytes(dataKeySize)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"one byte", 1},
+		{"one chunk", streamChunkSize},
+		{"one chunk plus one byte", streamChunkSize + 1},
+		{"several chunks", 3*streamChunkSize + 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := getRandomBytes(tt.size)
+			if err != nil {
+				t.Fatalf("failed to generate data: %s", err)
+			}
+
+			ciphertext := encryptStream(t, key, data)
+
+			r, err := NewReader(bytes.NewReader(ciphertext), key)
+			if err != nil {
+				t.Fatalf("unexpected error while creating reader: %s", err)
+			}
+			plaintext, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected error while decrypting: %s", err)
+			}
+			if !bytes.Equal(plaintext, data) {
+				t.Fatalf("decrypted data doesn't match original data")
+			}
+		})
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	key, err := getRandomBytes(dataKeySize)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	otherKey, err := getRandomBytes(dataKeySize)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	data, err := getRandomBytes(2*streamChunkSize + 10)
+	if err != nil {
+		t.Fatalf("failed to generate data: %s", err)
+	}
+
+	ciphertext := encryptStream(t, key, data)
+
+	tampered := make([]byte, len(ciphertext))
+	copy(tampered, ciphertext)
+	tampered[len(streamMagic)+streamNoncePrefix+10] ^= 0xff
+
+	header := len(streamMagic) + streamNoncePrefix
+	truncated := ciphertext[:header+2*(streamChunkSize+16)]
+
+	tests := []struct {
+		name       string
+		key        []byte
+		ciphertext []byte
+	}{
+		{"wrong key", otherKey, ciphertext},
+		{"tampered", key, tampered},
+		{"truncated", key, truncated},
+		{"bad magic", key, append([]byte("NOTMAGIC"), ciphertext[len(streamMagic):]...)},
+		{"short key", key[:16], ciphertext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.ciphertext), tt.key)
+			if err == nil {
+				_, err = ioutil.ReadAll(r)
+			}
+			if err == nil {
+				t.Fatalf("unexpected success while decrypting")
+			}
+		})
+	}
+}